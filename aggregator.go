@@ -29,6 +29,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,11 +40,15 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
 	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/dump"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/logger"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/retention"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
 )
 
 const (
@@ -57,6 +62,14 @@ const (
 	ExitStatusServerError
 	// ExitStatusMigrationError is returned in case of an error while attempting to perform DB migrations
 	ExitStatusMigrationError
+	// ExitStatusContentError is returned when the rule content is not valid
+	ExitStatusContentError
+	// ExitStatusRetentionError is returned when the stale clusters can't be purged
+	ExitStatusRetentionError
+	// ExitStatusOrganizationPurgeError is returned when data of the organization can't be purged
+	ExitStatusOrganizationPurgeError
+	// ExitStatusDumpError is returned when the data can't be exported or imported
+	ExitStatusDumpError
 	defaultConfigFilename = "config"
 
 	databasePreparationMessage = "database preparation exited with error code %v"
@@ -64,8 +77,12 @@ const (
 )
 
 var (
-	serverInstance   *server.HTTPServer
-	consumerInstance consumer.Consumer
+	serverInstance *server.HTTPServer
+
+	// consumerInstance is assigned by startConsumer running in its own goroutine,
+	// so it is only accessed through getConsumerInstance and setConsumerInstance
+	consumerInstance      consumer.Consumer
+	consumerInstanceMutex sync.RWMutex
 
 	// BuildVersion contains the major.minor version of the CLI client
 	BuildVersion = "*not set*"
@@ -83,6 +100,19 @@ var (
 	// the database to the latest migration version. This is necessary
 	// for certain tests that work with a temporary, empty SQLite DB.
 	autoMigrate = false
+
+	// storageCache is shared by the consumer and the server so that
+	// reports written by the consumer invalidate the cached ones
+	storageCache     *storage.Cache
+	storageCacheOnce sync.Once
+
+	// memoryStorage is shared by the consumer and the server
+	// when the memory storage driver is configured
+	memoryStorage     *storage.MemoryStorage
+	memoryStorageOnce sync.Once
+
+	// eventBus delivers events from the consumer to clients of the server
+	eventBus = events.NewBus()
 )
 
 func createStorage() (*storage.DBStorage, error) {
@@ -97,9 +127,44 @@ func createStorage() (*storage.DBStorage, error) {
 	return dbStorage, nil
 }
 
-// closeStorage closes specified DBStorage with proper error checking
+// createServiceStorage creates the storage used by the consumer and the server.
+// The memory storage is created only once so that both of them share it,
+// its data are lost when the service exits.
+func createServiceStorage() (storage.Storage, error) {
+	if conf.GetStorageConfiguration().Driver == storage.MemoryDriverName {
+		memoryStorageOnce.Do(func() {
+			log.Warn().Msg("Using memory storage, all data will be lost when the service exits")
+			memoryStorage = storage.NewMemoryStorage()
+		})
+		return memoryStorage, nil
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	return dbStorage, nil
+}
+
+// wrapStorage puts the in-process read cache in front
+// of the storage if it is enabled in the configuration.
+func wrapStorage(backend storage.Storage) storage.Storage {
+	storageCfg := conf.GetStorageConfiguration()
+	if !storageCfg.CacheEnabled {
+		return backend
+	}
+
+	storageCacheOnce.Do(func() {
+		storageCache = storage.NewCache(storageCfg)
+	})
+
+	return storage.NewCachedStorage(backend, storageCache)
+}
+
+// closeStorage closes specified storage with proper error checking
 // whether the close operation was successful or not.
-func closeStorage(storage *storage.DBStorage) {
+func closeStorage(storage storage.Storage) {
 	err := storage.Close()
 	if err != nil {
 		log.Error().Err(err).Msg("Error during closing storage connection")
@@ -132,32 +197,39 @@ func prepareDBMigrations(dbStorage *storage.DBStorage) int {
 
 // prepareDB opens a DB connection and loads all available rule content into it.
 func prepareDB() int {
-	dbStorage, err := createStorage()
+	serviceStorage, err := createServiceStorage()
 	if err != nil {
 		return ExitStatusPrepareDbError
 	}
-	defer closeStorage(dbStorage)
+	defer closeStorage(serviceStorage)
 
 	// Ensure that the DB is at the latest migration version.
-	if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
-		return exitCode
+	// The memory storage has no migrations.
+	if dbStorage, ok := serviceStorage.(*storage.DBStorage); ok {
+		if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
+			return exitCode
+		}
 	}
 
 	// Initialize the database.
-	err = dbStorage.Init()
+	err = serviceStorage.Init()
 	if err != nil {
 		log.Error().Err(err).Msg("DB initialization error")
 		return ExitStatusPrepareDbError
 	}
 
-	ruleContentDirPath := conf.GetContentPathConfiguration()
-	contentDir, err := content.ParseRuleContentDir(ruleContentDirPath)
+	contentDir, err := content.ParseRuleContent(
+		conf.GetContentPathConfiguration(), conf.GetContentChecksumConfiguration(),
+	)
 	if osPathError, ok := err.(*os.PathError); ok {
 		log.Error().Err(osPathError).Msg("No rules directory")
 		return ExitStatusPrepareDbError
+	} else if err != nil {
+		log.Error().Err(err).Msg("Rules content parsing error")
+		return ExitStatusPrepareDbError
 	}
 
-	if err := dbStorage.LoadRuleContent(contentDir); err != nil {
+	if err := serviceStorage.LoadRuleContent(contentDir); err != nil {
 		log.Error().Err(err).Msg("Rules content loading error")
 		return ExitStatusPrepareDbError
 	}
@@ -167,11 +239,11 @@ func prepareDB() int {
 
 // startConsumer starts consumer and returns exit code, ExitStatusOK is no error
 func startConsumer() int {
-	dbStorage, err := createStorage()
+	serviceStorage, err := createServiceStorage()
 	if err != nil {
 		return ExitStatusConsumerError
 	}
-	defer closeStorage(dbStorage)
+	defer closeStorage(serviceStorage)
 
 	brokerCfg := conf.GetBrokerConfiguration()
 
@@ -181,27 +253,66 @@ func startConsumer() int {
 		return ExitStatusOK
 	}
 
-	consumerInstance, err = consumer.New(brokerCfg, dbStorage)
+	kafkaConsumer, err := consumer.New(brokerCfg, wrapStorage(serviceStorage))
 	if err != nil {
 		log.Error().Err(err).Msg("Broker initialization error")
 		return ExitStatusConsumerError
 	}
+	kafkaConsumer.EventBus = eventBus
 
-	consumerInstance.Serve()
+	webhooksCfg := conf.GetWebhooksConfiguration()
+	if webhooksCfg.Enabled {
+		kafkaConsumer.Webhooks = webhooks.New(webhooksCfg, kafkaConsumer.Storage)
+	}
+
+	setConsumerInstance(kafkaConsumer)
+	kafkaConsumer.Serve()
 
 	return ExitStatusOK
 }
 
+// setConsumerInstance stores the started consumer for the health check and stopService
+func setConsumerInstance(instance consumer.Consumer) {
+	consumerInstanceMutex.Lock()
+	defer consumerInstanceMutex.Unlock()
+
+	consumerInstance = instance
+}
+
+// getConsumerInstance returns the started consumer, nil if it hasn't been started yet
+func getConsumerInstance() consumer.Consumer {
+	consumerInstanceMutex.RLock()
+	defer consumerInstanceMutex.RUnlock()
+
+	return consumerInstance
+}
+
 // startServer starts the server and returns error code
 func startServer() int {
-	dbStorage, err := createStorage()
+	serviceStorage, err := createServiceStorage()
 	if err != nil {
 		return ExitStatusServerError
 	}
-	defer closeStorage(dbStorage)
+	defer closeStorage(serviceStorage)
 
 	serverCfg := conf.GetServerConfiguration()
-	serverInstance = server.New(serverCfg, dbStorage)
+	serverInstance = server.New(serverCfg, wrapStorage(serviceStorage))
+	serverInstance.EventBus = eventBus
+	serverInstance.HealthChecks = healthChecks(serviceStorage)
+	serverInstance.AllowPrivateWebhookAddresses = conf.GetWebhooksConfiguration().AllowPrivateAddresses
+
+	contentWatcher := startContentWatcher(serverInstance.Storage)
+	if contentWatcher != nil {
+		defer contentWatcher.Stop()
+		serverInstance.ContentReloader = contentWatcher
+	}
+
+	if retentionCfg := conf.GetRetentionConfiguration(); retentionCfg.Enabled {
+		retentionJob := retention.New(retentionCfg, serverInstance.Storage)
+		retentionJob.Start()
+		defer retentionJob.Stop()
+	}
+
 	err = serverInstance.Start()
 	if err != nil {
 		log.Error().Err(err).Msg("HTTP(s) start error")
@@ -211,6 +322,49 @@ func startServer() int {
 	return ExitStatusOK
 }
 
+// startContentWatcher starts reloading of rule content when the content directory
+// is modified or SIGHUP is received, nil is returned if the watcher can't be started
+func startContentWatcher(contentStorage storage.Storage) *content.Watcher {
+	watcher, err := content.NewWatcher(
+		conf.GetContentPathConfiguration(),
+		conf.GetContentChecksumConfiguration(),
+		contentStorage.LoadRuleContent,
+		conf.GetContentWatchIntervalConfiguration(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to start rule content watcher")
+		return nil
+	}
+
+	watcher.Start()
+
+	return watcher
+}
+
+// healthChecks returns checks of dependencies done by the readiness endpoint
+func healthChecks(serviceStorage storage.Storage) []server.HealthCheck {
+	var checks []server.HealthCheck
+
+	if dbStorage, ok := serviceStorage.(*storage.DBStorage); ok {
+		checks = append(checks,
+			server.DatabaseHealthCheck(dbStorage.GetConnection()),
+			server.MigrationHealthCheck(dbStorage.GetConnection()),
+		)
+	}
+
+	if conf.GetBrokerConfiguration().Enabled {
+		checks = append(checks, server.ConsumerHealthCheck(func() server.ConsumerSession {
+			kafkaConsumer, ok := getConsumerInstance().(*consumer.KafkaConsumer)
+			if !ok {
+				return nil
+			}
+			return kafkaConsumer
+		}))
+	}
+
+	return checks
+}
+
 // startService starts service and returns error code
 func startService() int {
 	var waitGroup sync.WaitGroup
@@ -250,7 +404,7 @@ func startService() int {
 func waitForServiceToStart() {
 	for {
 		isStarted := true
-		if conf.GetBrokerConfiguration().Enabled && consumerInstance == nil {
+		if conf.GetBrokerConfiguration().Enabled && getConsumerInstance() == nil {
 			isStarted = false
 		}
 		if serverInstance == nil {
@@ -276,8 +430,8 @@ func stopService() int {
 		}
 	}
 
-	if consumerInstance != nil {
-		err := consumerInstance.Close()
+	if instance := getConsumerInstance(); instance != nil {
+		err := instance.Close()
 		if err != nil {
 			log.Error().Err(err).Msg("Consumer stop error")
 			errCode++
@@ -298,6 +452,28 @@ func printVersionInfo() {
 	initInfoLog("Commit: " + BuildCommit)
 }
 
+// printContentVersionInfo prints version of the rule content loaded into
+// the database, it's not available when the database can't be reached
+func printContentVersionInfo() {
+	dbStorage, err := createStorage()
+	if err != nil {
+		initInfoLog("Content version: unknown")
+		return
+	}
+	defer closeStorage(dbStorage)
+
+	version, err := dbStorage.GetContentVersion()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read content version")
+		initInfoLog("Content version: unknown")
+		return
+	}
+
+	initInfoLog("Content version: " + version.Hash)
+	initInfoLog("Content commit: " + version.Commit)
+	initInfoLog("Content loaded at: " + version.LoadedAt.Format(time.RFC3339))
+}
+
 const helpMessageTemplate = `
 Aggregator service for insights results
 
@@ -313,9 +489,19 @@ The commands are:
     print-help          prints help
     print-config        prints current configuration set by files & env variables
     print-env           prints env variables
-    print-version-info  prints version info
+    print-version-info  prints version info including version of the loaded rule content
     migration           prints information about migrations (current, latest)
     migration <version> migrates database to the specified version
+    validate-content    checks the configured rule content and reports all problems
+    validate-content <path> [<sha256>]
+                        checks rule content in the directory, bundle or URL
+    purge-stale [--dry-run]
+                        removes clusters that stopped reporting with their votes and toggles
+    purge-organization <org_id>
+                        removes all data of the organization from all tables
+    export [--org <org_id>] [--cluster <cluster>] <file>
+                        exports rule content, reports, feedback, toggles and rule disables into the file
+    import <file>       imports the data exported by the export command
 
 `
 
@@ -428,6 +614,228 @@ func performMigrations() int {
 	}
 }
 
+// validateContent handles validate-content subcommand. It checks rule content
+// in the location passed as an argument (or the configured one) and prints all
+// problems that were found, so it can be used in CI of the content repository.
+// The expected checksum of a bundle can be passed as the second argument.
+func validateContent() int {
+	contentArgs := os.Args[2:]
+
+	var contentPath, checksum string
+	switch len(contentArgs) {
+	case 0:
+		contentPath = conf.GetContentPathConfiguration()
+		checksum = conf.GetContentChecksumConfiguration()
+	case 1:
+		contentPath = contentArgs[0]
+	case 2:
+		contentPath, checksum = contentArgs[0], contentArgs[1]
+	default:
+		log.Error().Msg("Unexpected number of arguments to validate-content command (expected 0-2)")
+		return ExitStatusContentError
+	}
+
+	err := content.LintRuleContent(contentPath, checksum)
+	if invalidContentErr, ok := err.(*content.InvalidContentError); ok {
+		for _, problem := range invalidContentErr.Problems {
+			fmt.Println(problem)
+		}
+		fmt.Printf("\nRule content in %v has %d problem(s)\n", contentPath, len(invalidContentErr.Problems))
+		return ExitStatusContentError
+	} else if err != nil {
+		log.Error().Err(err).Msg("Unable to validate rule content")
+		return ExitStatusContentError
+	}
+
+	fmt.Printf("Rule content in %v is valid\n", contentPath)
+	return ExitStatusOK
+}
+
+// purgeStale handles purge-stale subcommand. It removes the clusters whose last report
+// is older than the configured maximal age and prints them, --dry-run argument
+// (or the dry run mode in the configuration) prints them without removing anything.
+func purgeStale() int {
+	retentionCfg := conf.GetRetentionConfiguration()
+
+	for _, arg := range os.Args[2:] {
+		if arg != "--dry-run" {
+			log.Error().Msgf("Unexpected argument '%v' to purge-stale command", arg)
+			return ExitStatusRetentionError
+		}
+		retentionCfg.DryRun = true
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusRetentionError
+	}
+	defer closeStorage(dbStorage)
+
+	purged, err := retention.New(retentionCfg, dbStorage).Purge()
+	if err != nil {
+		return ExitStatusRetentionError
+	}
+
+	for _, clusterName := range purged.Clusters {
+		fmt.Println(clusterName)
+	}
+
+	action := "Purged"
+	if retentionCfg.DryRun {
+		action = "Would purge"
+	}
+	fmt.Printf(
+		"\n%v %d cluster(s): %d report(s), %d feedback(s), %d toggle(s)\n",
+		action, len(purged.Clusters), purged.Reports, purged.Feedback, purged.Toggles,
+	)
+
+	return ExitStatusOK
+}
+
+// purgeOrganization handles purge-organization subcommand. It removes all data of the organization
+// given as the only argument and prints numbers of the removed rows for every table.
+func purgeOrganization() int {
+	if len(os.Args) != 3 {
+		log.Error().Msg("Organization ID is expected as the only argument to purge-organization command")
+		return ExitStatusOrganizationPurgeError
+	}
+
+	orgID, err := strconv.ParseUint(os.Args[2], 10, 32)
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to parse organization ID '%v'", os.Args[2])
+		return ExitStatusOrganizationPurgeError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusOrganizationPurgeError
+	}
+	defer closeStorage(dbStorage)
+
+	purged, err := dbStorage.PurgeOrganization(types.OrgID(orgID))
+	if err != nil {
+		log.Error().Err(err).
+			Str("audit", "organization_purge").
+			Uint64("organization", orgID).
+			Msg("Unable to purge organization")
+		return ExitStatusOrganizationPurgeError
+	}
+
+	log.Info().
+		Str("audit", "organization_purge").
+		Uint64("organization", orgID).
+		Interface("removed", purged).
+		Msg("Organization purged")
+
+	tables := make([]string, 0, len(purged))
+	for table := range purged {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	fmt.Printf("Purged organization %v:\n", orgID)
+	for _, table := range tables {
+		fmt.Printf("    %-28v %d row(s)\n", table, purged[table])
+	}
+
+	return ExitStatusOK
+}
+
+// parseExportArgs parses arguments of export subcommand, i.e. the optional filter and the file
+func parseExportArgs(args []string) (dump.Filter, string, error) {
+	var filter dump.Filter
+	var path string
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case (arg == "--org" || arg == "--cluster") && i+1 == len(args):
+			return filter, "", fmt.Errorf("value of %v argument is missing", arg)
+		case arg == "--org":
+			i++
+			orgID, err := strconv.ParseUint(args[i], 10, 32)
+			if err != nil {
+				return filter, "", fmt.Errorf("unable to parse organization ID '%v'", args[i])
+			}
+			filter.OrgID = types.OrgID(orgID)
+		case arg == "--cluster":
+			i++
+			filter.ClusterName = types.ClusterName(args[i])
+		case path == "" && !strings.HasPrefix(arg, "--"):
+			path = arg
+		default:
+			return filter, "", fmt.Errorf("unexpected argument '%v'", arg)
+		}
+	}
+
+	if path == "" {
+		return filter, "", fmt.Errorf("file to export to is missing")
+	}
+
+	return filter, path, nil
+}
+
+// printDumpSummary prints numbers of the exported or imported items
+func printDumpSummary(action, path string, summary dump.Summary) {
+	fmt.Printf(
+		"%v %v: %d report(s), %d feedback(s), %d toggle(s), %d rule disable(s), rule content: %v\n",
+		action, path, summary.Reports, summary.Feedback, summary.Toggles, summary.RuleDisables, summary.RuleContent,
+	)
+	if summary.SkippedReports > 0 {
+		fmt.Printf("Skipped %d report(s) older than the stored ones\n", summary.SkippedReports)
+	}
+}
+
+// exportData handles export subcommand. It writes the rule content and data of the clusters,
+// optionally just of the organization or the cluster, into the file (compressed if it ends with .gz).
+func exportData() int {
+	filter, path, err := parseExportArgs(os.Args[2:])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid arguments to export command")
+		return ExitStatusDumpError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusDumpError
+	}
+	defer closeStorage(dbStorage)
+
+	summary, err := dump.ExportFile(dbStorage, path, filter)
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to export data into %v", path)
+		return ExitStatusDumpError
+	}
+
+	printDumpSummary("Exported into", path, summary)
+	return ExitStatusOK
+}
+
+// importData handles import subcommand. It writes the data from the file written
+// by export subcommand into the configured storage.
+func importData() int {
+	if len(os.Args) != 3 {
+		log.Error().Msg("File to import is expected as the only argument to import command")
+		return ExitStatusDumpError
+	}
+	path := os.Args[2]
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusDumpError
+	}
+	defer closeStorage(dbStorage)
+
+	summary, err := dump.ImportFile(dbStorage, path)
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to import data from %v", path)
+		printDumpSummary("Partially imported from", path, summary)
+		return ExitStatusDumpError
+	}
+
+	printDumpSummary("Imported from", path, summary)
+	return ExitStatusOK
+}
+
 func main() {
 	err := conf.LoadConfiguration(defaultConfigFilename)
 	if err != nil {
@@ -439,6 +847,8 @@ func main() {
 		panic(err)
 	}
 
+	content.SetBundleLimits(conf.GetContentBundleLimitsConfiguration())
+
 	command := "start-service"
 
 	if len(os.Args) >= 2 {
@@ -471,8 +881,19 @@ func handleCommand(command string) int {
 		return printEnv()
 	case "print-version-info":
 		printVersionInfo()
+		printContentVersionInfo()
 	case "migrations", "migration", "migrate":
 		return performMigrations()
+	case "validate-content":
+		return validateContent()
+	case "purge-stale":
+		return purgeStale()
+	case "purge-organization":
+		return purgeOrganization()
+	case "export":
+		return exportData()
+	case "import":
+		return importData()
 	default:
 		fmt.Printf("\nCommand '%v' not found\n", command)
 		return printHelp()
@@ -19,7 +19,9 @@ package main_test
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -29,9 +31,12 @@ import (
 
 	main "github.com/RedHatInsights/insights-results-aggregator"
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/dump"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 const (
@@ -163,6 +168,30 @@ func TestCreateStorage_BadDriver(t *testing.T) {
 	assert.EqualError(t, err, "driver non-existing-driver is not supported")
 }
 
+func TestCreateStorage_MemoryDriver(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER": "memory",
+	})
+
+	_, err := main.CreateStorage()
+	assert.EqualError(t, err, "driver memory is supported only by the service, not by the commands")
+}
+
+func TestCreateServiceStorage_MemoryDriver(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER": "memory",
+	})
+
+	consumerStorage, err := main.CreateServiceStorage()
+	helpers.FailOnError(t, err)
+	assert.IsType(t, &storage.MemoryStorage{}, consumerStorage)
+
+	// the consumer and the server have to share the data
+	serverStorage, err := main.CreateServiceStorage()
+	helpers.FailOnError(t, err)
+	assert.True(t, consumerStorage == serverStorage)
+}
+
 func TestCloseStorage_Error(t *testing.T) {
 	const errStr = "close error"
 
@@ -203,6 +232,17 @@ func TestPrepareDB(t *testing.T) {
 	*main.AutoMigratePtr = false
 }
 
+func TestPrepareDB_MemoryDriver(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER": "memory",
+
+		"INSIGHTS_RESULTS_AGGREGATOR__CONTENT__PATH": "./tests/content/ok/",
+	})
+
+	errCode := main.PrepareDB()
+	assert.Equal(t, main.ExitStatusOK, errCode)
+}
+
 func TestPrepareDB_NoRulesDirectory(t *testing.T) {
 	setEnvSettings(t, map[string]string{
 		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
@@ -300,6 +340,16 @@ func TestPrintVersionInfo(t *testing.T) {
 	main.PrintVersionInfo()
 }
 
+// TestPrintContentVersionInfo checks that missing content version doesn't cause a panic
+func TestPrintContentVersionInfo(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": ":memory:",
+	})
+
+	main.PrintContentVersionInfo()
+}
+
 // TestPrintHelp checks that printing help returns OK exit code.
 func TestPrintHelp(t *testing.T) {
 	assert.Equal(t, main.ExitStatusOK, main.PrintHelp())
@@ -439,3 +489,265 @@ func TestPerformMigrationsTooManyArgs(t *testing.T) {
 
 	os.Args = oldArgs
 }
+
+// TestValidateContentOK checks that valid rule content results in the OK exit code.
+func TestValidateContentOK(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "validate-content", "./tests/content/ok/"}
+	exitCode := main.ValidateContent()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	os.Args = oldArgs
+}
+
+// TestValidateContentInvalid checks that invalid rule content
+// results in the content error exit code.
+func TestValidateContentInvalid(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "validate-content", "./tests/content/invalid/"}
+	exitCode := main.ValidateContent()
+	assert.Equal(t, main.ExitStatusContentError, exitCode)
+
+	os.Args = []string{os.Args[0], "validate-content", "./tests/content/not-a-real-dir/"}
+	exitCode = main.ValidateContent()
+	assert.Equal(t, main.ExitStatusContentError, exitCode)
+
+	os.Args = oldArgs
+}
+
+// TestValidateContentTooManyArgs checks that supplying too many arguments
+// to the validate-content sub-command results in the content error exit code.
+func TestValidateContentTooManyArgs(t *testing.T) {
+	oldArgs := os.Args
+
+	os.Args = []string{os.Args[0], "validate-content", "hello", "world", "!"}
+	exitCode := main.ValidateContent()
+	assert.Equal(t, main.ExitStatusContentError, exitCode)
+
+	os.Args = oldArgs
+}
+
+// TestPurgeStaleDryRun checks that the dry run of purging stale clusters
+// keeps the reports and exits with the OK exit code.
+func TestPurgeStaleDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purge_stale")
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, os.RemoveAll(dir)) }()
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": filepath.Join(dir, "aggregator.db"),
+	})
+
+	dbStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, dbStorage.MigrateToLatest())
+	helpers.FailOnError(t, dbStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, time.Now().Add(-365*24*time.Hour), testdata.KafkaOffset,
+	))
+	main.CloseStorage(dbStorage)
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{os.Args[0], "purge-stale", "--dry-run"}
+	exitCode := main.PurgeStale()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	dbStorage, err = main.CreateStorage()
+	helpers.FailOnError(t, err)
+	defer main.CloseStorage(dbStorage)
+
+	count, err := dbStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	os.Args = []string{os.Args[0], "purge-stale"}
+	exitCode = main.PurgeStale()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	count, err = dbStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// TestPurgeStaleErrors checks that unexpected arguments and storage
+// errors result in the retention error exit code.
+func TestPurgeStaleErrors(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{os.Args[0], "purge-stale", "--force"}
+	exitCode := main.PurgeStale()
+	assert.Equal(t, main.ExitStatusRetentionError, exitCode)
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "non-existing-driver",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": "/non/existing/path",
+	})
+
+	os.Args = []string{os.Args[0], "purge-stale"}
+	exitCode = main.PurgeStale()
+	assert.Equal(t, main.ExitStatusRetentionError, exitCode)
+}
+
+// TestPurgeOrganization checks that purging the organization removes just its reports.
+func TestPurgeOrganization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purge_organization")
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, os.RemoveAll(dir)) }()
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": filepath.Join(dir, "aggregator.db"),
+	})
+
+	dbStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, dbStorage.MigrateToLatest())
+	for _, orgID := range []types.OrgID{testdata.OrgID, testdata.OrgID + 1} {
+		helpers.FailOnError(t, dbStorage.WriteReportForCluster(
+			orgID, testdata.GetRandomClusterID(), testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+		))
+	}
+	main.CloseStorage(dbStorage)
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{os.Args[0], "purge-organization", fmt.Sprint(testdata.OrgID)}
+	exitCode := main.PurgeOrganization()
+	assert.Equal(t, main.ExitStatusOK, exitCode)
+
+	dbStorage, err = main.CreateStorage()
+	helpers.FailOnError(t, err)
+	defer main.CloseStorage(dbStorage)
+
+	orgs, err := dbStorage.ListOfOrgs()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID + 1}, orgs)
+}
+
+// TestPurgeOrganizationErrors checks that missing or invalid organization ID
+// and storage errors result in the organization purge error exit code.
+func TestPurgeOrganizationErrors(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	for _, args := range [][]string{
+		{"purge-organization"},
+		{"purge-organization", "not-a-number"},
+		{"purge-organization", "1", "2"},
+	} {
+		os.Args = append([]string{os.Args[0]}, args...)
+		exitCode := main.PurgeOrganization()
+		assert.Equal(t, main.ExitStatusOrganizationPurgeError, exitCode, args)
+	}
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "non-existing-driver",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": "/non/existing/path",
+	})
+
+	os.Args = []string{oldArgs[0], "purge-organization", "1"}
+	exitCode := main.PurgeOrganization()
+	assert.Equal(t, main.ExitStatusOrganizationPurgeError, exitCode)
+}
+
+// TestParseExportArgs checks parsing of the filter and the file of the export sub-command.
+func TestParseExportArgs(t *testing.T) {
+	filter, path, err := main.ParseExportArgs([]string{"--org", "1", "--cluster", string(testdata.ClusterName), "dump.jsonl"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, dump.Filter{OrgID: 1, ClusterName: testdata.ClusterName}, filter)
+	assert.Equal(t, "dump.jsonl", path)
+
+	filter, path, err = main.ParseExportArgs([]string{"dump.jsonl.gz"})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, dump.Filter{}, filter)
+	assert.Equal(t, "dump.jsonl.gz", path)
+
+	for _, args := range [][]string{
+		{},
+		{"--org", "1"},
+		{"--org", "not-a-number", "dump.jsonl"},
+		{"dump.jsonl", "--cluster"},
+		{"dump.jsonl", "other.jsonl"},
+		{"--force", "dump.jsonl"},
+	} {
+		_, _, err := main.ParseExportArgs(args)
+		assert.Error(t, err, args)
+	}
+}
+
+// TestExportAndImportData checks that data exported from one database
+// by the export sub-command are imported into another one.
+func TestExportAndImportData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export_import")
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, os.RemoveAll(dir)) }()
+
+	dumpPath := filepath.Join(dir, "dump.jsonl")
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	for i, command := range []string{"export", "import"} {
+		setEnvSettings(t, map[string]string{
+			"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
+			"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": filepath.Join(dir, fmt.Sprintf("aggregator%v.db", i)),
+		})
+
+		dbStorage, err := main.CreateStorage()
+		helpers.FailOnError(t, err)
+		helpers.FailOnError(t, dbStorage.MigrateToLatest())
+		if command == "export" {
+			helpers.FailOnError(t, dbStorage.WriteReportForCluster(
+				testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+			))
+		}
+		main.CloseStorage(dbStorage)
+
+		os.Args = []string{oldArgs[0], command, dumpPath}
+		if command == "export" {
+			exitCode := main.ExportData()
+			assert.Equal(t, main.ExitStatusOK, exitCode)
+		} else {
+			exitCode := main.ImportData()
+			assert.Equal(t, main.ExitStatusOK, exitCode)
+		}
+	}
+
+	dbStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	defer main.CloseStorage(dbStorage)
+
+	report, _, err := dbStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+}
+
+// TestExportAndImportDataErrors checks that invalid arguments and storage
+// errors result in the dump error exit code.
+func TestExportAndImportDataErrors(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "export"}
+	assert.Equal(t, main.ExitStatusDumpError, main.ExportData())
+
+	os.Args = []string{oldArgs[0], "import"}
+	assert.Equal(t, main.ExitStatusDumpError, main.ImportData())
+
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "non-existing-driver",
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": "/non/existing/path",
+	})
+
+	os.Args = []string{oldArgs[0], "export", "dump.jsonl"}
+	assert.Equal(t, main.ExitStatusDumpError, main.ExportData())
+
+	os.Args = []string{oldArgs[0], "import", "dump.jsonl"}
+	assert.Equal(t, main.ExitStatusDumpError, main.ImportData())
+}
@@ -19,6 +19,8 @@ limitations under the License.
 package broker
 
 import (
+	"time"
+
 	mapset "github.com/deckarep/golang-set"
 )
 
@@ -32,4 +34,9 @@ type Configuration struct {
 	Enabled             bool       `mapstructure:"enabled" toml:"enabled"`
 	OrgWhitelist        mapset.Set `mapstructure:"org_whitelist_file" toml:"org_whitelist_file"`
 	OrgWhitelistEnabled bool       `mapstructure:"enable_org_whitelist" toml:"enable_org_whitelist"`
+	// BatchSize is the maximal number of messages whose reports are written in a single
+	// transaction, values lower than 2 mean that every message is processed on its own
+	BatchSize int `mapstructure:"batch_size" toml:"batch_size"`
+	// BatchTimeout limits how long the first message of an incomplete batch waits for the others
+	BatchTimeout time.Duration `mapstructure:"batch_timeout" toml:"batch_timeout"`
 }
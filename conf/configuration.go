@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	mapset "github.com/deckarep/golang-set"
@@ -33,10 +34,13 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/content"
 	"github.com/RedHatInsights/insights-results-aggregator/logger"
+	"github.com/RedHatInsights/insights-results-aggregator/retention"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
 )
 
 const (
@@ -54,10 +58,16 @@ var Config struct {
 	} `mapstructure:"processing"`
 	Storage storage.Configuration `mapstructure:"storage" toml:"storage"`
 	Content struct {
-		ContentPath string `mapstructure:"path" toml:"path"`
+		ContentPath       string        `mapstructure:"path" toml:"path"`
+		Checksum          string        `mapstructure:"checksum" toml:"checksum"`
+		WatchInterval     time.Duration `mapstructure:"watch_interval" toml:"watch_interval"`
+		MaxBundleSize     int64         `mapstructure:"max_bundle_size" toml:"max_bundle_size"`
+		MaxBundleFileSize int64         `mapstructure:"max_bundle_file_size" toml:"max_bundle_file_size"`
 	} `mapstructure:"content" toml:"content"`
 	Logging    logger.LoggingConfiguration    `mapstructure:"logging" toml:"logging"`
 	CloudWatch logger.CloudWatchConfiguration `mapstructure:"cloudwatch" toml:"cloudwatch"`
+	Webhooks   webhooks.Configuration         `mapstructure:"webhooks" toml:"webhooks"`
+	Retention  retention.Configuration        `mapstructure:"retention" toml:"retention"`
 }
 
 // LoadConfiguration loads configuration from defaultConfigFile, file set in configFileEnvVariableName or from env
@@ -153,6 +163,16 @@ func GetCloudWatchConfiguration() logger.CloudWatchConfiguration {
 	return Config.CloudWatch
 }
 
+// GetWebhooksConfiguration returns webhooks configuration
+func GetWebhooksConfiguration() webhooks.Configuration {
+	return Config.Webhooks
+}
+
+// GetRetentionConfiguration returns configuration of the retention job
+func GetRetentionConfiguration() retention.Configuration {
+	return Config.Retention
+}
+
 // GetServerConfiguration returns server configuration
 func GetServerConfiguration() server.Configuration {
 	err := checkIfFileExists(Config.Server.APISpecFile)
@@ -172,6 +192,26 @@ func GetContentPathConfiguration() string {
 	return Config.Content.ContentPath
 }
 
+// GetContentChecksumConfiguration get the expected SHA-256 checksum of the content bundle
+func GetContentChecksumConfiguration() string {
+	return Config.Content.Checksum
+}
+
+// GetContentWatchIntervalConfiguration get the interval of checking the content directory
+// for changes, zero means that the content is reloaded only on demand
+func GetContentWatchIntervalConfiguration() time.Duration {
+	return Config.Content.WatchInterval
+}
+
+// GetContentBundleLimitsConfiguration get the limits of sizes of the content bundle
+// and of files extracted from it, zero means the default limit
+func GetContentBundleLimitsConfiguration() content.BundleLimits {
+	return content.BundleLimits{
+		MaxSize:     Config.Content.MaxBundleSize,
+		MaxFileSize: Config.Content.MaxBundleFileSize,
+	}
+}
+
 // checkIfFileExists returns nil if path doesn't exist or isn't a file, otherwise it returns corresponding error
 func checkIfFileExists(path string) error {
 	fileInfo, err := os.Stat(path)
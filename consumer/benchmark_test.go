@@ -15,15 +15,18 @@
 package consumer_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path"
 	"strings"
 	"testing"
 
+	"github.com/Shopify/sarama"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -163,3 +166,57 @@ func BenchmarkKafkaConsumer_ProcessMessage_RealMessages(b *testing.B) {
 		})
 	}
 }
+
+// benchmarkHandlingMessagesInBatches handles b.N random messages, one operation is one message
+func benchmarkHandlingMessagesInBatches(b *testing.B, s storage.Storage, batchSize int) {
+	kafkaConsumer := &consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: batchSize},
+		Storage:       s,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += batchSize {
+		b.StopTimer()
+		var batch []*sarama.ConsumerMessage
+		for j := i; j < i+batchSize && j < b.N; j++ {
+			batch = append(batch, helpers.StringToSaramaConsumerMessage(testdata.GetRandomConsumerMessage()))
+		}
+		b.StartTimer()
+
+		if batchSize > 1 {
+			kafkaConsumer.HandleMessages(batch)
+		} else {
+			kafkaConsumer.HandleMessage(batch[0])
+		}
+	}
+}
+
+func BenchmarkKafkaConsumer_HandleMessages_Batches(b *testing.B) {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	var testCases = []struct {
+		Name            string
+		StorageProducer func(testing.TB, bool) (storage.Storage, func())
+	}{
+		{"SQLiteInMemory", helpers.MustGetSQLiteMemoryStorage},
+		{"Postgres", helpers.MustGetPostgresStorage},
+		{"SQLiteFile", helpers.MustGetSQLiteFileStorage},
+	}
+
+	for _, testCase := range testCases {
+		for _, batchSize := range []int{1, 10, 100} {
+			testCase := testCase
+			batchSize := batchSize
+
+			b.Run(fmt.Sprintf("%v/BatchSize%v", testCase.Name, batchSize), func(b *testing.B) {
+				benchStorage, cleaner := testCase.StorageProducer(b, true)
+				if cleaner != nil {
+					defer cleaner()
+				}
+				defer helpers.MustCloseStorage(b, benchStorage)
+
+				benchmarkHandlingMessagesInBatches(b, benchStorage, batchSize)
+			})
+		}
+	}
+}
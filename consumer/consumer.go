@@ -20,14 +20,19 @@ package consumer
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
 )
 
 // Consumer represents any consumer of insights-rules messages
@@ -55,13 +60,23 @@ type KafkaConsumer struct {
 	Configuration                        broker.Configuration
 	ConsumerGroup                        sarama.ConsumerGroup
 	Storage                              storage.Storage
+	EventBus                             *events.Bus
+	Webhooks                             *webhooks.Dispatcher
 	numberOfSuccessfullyConsumedMessages uint64
 	numberOfErrorsConsumingMessages      uint64
+	sessionActive                        int32
 	ready                                chan bool
+	ctx                                  context.Context
 	cancel                               context.CancelFunc
 	payloadTrackerProducer               *producer.KafkaProducer
+	// batchHandled is called with the number of consumed messages after every batch, used by tests
+	batchHandled func(consumed int)
 }
 
+// DefaultBatchTimeout is the time the first message of an incomplete batch waits for the others
+// if the batch timeout is not configured
+const DefaultBatchTimeout = time.Second
+
 // DefaultSaramaConfig is a config which will be used by default
 // here you can use specific version of a protocol for example
 // useful for testing
@@ -110,6 +125,7 @@ func NewWithSaramaConfig(
 // Serve starts listening for messages and processing them. It blocks current thread.
 func (consumer *KafkaConsumer) Serve() {
 	ctx, cancel := context.WithCancel(context.Background())
+	consumer.ctx = ctx
 	consumer.cancel = cancel
 
 	go func() {
@@ -148,6 +164,7 @@ func (consumer *KafkaConsumer) Serve() {
 // Setup is run at the beginning of a new session, before ConsumeClaim
 func (consumer *KafkaConsumer) Setup(sarama.ConsumerGroupSession) error {
 	log.Info().Msg("new session has been setup")
+	atomic.StoreInt32(&consumer.sessionActive, 1)
 	// Mark the consumer as ready
 	close(consumer.ready)
 	return nil
@@ -156,6 +173,7 @@ func (consumer *KafkaConsumer) Setup(sarama.ConsumerGroupSession) error {
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
 func (consumer *KafkaConsumer) Cleanup(sarama.ConsumerGroupSession) error {
 	log.Info().Msg("new session has been finished")
+	atomic.StoreInt32(&consumer.sessionActive, 0)
 	return nil
 }
 
@@ -171,6 +189,11 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 		latestMessageOffset = 0
 	}
 
+	if consumer.Configuration.BatchSize > 1 {
+		consumer.consumeBatches(session, claim, latestMessageOffset)
+		return nil
+	}
+
 	for message := range claim.Messages() {
 		if types.KafkaOffset(message.Offset) <= latestMessageOffset {
 			log.Warn().
@@ -189,6 +212,110 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 	return nil
 }
 
+// consumeBatches accumulates messages of the claim until the batch is full or the batch timeout
+// elapses, the messages are marked as consumed after the whole batch has been handled. When
+// a report of the batch can't be written, the offset isn't advanced past its message and the
+// claim is left, so that the messages are consumed again in the next session.
+func (consumer *KafkaConsumer) consumeBatches(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+	latestMessageOffset types.KafkaOffset,
+) {
+	batchTimeout := consumer.Configuration.BatchTimeout
+	if batchTimeout <= 0 {
+		batchTimeout = DefaultBatchTimeout
+	}
+
+	batch := make([]*sarama.ConsumerMessage, 0, consumer.Configuration.BatchSize)
+	// timer is running only while the batch isn't empty
+	var timer *time.Timer
+	var timeout <-chan time.Time
+
+	// flush handles the batch and returns false if the claim can't be consumed any further
+	flush := func() bool {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+
+		if len(batch) == 0 {
+			return true
+		}
+
+		consumed := consumer.HandleMessages(batch)
+
+		for _, message := range batch[:consumed] {
+			session.MarkMessage(message, "")
+		}
+
+		if consumer.batchHandled != nil {
+			consumer.batchHandled(consumed)
+		}
+
+		if consumed < len(batch) {
+			unconsumed := len(batch) - consumed
+			metrics.UnconsumedMessages.Add(float64(unconsumed))
+			log.Error().
+				Int("messages", unconsumed).
+				Int64(offsetKey, batch[consumed].Offset).
+				Msg("reports of the batch couldn't be written, the messages will be consumed again")
+			batch = batch[:0]
+			return false
+		}
+
+		log.Info().Int("messages", len(batch)).Msg("batch of messages consumed")
+		batch = batch[:0]
+		return true
+	}
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				flush()
+				return
+			}
+
+			if types.KafkaOffset(message.Offset) <= latestMessageOffset {
+				log.Warn().
+					Int64(offsetKey, message.Offset).
+					Msg("this offset was already processed by aggregator")
+			} else {
+				latestMessageOffset = types.KafkaOffset(message.Offset)
+			}
+
+			batch = append(batch, message)
+			if len(batch) == 1 {
+				timer = time.NewTimer(batchTimeout)
+				timeout = timer.C
+			}
+
+			if len(batch) >= consumer.Configuration.BatchSize && !flush() {
+				return
+			}
+		case <-timeout:
+			if !flush() {
+				return
+			}
+		}
+	}
+}
+
+// context returns context of the consumer canceled when the consumer is closed
+func (consumer *KafkaConsumer) context() context.Context {
+	if consumer.ctx == nil {
+		return context.Background()
+	}
+
+	return consumer.ctx
+}
+
+// HasActiveSession returns true when the consumer has been set up for a session
+// of the consumer group and it hasn't been finished yet
+func (consumer *KafkaConsumer) HasActiveSession() bool {
+	return atomic.LoadInt32(&consumer.sessionActive) == 1
+}
+
 // Close method closes all resources used by consumer
 func (consumer *KafkaConsumer) Close() error {
 	if consumer.cancel != nil {
@@ -201,6 +328,9 @@ func (consumer *KafkaConsumer) Close() error {
 		}
 	}
 
+	// let the pending webhook deliveries finish
+	consumer.Webhooks.Wait()
+
 	if consumer.payloadTrackerProducer != nil {
 		if err := consumer.payloadTrackerProducer.Close(); err != nil {
 			log.Error().Err(err).Msg("unable to close payload tracker Kafka producer")
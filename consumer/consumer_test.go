@@ -21,7 +21,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -33,10 +36,12 @@ import (
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
 )
 
 const (
@@ -240,6 +245,70 @@ func TestProcessCorrectMessage(t *testing.T) {
 	assert.Equal(t, 1, count)
 }
 
+func TestProcessCorrectMessagePublishesEvent(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: broker.Configuration{OrgWhitelistEnabled: false},
+		Storage:       mockStorage,
+		EventBus:      bus,
+	}
+
+	mustConsumerProcessMessage(t, mockConsumer, testdata.ConsumerMessage)
+
+	event := <-subscription.Events()
+	assert.Equal(t, events.ReportStored, event.Type)
+	assert.Equal(t, testdata.OrgID, event.OrgID)
+	assert.Equal(t, testdata.ClusterName, event.ClusterName)
+}
+
+func TestProcessingMessageNotifiesWebhooks(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	var deliveries int32
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&deliveries, 1)
+	}))
+	defer webhookServer.Close()
+
+	_, err := mockStorage.CreateWebhook(types.Webhook{
+		OrgID:        testdata.OrgID,
+		URL:          webhookServer.URL,
+		Secret:       "secret",
+		MinTotalRisk: 1,
+	})
+	helpers.FailOnError(t, err)
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: broker.Configuration{OrgWhitelistEnabled: false},
+		Storage:       mockStorage,
+		Webhooks:      webhooks.New(webhooks.Configuration{Enabled: true, AllowPrivateAddresses: true}, mockStorage),
+	}
+
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": ` + string(testdata.Report3Rules) + `,
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `"
+	}`
+
+	// the same rules are hit by the second report, so webhook is called just once
+	mustConsumerProcessMessage(t, mockConsumer, message)
+	mustConsumerProcessMessage(t, mockConsumer, message)
+	mockConsumer.Webhooks.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&deliveries))
+}
+
 func TestProcessingMessageWithClosedStorage(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 
@@ -562,8 +631,182 @@ func TestKafkaConsumer_SetupCleanup(t *testing.T) {
 		helpers.FailOnError(t, mockConsumer.Close())
 	}()
 
+	assert.False(t, mockConsumer.HasActiveSession())
+
 	// The functions don't really use their arguments at all,
 	// so it's possible to just pass nil into them.
 	helpers.FailOnError(t, mockConsumer.Setup(nil))
+	assert.True(t, mockConsumer.HasActiveSession())
+
 	helpers.FailOnError(t, mockConsumer.Cleanup(nil))
+	assert.False(t, mockConsumer.HasActiveSession())
+}
+
+func consumerMessageCheckedAt(lastChecked time.Time) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report":` + testdata.ConsumerReport + `,
+		"LastChecked": "` + lastChecked.Format(time.RFC3339) + `"
+	}`
+}
+
+func TestKafkaConsumer_ConsumeClaim_Batches(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: 2},
+		Storage:       mockStorage,
+	}
+
+	var messages []*sarama.ConsumerMessage
+	for i := 0; i < 5; i++ {
+		messages = append(messages, helpers.StringToSaramaConsumerMessage(testdata.GetRandomConsumerMessage()))
+	}
+
+	mockConsumerGroupSession := &helpers.MockConsumerGroupSession{}
+	mockConsumerGroupClaim := helpers.NewMockConsumerGroupClaim(messages)
+
+	err := kafkaConsumer.ConsumeClaim(mockConsumerGroupSession, mockConsumerGroupClaim)
+	helpers.FailOnError(t, err)
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 5, count)
+	assert.Equal(t, uint64(5), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+}
+
+func TestKafkaConsumer_HandleMessages_BrokenMessage(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	kafkaConsumer.HandleMessages([]*sarama.ConsumerMessage{
+		helpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage),
+		helpers.StringToSaramaConsumerMessage("broken message"),
+	})
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+}
+
+func TestKafkaConsumer_HandleMessages_OlderReportInBatch(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zerolog_log.Logger = zerolog.New(buf)
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	newerMessage := helpers.StringToSaramaConsumerMessage(consumerMessageCheckedAt(testdata.LastCheckedAt))
+	olderMessage := helpers.StringToSaramaConsumerMessage(consumerMessageCheckedAt(testdata.LastCheckedAt.Add(-time.Hour)))
+
+	kafkaConsumer.HandleMessages([]*sarama.ConsumerMessage{newerMessage, olderMessage})
+
+	// the report of the newer message is kept
+	offset, err := mockStorage.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(newerMessage.Offset), offset)
+
+	assert.Equal(t, uint64(2), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Contains(t, buf.String(), "Skipping because a more recent report already exists for this cluster")
+}
+
+// openConsumerGroupClaim is a claim whose messages channel is closed by the test
+type openConsumerGroupClaim struct {
+	*helpers.MockConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (claim openConsumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return claim.messages
+}
+
+func TestKafkaConsumer_ConsumeClaim_BatchTimeout(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: 100, BatchTimeout: 10 * time.Millisecond},
+		Storage:       mockStorage,
+	}
+
+	claim := openConsumerGroupClaim{
+		MockConsumerGroupClaim: helpers.NewMockConsumerGroupClaim(nil),
+		messages:               make(chan *sarama.ConsumerMessage, 1),
+	}
+
+	batchHandled := make(chan int, 1)
+	consumer.SetBatchHandledHook(&kafkaConsumer, func(consumed int) {
+		batchHandled <- consumed
+	})
+
+	session := &helpers.MockConsumerGroupSession{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := kafkaConsumer.ConsumeClaim(session, claim)
+		helpers.FailOnError(t, err)
+	}()
+
+	claim.messages <- helpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage)
+
+	// the incomplete batch is written once the timeout elapses
+	select {
+	case consumed := <-batchHandled:
+		assert.Equal(t, 1, consumed)
+	case <-time.After(testCaseTimeLimit):
+		t.Fatal("the incomplete batch hasn't been handled")
+	}
+
+	close(claim.messages)
+	<-done
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, session.MarkedMessages, 1)
+}
+
+func TestKafkaConsumer_ConsumeClaim_BatchWriteError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zerolog_log.Logger = zerolog.New(buf)
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	// all writes fail on the closed storage
+	closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: 2},
+		Storage:       mockStorage,
+	}
+
+	claim := openConsumerGroupClaim{
+		MockConsumerGroupClaim: helpers.NewMockConsumerGroupClaim(nil),
+		messages:               make(chan *sarama.ConsumerMessage, 3),
+	}
+	for i := 0; i < 3; i++ {
+		claim.messages <- helpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage)
+	}
+
+	session := &helpers.MockConsumerGroupSession{}
+
+	// the claim is left after the first batch, so that its messages are consumed again in the next session
+	err := kafkaConsumer.ConsumeClaim(session, claim)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, session.MarkedMessages)
+	assert.Len(t, claim.messages, 1)
+	assert.Contains(t, buf.String(), "the messages will be consumed again")
 }
@@ -25,3 +25,8 @@ var (
 	ParseMessage         = parseMessage
 	CheckReportStructure = checkReportStructure
 )
+
+// SetBatchHandledHook sets the function called after every batch handled by ConsumeClaim
+func SetBatchHandledHook(consumer *KafkaConsumer, hook func(consumed int)) {
+	consumer.batchHandled = hook
+}
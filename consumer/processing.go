@@ -15,6 +15,7 @@
 package consumer
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -23,8 +24,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -41,8 +44,54 @@ type incomingMessage struct {
 	RequestID   types.RequestID `json:"RequestId"`
 }
 
+// preparedMessage is a parsed and checked message whose report is ready to be written into the storage
+type preparedMessage struct {
+	msg         *sarama.ConsumerMessage
+	message     incomingMessage
+	report      types.ClusterReport
+	lastChecked time.Time
+	// tPrepared is the time when the message was prepared, it's used to measure the storing duration
+	tPrepared time.Time
+}
+
 // HandleMessage handles the message and does all logging, metrics, etc
 func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
+	startTime := messageReceived(msg)
+
+	// storage queries of the message are canceled when the consumer is being closed
+	ctx, cancel := context.WithCancel(consumer.context())
+	defer cancel()
+
+	requestID, err := consumer.processMessage(ctx, msg)
+
+	consumer.messageProcessed(msg, requestID, startTime, err)
+}
+
+// HandleMessages handles the batch of messages, their reports are written into the storage
+// in a single transaction, and does all logging, metrics, etc for every message. It returns
+// the number of messages from the beginning of the batch that can be marked as consumed,
+// i.e. the messages before the first one whose report couldn't be written.
+func (consumer *KafkaConsumer) HandleMessages(msgs []*sarama.ConsumerMessage) int {
+	startTimes := make([]time.Time, len(msgs))
+	for i, msg := range msgs {
+		startTimes[i] = messageReceived(msg)
+	}
+
+	// storage queries of the messages are canceled when the consumer is being closed
+	ctx, cancel := context.WithCancel(consumer.context())
+	defer cancel()
+
+	requestIDs, errs, consumed := consumer.processMessages(ctx, msgs)
+
+	for i, msg := range msgs {
+		consumer.messageProcessed(msg, requestIDs[i], startTimes[i], errs[i])
+	}
+
+	return consumed
+}
+
+// messageReceived logs the start of processing of the message and returns the start time
+func messageReceived(msg *sarama.ConsumerMessage) time.Time {
 	log.Info().
 		Int64(offsetKey, msg.Offset).
 		Int32(partitionKey, msg.Partition).
@@ -52,8 +101,13 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 
 	metrics.ConsumedMessages.Inc()
 
-	startTime := time.Now()
-	requestID, err := consumer.ProcessMessage(msg)
+	return time.Now()
+}
+
+// messageProcessed does the logging, metrics and payload tracking of the processed message
+func (consumer *KafkaConsumer) messageProcessed(
+	msg *sarama.ConsumerMessage, requestID types.RequestID, startTime time.Time, err error,
+) {
 	timeAfterProfessingMessage := time.Now()
 	messageProcessingDuration := timeAfterProfessingMessage.Sub(startTime)
 
@@ -93,13 +147,119 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 
 // ProcessMessage processes an incoming message
 func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (types.RequestID, error) {
+	return consumer.processMessage(context.Background(), msg)
+}
+
+// processMessage processes an incoming message, storage queries are run in the context
+func (consumer *KafkaConsumer) processMessage(
+	ctx context.Context, msg *sarama.ConsumerMessage,
+) (types.RequestID, error) {
+	prepared, err := consumer.prepareMessage(msg)
+	if err != nil {
+		return prepared.message.RequestID, err
+	}
+
+	previousReport := consumer.Webhooks.LastReport(*prepared.message.Organization, *prepared.message.ClusterName)
+
+	err = consumer.Storage.WithContext(ctx).WriteReportForCluster(
+		*prepared.message.Organization,
+		*prepared.message.ClusterName,
+		prepared.report,
+		prepared.lastChecked,
+		types.KafkaOffset(msg.Offset),
+	)
+
+	return consumer.reportWritten(prepared, previousReport, err)
+}
+
+// processMessages processes the batch of incoming messages, reports of all messages
+// that can be processed are written in a single storage call run in the context.
+// The request ID and the processing error of every message are returned together with
+// the index of the first message whose report couldn't be written, the length of the batch
+// if all reports were written.
+func (consumer *KafkaConsumer) processMessages(
+	ctx context.Context, msgs []*sarama.ConsumerMessage,
+) ([]types.RequestID, []error, int) {
+	requestIDs := make([]types.RequestID, len(msgs))
+	errs := make([]error, len(msgs))
+	consumed := len(msgs)
+
+	var preparedMessages []preparedMessage
+	// indexes of the prepared messages in the batch
+	var indexes []int
+	var reports []storage.ReportWrite
+	previousReports := make(map[types.ClusterName]types.ClusterReport)
+
+	for i, msg := range msgs {
+		prepared, err := consumer.prepareMessage(msg)
+		requestIDs[i] = prepared.message.RequestID
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		clusterName := *prepared.message.ClusterName
+		if _, found := previousReports[clusterName]; !found {
+			previousReports[clusterName] = consumer.Webhooks.LastReport(*prepared.message.Organization, clusterName)
+		}
+
+		preparedMessages = append(preparedMessages, prepared)
+		indexes = append(indexes, i)
+		reports = append(reports, storage.ReportWrite{
+			OrgID:           *prepared.message.Organization,
+			ClusterName:     clusterName,
+			Report:          prepared.report,
+			LastCheckedTime: prepared.lastChecked,
+			KafkaOffset:     types.KafkaOffset(msg.Offset),
+		})
+	}
+
+	if len(reports) == 0 {
+		return requestIDs, errs, consumed
+	}
+
+	results, err := consumer.Storage.WithContext(ctx).WriteReportsForClusters(reports)
+	if err != nil {
+		// a single broken report must not make the whole batch fail
+		log.Error().Err(err).Msg("Unable to write the batch of reports, writing them one by one")
+
+		results = make([]error, len(reports))
+		for i, report := range reports {
+			results[i] = consumer.Storage.WithContext(ctx).WriteReportForCluster(
+				report.OrgID, report.ClusterName, report.Report, report.LastCheckedTime, report.KafkaOffset,
+			)
+		}
+	}
+
+	for i, prepared := range preparedMessages {
+		clusterName := reports[i].ClusterName
+
+		_, errs[indexes[i]] = consumer.reportWritten(prepared, previousReports[clusterName], results[i])
+
+		// the message has to be consumed again unless its report is outdated
+		if results[i] != nil && results[i] != types.ErrOldReport && consumed == len(msgs) {
+			consumed = indexes[i]
+		}
+
+		// the next report of the same cluster in the batch is compared to this one
+		if results[i] == nil {
+			previousReports[clusterName] = reports[i].Report
+		}
+	}
+
+	return requestIDs, errs, consumed
+}
+
+// prepareMessage parses and checks the message and prepares its report for writing into the storage
+func (consumer *KafkaConsumer) prepareMessage(msg *sarama.ConsumerMessage) (preparedMessage, error) {
 	tStart := time.Now()
 
 	log.Info().Int(offsetKey, int(msg.Offset)).Str(topicKey, consumer.Configuration.Topic).Str(groupKey, consumer.Configuration.Group).Msg("Consumed")
 	message, err := parseMessage(msg.Value)
+	prepared := preparedMessage{msg: msg, message: message}
 	if err != nil {
 		logUnparsedMessageError(consumer, msg, "Error parsing message from Kafka", err)
-		return message.RequestID, err
+		return prepared, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Read")
@@ -113,7 +273,7 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 			// now we have all required information about the incoming message,
 			// the right time to record structured log entry
 			logMessageError(consumer, msg, message, cause, err)
-			return message.RequestID, errors.New(cause)
+			return prepared, errors.New(cause)
 		}
 
 		logMessageInfo(consumer, msg, message, "Organization whitelisted")
@@ -125,7 +285,7 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	reportAsStr, err := json.Marshal(*message.Report)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error marshalling report", err)
-		return message.RequestID, err
+		return prepared, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Marshalled")
@@ -134,7 +294,7 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error parsing date from message", err)
-		return message.RequestID, err
+		return prepared, err
 	}
 
 	lastCheckedTimestampLagMinutes := time.Now().Sub(lastCheckedTime).Minutes()
@@ -147,31 +307,45 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	logMessageInfo(consumer, msg, message, "Time ok")
 	tTimeCheck := time.Now()
 
-	err = consumer.Storage.WriteReportForCluster(
-		*message.Organization,
-		*message.ClusterName,
-		types.ClusterReport(reportAsStr),
-		lastCheckedTime,
-		types.KafkaOffset(msg.Offset),
-	)
-	if err != nil {
-		if err == types.ErrOldReport {
+	// log durations for every message consumption steps
+	logDuration(tStart, tRead, msg.Offset, "read")
+	logDuration(tRead, tWhitelisted, msg.Offset, "whitelisting")
+	logDuration(tWhitelisted, tMarshalled, msg.Offset, "marshalling")
+	logDuration(tMarshalled, tTimeCheck, msg.Offset, "time_check")
+
+	prepared.report = types.ClusterReport(reportAsStr)
+	prepared.lastChecked = lastCheckedTime
+	prepared.tPrepared = tTimeCheck
+
+	return prepared, nil
+}
+
+// reportWritten finishes processing of the prepared message after its report was written
+// into the storage, writeErr is the result of the write
+func (consumer *KafkaConsumer) reportWritten(
+	prepared preparedMessage, previousReport types.ClusterReport, writeErr error,
+) (types.RequestID, error) {
+	msg, message := prepared.msg, prepared.message
+
+	if writeErr != nil {
+		if writeErr == types.ErrOldReport {
 			logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
 			return message.RequestID, nil
 		}
 
-		logMessageError(consumer, msg, message, "Error writing report to database", err)
-		return message.RequestID, err
+		logMessageError(consumer, msg, message, "Error writing report to database", writeErr)
+		return message.RequestID, writeErr
 	}
 	logMessageInfo(consumer, msg, message, "Stored")
-	tStored := time.Now()
+	logDuration(prepared.tPrepared, time.Now(), msg.Offset, "db_store")
 
-	// log durations for every message consumption steps
-	logDuration(tStart, tRead, msg.Offset, "read")
-	logDuration(tRead, tWhitelisted, msg.Offset, "whitelisting")
-	logDuration(tWhitelisted, tMarshalled, msg.Offset, "marshalling")
-	logDuration(tMarshalled, tTimeCheck, msg.Offset, "time_check")
-	logDuration(tTimeCheck, tStored, msg.Offset, "db_store")
+	consumer.EventBus.Publish(events.Event{
+		Type:        events.ReportStored,
+		OrgID:       *message.Organization,
+		ClusterName: *message.ClusterName,
+	})
+
+	consumer.Webhooks.ReportStored(*message.Organization, *message.ClusterName, previousReport, prepared.report)
 
 	// message has been parsed and stored into storage
 	return message.RequestID, nil
@@ -0,0 +1,481 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// bundleDownloadTimeout limits the time of downloading the content bundle including reading its body
+	bundleDownloadTimeout = 2 * time.Minute
+
+	// DefaultMaxBundleSize limits the size of the bundle and the total size of files extracted from it
+	DefaultMaxBundleSize = 256 * 1024 * 1024
+	// DefaultMaxBundleFileSize limits the size of every file extracted from the bundle
+	DefaultMaxBundleFileSize = 16 * 1024 * 1024
+)
+
+// BundleLimits limits sizes of rule content bundles, so that a broken or malicious
+// bundle can't exhaust the memory. Zero values mean the default limits.
+type BundleLimits struct {
+	MaxSize     int64
+	MaxFileSize int64
+}
+
+var (
+	bundleLimits      = BundleLimits{MaxSize: DefaultMaxBundleSize, MaxFileSize: DefaultMaxBundleFileSize}
+	bundleLimitsMutex sync.RWMutex
+
+	// downloadedBundle is the last bundle downloaded from URL with verified checksum.
+	// It's reused while the URL and the checksum are the same, so the bundle loaded
+	// when the service starts isn't downloaded again by the watcher.
+	downloadedBundle struct {
+		sync.Mutex
+		url      string
+		checksum string
+		data     []byte
+	}
+
+	// errTooLarge is returned by readAllLimited, callers replace it by a descriptive error
+	errTooLarge = errors.New("data exceed the size limit")
+)
+
+// SetBundleLimits sets limits of sizes of all bundles opened afterwards
+func SetBundleLimits(limits BundleLimits) {
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultMaxBundleSize
+	}
+	if limits.MaxFileSize <= 0 {
+		limits.MaxFileSize = DefaultMaxBundleFileSize
+	}
+
+	bundleLimitsMutex.Lock()
+	defer bundleLimitsMutex.Unlock()
+
+	bundleLimits = limits
+}
+
+func getBundleLimits() BundleLimits {
+	bundleLimitsMutex.RLock()
+	defer bundleLimitsMutex.RUnlock()
+
+	return bundleLimits
+}
+
+// readAllLimited reads the whole reader, errTooLarge is returned if there are more than limit bytes
+func readAllLimited(reader io.Reader, limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > limit {
+		return nil, errTooLarge
+	}
+
+	return data, nil
+}
+
+// isURL returns true if the content location is an HTTP(S) URL
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// isBundle returns true if the name has an extension of the supported archive
+func isBundle(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".zip")
+}
+
+// openContent returns the file system and the path of the content directory in it
+func openContent(location, checksum string) (fileSystem, string, error) {
+	switch {
+	case isURL(location):
+		if checksum == "" {
+			return nil, "", fmt.Errorf("SHA-256 checksum is required for rule content downloaded from %v", location)
+		}
+
+		bundleURL, err := url.Parse(location)
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, err := downloadVerifiedBundle(location, checksum)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return openBundle(bundleURL.Path, data, checksum)
+	case isBundle(location):
+		data, err := readBundleFile(location)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return openBundle(location, data, checksum)
+	default:
+		return osFileSystem{}, location, nil
+	}
+}
+
+// downloadVerifiedBundle downloads the bundle and verifies its checksum,
+// the last downloaded bundle is returned if its URL and checksum are the same
+func downloadVerifiedBundle(bundleURL, checksum string) ([]byte, error) {
+	downloadedBundle.Lock()
+	defer downloadedBundle.Unlock()
+
+	if downloadedBundle.url == bundleURL && downloadedBundle.checksum == checksum {
+		return downloadedBundle.data, nil
+	}
+
+	data, err := downloadBundle(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksum(bundleURL, data, checksum); err != nil {
+		return nil, err
+	}
+
+	downloadedBundle.url = bundleURL
+	downloadedBundle.checksum = checksum
+	downloadedBundle.data = data
+
+	return data, nil
+}
+
+func downloadBundle(bundleURL string) ([]byte, error) {
+	client := http.Client{Timeout: bundleDownloadTimeout}
+
+	response, err := client.Get(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download rule content from %v: %v", bundleURL, response.Status)
+	}
+
+	maxSize := getBundleLimits().MaxSize
+	data, err := readAllLimited(response.Body, maxSize)
+	if err == errTooLarge {
+		return nil, fmt.Errorf("rule content bundle %v is larger than %d bytes", bundleURL, maxSize)
+	}
+
+	return data, err
+}
+
+func readBundleFile(bundlePath string) ([]byte, error) {
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	maxSize := getBundleLimits().MaxSize
+	data, err := readAllLimited(file, maxSize)
+	if err == errTooLarge {
+		return nil, fmt.Errorf("rule content bundle %v is larger than %d bytes", bundlePath, maxSize)
+	}
+
+	return data, err
+}
+
+// verifyChecksum checks that the data have the expected SHA-256 checksum in hex
+func verifyChecksum(name string, data []byte, checksum string) error {
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+
+	if !strings.EqualFold(actual, strings.TrimSpace(checksum)) {
+		return fmt.Errorf("SHA-256 checksum of rule content bundle %v is %v, expected %v", name, actual, checksum)
+	}
+
+	return nil
+}
+
+// openBundle verifies and extracts the archive into memory. Content in the archive
+// can be either at its top level or in a single top-level directory. Sizes
+// of the extracted files are limited by the limits set by SetBundleLimits.
+func openBundle(name string, data []byte, checksum string) (fileSystem, string, error) {
+	if checksum != "" {
+		if err := verifyChecksum(name, data, checksum); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var (
+		fsys *memFileSystem
+		err  error
+	)
+	extractor := newBundleExtractor(getBundleLimits())
+	if strings.HasSuffix(name, ".zip") {
+		fsys, err = extractor.extractZip(data)
+	} else {
+		fsys, err = extractor.extractTarGz(data)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to extract rule content bundle %v: %v", name, err)
+	}
+
+	if _, err := fsys.Stat("config.yaml"); os.IsNotExist(err) {
+		if topLevel, _ := fsys.ReadDir("."); len(topLevel) == 1 && topLevel[0].IsDir() {
+			return fsys, topLevel[0].Name(), nil
+		}
+	}
+
+	return fsys, ".", nil
+}
+
+// bundleExtractor reads files of one bundle and checks that they don't exceed the limits
+type bundleExtractor struct {
+	limits BundleLimits
+	// total is the size of all files read so far
+	total int64
+}
+
+func newBundleExtractor(limits BundleLimits) *bundleExtractor {
+	return &bundleExtractor{limits: limits}
+}
+
+// readFile reads one file of the bundle, the sizes claimed by the archive aren't trusted
+func (extractor *bundleExtractor) readFile(name string, reader io.Reader) ([]byte, error) {
+	limit := extractor.limits.MaxFileSize
+	if remaining := extractor.limits.MaxSize - extractor.total; remaining < limit {
+		limit = remaining
+	}
+
+	content, err := readAllLimited(reader, limit)
+	if err == errTooLarge {
+		if limit == extractor.limits.MaxFileSize {
+			return nil, fmt.Errorf("file %v is larger than %d bytes", name, limit)
+		}
+		return nil, fmt.Errorf("extracted files are larger than %d bytes in total", extractor.limits.MaxSize)
+	} else if err != nil {
+		return nil, err
+	}
+
+	extractor.total += int64(len(content))
+
+	return content, nil
+}
+
+func (extractor *bundleExtractor) extractTarGz(data []byte) (*memFileSystem, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := newMemFileSystem()
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return fsys, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			fsys.addDir(header.Name)
+		case tar.TypeReg, tar.TypeRegA:
+			content, err := extractor.readFile(header.Name, tarReader)
+			if err != nil {
+				return nil, err
+			}
+			fsys.addFile(header.Name, content)
+		}
+	}
+}
+
+func (extractor *bundleExtractor) extractZip(data []byte) (*memFileSystem, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := newMemFileSystem()
+
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			fsys.addDir(file.Name)
+			continue
+		}
+
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := extractor.readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fsys.addFile(file.Name, content)
+	}
+
+	return fsys, nil
+}
+
+func (extractor *bundleExtractor) readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	return extractor.readFile(file.Name, reader)
+}
+
+// memFileSystem keeps files of the extracted bundle, paths are relative to its root
+type memFileSystem struct {
+	files map[string][]byte
+	// dirs contains names of entries in every directory
+	dirs map[string]map[string]bool
+}
+
+func newMemFileSystem() *memFileSystem {
+	return &memFileSystem{
+		files: map[string][]byte{},
+		dirs:  map[string]map[string]bool{".": {}},
+	}
+}
+
+// cleanPath makes the path relative to the root, so "./a", "/a" and "a" are the same
+func cleanPath(name string) string {
+	return path.Clean(strings.TrimPrefix(path.Clean("/"+name), "/"))
+}
+
+func (fsys *memFileSystem) addDir(name string) {
+	name = cleanPath(name)
+	if _, found := fsys.dirs[name]; found {
+		return
+	}
+
+	fsys.dirs[name] = map[string]bool{}
+
+	parent := path.Dir(name)
+	fsys.addDir(parent)
+	fsys.dirs[parent][path.Base(name)] = true
+}
+
+func (fsys *memFileSystem) addFile(name string, content []byte) {
+	name = cleanPath(name)
+	fsys.files[name] = content
+
+	parent := path.Dir(name)
+	fsys.addDir(parent)
+	fsys.dirs[parent][path.Base(name)] = true
+}
+
+func (fsys *memFileSystem) ReadFile(name string) ([]byte, error) {
+	content, found := fsys.files[cleanPath(name)]
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return content, nil
+}
+
+// ReadDir returns entries of the directory sorted by name like ioutil.ReadDir
+func (fsys *memFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	dirPath := cleanPath(name)
+
+	entries, found := fsys.dirs[dirPath]
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	names := make([]string, 0, len(entries))
+	for entryName := range entries {
+		names = append(names, entryName)
+	}
+	sort.Strings(names)
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, entryName := range names {
+		info, err := fsys.Stat(path.Join(dirPath, entryName))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (fsys *memFileSystem) Stat(name string) (os.FileInfo, error) {
+	cleanName := cleanPath(name)
+
+	if content, found := fsys.files[cleanName]; found {
+		return memFileInfo{name: path.Base(cleanName), size: int64(len(content))}, nil
+	}
+
+	if _, found := fsys.dirs[cleanName]; found {
+		return memFileInfo{name: path.Base(cleanName), dir: true}, nil
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// memFileInfo describes a file or directory of memFileSystem
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (info memFileInfo) Name() string { return info.name }
+
+func (info memFileInfo) Size() int64 { return info.size }
+
+func (info memFileInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (info memFileInfo) ModTime() time.Time { return time.Time{} }
+
+func (info memFileInfo) IsDir() bool { return info.dir }
+
+func (info memFileInfo) Sys() interface{} { return nil }
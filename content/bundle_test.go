@@ -0,0 +1,279 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// bundleFile is a file added to the test bundle
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// mustReadBundleFiles reads all files in the directory, names are prefixed by the prefix
+func mustReadBundleFiles(t *testing.T, dirPath, prefix string) []bundleFile {
+	var files []bundleFile
+
+	err := filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, bundleFile{name: path.Join(prefix, filepath.ToSlash(relativePath)), data: data})
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	return files
+}
+
+func mustCreateTarGz(t *testing.T, files []bundleFile) []byte {
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		helpers.FailOnError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     0644,
+			Size:     int64(len(file.data)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write(file.data)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, tarWriter.Close())
+	helpers.FailOnError(t, gzipWriter.Close())
+
+	return buffer.Bytes()
+}
+
+func mustCreateZip(t *testing.T, files []bundleFile) []byte {
+	buffer := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buffer)
+
+	for _, file := range files {
+		writer, err := zipWriter.Create(file.name)
+		helpers.FailOnError(t, err)
+		_, err = io.Copy(writer, bytes.NewReader(file.data))
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, zipWriter.Close())
+
+	return buffer.Bytes()
+}
+
+// mustWriteBundle writes the bundle into a temporary directory and returns its path
+func mustWriteBundle(t *testing.T, name string, data []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "content-bundle")
+	helpers.FailOnError(t, err)
+
+	bundlePath := filepath.Join(dir, name)
+	helpers.FailOnError(t, ioutil.WriteFile(bundlePath, data, 0644))
+
+	return bundlePath, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func mustParseOKContentDir(t *testing.T) content.RuleContentDirectory {
+	expected, err := content.ParseRuleContentDir("../tests/content/ok/")
+	helpers.FailOnError(t, err)
+
+	return expected
+}
+
+func TestParseRuleContentDirectory(t *testing.T) {
+	parsed, err := content.ParseRuleContent("../tests/content/ok/", "")
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, mustParseOKContentDir(t), parsed)
+}
+
+func TestParseRuleContentTarGz(t *testing.T) {
+	// content in a top-level directory
+	data := mustCreateTarGz(t, mustReadBundleFiles(t, "../tests/content/ok/", "rules-content"))
+	bundlePath, cleanup := mustWriteBundle(t, "content.tar.gz", data)
+	defer cleanup()
+
+	parsed, err := content.ParseRuleContent(bundlePath, checksum(data))
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, mustParseOKContentDir(t), parsed)
+}
+
+func TestParseRuleContentZip(t *testing.T) {
+	// content at the top level, checksum is optional for local bundles
+	data := mustCreateZip(t, mustReadBundleFiles(t, "../tests/content/ok/", ""))
+	bundlePath, cleanup := mustWriteBundle(t, "content.zip", data)
+	defer cleanup()
+
+	parsed, err := content.ParseRuleContent(bundlePath, "")
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, mustParseOKContentDir(t), parsed)
+}
+
+func TestParseRuleContentBundleErrors(t *testing.T) {
+	data := mustCreateZip(t, mustReadBundleFiles(t, "../tests/content/ok/", ""))
+	bundlePath, cleanup := mustWriteBundle(t, "content.zip", data)
+	defer cleanup()
+
+	_, err := content.ParseRuleContent(bundlePath, checksum([]byte("other data")))
+	assert.Contains(t, err.Error(), "SHA-256 checksum of rule content bundle")
+
+	brokenPath, cleanupBroken := mustWriteBundle(t, "content.tar.gz", []byte("not an archive"))
+	defer cleanupBroken()
+
+	_, err = content.ParseRuleContent(brokenPath, "")
+	assert.Contains(t, err.Error(), "unable to extract rule content bundle")
+
+	// content is missing in the bundle
+	emptyData := mustCreateZip(t, nil)
+	emptyPath, cleanupEmpty := mustWriteBundle(t, "empty.zip", emptyData)
+	defer cleanupEmpty()
+
+	_, err = content.ParseRuleContent(emptyPath, "")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseRuleContentURL(t *testing.T) {
+	data := mustCreateTarGz(t, mustReadBundleFiles(t, "../tests/content/ok/", "rules-content"))
+
+	bundleServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/content.tar.gz" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = writer.Write(data)
+	}))
+	defer bundleServer.Close()
+
+	parsed, err := content.ParseRuleContent(bundleServer.URL+"/content.tar.gz", checksum(data))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, mustParseOKContentDir(t), parsed)
+
+	_, err = content.ParseRuleContent(bundleServer.URL+"/content.tar.gz", "")
+	assert.EqualError(t, err, "SHA-256 checksum is required for rule content downloaded from "+
+		bundleServer.URL+"/content.tar.gz")
+
+	_, err = content.ParseRuleContent(bundleServer.URL+"/missing.tar.gz", checksum(data))
+	assert.EqualError(t, err, "unable to download rule content from "+
+		bundleServer.URL+"/missing.tar.gz: 404 Not Found")
+}
+
+func TestParseRuleContentURLDownloadedOnce(t *testing.T) {
+	data := mustCreateTarGz(t, mustReadBundleFiles(t, "../tests/content/ok/", "rules-content"))
+
+	var requests int32
+	bundleServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = writer.Write(data)
+	}))
+	defer bundleServer.Close()
+
+	bundleURL := bundleServer.URL + "/content.tar.gz"
+
+	_, err := content.ParseRuleContent(bundleURL, checksum(data))
+	helpers.FailOnError(t, err)
+
+	// the watcher parses the same bundle again when it's created
+	_, err = content.NewWatcher(bundleURL, checksum(data), newLoaderMock().load, 0)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// the bundle is downloaded again if it doesn't have the expected checksum
+	_, err = content.ParseRuleContent(bundleURL, checksum([]byte("other data")))
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestParseRuleContentBundleLimits(t *testing.T) {
+	defer content.SetBundleLimits(content.BundleLimits{})
+
+	zeros := make([]byte, 1000)
+	files := []bundleFile{{name: "a", data: zeros}, {name: "b", data: zeros}, {name: "c", data: zeros}}
+
+	for name, data := range map[string][]byte{
+		"content.tar.gz": mustCreateTarGz(t, files),
+		"content.zip":    mustCreateZip(t, files),
+	} {
+		bundlePath, cleanup := mustWriteBundle(t, name, data)
+		defer cleanup()
+
+		content.SetBundleLimits(content.BundleLimits{MaxFileSize: 500})
+		_, err := content.ParseRuleContent(bundlePath, "")
+		assert.EqualError(t, err, "unable to extract rule content bundle "+bundlePath+
+			": file a is larger than 500 bytes")
+
+		content.SetBundleLimits(content.BundleLimits{MaxSize: 2500})
+		_, err = content.ParseRuleContent(bundlePath, "")
+		assert.EqualError(t, err, "unable to extract rule content bundle "+bundlePath+
+			": extracted files are larger than 2500 bytes in total")
+
+		content.SetBundleLimits(content.BundleLimits{MaxSize: 10})
+		_, err = content.ParseRuleContent(bundlePath, "")
+		assert.EqualError(t, err, "rule content bundle "+bundlePath+" is larger than 10 bytes")
+	}
+}
+
+func TestLintRuleContentBundle(t *testing.T) {
+	data := mustCreateZip(t, mustReadBundleFiles(t, "../tests/content/invalid/", "rules-content"))
+	bundlePath, cleanup := mustWriteBundle(t, "content.zip", data)
+	defer cleanup()
+
+	assert.Equal(t, content.LintRuleContentDir("../tests/content/invalid/"), content.LintRuleContent(bundlePath, ""))
+}
@@ -18,10 +18,13 @@ limitations under the License.
 package content
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/go-yaml/yaml"
 )
@@ -48,6 +51,9 @@ type ErrorKeyMetadata struct {
 type RuleErrorKeyContent struct {
 	Generic  []byte
 	Metadata ErrorKeyMetadata
+	// GenericTranslations maps lower case language tags to the translated
+	// generic.md read from files like generic.ja.md
+	GenericTranslations map[string][]byte
 }
 
 // RulePluginInfo is a Go representation of the `plugin.yaml`
@@ -59,6 +65,15 @@ type RulePluginInfo struct {
 	PythonModule string `yaml:"python_module"`
 }
 
+// RuleTranslation contains texts of the rule translated to a language,
+// the texts that are not translated are nil.
+type RuleTranslation struct {
+	Summary    []byte
+	Reason     []byte
+	Resolution []byte
+	MoreInfo   []byte
+}
+
 // RuleContent wraps all the content available for a rule into a single structure.
 type RuleContent struct {
 	Summary    []byte
@@ -67,20 +82,50 @@ type RuleContent struct {
 	MoreInfo   []byte
 	Plugin     RulePluginInfo
 	ErrorKeys  map[string]RuleErrorKeyContent
+	// Internal is set for rules from the `internal` directory which are
+	// shown to internal users only
+	Internal bool
+	// Translations maps lower case language tags to the translated texts
+	// read from files like summary.ja.md
+	Translations map[string]RuleTranslation
 }
 
 // RuleContentDirectory contains content for all available rules in a directory.
 type RuleContentDirectory struct {
-	Config GlobalRuleConfig
-	Rules  map[string]RuleContent
+	Config  GlobalRuleConfig
+	Rules   map[string]RuleContent
+	Version RuleContentVersion
+}
+
+// fileSystem is a read-only file system the rule content is parsed from,
+// it's either the OS one or a bundle extracted into memory
+type fileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+// osFileSystem reads files from the OS file system
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Clean(name))
+}
+
+func (osFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
 }
 
 // readFilesIntoByteArrayPointers reads the contents of the specified files
 // in the base directory and saves them via the specified byte slice pointers.
-func readFilesIntoByteArrayPointers(baseDir string, fileMap map[string]*[]byte) error {
+func readFilesIntoByteArrayPointers(fsys fileSystem, baseDir string, fileMap map[string]*[]byte) error {
 	for name, ptr := range fileMap {
 		var err error
-		*ptr, err = ioutil.ReadFile(filepath.Clean(path.Join(baseDir, name)))
+		*ptr, err = fsys.ReadFile(path.Join(baseDir, name))
 		if err != nil {
 			return err
 		}
@@ -88,12 +133,48 @@ func readFilesIntoByteArrayPointers(baseDir string, fileMap map[string]*[]byte)
 	return nil
 }
 
+// translatedFileRegex matches names of translated content files, e.g. summary.ja.md
+// or generic.pt-BR.md, the first group is the name of the file that is translated
+// and the second one is the language tag
+var translatedFileRegex = regexp.MustCompile(`^([a-z_]+)\.([a-zA-Z]{2,3}(?:-[a-zA-Z0-9]{2,8})?)\.md$`)
+
+// readTranslatedFiles reads all translated content files in the directory,
+// the contents are indexed by the translated file name and the language tag
+func readTranslatedFiles(fsys fileSystem, dirPath string) (map[string]map[string][]byte, error) {
+	entries, err := fsys.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := map[string]map[string][]byte{}
+
+	for _, e := range entries {
+		match := translatedFileRegex.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		data, err := fsys.ReadFile(path.Join(dirPath, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		fileName, language := match[1]+".md", strings.ToLower(match[2])
+		if translations[fileName] == nil {
+			translations[fileName] = map[string][]byte{}
+		}
+		translations[fileName][language] = data
+	}
+
+	return translations, nil
+}
+
 // parseErrorContents reads the contents of the specified directory
 // and parses all subdirectories as error key contents.
 // This implicitly checks that the directory exists,
 // so it is not necessary to ever check that elsewhere.
-func parseErrorContents(ruleDirPath string) (map[string]RuleErrorKeyContent, error) {
-	entries, err := ioutil.ReadDir(ruleDirPath)
+func parseErrorContents(fsys fileSystem, ruleDirPath string) (map[string]RuleErrorKeyContent, error) {
+	entries, err := fsys.ReadDir(ruleDirPath)
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +192,7 @@ func parseErrorContents(ruleDirPath string) (map[string]RuleErrorKeyContent, err
 				"generic.md":    &errContent.Generic,
 				"metadata.yaml": &metadataBytes,
 			}
-			if err := readFilesIntoByteArrayPointers(path.Join(ruleDirPath, name), contentFiles); err != nil {
+			if err := readFilesIntoByteArrayPointers(fsys, path.Join(ruleDirPath, name), contentFiles); err != nil {
 				return errorContents, err
 			}
 
@@ -119,6 +200,12 @@ func parseErrorContents(ruleDirPath string) (map[string]RuleErrorKeyContent, err
 				return errorContents, err
 			}
 
+			translations, err := readTranslatedFiles(fsys, path.Join(ruleDirPath, name))
+			if err != nil {
+				return errorContents, err
+			}
+			errContent.GenericTranslations = translations["generic.md"]
+
 			errorContents[name] = errContent
 		}
 	}
@@ -127,8 +214,8 @@ func parseErrorContents(ruleDirPath string) (map[string]RuleErrorKeyContent, err
 }
 
 // parseRuleContent attempts to parse all available rule content from the specified directory.
-func parseRuleContent(ruleDirPath string) (RuleContent, error) {
-	errorContents, err := parseErrorContents(ruleDirPath)
+func parseRuleContent(fsys fileSystem, ruleDirPath string) (RuleContent, error) {
+	errorContents, err := parseErrorContents(fsys, ruleDirPath)
 	if err != nil {
 		return RuleContent{}, err
 	}
@@ -143,7 +230,7 @@ func parseRuleContent(ruleDirPath string) (RuleContent, error) {
 		"more_info.md":  &ruleContent.MoreInfo,
 		"plugin.yaml":   &pluginBytes,
 	}
-	if err := readFilesIntoByteArrayPointers(ruleDirPath, contentFiles); err != nil {
+	if err := readFilesIntoByteArrayPointers(fsys, ruleDirPath, contentFiles); err != nil {
 		return RuleContent{}, err
 	}
 
@@ -151,13 +238,41 @@ func parseRuleContent(ruleDirPath string) (RuleContent, error) {
 		return RuleContent{}, err
 	}
 
+	translations, err := readTranslatedFiles(fsys, ruleDirPath)
+	if err != nil {
+		return RuleContent{}, err
+	}
+	ruleContent.Translations = collectRuleTranslations(translations)
+
 	return ruleContent, nil
 }
 
+// collectRuleTranslations groups translated texts of the rule by language
+func collectRuleTranslations(translations map[string]map[string][]byte) map[string]RuleTranslation {
+	ruleTranslations := map[string]RuleTranslation{}
+
+	fields := map[string]func(*RuleTranslation) *[]byte{
+		"summary.md":    func(t *RuleTranslation) *[]byte { return &t.Summary },
+		"reason.md":     func(t *RuleTranslation) *[]byte { return &t.Reason },
+		"resolution.md": func(t *RuleTranslation) *[]byte { return &t.Resolution },
+		"more_info.md":  func(t *RuleTranslation) *[]byte { return &t.MoreInfo },
+	}
+
+	for fileName, field := range fields {
+		for language, data := range translations[fileName] {
+			translation := ruleTranslations[language]
+			*field(&translation) = data
+			ruleTranslations[language] = translation
+		}
+	}
+
+	return ruleTranslations
+}
+
 // parseGlobalContentConfig reads the configuration file used to store
 // metadata used by all rule content, such as impact dictionary.
-func parseGlobalContentConfig(configPath string) (GlobalRuleConfig, error) {
-	configBytes, err := ioutil.ReadFile(filepath.Clean(configPath))
+func parseGlobalContentConfig(fsys fileSystem, configPath string) (GlobalRuleConfig, error) {
+	configBytes, err := fsys.ReadFile(configPath)
 	if err != nil {
 		return GlobalRuleConfig{}, err
 	}
@@ -167,10 +282,17 @@ func parseGlobalContentConfig(configPath string) (GlobalRuleConfig, error) {
 	return conf, err
 }
 
+// isRuleDir checks if the directory directly contains a rule content
+func isRuleDir(fsys fileSystem, dirPath string) bool {
+	pluginYaml, err := fsys.Stat(path.Join(dirPath, "plugin.yaml"))
+	return err == nil && os.FileMode.IsRegular(pluginYaml.Mode())
+}
+
 // parseRulesInDir finds all rules and their content in the specified
-// directory and stores the content in the provided map.
-func parseRulesInDir(dirPath string, contentMap *map[string]RuleContent) error {
-	entries, err := ioutil.ReadDir(dirPath)
+// directory and stores the content in the provided map. The rules
+// are marked as internal when the internal flag is set.
+func parseRulesInDir(fsys fileSystem, dirPath string, internal bool, contentMap *map[string]RuleContent) error {
+	entries, err := fsys.ReadDir(dirPath)
 	if err != nil {
 		return err
 	}
@@ -185,17 +307,21 @@ func parseRulesInDir(dirPath string, contentMap *map[string]RuleContent) error {
 			// upon which this function is called because the very top level directory
 			// should never directly contain any rule content and because the name
 			// of the directory is much easier to access here without an extra call.
-			if pluginYaml, err := os.Stat(path.Join(subdirPath, "plugin.yaml")); err == nil && os.FileMode.IsRegular(pluginYaml.Mode()) {
-				ruleContent, err := parseRuleContent(subdirPath)
+			if isRuleDir(fsys, subdirPath) {
+				ruleContent, err := parseRuleContent(fsys, subdirPath)
 				if err != nil {
 					return err
 				}
+				ruleContent.Internal = internal
 
-				// TODO: Add name uniqueness check.
+				// rules are identified by names of their directories
+				if _, found := (*contentMap)[name]; found {
+					return fmt.Errorf("duplicate rule name '%v' in %v", name, subdirPath)
+				}
 				(*contentMap)[name] = ruleContent
 			} else {
 				// Otherwise, descend into the sub-directory and see if there is any rule content.
-				if err := parseRulesInDir(subdirPath, contentMap); err != nil {
+				if err := parseRulesInDir(fsys, subdirPath, internal, contentMap); err != nil {
 					return err
 				}
 			}
@@ -205,9 +331,9 @@ func parseRulesInDir(dirPath string, contentMap *map[string]RuleContent) error {
 	return nil
 }
 
-// ParseRuleContentDir finds all rule content in a directory and parses it.
-func ParseRuleContentDir(contentDirPath string) (RuleContentDirectory, error) {
-	globalConfig, err := parseGlobalContentConfig(path.Join(contentDirPath, "config.yaml"))
+// parseContentDir finds all rule content in a directory of the file system and parses it.
+func parseContentDir(fsys fileSystem, contentDirPath string) (RuleContentDirectory, error) {
+	globalConfig, err := parseGlobalContentConfig(fsys, path.Join(contentDirPath, "config.yaml"))
 	if err != nil {
 		return RuleContentDirectory{}, err
 	}
@@ -218,7 +344,39 @@ func ParseRuleContentDir(contentDirPath string) (RuleContentDirectory, error) {
 	}
 
 	externalContentDir := path.Join(contentDirPath, "external")
-	err = parseRulesInDir(externalContentDir, &contentDir.Rules)
+	if err := parseRulesInDir(fsys, externalContentDir, false, &contentDir.Rules); err != nil {
+		return contentDir, err
+	}
+
+	// internal content is optional, not every content repository contains internal rules
+	internalContentDir := path.Join(contentDirPath, "internal")
+	if _, err := fsys.Stat(internalContentDir); err == nil {
+		if err := parseRulesInDir(fsys, internalContentDir, true, &contentDir.Rules); err != nil {
+			return contentDir, err
+		}
+	} else if !os.IsNotExist(err) {
+		return contentDir, err
+	}
+
+	contentDir.Version, err = readContentVersion(fsys, contentDirPath)
 
 	return contentDir, err
 }
+
+// ParseRuleContentDir finds all rule content in a directory and parses it.
+func ParseRuleContentDir(contentDirPath string) (RuleContentDirectory, error) {
+	return parseContentDir(osFileSystem{}, contentDirPath)
+}
+
+// ParseRuleContent parses rule content from the location which is either a directory,
+// a .tar.gz or .zip bundle or an HTTP URL serving such bundle. Bundles are extracted
+// into memory and their SHA-256 checksum is verified if it's set, for bundles downloaded
+// from URL it's required. The checksum is not used for directories.
+func ParseRuleContent(location, checksum string) (RuleContentDirectory, error) {
+	fsys, contentDirPath, err := openContent(location, checksum)
+	if err != nil {
+		return RuleContentDirectory{}, err
+	}
+
+	return parseContentDir(fsys, contentDirPath)
+}
@@ -18,6 +18,7 @@ package content_test
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -101,3 +102,63 @@ func TestContentParseNoExternal(t *testing.T) {
 	_, err := content.ParseRuleContentDir(noExternalPath)
 	assert.EqualError(t, err, fmt.Sprintf("open %s/external: no such file or directory", noExternalPath))
 }
+
+// TestContentParseDuplicateRuleName checks that rules with the same name in different directories are refused
+func TestContentParseDuplicateRuleName(t *testing.T) {
+	_, err := content.ParseRuleContentDir("../tests/content/duplicate_rule/")
+	assert.EqualError(t, err, "duplicate rule name 'rule1' in ../tests/content/duplicate_rule/external/upgrade/rule1")
+}
+
+// TestContentParseInternal checks that rules from the internal directory are parsed and marked as internal
+func TestContentParseInternal(t *testing.T) {
+	con, err := content.ParseRuleContentDir("../tests/content/internal/")
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, con.Rules, 2)
+	assert.False(t, con.Rules["rule1"].Internal)
+	assert.True(t, con.Rules["rule2"].Internal)
+	assert.Equal(t, "# Rule 2 Summary\n", string(con.Rules["rule2"].Summary))
+}
+
+// TestContentParseTranslations checks that translated files are read and grouped by language
+func TestContentParseTranslations(t *testing.T) {
+	con, err := content.ParseRuleContentDir("../tests/content/translated/")
+	helpers.FailOnError(t, err)
+
+	rule := con.Rules["rule1"]
+	assert.Equal(t, map[string]content.RuleTranslation{
+		"ja": {Summary: []byte("# ルール1の概要\n"), Reason: []byte("ルール1の理由\n")},
+		"es": {Resolution: []byte("Resolución de la regla 1\n")},
+	}, rule.Translations)
+
+	// language tags are lower case
+	assert.Equal(t, map[string][]byte{
+		"ja":    []byte("汎用\n"),
+		"pt-br": []byte("Genérico\n"),
+	}, rule.ErrorKeys["err_key"].GenericTranslations)
+}
+
+// TestContentParseVersion checks that the version identifies content of the files
+// and that the commit is read from the optional manifest
+func TestContentParseVersion(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	con, err := content.ParseRuleContentDir(contentDir)
+	helpers.FailOnError(t, err)
+	assert.Len(t, con.Version.Hash, 64)
+	assert.Empty(t, con.Version.Commit)
+
+	// the manifest is not part of the hash
+	mustWriteFile(t, filepath.Join(contentDir, "manifest.yaml"), `commit: "0123abcd"`)
+
+	withManifest, err := content.ParseRuleContentDir(contentDir)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, content.RuleContentVersion{Hash: con.Version.Hash, Commit: "0123abcd"}, withManifest.Version)
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "summary.md"), "new summary")
+
+	modified, err := content.ParseRuleContentDir(contentDir)
+	helpers.FailOnError(t, err)
+	assert.NotEqual(t, con.Version.Hash, modified.Version.Hash)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-yaml/yaml"
+)
+
+// ruleMarkdownFiles are files required in every rule directory besides plugin.yaml
+var ruleMarkdownFiles = []string{"summary.md", "reason.md", "resolution.md", "more_info.md"}
+
+// contentLinter collects problems found in the content directory
+// instead of stopping at the first one like the parser does
+type contentLinter struct {
+	fsys       fileSystem
+	rootPath   string
+	problems   []string
+	rulePaths  map[string]string
+	contentDir RuleContentDirectory
+}
+
+// LintRuleContentDir checks the whole content directory, i.e. everything that's
+// checked by ParseRuleContentDir and ValidateRuleContentDir, and reports all problems
+// at once by InvalidContentError. Files are referenced relative to the directory.
+func LintRuleContentDir(contentDirPath string) error {
+	return lintContentDir(osFileSystem{}, contentDirPath)
+}
+
+// LintRuleContent checks rule content in any location supported by ParseRuleContent
+// the same way as LintRuleContentDir. Problems with the location itself, like a bundle
+// that can't be downloaded or that has a wrong checksum, are returned as they are.
+func LintRuleContent(location, checksum string) error {
+	fsys, contentDirPath, err := openContent(location, checksum)
+	if err != nil {
+		return err
+	}
+
+	return lintContentDir(fsys, contentDirPath)
+}
+
+func lintContentDir(fsys fileSystem, contentDirPath string) error {
+	linter := contentLinter{
+		fsys:      fsys,
+		rootPath:  contentDirPath,
+		rulePaths: map[string]string{},
+		contentDir: RuleContentDirectory{
+			Rules: map[string]RuleContent{},
+		},
+	}
+
+	config, err := parseGlobalContentConfig(fsys, path.Join(contentDirPath, "config.yaml"))
+	if err != nil {
+		linter.addFileProblem(path.Join(contentDirPath, "config.yaml"), err)
+	}
+	linter.contentDir.Config = config
+
+	linter.lintRulesInDir(path.Join(contentDirPath, "external"))
+	if _, err := fsys.Stat(path.Join(contentDirPath, "internal")); !os.IsNotExist(err) {
+		linter.lintRulesInDir(path.Join(contentDirPath, "internal"))
+	}
+
+	if err := ValidateRuleContentDir(linter.contentDir); err != nil {
+		linter.problems = append(linter.problems, err.(*InvalidContentError).Problems...)
+	}
+
+	if len(linter.problems) != 0 {
+		return &InvalidContentError{Problems: linter.problems}
+	}
+
+	return nil
+}
+
+// relativePath returns the path relative to the content directory
+func (linter *contentLinter) relativePath(filePath string) string {
+	relativePath, err := filepath.Rel(linter.rootPath, filePath)
+	if err != nil {
+		return filePath
+	}
+
+	return relativePath
+}
+
+func (linter *contentLinter) addFileProblem(filePath string, err error) {
+	filePath = linter.relativePath(filePath)
+
+	switch {
+	case os.IsNotExist(err):
+		linter.problems = append(linter.problems, fmt.Sprintf("%v: does not exist", filePath))
+	case err != nil:
+		linter.problems = append(linter.problems, fmt.Sprintf("%v: %v", filePath, err))
+	}
+}
+
+// readFile reads the file and records a problem if it can't be read
+func (linter *contentLinter) readFile(filePath string) ([]byte, bool) {
+	data, err := linter.fsys.ReadFile(filePath)
+	if err != nil {
+		linter.addFileProblem(filePath, err)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// unmarshalFile reads and parses the YAML file and records a problem if it's not possible
+func (linter *contentLinter) unmarshalFile(filePath string, out interface{}) bool {
+	data, ok := linter.readFile(filePath)
+	if !ok {
+		return false
+	}
+
+	if err := yaml.Unmarshal(data, out); err != nil {
+		linter.addFileProblem(filePath, err)
+		return false
+	}
+
+	return true
+}
+
+// lintRulesInDir walks the directory the same way as parseRulesInDir
+func (linter *contentLinter) lintRulesInDir(dirPath string) {
+	entries, err := linter.fsys.ReadDir(dirPath)
+	if err != nil {
+		linter.addFileProblem(dirPath, err)
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+		subdirPath := path.Join(dirPath, name)
+
+		if isRuleDir(linter.fsys, subdirPath) {
+			linter.lintRule(name, subdirPath)
+		} else {
+			linter.lintRulesInDir(subdirPath)
+		}
+	}
+}
+
+func (linter *contentLinter) lintRule(ruleName, ruleDirPath string) {
+	if otherPath, found := linter.rulePaths[ruleName]; found {
+		linter.problems = append(linter.problems, fmt.Sprintf(
+			"rule '%v' is defined in both %v and %v",
+			ruleName, linter.relativePath(otherPath), linter.relativePath(ruleDirPath),
+		))
+		return
+	}
+	linter.rulePaths[ruleName] = ruleDirPath
+
+	rule := RuleContent{ErrorKeys: map[string]RuleErrorKeyContent{}}
+
+	for _, fileName := range ruleMarkdownFiles {
+		linter.readFile(path.Join(ruleDirPath, fileName))
+	}
+	linter.unmarshalFile(path.Join(ruleDirPath, "plugin.yaml"), &rule.Plugin)
+
+	entries, err := linter.fsys.ReadDir(ruleDirPath)
+	if err != nil {
+		linter.addFileProblem(ruleDirPath, err)
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		errorKeyDirPath := path.Join(ruleDirPath, e.Name())
+		errorKey := RuleErrorKeyContent{}
+
+		linter.readFile(path.Join(errorKeyDirPath, "generic.md"))
+
+		// metadata that can't be parsed would be reported again by the validation
+		if linter.unmarshalFile(path.Join(errorKeyDirPath, "metadata.yaml"), &errorKey.Metadata) {
+			rule.ErrorKeys[e.Name()] = errorKey
+		}
+	}
+
+	linter.contentDir.Rules[ruleName] = rule
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// publishDateLayouts are formats of publish_date accepted in error key metadata
+var publishDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// InvalidContentError is returned when the parsed rule content
+// can't be loaded, it contains all problems that were found
+type InvalidContentError struct {
+	Problems []string
+}
+
+func (e *InvalidContentError) Error() string {
+	return fmt.Sprintf("invalid rule content: %v", strings.Join(e.Problems, "; "))
+}
+
+// ValidateRuleContentDir checks that the parsed rule content can be loaded
+// into the storage. All problems are reported at once by InvalidContentError.
+func ValidateRuleContentDir(contentDir RuleContentDirectory) error {
+	var problems []string
+
+	ruleNames := make([]string, 0, len(contentDir.Rules))
+	for ruleName := range contentDir.Rules {
+		ruleNames = append(ruleNames, ruleName)
+	}
+	// rules are checked in the same order every time to make the problems reproducible
+	sort.Strings(ruleNames)
+
+	rulesByModule := make(map[string]string)
+
+	for _, ruleName := range ruleNames {
+		rule := contentDir.Rules[ruleName]
+
+		if module := rule.Plugin.PythonModule; module != "" {
+			if otherRuleName, found := rulesByModule[module]; found {
+				problems = append(problems, fmt.Sprintf(
+					"rules '%v' and '%v' have the same python module '%v'", otherRuleName, ruleName, module,
+				))
+			}
+			rulesByModule[module] = ruleName
+		}
+
+		problems = append(problems, validateErrorKeys(contentDir.Config, ruleName, rule.ErrorKeys)...)
+	}
+
+	if len(problems) != 0 {
+		return &InvalidContentError{Problems: problems}
+	}
+
+	return nil
+}
+
+func validateErrorKeys(config GlobalRuleConfig, ruleName string, errorKeys map[string]RuleErrorKeyContent) []string {
+	var problems []string
+
+	errorKeyNames := make([]string, 0, len(errorKeys))
+	for errorKeyName := range errorKeys {
+		errorKeyNames = append(errorKeyNames, errorKeyName)
+	}
+	sort.Strings(errorKeyNames)
+
+	for _, errorKeyName := range errorKeyNames {
+		metadata := errorKeys[errorKeyName].Metadata
+
+		switch strings.ToLower(metadata.Status) {
+		case "active", "inactive":
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"rule '%v', error key '%v': invalid status '%v'", ruleName, errorKeyName, metadata.Status,
+			))
+		}
+
+		if _, found := config.Impact[metadata.Impact]; metadata.Impact != "" && !found {
+			problems = append(problems, fmt.Sprintf(
+				"rule '%v', error key '%v': unknown impact '%v'", ruleName, errorKeyName, metadata.Impact,
+			))
+		}
+
+		if !isValidPublishDate(metadata.PublishDate) {
+			problems = append(problems, fmt.Sprintf(
+				"rule '%v', error key '%v': invalid publish_date '%v'", ruleName, errorKeyName, metadata.PublishDate,
+			))
+		}
+
+		if metadata.Likelihood < 0 {
+			problems = append(problems, fmt.Sprintf(
+				"rule '%v', error key '%v': negative likelihood %v", ruleName, errorKeyName, metadata.Likelihood,
+			))
+		}
+	}
+
+	return problems
+}
+
+func isValidPublishDate(publishDate string) bool {
+	_, err := ParsePublishDate(publishDate)
+	return err == nil
+}
+
+// ParsePublishDate parses publish_date of error key metadata in any of the accepted formats
+func ParsePublishDate(publishDate string) (time.Time, error) {
+	var err error
+
+	for _, layout := range publishDateLayouts {
+		var parsed time.Time
+		if parsed, err = time.Parse(layout, publishDate); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, err
+}
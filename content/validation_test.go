@@ -0,0 +1,103 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+)
+
+func TestValidateRuleContentDirOK(t *testing.T) {
+	con, err := content.ParseRuleContentDir("../tests/content/ok/")
+	helpers.FailOnError(t, err)
+
+	assert.NoError(t, content.ValidateRuleContentDir(con))
+	assert.NoError(t, content.ValidateRuleContentDir(testdata.RuleContent3Rules))
+}
+
+func TestValidateRuleContentDirBadStatus(t *testing.T) {
+	con, err := content.ParseRuleContentDir("../tests/content/bad_metadata_status/")
+	helpers.FailOnError(t, err)
+
+	err = content.ValidateRuleContentDir(con)
+	assert.EqualError(t, err, "invalid rule content: rule 'rule1', error key 'err_key': invalid status 'bad-status'")
+}
+
+func TestValidateRuleContentDirAllProblems(t *testing.T) {
+	contentDir := content.RuleContentDirectory{
+		Config: content.GlobalRuleConfig{Impact: map[string]int{"One": 1}},
+		Rules: map[string]content.RuleContent{
+			"rule1": {
+				Plugin: content.RulePluginInfo{PythonModule: "ccx_rules_ocp.external.rules.rule"},
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": {Metadata: content.ErrorKeyMetadata{Impact: "Two", Status: "active", PublishDate: "2020-04-03"}},
+					"ek2": {Metadata: content.ErrorKeyMetadata{
+						Impact: "One", Status: "done", Likelihood: -1, PublishDate: "yesterday",
+					}},
+				},
+			},
+			"rule2": {
+				Plugin: content.RulePluginInfo{PythonModule: "ccx_rules_ocp.external.rules.rule"},
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek": {Metadata: content.ErrorKeyMetadata{
+						Impact: "One", Status: "Inactive", PublishDate: "2020-04-03T16:13:30+02:00",
+					}},
+				},
+			},
+		},
+	}
+
+	err := content.ValidateRuleContentDir(contentDir)
+	assert.Equal(t, &content.InvalidContentError{Problems: []string{
+		"rule 'rule1', error key 'ek1': unknown impact 'Two'",
+		"rule 'rule1', error key 'ek2': invalid status 'done'",
+		"rule 'rule1', error key 'ek2': invalid publish_date 'yesterday'",
+		"rule 'rule1', error key 'ek2': negative likelihood -1",
+		"rules 'rule1' and 'rule2' have the same python module 'ccx_rules_ocp.external.rules.rule'",
+	}}, err)
+}
+
+func TestLintRuleContentDirOK(t *testing.T) {
+	assert.NoError(t, content.LintRuleContentDir("../tests/content/ok/"))
+	assert.NoError(t, content.LintRuleContentDir("../tests/content/ok_no_content/"))
+	assert.NoError(t, content.LintRuleContentDir("../tests/content/internal/"))
+}
+
+func TestLintRuleContentDirAllProblems(t *testing.T) {
+	err := content.LintRuleContentDir("../tests/content/invalid/")
+	assert.Equal(t, &content.InvalidContentError{Problems: []string{
+		"external/rules/rule1/resolution.md: does not exist",
+		"external/rules/rule2/err_key/metadata.yaml: does not exist",
+		"rule 'rule1' is defined in both external/rules/rule1 and external/upgrade/rule1",
+		"rule 'rule1', error key 'err_key': invalid status 'deprecated'",
+		"rule 'rule1', error key 'err_key': unknown impact 'Ten'",
+		"rule 'rule1', error key 'err_key': invalid publish_date 'someday'",
+	}}, err)
+}
+
+func TestLintRuleContentDirMissing(t *testing.T) {
+	err := content.LintRuleContentDir("../tests/content/no_external/")
+	assert.EqualError(t, err, "invalid rule content: external: does not exist")
+
+	err = content.LintRuleContentDir("../tests/content/bad_metadata/")
+	assert.Contains(t, err.Error(), "external/rules/rule1/err_key/metadata.yaml: yaml:")
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-yaml/yaml"
+)
+
+// manifestFileName is the optional file with provenance of the content,
+// it's generated when the content is exported from its repository
+const manifestFileName = "manifest.yaml"
+
+// RuleContentVersion identifies revision of the rule content
+type RuleContentVersion struct {
+	// Hash is SHA-256 of names and contents of all files except the manifest
+	Hash string
+	// Commit is the git commit of the content repository taken from the manifest
+	Commit string
+}
+
+// contentManifest is a Go representation of the `manifest.yaml` file
+type contentManifest struct {
+	Commit string `yaml:"commit"`
+}
+
+// readContentVersion computes the version of content in the directory
+func readContentVersion(fsys fileSystem, contentDirPath string) (RuleContentVersion, error) {
+	hash := sha256.New()
+
+	if err := hashDir(fsys, contentDirPath, "", hash); err != nil {
+		return RuleContentVersion{}, err
+	}
+
+	version := RuleContentVersion{Hash: hex.EncodeToString(hash.Sum(nil))}
+
+	manifestBytes, err := fsys.ReadFile(path.Join(contentDirPath, manifestFileName))
+	if os.IsNotExist(err) {
+		return version, nil
+	} else if err != nil {
+		return RuleContentVersion{}, err
+	}
+
+	manifest := contentManifest{}
+	if err := yaml.Unmarshal(manifestBytes, &manifest); err != nil {
+		return RuleContentVersion{}, err
+	}
+	version.Commit = manifest.Commit
+
+	return version, nil
+}
+
+// hashDir writes names and contents of all files in the directory into the hash.
+// Names are relative to the content directory, so the version doesn't depend
+// on where the content is stored. Entries are sorted by ReadDir.
+func hashDir(fsys fileSystem, dirPath, relativePath string, hash io.Writer) error {
+	entries, err := fsys.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		entryPath := path.Join(relativePath, e.Name())
+
+		if e.IsDir() {
+			if err := hashDir(fsys, path.Join(dirPath, e.Name()), entryPath, hash); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if entryPath == manifestFileName || !e.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := fsys.ReadFile(path.Join(dirPath, e.Name()))
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(hash, "%v\x00%d\x00", entryPath, len(data)); err != nil {
+			return err
+		}
+		if _, err := hash.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Changes summarizes differences between two versions of rule content,
+// rules are identified by names of their directories
+type Changes struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+// Empty returns true if there are no changes
+func (changes Changes) Empty() bool {
+	return len(changes.Added) == 0 && len(changes.Changed) == 0 && len(changes.Removed) == 0
+}
+
+// CompareRuleContentDirs returns rules added, changed and removed in the new content
+func CompareRuleContentDirs(oldContent, newContent RuleContentDirectory) Changes {
+	changes := Changes{
+		Added:   []string{},
+		Changed: []string{},
+		Removed: []string{},
+	}
+
+	// impact of all rules may change with the global config
+	configChanged := !reflect.DeepEqual(oldContent.Config, newContent.Config)
+
+	for ruleName, newRule := range newContent.Rules {
+		oldRule, found := oldContent.Rules[ruleName]
+		switch {
+		case !found:
+			changes.Added = append(changes.Added, ruleName)
+		case configChanged || !reflect.DeepEqual(oldRule, newRule):
+			changes.Changed = append(changes.Changed, ruleName)
+		}
+	}
+
+	for ruleName := range oldContent.Rules {
+		if _, found := newContent.Rules[ruleName]; !found {
+			changes.Removed = append(changes.Removed, ruleName)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+
+	return changes
+}
+
+// Loader loads validated rule content, usually into the storage
+type Loader func(contentDir RuleContentDirectory) error
+
+// Watcher reloads rule content from the directory or bundle when its files change,
+// when the process receives SIGHUP or when Reload is called. The new content
+// is parsed and validated first, so the loaded one is kept if there's any problem.
+//
+// Changes of files are detected by polling, which also works for directories
+// mounted from config maps where files are replaced by swapping symlinks.
+// Bundles downloaded from URL are not polled, because their checksum is fixed
+// by the configuration anyway. For the same reason the bundle already downloaded
+// when the content was loaded is reused instead of downloading it again.
+type Watcher struct {
+	location      string
+	checksum      string
+	load          Loader
+	pollInterval  time.Duration
+	mutex         sync.Mutex
+	current       RuleContentDirectory
+	fingerprint   string
+	stop          chan struct{}
+	stopped       sync.WaitGroup
+	signalChannel chan os.Signal
+}
+
+// NewWatcher constructs watcher of the content location, see ParseRuleContent.
+// The current content is parsed from the location, it should be the one that's
+// already loaded. Polling is disabled if the interval is not positive.
+func NewWatcher(location, checksum string, load Loader, pollInterval time.Duration) (*Watcher, error) {
+	fingerprint, err := fingerprintContent(location, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := ParseRuleContent(location, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		location:     location,
+		checksum:     checksum,
+		load:         load,
+		pollInterval: pollInterval,
+		current:      current,
+		fingerprint:  fingerprint,
+	}, nil
+}
+
+// Reload parses, validates and loads the content if it differs
+// from the current one and returns summary of the changes
+func (watcher *Watcher) Reload() (Changes, error) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	fingerprint, err := fingerprintContent(watcher.location, watcher.checksum)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	return watcher.reload(fingerprint)
+}
+
+// reload has to be called with the mutex locked
+func (watcher *Watcher) reload(fingerprint string) (Changes, error) {
+	newContent, err := ParseRuleContent(watcher.location, watcher.checksum)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to parse rule content, keeping the current one")
+		return Changes{}, err
+	}
+
+	if err := ValidateRuleContentDir(newContent); err != nil {
+		log.Error().Err(err).Msg("Rule content is not valid, keeping the current one")
+		return Changes{}, err
+	}
+
+	changes := CompareRuleContentDirs(watcher.current, newContent)
+	// the version is loaded too, so it's reloaded even if only files not related
+	// to any rule or the manifest changed
+	if changes.Empty() && newContent.Version == watcher.current.Version {
+		log.Info().Msg("Rule content has not changed")
+		watcher.fingerprint = fingerprint
+		return changes, nil
+	}
+
+	if err := watcher.load(newContent); err != nil {
+		log.Error().Err(err).Msg("Unable to load rule content, keeping the current one")
+		return Changes{}, err
+	}
+
+	watcher.current = newContent
+	watcher.fingerprint = fingerprint
+
+	log.Info().
+		Str("version", newContent.Version.Hash).
+		Strs("added", changes.Added).
+		Strs("changed", changes.Changed).
+		Strs("removed", changes.Removed).
+		Msgf(
+			"Rule content reloaded: %d added, %d changed, %d removed",
+			len(changes.Added), len(changes.Changed), len(changes.Removed),
+		)
+
+	return changes, nil
+}
+
+// reloadIfModified reloads the content if any file in the directory was modified
+func (watcher *Watcher) reloadIfModified() {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	fingerprint, err := fingerprintContent(watcher.location, watcher.checksum)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to check rule content directory")
+		return
+	}
+
+	if fingerprint == watcher.fingerprint {
+		return
+	}
+
+	log.Info().Msg("Rule content directory was modified")
+	if _, err := watcher.reload(fingerprint); err != nil {
+		// the same content isn't reloaded again until it's modified
+		watcher.fingerprint = fingerprint
+	}
+}
+
+// Start starts watching the directory and SIGHUP signal in background
+func (watcher *Watcher) Start() {
+	watcher.stop = make(chan struct{})
+	watcher.signalChannel = make(chan os.Signal, 1)
+	signal.Notify(watcher.signalChannel, syscall.SIGHUP)
+
+	watcher.stopped.Add(1)
+	go func() {
+		defer watcher.stopped.Done()
+
+		// nil channel blocks forever, so polling is disabled
+		var ticks <-chan time.Time
+		if watcher.pollInterval > 0 {
+			ticker := time.NewTicker(watcher.pollInterval)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+
+		for {
+			select {
+			case <-watcher.stop:
+				return
+			case <-watcher.signalChannel:
+				log.Info().Msg("SIGHUP received, reloading rule content")
+				_, _ = watcher.Reload()
+			case <-ticks:
+				watcher.reloadIfModified()
+			}
+		}
+	}()
+}
+
+// Stop stops watching started by Start
+func (watcher *Watcher) Stop() {
+	if watcher.stop == nil {
+		return
+	}
+
+	signal.Stop(watcher.signalChannel)
+	close(watcher.stop)
+	watcher.stopped.Wait()
+	watcher.stop = nil
+}
+
+// fingerprintContent returns hash of paths, sizes and modification times of all files
+// in the directory (or of the bundle file), URLs are identified just by the checksum
+func fingerprintContent(location, checksum string) (string, error) {
+	hash := sha256.New()
+
+	if isURL(location) {
+		_, err := fmt.Fprintf(hash, "%v|%v\n", location, checksum)
+		return hex.EncodeToString(hash.Sum(nil)), err
+	}
+
+	err := filepath.Walk(location, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(hash, "%v|%v|%v|%v\n", filePath, info.Size(), info.ModTime().UnixNano(), info.Mode())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,262 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+const (
+	rule1Path   = "external/rules/rule1"
+	loadTimeout = 5 * time.Second
+)
+
+// mustCopyContentDir copies the content directory into new temporary directory
+func mustCopyContentDir(t *testing.T, sourceDir string) (string, func()) {
+	targetDir, err := ioutil.TempDir("", "content")
+	helpers.FailOnError(t, err)
+
+	err = filepath.Walk(sourceDir, func(sourcePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(sourceDir, sourcePath)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(targetDir, relativePath)
+
+		if info.IsDir() {
+			return os.MkdirAll(targetPath, info.Mode())
+		}
+
+		data, err := ioutil.ReadFile(sourcePath)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(targetPath, data, info.Mode())
+	})
+	helpers.FailOnError(t, err)
+
+	return targetDir, func() {
+		_ = os.RemoveAll(targetDir)
+	}
+}
+
+func mustWriteFile(t *testing.T, path, data string) {
+	helpers.FailOnError(t, ioutil.WriteFile(path, []byte(data), 0644))
+}
+
+// loaderMock records all loaded content
+type loaderMock struct {
+	loaded chan content.RuleContentDirectory
+	err    error
+}
+
+func newLoaderMock() *loaderMock {
+	return &loaderMock{loaded: make(chan content.RuleContentDirectory, 10)}
+}
+
+func (loader *loaderMock) load(contentDir content.RuleContentDirectory) error {
+	if loader.err != nil {
+		return loader.err
+	}
+
+	loader.loaded <- contentDir
+	return nil
+}
+
+func (loader *loaderMock) mustBeLoaded(t *testing.T) content.RuleContentDirectory {
+	select {
+	case contentDir := <-loader.loaded:
+		return contentDir
+	case <-time.After(loadTimeout):
+		t.Fatal("content was not loaded")
+		return content.RuleContentDirectory{}
+	}
+}
+
+func mustGetWatcher(t *testing.T, contentDir string, loader *loaderMock, interval time.Duration) *content.Watcher {
+	watcher, err := content.NewWatcher(contentDir, "", loader.load, interval)
+	helpers.FailOnError(t, err)
+
+	return watcher
+}
+
+func TestCompareRuleContentDirs(t *testing.T) {
+	oldContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept":    {Summary: []byte("summary")},
+			"changed": {Summary: []byte("summary")},
+			"removed": {Summary: []byte("summary")},
+		},
+	}
+	newContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept":    {Summary: []byte("summary")},
+			"changed": {Summary: []byte("new summary")},
+			"added":   {Summary: []byte("summary")},
+		},
+	}
+
+	assert.Equal(t, content.Changes{
+		Added:   []string{"added"},
+		Changed: []string{"changed"},
+		Removed: []string{"removed"},
+	}, content.CompareRuleContentDirs(oldContent, newContent))
+
+	// impact dictionary can change total risk of any rule
+	newContent.Config.Impact = map[string]int{"One": 1}
+	assert.Equal(t, []string{"changed", "kept"}, content.CompareRuleContentDirs(oldContent, newContent).Changed)
+
+	assert.True(t, content.CompareRuleContentDirs(oldContent, oldContent).Empty())
+}
+
+func TestNewWatcherInvalidDir(t *testing.T) {
+	_, err := content.NewWatcher("../tests/content/not-a-real-dir", "", newLoaderMock().load, 0)
+	assert.Error(t, err)
+}
+
+func TestWatcherReload(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	watcher := mustGetWatcher(t, contentDir, loader, 0)
+
+	// nothing has changed
+	changes, err := watcher.Reload()
+	helpers.FailOnError(t, err)
+	assert.True(t, changes.Empty())
+	assert.Empty(t, loader.loaded)
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "summary.md"), "new summary")
+
+	changes, err = watcher.Reload()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, content.Changes{Added: []string{}, Changed: []string{"rule1"}, Removed: []string{}}, changes)
+	assert.Equal(t, []byte("new summary"), loader.mustBeLoaded(t).Rules["rule1"].Summary)
+
+	helpers.FailOnError(t, os.Rename(
+		filepath.Join(contentDir, rule1Path), filepath.Join(contentDir, "external/rules/rule2"),
+	))
+
+	changes, err = watcher.Reload()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, content.Changes{Added: []string{"rule2"}, Changed: []string{}, Removed: []string{"rule1"}}, changes)
+	assert.Contains(t, loader.mustBeLoaded(t).Rules, "rule2")
+}
+
+func TestWatcherReloadInvalidContent(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	watcher := mustGetWatcher(t, contentDir, loader, 0)
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "err_key/metadata.yaml"), `status: "unknown"`)
+
+	_, err := watcher.Reload()
+	assert.IsType(t, &content.InvalidContentError{}, err)
+
+	helpers.FailOnError(t, os.Remove(filepath.Join(contentDir, rule1Path, "summary.md")))
+
+	_, err = watcher.Reload()
+	assert.Error(t, err)
+
+	assert.Empty(t, loader.loaded)
+}
+
+func TestWatcherReloadLoadError(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	loader.err = fmt.Errorf("database is down")
+	watcher := mustGetWatcher(t, contentDir, loader, 0)
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "summary.md"), "new summary")
+
+	_, err := watcher.Reload()
+	assert.EqualError(t, err, "database is down")
+
+	// the content is still considered changed
+	loader.err = nil
+
+	changes, err := watcher.Reload()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []string{"rule1"}, changes.Changed)
+}
+
+func TestWatcherPolling(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	watcher := mustGetWatcher(t, contentDir, loader, 10*time.Millisecond)
+	watcher.Start()
+	defer watcher.Stop()
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "summary.md"), "new summary")
+
+	assert.Equal(t, []byte("new summary"), loader.mustBeLoaded(t).Rules["rule1"].Summary)
+}
+
+func TestWatcherSIGHUP(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	watcher := mustGetWatcher(t, contentDir, loader, 0)
+	watcher.Start()
+	defer watcher.Stop()
+
+	mustWriteFile(t, filepath.Join(contentDir, rule1Path, "summary.md"), "new summary")
+
+	helpers.FailOnError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Equal(t, []byte("new summary"), loader.mustBeLoaded(t).Rules["rule1"].Summary)
+}
+
+func TestWatcherReloadVersionChange(t *testing.T) {
+	contentDir, cleanup := mustCopyContentDir(t, "../tests/content/ok/")
+	defer cleanup()
+
+	loader := newLoaderMock()
+	watcher := mustGetWatcher(t, contentDir, loader, 0)
+
+	// no rule has changed, but the new version has to be loaded
+	mustWriteFile(t, filepath.Join(contentDir, "manifest.yaml"), `commit: "0123abcd"`)
+
+	changes, err := watcher.Reload()
+	helpers.FailOnError(t, err)
+	assert.True(t, changes.Empty())
+	assert.Equal(t, "0123abcd", loader.mustBeLoaded(t).Version.Commit)
+}
@@ -0,0 +1,513 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dump contains export of reports, feedback, rule toggles, rule disables and rule content
+// from a storage into a portable file and import of the file into another storage. The file
+// contains one JSON record per line, it's compressed by gzip when its name ends with .gz.
+package dump
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// FormatVersion is the version of the file format written by Export,
+// files of other versions are refused by Import
+const FormatVersion = 1
+
+// record kinds, the header is always the first record and the rule content
+// (when exported) precedes the other records, because loading it removes
+// the existing feedback
+const (
+	kindHeader      = "header"
+	kindRuleContent = "rule_content"
+	kindReport      = "report"
+	kindFeedback    = "feedback"
+	kindToggle      = "toggle"
+	kindRuleDisable = "rule_disable"
+)
+
+// Filter selects the clusters whose data are exported, the zero value selects all of them
+type Filter struct {
+	OrgID       types.OrgID
+	ClusterName types.ClusterName
+}
+
+// Summary contains numbers of the exported or imported items
+type Summary struct {
+	RuleContent    bool
+	Reports        int
+	SkippedReports int
+	Feedback       int
+	Toggles        int
+	RuleDisables   int
+}
+
+// UnsupportedFileError is returned by Import when the file doesn't start with
+// the header or its format version is not supported
+type UnsupportedFileError struct {
+	reason string
+}
+
+func (e *UnsupportedFileError) Error() string {
+	return fmt.Sprintf("unsupported dump file: %v", e.reason)
+}
+
+// record is a single line of the file, data depend on the kind of the record
+type record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type header struct {
+	FormatVersion int               `json:"format_version"`
+	ExportedAt    time.Time         `json:"exported_at"`
+	OrgID         types.OrgID       `json:"org_id,omitempty"`
+	ClusterName   types.ClusterName `json:"cluster,omitempty"`
+}
+
+type report struct {
+	OrgID         types.OrgID         `json:"org_id"`
+	ClusterName   types.ClusterName   `json:"cluster"`
+	Report        types.ClusterReport `json:"report"`
+	LastCheckedAt time.Time           `json:"last_checked_at"`
+}
+
+// feedback and toggle records don't contain timestamps, they're set when imported
+type feedback struct {
+	ClusterName types.ClusterName `json:"cluster"`
+	RuleID      types.RuleID      `json:"rule_id"`
+	UserID      types.UserID      `json:"user_id"`
+	UserVote    types.UserVote    `json:"user_vote"`
+	Message     string            `json:"message"`
+}
+
+type toggle struct {
+	ClusterName types.ClusterName  `json:"cluster"`
+	RuleID      types.RuleID       `json:"rule_id"`
+	UserID      types.UserID       `json:"user_id"`
+	Disabled    storage.RuleToggle `json:"disabled"`
+}
+
+// ruleDisable is the rule disabled on all clusters of the organization,
+// for all its users if the user is empty
+type ruleDisable struct {
+	OrgID  types.OrgID  `json:"org_id"`
+	RuleID types.RuleID `json:"rule_id"`
+	UserID types.UserID `json:"user_id,omitempty"`
+}
+
+// writeRecord writes the record of the kind with the data as a single line
+func writeRecord(encoder *json.Encoder, kind string, data interface{}) error {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Encode(record{Kind: kind, Data: rawData})
+}
+
+// cluster identifies the exported cluster
+type cluster struct {
+	orgID       types.OrgID
+	clusterName types.ClusterName
+}
+
+// exportedClusters returns the clusters selected by the filter
+func exportedClusters(dbStorage storage.Storage, filter Filter) ([]cluster, error) {
+	if filter.ClusterName != "" {
+		orgID, err := dbStorage.GetOrgIDByClusterID(filter.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.OrgID != 0 && filter.OrgID != orgID {
+			return nil, &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v", filter.OrgID, filter.ClusterName)}
+		}
+
+		return []cluster{{orgID: orgID, clusterName: filter.ClusterName}}, nil
+	}
+
+	orgIDs := []types.OrgID{filter.OrgID}
+	if filter.OrgID == 0 {
+		var err error
+		if orgIDs, err = dbStorage.ListOfOrgs(); err != nil {
+			return nil, err
+		}
+	}
+
+	var clusters []cluster
+	for _, orgID := range orgIDs {
+		clusterNames, err := dbStorage.ListOfClustersForOrg(orgID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, clusterName := range clusterNames {
+			clusters = append(clusters, cluster{orgID: orgID, clusterName: clusterName})
+		}
+	}
+
+	return clusters, nil
+}
+
+// exportedOrganizations returns the organizations of the exported clusters,
+// the organization selected by the filter is returned even if it has no clusters
+func exportedOrganizations(clusters []cluster, filter Filter) []types.OrgID {
+	var orgIDs []types.OrgID
+	seen := make(map[types.OrgID]bool)
+
+	if filter.OrgID != 0 {
+		orgIDs = append(orgIDs, filter.OrgID)
+		seen[filter.OrgID] = true
+	}
+
+	for _, exported := range clusters {
+		if !seen[exported.orgID] {
+			orgIDs = append(orgIDs, exported.orgID)
+			seen[exported.orgID] = true
+		}
+	}
+
+	return orgIDs
+}
+
+// exportRuleDisables writes the rules disabled on all clusters of the organization
+func exportRuleDisables(dbStorage storage.Storage, encoder *json.Encoder, orgID types.OrgID, summary *Summary) error {
+	disables, err := dbStorage.ListRuleDisablesForOrg(orgID)
+	if err != nil {
+		return err
+	}
+
+	for _, disable := range disables {
+		err := writeRecord(encoder, kindRuleDisable, ruleDisable{
+			OrgID:  disable.OrgID,
+			RuleID: disable.RuleID,
+			UserID: disable.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		summary.RuleDisables++
+	}
+
+	return nil
+}
+
+// exportCluster writes the report of the cluster together with feedback and toggles of its rules
+func exportCluster(
+	dbStorage storage.Storage, encoder *json.Encoder, orgID types.OrgID, clusterName types.ClusterName, summary *Summary,
+) error {
+	clusterReport, lastChecked, err := dbStorage.ReadReportForCluster(orgID, clusterName)
+	if err != nil {
+		return err
+	}
+
+	lastCheckedAt, err := time.Parse(time.RFC3339, string(lastChecked))
+	if err != nil {
+		return err
+	}
+
+	err = writeRecord(encoder, kindReport, report{
+		OrgID:         orgID,
+		ClusterName:   clusterName,
+		Report:        clusterReport,
+		LastCheckedAt: lastCheckedAt,
+	})
+	if err != nil {
+		return err
+	}
+	summary.Reports++
+
+	feedbacks, err := dbStorage.ListFeedbackForCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	for _, userFeedback := range feedbacks {
+		err := writeRecord(encoder, kindFeedback, feedback{
+			ClusterName: userFeedback.ClusterID,
+			RuleID:      userFeedback.RuleID,
+			UserID:      userFeedback.UserID,
+			UserVote:    userFeedback.UserVote,
+			Message:     userFeedback.Message,
+		})
+		if err != nil {
+			return err
+		}
+		summary.Feedback++
+	}
+
+	toggles, err := dbStorage.ListTogglesForCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	for _, ruleToggle := range toggles {
+		err := writeRecord(encoder, kindToggle, toggle{
+			ClusterName: ruleToggle.ClusterID,
+			RuleID:      ruleToggle.RuleID,
+			UserID:      ruleToggle.UserID,
+			Disabled:    ruleToggle.Disabled,
+		})
+		if err != nil {
+			return err
+		}
+		summary.Toggles++
+	}
+
+	return nil
+}
+
+// Export writes the loaded rule content and data of the clusters selected by the filter,
+// i.e. their reports together with feedback and toggles of their rules, and the rules
+// disabled on all clusters of their organizations
+func Export(dbStorage storage.Storage, writer io.Writer, filter Filter) (Summary, error) {
+	var summary Summary
+	encoder := json.NewEncoder(writer)
+
+	err := writeRecord(encoder, kindHeader, header{
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		OrgID:         filter.OrgID,
+		ClusterName:   filter.ClusterName,
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	contentDir, err := dbStorage.ReadRuleContent()
+	if err != nil {
+		return summary, err
+	}
+
+	// the content is not written when there is none, so it's not replaced by an empty one when imported
+	if len(contentDir.Rules) > 0 {
+		if err := writeRecord(encoder, kindRuleContent, contentDir); err != nil {
+			return summary, err
+		}
+		summary.RuleContent = true
+	}
+
+	clusters, err := exportedClusters(dbStorage, filter)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, exported := range clusters {
+		if err := exportCluster(dbStorage, encoder, exported.orgID, exported.clusterName, &summary); err != nil {
+			return summary, err
+		}
+	}
+
+	for _, orgID := range exportedOrganizations(clusters, filter) {
+		if err := exportRuleDisables(dbStorage, encoder, orgID, &summary); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+// importRecord writes data of the record into the storage
+func importRecord(dbStorage storage.Storage, rec record, summary *Summary) error {
+	switch rec.Kind {
+	case kindRuleContent:
+		var contentDir content.RuleContentDirectory
+		if err := json.Unmarshal(rec.Data, &contentDir); err != nil {
+			return err
+		}
+
+		if err := dbStorage.LoadRuleContent(contentDir); err != nil {
+			return err
+		}
+		summary.RuleContent = true
+	case kindReport:
+		var clusterReport report
+		if err := json.Unmarshal(rec.Data, &clusterReport); err != nil {
+			return err
+		}
+
+		// the Kafka offset of the report is not known
+		err := dbStorage.WriteReportForCluster(
+			clusterReport.OrgID, clusterReport.ClusterName, clusterReport.Report, clusterReport.LastCheckedAt, 0,
+		)
+		if err == types.ErrOldReport {
+			log.Warn().Msgf("Report of cluster %v is older than the stored one, skipping it", clusterReport.ClusterName)
+			summary.SkippedReports++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		summary.Reports++
+	case kindFeedback:
+		var userFeedback feedback
+		if err := json.Unmarshal(rec.Data, &userFeedback); err != nil {
+			return err
+		}
+
+		err := dbStorage.VoteOnRule(userFeedback.ClusterName, userFeedback.RuleID, userFeedback.UserID, userFeedback.UserVote)
+		if err != nil {
+			return err
+		}
+
+		if userFeedback.Message != "" {
+			err := dbStorage.AddOrUpdateFeedbackOnRule(
+				userFeedback.ClusterName, userFeedback.RuleID, userFeedback.UserID, userFeedback.Message,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		summary.Feedback++
+	case kindToggle:
+		var ruleToggle toggle
+		if err := json.Unmarshal(rec.Data, &ruleToggle); err != nil {
+			return err
+		}
+
+		err := dbStorage.ToggleRuleForCluster(ruleToggle.ClusterName, ruleToggle.RuleID, ruleToggle.UserID, ruleToggle.Disabled)
+		if err != nil {
+			return err
+		}
+		summary.Toggles++
+	case kindRuleDisable:
+		var disable ruleDisable
+		if err := json.Unmarshal(rec.Data, &disable); err != nil {
+			return err
+		}
+
+		if err := dbStorage.DisableRuleForOrg(disable.OrgID, disable.RuleID, disable.UserID); err != nil {
+			return err
+		}
+		summary.RuleDisables++
+	default:
+		return &UnsupportedFileError{reason: fmt.Sprintf("unknown record kind '%v'", rec.Kind)}
+	}
+
+	return nil
+}
+
+// Import writes data read from the file written by Export into the storage. The rule content
+// (if present in the file) replaces the loaded one. Reports older than the stored ones are skipped.
+// Records are imported one by one, the ones imported before an error are kept.
+func Import(dbStorage storage.Storage, reader io.Reader) (Summary, error) {
+	var summary Summary
+	decoder := json.NewDecoder(reader)
+
+	var rec record
+	if err := decoder.Decode(&rec); err != nil {
+		return summary, err
+	}
+
+	var fileHeader header
+	if rec.Kind != kindHeader {
+		return summary, &UnsupportedFileError{reason: "header is missing"}
+	}
+	if err := json.Unmarshal(rec.Data, &fileHeader); err != nil {
+		return summary, err
+	}
+	if fileHeader.FormatVersion != FormatVersion {
+		return summary, &UnsupportedFileError{reason: fmt.Sprintf("format version %v", fileHeader.FormatVersion)}
+	}
+
+	for {
+		var rec record
+		err := decoder.Decode(&rec)
+		if err == io.EOF {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		if err := importRecord(dbStorage, rec, &summary); err != nil {
+			return summary, err
+		}
+	}
+}
+
+// isCompressed returns whether the file is compressed according to its name
+func isCompressed(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// ExportFile exports the data selected by the filter into the file, see Export
+func ExportFile(dbStorage storage.Storage, path string, filter Filter) (Summary, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	var writer io.Writer = file
+	var gzipWriter *gzip.Writer
+	if isCompressed(path) {
+		gzipWriter = gzip.NewWriter(file)
+		writer = gzipWriter
+	}
+
+	summary, err := Export(dbStorage, writer, filter)
+
+	if gzipWriter != nil {
+		if closeErr := gzipWriter.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return summary, err
+}
+
+// ImportFile imports the data from the file written by ExportFile, see Import
+func ImportFile(dbStorage storage.Storage, path string) (Summary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var reader io.Reader = file
+	if isCompressed(path) {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return Summary{}, err
+		}
+		defer func() {
+			_ = gzipReader.Close()
+		}()
+
+		reader = gzipReader
+	}
+
+	return Import(dbStorage, reader)
+}
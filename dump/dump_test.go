@@ -0,0 +1,188 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dump_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/dump"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteClusters writes content and reports of two organizations with a vote, a toggle
+// and rule disables for the cluster of testdata.OrgID and returns the other cluster
+func mustWriteClusters(t *testing.T, s storage.Storage) types.ClusterName {
+	helpers.FailOnError(t, s.LoadRuleContent(testdata.RuleContent3Rules))
+
+	otherCluster := testdata.GetRandomClusterID()
+	helpers.FailOnError(t, s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	))
+	helpers.FailOnError(t, s.WriteReportForCluster(
+		testdata.OrgID+1, otherCluster, testdata.Report2Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	))
+
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteDislike))
+	helpers.FailOnError(t, s.AddOrUpdateFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, "message"))
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule2ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule3ID, ""))
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID))
+
+	return otherCluster
+}
+
+func TestExportAndImportFile(t *testing.T) {
+	dbStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	otherCluster := mustWriteClusters(t, dbStorage)
+
+	dir, err := ioutil.TempDir("", "dump")
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, os.RemoveAll(dir)) }()
+	path := filepath.Join(dir, "dump.jsonl.gz")
+
+	summary, err := dump.ExportFile(dbStorage, path, dump.Filter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, dump.Summary{RuleContent: true, Reports: 2, Feedback: 1, Toggles: 1, RuleDisables: 2}, summary)
+
+	// the data are imported into a storage of another kind
+	memoryStorage := storage.NewMemoryStorage()
+
+	summary, err = dump.ImportFile(memoryStorage, path)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, dump.Summary{RuleContent: true, Reports: 2, Feedback: 1, Toggles: 1, RuleDisables: 2}, summary)
+
+	report, lastChecked, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339)), lastChecked)
+
+	report, _, err = memoryStorage.ReadReportForCluster(testdata.OrgID+1, otherCluster)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+
+	feedback, err := memoryStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteDislike, feedback.UserVote)
+	assert.Equal(t, "message", feedback.Message)
+
+	toggle, err := memoryStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule2ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+
+	disables, err := memoryStorage.ListRuleDisablesForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disables, 2)
+	assert.Equal(t, testdata.Rule1ID, disables[0].RuleID)
+	assert.Equal(t, testdata.UserID, disables[0].UserID)
+	assert.Equal(t, testdata.Rule3ID, disables[1].RuleID)
+	assert.Equal(t, storage.RuleDisableScopeOrganization, disables[1].Scope())
+
+	rule, err := memoryStorage.GetRuleWithContent(testdata.Rule1ID, testdata.ErrorKey1, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Rule1Description, rule.Description)
+	assert.Equal(t, 3, rule.TotalRisk)
+}
+
+func TestExportFilter(t *testing.T) {
+	dbStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	otherCluster := mustWriteClusters(t, dbStorage)
+
+	for _, filter := range []dump.Filter{
+		{OrgID: testdata.OrgID + 1},
+		{ClusterName: otherCluster},
+		{OrgID: testdata.OrgID + 1, ClusterName: otherCluster},
+	} {
+		var buffer bytes.Buffer
+		summary, err := dump.Export(dbStorage, &buffer, filter)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, dump.Summary{RuleContent: true, Reports: 1}, summary, filter)
+
+		memoryStorage := storage.NewMemoryStorage()
+		_, err = dump.Import(memoryStorage, &buffer)
+		helpers.FailOnError(t, err)
+
+		clusters, err := memoryStorage.ListOfClustersForOrg(testdata.OrgID + 1)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterName{otherCluster}, clusters)
+
+		count, err := memoryStorage.ReportsCount()
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 1, count)
+	}
+
+	// the cluster doesn't belong to the organization
+	_, err := dump.Export(dbStorage, ioutil.Discard, dump.Filter{OrgID: testdata.OrgID, ClusterName: otherCluster})
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestImportSkipsOlderReport(t *testing.T) {
+	dbStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClusters(t, dbStorage)
+
+	var buffer bytes.Buffer
+	_, err := dump.Export(dbStorage, &buffer, dump.Filter{OrgID: testdata.OrgID})
+	helpers.FailOnError(t, err)
+
+	memoryStorage := storage.NewMemoryStorage()
+	helpers.FailOnError(t, memoryStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	))
+
+	summary, err := dump.Import(memoryStorage, &buffer)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, dump.Summary{RuleContent: true, SkippedReports: 1, Feedback: 1, Toggles: 1, RuleDisables: 2}, summary)
+
+	report, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report0Rules, report)
+}
+
+func TestImportUnsupportedFile(t *testing.T) {
+	for _, file := range []string{
+		`{"kind": "report", "data": {}}`,
+		`{"kind": "header", "data": {"format_version": 2}}`,
+		`{"kind": "header", "data": {"format_version": 1}}
+		{"kind": "webhook", "data": {}}`,
+	} {
+		_, err := dump.Import(storage.NewMemoryStorage(), strings.NewReader(file))
+		assert.IsType(t, &dump.UnsupportedFileError{}, err, file)
+	}
+
+	_, err := dump.Import(storage.NewMemoryStorage(), strings.NewReader("not a JSON"))
+	assert.Error(t, err)
+
+	_, err = dump.ImportFile(storage.NewMemoryStorage(), "/non/existing/dump.jsonl")
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package events contains a simple in-process publish/subscribe bus used to
+// notify REST API clients about changes made by the consumer or by other
+// clients. Subscriptions are made per organization, every subscriber receives
+// only events related to clusters belonging to its organization.
+//
+// Publishing never blocks: when the subscriber is not able to keep up, the
+// event is dropped for that subscriber.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// DefaultSubscriptionBufferSize is the number of events which can be queued
+// for one subscriber before new events start to be dropped
+const DefaultSubscriptionBufferSize = 64
+
+// EventType represents the kind of change the event notifies about
+type EventType string
+
+const (
+	// ReportStored is published when the consumer stores a new report for a cluster
+	ReportStored EventType = "report_stored"
+	// RuleToggled is published when a rule is disabled or enabled for a cluster
+	RuleToggled EventType = "rule_toggled"
+	// RuleVoted is published when a user votes for a rule hit on a cluster
+	RuleVoted EventType = "rule_voted"
+)
+
+// Event is a notification about a change related to one cluster
+type Event struct {
+	Type        EventType         `json:"type"`
+	OrgID       types.OrgID       `json:"org_id"`
+	ClusterName types.ClusterName `json:"cluster"`
+	RuleID      types.RuleID      `json:"rule_id,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// Subscription receives events published for one organization
+type Subscription struct {
+	orgID  types.OrgID
+	events chan Event
+}
+
+// Events returns the channel the events are delivered to. The channel is
+// closed when the subscription is cancelled or the bus is closed.
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+// Bus delivers published events to all subscribers of the event's organization.
+// All methods can be called on nil *Bus, which makes the bus optional for its users.
+type Bus struct {
+	mutex       sync.RWMutex
+	bufferSize  int
+	subscribers map[types.OrgID]map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBus constructs new event bus
+func NewBus() *Bus {
+	return &Bus{
+		bufferSize:  DefaultSubscriptionBufferSize,
+		subscribers: map[types.OrgID]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe creates new subscription for events of specified organization.
+// Returns nil if the bus is nil or has been already closed.
+func (bus *Bus) Subscribe(orgID types.OrgID) *Subscription {
+	if bus == nil {
+		return nil
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if bus.closed {
+		return nil
+	}
+
+	subscription := &Subscription{
+		orgID:  orgID,
+		events: make(chan Event, bus.bufferSize),
+	}
+
+	if _, found := bus.subscribers[orgID]; !found {
+		bus.subscribers[orgID] = map[*Subscription]struct{}{}
+	}
+	bus.subscribers[orgID][subscription] = struct{}{}
+
+	return subscription
+}
+
+// Unsubscribe cancels the subscription and closes its channel
+func (bus *Bus) Unsubscribe(subscription *Subscription) {
+	if bus == nil || subscription == nil {
+		return
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	orgSubscribers, found := bus.subscribers[subscription.orgID]
+	if !found {
+		return
+	}
+
+	if _, found := orgSubscribers[subscription]; !found {
+		return
+	}
+
+	delete(orgSubscribers, subscription)
+	close(subscription.events)
+
+	if len(orgSubscribers) == 0 {
+		delete(bus.subscribers, subscription.orgID)
+	}
+}
+
+// Publish sends the event to all subscribers of its organization. Timestamp is
+// filled in if it's not set already.
+func (bus *Bus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for subscription := range bus.subscribers[event.OrgID] {
+		select {
+		case subscription.events <- event:
+		default:
+			log.Warn().
+				Uint32("org_id", uint32(event.OrgID)).
+				Str("event_type", string(event.Type)).
+				Msg("Subscriber is not able to receive events, dropping event")
+		}
+	}
+}
+
+// Close cancels all subscriptions, events published afterwards are ignored
+func (bus *Bus) Close() {
+	if bus == nil {
+		return
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for orgID, orgSubscribers := range bus.subscribers {
+		for subscription := range orgSubscribers {
+			close(subscription.events)
+		}
+		delete(bus.subscribers, orgID)
+	}
+
+	bus.closed = true
+}
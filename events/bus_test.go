@@ -0,0 +1,114 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events_test
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+)
+
+func init() {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+}
+
+func TestBusPublishToSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+
+	bus.Publish(events.Event{
+		Type:        events.ReportStored,
+		OrgID:       testdata.OrgID,
+		ClusterName: testdata.ClusterName,
+	})
+
+	event := <-subscription.Events()
+	assert.Equal(t, events.ReportStored, event.Type)
+	assert.Equal(t, testdata.ClusterName, event.ClusterName)
+	assert.False(t, event.Timestamp.IsZero())
+}
+
+func TestBusPublishOnlyToSameOrg(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+	otherSubscription := bus.Subscribe(testdata.OrgID + 1)
+
+	bus.Publish(events.Event{Type: events.RuleVoted, OrgID: testdata.OrgID + 1})
+
+	assert.Len(t, subscription.Events(), 0)
+	assert.Len(t, otherSubscription.Events(), 1)
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+	bus.Unsubscribe(subscription)
+
+	// second call is no-op
+	bus.Unsubscribe(subscription)
+
+	_, ok := <-subscription.Events()
+	assert.False(t, ok, "channel should be closed")
+
+	// nothing to deliver to, shouldn't panic
+	bus.Publish(events.Event{Type: events.RuleToggled, OrgID: testdata.OrgID})
+}
+
+func TestBusDropsEventsForSlowSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+
+	for i := 0; i < events.DefaultSubscriptionBufferSize+1; i++ {
+		bus.Publish(events.Event{Type: events.ReportStored, OrgID: testdata.OrgID})
+	}
+
+	assert.Len(t, subscription.Events(), events.DefaultSubscriptionBufferSize)
+}
+
+func TestBusClose(t *testing.T) {
+	bus := events.NewBus()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+	bus.Close()
+
+	_, ok := <-subscription.Events()
+	assert.False(t, ok, "channel should be closed")
+
+	assert.Nil(t, bus.Subscribe(testdata.OrgID))
+	bus.Publish(events.Event{Type: events.ReportStored, OrgID: testdata.OrgID})
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *events.Bus
+
+	assert.Nil(t, bus.Subscribe(testdata.OrgID))
+	bus.Publish(events.Event{Type: events.ReportStored, OrgID: testdata.OrgID})
+	bus.Unsubscribe(nil)
+	bus.Close()
+}
@@ -22,22 +22,31 @@ package main
 // https://medium.com/@robiplus/golang-trick-export-for-test-aa16cbd7b8cd
 // to see why this trick is needed.
 var (
-	CreateStorage         = createStorage
-	StartService          = startService
-	StopService           = stopService
-	WaitForServiceToStart = waitForServiceToStart
-	CloseStorage          = closeStorage
-	PrepareDB             = prepareDB
-	StartConsumer         = startConsumer
-	StartServer           = startServer
-	PrintVersionInfo      = printVersionInfo
-	PrintHelp             = printHelp
-	PrintConfig           = printConfig
-	PrintEnv              = printEnv
-	GetDBForMigrations    = getDBForMigrations
-	PrintMigrationInfo    = printMigrationInfo
-	SetMigrationVersion   = setMigrationVersion
-	PerformMigrations     = performMigrations
-	AutoMigratePtr        = &autoMigrate
-	Main                  = main
+	CreateStorage           = createStorage
+	CreateServiceStorage    = createServiceStorage
+	StartService            = startService
+	StopService             = stopService
+	WaitForServiceToStart   = waitForServiceToStart
+	CloseStorage            = closeStorage
+	WrapStorage             = wrapStorage
+	PrepareDB               = prepareDB
+	StartConsumer           = startConsumer
+	StartServer             = startServer
+	PrintVersionInfo        = printVersionInfo
+	PrintContentVersionInfo = printContentVersionInfo
+	PrintHelp               = printHelp
+	PrintConfig             = printConfig
+	PrintEnv                = printEnv
+	GetDBForMigrations      = getDBForMigrations
+	PrintMigrationInfo      = printMigrationInfo
+	SetMigrationVersion     = setMigrationVersion
+	PerformMigrations       = performMigrations
+	ValidateContent         = validateContent
+	PurgeStale              = purgeStale
+	PurgeOrganization       = purgeOrganization
+	ParseExportArgs         = parseExportArgs
+	ExportData              = exportData
+	ImportData              = importData
+	AutoMigratePtr          = &autoMigrate
+	Main                    = main
 )
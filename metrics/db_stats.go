@@ -0,0 +1,138 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBStatsSource provides statistics of a connection pool, it's implemented by sql.DB
+type DBStatsSource interface {
+	Stats() sql.DBStats
+}
+
+// DBStatsCollector exposes statistics of the connection pools of the databases, labelled by their
+// names (e.g. primary or replica). The sources can be set and removed while it's registered.
+type DBStatsCollector struct {
+	mutex   sync.RWMutex
+	sources map[string]DBStatsSource
+
+	maxOpenConnections *prometheus.Desc
+	openConnections    *prometheus.Desc
+	inUseConnections   *prometheus.Desc
+	idleConnections    *prometheus.Desc
+	waitCount          *prometheus.Desc
+	waitDuration       *prometheus.Desc
+}
+
+// DBStats collects statistics of the connection pools of the storage
+var DBStats = registerDBStatsCollector()
+
+func registerDBStatsCollector() *DBStatsCollector {
+	collector := NewDBStatsCollector()
+	prometheus.MustRegister(collector)
+
+	return collector
+}
+
+// NewDBStatsCollector constructs the collector without any source
+func NewDBStatsCollector() *DBStatsCollector {
+	labels := []string{"db"}
+
+	return &DBStatsCollector{
+		sources: make(map[string]DBStatsSource),
+		maxOpenConnections: prometheus.NewDesc(
+			"sql_max_open_connections", "Maximum number of open connections to the database", labels, nil,
+		),
+		openConnections: prometheus.NewDesc(
+			"sql_open_connections", "The number of established connections both in use and idle", labels, nil,
+		),
+		inUseConnections: prometheus.NewDesc(
+			"sql_in_use_connections", "The number of connections currently in use", labels, nil,
+		),
+		idleConnections: prometheus.NewDesc(
+			"sql_idle_connections", "The number of idle connections", labels, nil,
+		),
+		waitCount: prometheus.NewDesc(
+			"sql_wait_count", "The total number of connections waited for", labels, nil,
+		),
+		waitDuration: prometheus.NewDesc(
+			"sql_wait_duration", "The total time in seconds blocked waiting for a new connection", labels, nil,
+		),
+	}
+}
+
+// SetSource sets the source of statistics of the database with the name
+func (collector *DBStatsCollector) SetSource(name string, source DBStatsSource) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	collector.sources[name] = source
+}
+
+// RemoveSource removes the source of statistics of the database with the name
+// unless it has been replaced by another one already
+func (collector *DBStatsCollector) RemoveSource(name string, source DBStatsSource) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	if collector.sources[name] == source {
+		delete(collector.sources, name)
+	}
+}
+
+// Describe implements prometheus.Collector
+func (collector *DBStatsCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.maxOpenConnections
+	descs <- collector.openConnections
+	descs <- collector.inUseConnections
+	descs <- collector.idleConnections
+	descs <- collector.waitCount
+	descs <- collector.waitDuration
+}
+
+// Collect implements prometheus.Collector
+func (collector *DBStatsCollector) Collect(metrics chan<- prometheus.Metric) {
+	collector.mutex.RLock()
+	defer collector.mutex.RUnlock()
+
+	for name, source := range collector.sources {
+		stats := source.Stats()
+
+		metrics <- prometheus.MustNewConstMetric(
+			collector.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name,
+		)
+		metrics <- prometheus.MustNewConstMetric(
+			collector.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections), name,
+		)
+		metrics <- prometheus.MustNewConstMetric(
+			collector.inUseConnections, prometheus.GaugeValue, float64(stats.InUse), name,
+		)
+		metrics <- prometheus.MustNewConstMetric(
+			collector.idleConnections, prometheus.GaugeValue, float64(stats.Idle), name,
+		)
+		metrics <- prometheus.MustNewConstMetric(
+			collector.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name,
+		)
+		metrics <- prometheus.MustNewConstMetric(
+			collector.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name,
+		)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+// dbStatsSourceMock returns the configured statistics
+type dbStatsSourceMock struct {
+	stats sql.DBStats
+}
+
+func (source *dbStatsSourceMock) Stats() sql.DBStats {
+	return source.stats
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	collector := metrics.NewDBStatsCollector()
+
+	primary := &dbStatsSourceMock{stats: sql.DBStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    3,
+		InUse:              2,
+		Idle:               1,
+		WaitCount:          4,
+		WaitDuration:       1500 * time.Millisecond,
+	}}
+	collector.SetSource("primary", primary)
+	collector.SetSource("replica", &dbStatsSourceMock{})
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(`
+		# HELP sql_in_use_connections The number of connections currently in use
+		# TYPE sql_in_use_connections gauge
+		sql_in_use_connections{db="primary"} 2
+		sql_in_use_connections{db="replica"} 0
+		# HELP sql_wait_duration The total time in seconds blocked waiting for a new connection
+		# TYPE sql_wait_duration counter
+		sql_wait_duration{db="primary"} 1.5
+		sql_wait_duration{db="replica"} 0
+	`), "sql_in_use_connections", "sql_wait_duration")
+	assert.NoError(t, err)
+
+	// the source that has been replaced is not removed
+	collector.RemoveSource("replica", primary)
+	collector.RemoveSource("primary", primary)
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(`
+		# HELP sql_open_connections The number of established connections both in use and idle
+		# TYPE sql_open_connections gauge
+		sql_open_connections{db="replica"} 0
+	`), "sql_open_connections")
+	assert.NoError(t, err)
+}
@@ -21,11 +21,31 @@ limitations under the License.
 //
 // api_endpoints_response_time - response times for all REST API endpoints
 //
+// content_service_cache_hits - total number of content service responses served from the cache
+//
+// content_service_response_time - response times of the content service
+//
 // consumed_messages - total number of messages consumed from selected broker
 //
 // produced_messages - total number of produced messages
 //
 // written_reports - total number of reports written into the storage (cache)
+//
+// skipped_old_reports - total number of reports not written because a more recent one is stored already
+//
+// retention_purged_rows - total number of rows of clusters that stopped reporting removed by the retention job
+//
+// sql_query_duration - durations of SQL queries by statement kind and normalized query name
+//
+// sql_query_errors - total number of failed SQL queries by statement kind and normalized query name
+//
+// sql_*_connections, sql_wait_* - statistics of the database connection pools, see NewDBStatsCollector
+//
+// storage_cache_hits - total number of reads served by the in-process storage cache
+//
+// storage_cache_misses - total number of reads that had to go to the underlying storage
+//
+// webhook_deliveries - total number of webhook deliveries by their result
 package metrics
 
 import (
@@ -52,6 +72,20 @@ var APIResponseStatusCodes = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help: "API endpoints status codes",
 }, []string{"status_code"})
 
+// ContentServiceResponseTime collects response times of the content service labelled
+// by status code of the response, "error" is used when there was no response
+var ContentServiceResponseTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "content_service_response_time",
+	Help: "Content service response time",
+}, []string{"status_code"})
+
+// ContentServiceCacheHits shows how many content service responses were served from the cache,
+// labelled by their state (fresh or stale when served because the content service was unavailable)
+var ContentServiceCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "content_service_cache_hits",
+	Help: "The total number of content service responses served from the cache",
+}, []string{"state"})
+
 // ConsumedMessages shows number of messages consumed from Kafka by aggregator
 var ConsumedMessages = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "consumed_messages",
@@ -95,8 +129,61 @@ var WrittenReports = promauto.NewCounter(prometheus.CounterOpts{
 	Help: "The total number of reports written to the storage",
 })
 
+// SkippedOldReports shows number of reports that were not written into the database,
+// because a more recent report of the same cluster is stored already
+var SkippedOldReports = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "skipped_old_reports",
+	Help: "The total number of reports skipped because a more recent one is stored",
+})
+
+// UnconsumedMessages shows number of messages of the batches that were not marked
+// as consumed, because their reports couldn't be written into the database
+var UnconsumedMessages = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "unconsumed_messages",
+	Help: "The total number of messages left to be consumed again, because their reports couldn't be written",
+})
+
 // FeedbackOnRules shows how many times users left feedback on rules
 var FeedbackOnRules = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "feedback_on_rules",
 	Help: "The total number of left feedback",
 })
+
+// StorageCacheHits shows how many reads were served from the in-process storage cache
+var StorageCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "storage_cache_hits",
+	Help: "The total number of reads served from the storage cache",
+}, []string{"cache"})
+
+// StorageCacheMisses shows how many reads had to be forwarded to the underlying storage
+var StorageCacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "storage_cache_misses",
+	Help: "The total number of reads not found in the storage cache",
+}, []string{"cache"})
+
+// WebhookDeliveries counts deliveries to webhooks, labelled by result (succeeded or failed)
+var WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries",
+	Help: "The total number of webhook deliveries by their result",
+}, []string{"status"})
+
+// RetentionPurgedRows counts rows of the clusters that stopped reporting removed by the retention job,
+// labelled by table
+var RetentionPurgedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "retention_purged_rows",
+	Help: "The total number of rows of stale clusters removed by the retention job",
+}, []string{"table"})
+
+// SQLQueryDuration collects durations of SQL queries in seconds, labelled by statement kind
+// (e.g. select) and normalized query name (the kind with the main table, e.g. select_report)
+var SQLQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "sql_query_duration",
+	Help:    "Duration of SQL queries in seconds",
+	Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+}, []string{"statement", "query"})
+
+// SQLQueryErrors counts failed SQL queries, labelled the same way as SQLQueryDuration
+var SQLQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sql_query_errors",
+	Help: "The total number of failed SQL queries",
+}, []string{"statement", "query"})
@@ -0,0 +1,82 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0011CreateWebhook creates tables for webhook subscriptions and their delivery log
+var mig0011CreateWebhook = Migration{
+	StepUp: func(tx *sql.Tx, driver types.DBDriver) error {
+		// SQLite generates values for INTEGER PRIMARY KEY automatically
+		idType := "INTEGER"
+		if driver == types.DBDriverPostgres {
+			idType = "SERIAL"
+		}
+
+		_, err := tx.Exec(fmt.Sprintf(`
+			CREATE TABLE webhook (
+				id             %v PRIMARY KEY,
+				org_id         INTEGER NOT NULL,
+				url            VARCHAR NOT NULL,
+				secret         VARCHAR NOT NULL,
+				min_total_risk INTEGER NOT NULL,
+				created_at     TIMESTAMP NOT NULL,
+				updated_at     TIMESTAMP NOT NULL
+			)`, idType))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX webhook_org_id_idx ON webhook (org_id)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE TABLE webhook_delivery (
+				webhook_id   INTEGER NOT NULL,
+				cluster_id   VARCHAR NOT NULL,
+				payload      VARCHAR NOT NULL,
+				attempts     INTEGER NOT NULL,
+				status_code  INTEGER NOT NULL,
+				error        VARCHAR NOT NULL,
+				succeeded    BOOLEAN NOT NULL,
+				delivered_at TIMESTAMP NOT NULL,
+
+				FOREIGN KEY (webhook_id)
+					REFERENCES webhook(id)
+					ON DELETE CASCADE
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX webhook_delivery_webhook_id_idx ON webhook_delivery (webhook_id)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE webhook_delivery`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DROP TABLE webhook`)
+		return err
+	},
+}
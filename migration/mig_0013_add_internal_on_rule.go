@@ -0,0 +1,63 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0013AddInternalFieldToRuleTable marks rules that are visible just to internal users
+var mig0013AddInternalFieldToRuleTable = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE rule ADD COLUMN internal BOOLEAN NOT NULL DEFAULT FALSE
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			// the table is rebuilt under a temporary name and renamed afterwards
+			// because renaming the original table would also rename the reference
+			// in rule_error_key table
+			queries := []string{`
+				CREATE TABLE rule_tmp (
+					"module"        VARCHAR PRIMARY KEY,
+					"name"          VARCHAR NOT NULL,
+					"summary"       VARCHAR NOT NULL,
+					"reason"        VARCHAR NOT NULL,
+					"resolution"    VARCHAR NOT NULL,
+					"more_info"     VARCHAR NOT NULL
+				)`,
+				`INSERT INTO rule_tmp SELECT module, name, summary, reason, resolution, more_info FROM rule`,
+				`DROP TABLE rule`,
+				`ALTER TABLE rule_tmp RENAME TO rule`,
+			}
+
+			for _, query := range queries {
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE rule DROP COLUMN internal
+		`)
+		return err
+	},
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0014CreateTranslations creates tables with translations of the rule content
+var mig0014CreateTranslations = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE rule_translation (
+				rule_module VARCHAR NOT NULL REFERENCES rule(module) ON DELETE CASCADE,
+				language    VARCHAR NOT NULL,
+				summary     VARCHAR,
+				reason      VARCHAR,
+				resolution  VARCHAR,
+				more_info   VARCHAR,
+				PRIMARY KEY(rule_module, language)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE TABLE rule_error_key_translation (
+				error_key   VARCHAR NOT NULL,
+				rule_module VARCHAR NOT NULL REFERENCES rule(module) ON DELETE CASCADE,
+				language    VARCHAR NOT NULL,
+				generic     VARCHAR NOT NULL,
+				PRIMARY KEY(error_key, rule_module, language)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE rule_error_key_translation`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DROP TABLE rule_translation`)
+		return err
+	},
+}
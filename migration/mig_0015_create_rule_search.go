@@ -0,0 +1,61 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0015CreateRuleSearch creates full-text search index of the rule content for SQLite,
+// PostgreSQL searches the rule content tables directly. FTS5 is used if SQLite is built
+// with it, FTS4 which is always available otherwise.
+var mig0015CreateRuleSearch = Migration{
+	StepUp: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver != types.DBDriverSQLite3 {
+			return nil
+		}
+
+		var fts5Enabled bool
+		err := tx.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5Enabled)
+		if err != nil {
+			return err
+		}
+
+		if fts5Enabled {
+			_, err := tx.Exec(`
+				CREATE VIRTUAL TABLE rule_search USING fts5(
+					rule_module UNINDEXED, error_key UNINDEXED,
+					name, summary, reason, resolution, description, tags
+				)`)
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE VIRTUAL TABLE rule_search USING fts4(
+				rule_module, error_key, name, summary, reason, resolution, description, tags,
+				notindexed=rule_module, notindexed=error_key, tokenize=unicode61
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver != types.DBDriverSQLite3 {
+			return nil
+		}
+
+		_, err := tx.Exec(`DROP TABLE rule_search`)
+		return err
+	},
+}
@@ -23,6 +23,7 @@ limitations under the License.
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -85,13 +86,18 @@ func InitInfoTable(db *sql.DB) error {
 
 // GetDBVersion reads the current version of the database from the migration info table.
 func GetDBVersion(db *sql.DB) (Version, error) {
-	err := validateNumberOfRows(db)
+	return GetDBVersionContext(context.Background(), db)
+}
+
+// GetDBVersionContext is like GetDBVersion, but the queries are canceled together with ctx.
+func GetDBVersionContext(ctx context.Context, db *sql.DB) (Version, error) {
+	err := validateNumberOfRows(ctx, db)
 	if err != nil {
 		return 0, err
 	}
 
 	var version Version = 0
-	err = db.QueryRow("SELECT version FROM migration_info;").Scan(&version)
+	err = db.QueryRowContext(ctx, "SELECT version FROM migration_info;").Scan(&version)
 	err = types.ConvertDBError(err, nil)
 
 	return version, err
@@ -174,8 +180,8 @@ func execStepsInTx(db *sql.DB, dbDriver types.DBDriver, currentVer, targetVer Ve
 	})
 }
 
-func validateNumberOfRows(db *sql.DB) error {
-	numberOfRows, err := getNumberOfRows(db)
+func validateNumberOfRows(ctx context.Context, db *sql.DB) error {
+	numberOfRows, err := getNumberOfRows(ctx, db)
 	if err != nil {
 		return err
 	}
@@ -186,9 +192,9 @@ func validateNumberOfRows(db *sql.DB) error {
 	return nil
 }
 
-func getNumberOfRows(db *sql.DB) (uint, error) {
+func getNumberOfRows(ctx context.Context, db *sql.DB) (uint, error) {
 	var count uint
-	err := db.QueryRow("SELECT COUNT(*) FROM migration_info;").Scan(&count)
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM migration_info;").Scan(&count)
 	err = types.ConvertDBError(err, nil)
 	return count, err
 }
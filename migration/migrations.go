@@ -27,4 +27,10 @@ var migrations = []Migration{
 	mig0008AddOffsetFieldToReportTable,
 	mig0009AddIndexOnReportKafkaOffset,
 	mig0010AddTagsFieldToRuleErrorKeyTable,
+	mig0011CreateWebhook,
+	mig0012CreateContentVersion,
+	mig0013AddInternalFieldToRuleTable,
+	mig0014CreateTranslations,
+	mig0015CreateRuleSearch,
+	mig0016CreateRuleDisable,
 }
@@ -0,0 +1,619 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// expression is a parsed JavaScript-like expression used in templates
+type expression interface {
+	evaluate(scope map[string]interface{}) interface{}
+}
+
+type literal struct {
+	value interface{}
+}
+
+type identifier struct {
+	name string
+}
+
+type member struct {
+	object   expression
+	property expression
+}
+
+type unary struct {
+	operator string
+	operand  expression
+}
+
+type binary struct {
+	operator    string
+	left, right expression
+}
+
+type ternary struct {
+	condition, then, otherwise expression
+}
+
+// operatorPrecedence is the binding power of binary operators, higher binds tighter
+var operatorPrecedence = map[string]int{
+	"||":  1,
+	"&&":  2,
+	"==":  3,
+	"!=":  3,
+	"===": 3,
+	"!==": 3,
+	"<":   4,
+	">":   4,
+	"<=":  4,
+	">=":  4,
+	"+":   5,
+	"-":   5,
+}
+
+// ternaryPrecedence is lower than precedence of all binary operators
+const ternaryPrecedence = 0
+
+// operators sorted so that longer ones are matched first
+var operators = []string{
+	"===", "!==", "==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", ".", "[", "]", "(", ")", "?", ":",
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// tokenize splits the expression into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+
+	for position := 0; position < len(source); {
+		char, size := utf8.DecodeRuneInString(source[position:])
+
+		switch {
+		case unicode.IsSpace(char):
+			position += size
+		case char == '_' || char == '$' || unicode.IsLetter(char):
+			end := position
+			for end < len(source) {
+				next, nextSize := utf8.DecodeRuneInString(source[end:])
+				if next != '_' && next != '$' && !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+					break
+				}
+				end += nextSize
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, value: source[position:end]})
+			position = end
+		case unicode.IsDigit(char):
+			end := position
+			for end < len(source) && (source[end] == '.' || (source[end] >= '0' && source[end] <= '9')) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: source[position:end]})
+			position = end
+		case char == '"' || char == '\'':
+			value, end, err := readString(source, position)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			position = end
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(source[position:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character '%c' in expression '%v'", char, source)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: operator})
+			position += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// readString reads string literal starting at the position and returns its value
+// and the position after the closing quote
+func readString(source string, position int) (string, int, error) {
+	quote := source[position]
+	var value strings.Builder
+
+	for i := position + 1; i < len(source); i++ {
+		switch source[i] {
+		case quote:
+			return value.String(), i + 1, nil
+		case '\\':
+			i++
+			if i < len(source) {
+				value.WriteByte(source[i])
+			}
+		default:
+			value.WriteByte(source[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string in expression '%v'", source)
+}
+
+// expressionParser is a precedence climbing parser of expressions
+type expressionParser struct {
+	source   string
+	tokens   []token
+	position int
+}
+
+// parseExpression parses the whole source as one expression
+func parseExpression(source string) (expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := expressionParser{source: source, tokens: tokens}
+
+	expr, err := parser.parse(ternaryPrecedence)
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.peek().kind != tokenEOF {
+		return nil, parser.unexpected()
+	}
+
+	return expr, nil
+}
+
+func (parser *expressionParser) peek() token {
+	return parser.tokens[parser.position]
+}
+
+func (parser *expressionParser) next() token {
+	current := parser.tokens[parser.position]
+	if current.kind != tokenEOF {
+		parser.position++
+	}
+	return current
+}
+
+func (parser *expressionParser) isOperator(operator string) bool {
+	current := parser.peek()
+	return current.kind == tokenOperator && current.value == operator
+}
+
+func (parser *expressionParser) expect(operator string) error {
+	if !parser.isOperator(operator) {
+		return parser.unexpected()
+	}
+	parser.next()
+	return nil
+}
+
+func (parser *expressionParser) unexpected() error {
+	current := parser.peek()
+	if current.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression '%v'", parser.source)
+	}
+	return fmt.Errorf("unexpected '%v' in expression '%v'", current.value, parser.source)
+}
+
+// parse parses expression with operators binding tighter than the precedence
+func (parser *expressionParser) parse(precedence int) (expression, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		current := parser.peek()
+		if current.kind != tokenOperator {
+			return left, nil
+		}
+
+		if current.value == "?" && precedence <= ternaryPrecedence {
+			parser.next()
+			then, err := parser.parse(ternaryPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			if err := parser.expect(":"); err != nil {
+				return nil, err
+			}
+			otherwise, err := parser.parse(ternaryPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			left = ternary{condition: left, then: then, otherwise: otherwise}
+			continue
+		}
+
+		operatorPrecedence, isBinary := operatorPrecedence[current.value]
+		if !isBinary || operatorPrecedence <= precedence {
+			return left, nil
+		}
+
+		parser.next()
+		right, err := parser.parse(operatorPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: current.value, left: left, right: right}
+	}
+}
+
+func (parser *expressionParser) parseUnary() (expression, error) {
+	if parser.isOperator("!") || parser.isOperator("-") {
+		operator := parser.next().value
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{operator: operator, operand: operand}, nil
+	}
+
+	return parser.parsePostfix()
+}
+
+func (parser *expressionParser) parsePostfix() (expression, error) {
+	expr, err := parser.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case parser.isOperator("."):
+			parser.next()
+			property := parser.next()
+			if property.kind != tokenIdentifier {
+				return nil, fmt.Errorf("expected property name in expression '%v'", parser.source)
+			}
+			expr = member{object: expr, property: literal{value: property.value}}
+		case parser.isOperator("["):
+			parser.next()
+			property, err := parser.parse(ternaryPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			if err := parser.expect("]"); err != nil {
+				return nil, err
+			}
+			expr = member{object: expr, property: property}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (parser *expressionParser) parsePrimary() (expression, error) {
+	current := parser.next()
+
+	switch current.kind {
+	case tokenNumber:
+		number, err := strconv.ParseFloat(current.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%v' in expression '%v'", current.value, parser.source)
+		}
+		return literal{value: number}, nil
+	case tokenString:
+		return literal{value: current.value}, nil
+	case tokenIdentifier:
+		switch current.value {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		case "null", "undefined":
+			return literal{value: nil}, nil
+		}
+		return identifier{name: current.value}, nil
+	case tokenOperator:
+		if current.value == "(" {
+			expr, err := parser.parse(ternaryPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			return expr, parser.expect(")")
+		}
+	}
+
+	// the unexpected token is reported, the end of expression is never consumed
+	if current.kind != tokenEOF {
+		parser.position--
+	}
+	return nil, parser.unexpected()
+}
+
+func (expr literal) evaluate(map[string]interface{}) interface{} {
+	return expr.value
+}
+
+// evaluate returns value of the variable, missing variables are undefined (nil)
+func (expr identifier) evaluate(scope map[string]interface{}) interface{} {
+	return scope[expr.name]
+}
+
+// evaluate returns the property of the object, nil is returned
+// instead of failing when the object doesn't have it
+func (expr member) evaluate(scope map[string]interface{}) interface{} {
+	object := expr.object.evaluate(scope)
+	property := expr.property.evaluate(scope)
+
+	switch typed := object.(type) {
+	case map[string]interface{}:
+		return typed[toString(property)]
+	case []interface{}:
+		if property == "length" {
+			return float64(len(typed))
+		}
+		index, ok := toNumber(property)
+		if !ok || index < 0 || index >= float64(len(typed)) || index != math.Trunc(index) {
+			return nil
+		}
+		return typed[int(index)]
+	case string:
+		if property == "length" {
+			return float64(utf8.RuneCountInString(typed))
+		}
+	}
+
+	return nil
+}
+
+func (expr unary) evaluate(scope map[string]interface{}) interface{} {
+	operand := expr.operand.evaluate(scope)
+
+	if expr.operator == "!" {
+		return !isTruthy(operand)
+	}
+
+	number, ok := toNumber(operand)
+	if !ok {
+		return math.NaN()
+	}
+	return -number
+}
+
+func (expr binary) evaluate(scope map[string]interface{}) interface{} {
+	left := expr.left.evaluate(scope)
+
+	// logical operators return one of the operands like in JavaScript
+	switch expr.operator {
+	case "&&":
+		if !isTruthy(left) {
+			return left
+		}
+		return expr.right.evaluate(scope)
+	case "||":
+		if isTruthy(left) {
+			return left
+		}
+		return expr.right.evaluate(scope)
+	}
+
+	right := expr.right.evaluate(scope)
+
+	switch expr.operator {
+	case "==":
+		return looseEquals(left, right)
+	case "!=":
+		return !looseEquals(left, right)
+	case "===":
+		return strictEquals(left, right)
+	case "!==":
+		return !strictEquals(left, right)
+	case "+":
+		return add(left, right)
+	case "-":
+		leftNumber, leftOK := toNumber(left)
+		rightNumber, rightOK := toNumber(right)
+		if !leftOK || !rightOK {
+			return math.NaN()
+		}
+		return leftNumber - rightNumber
+	default:
+		return compare(expr.operator, left, right)
+	}
+}
+
+func (expr ternary) evaluate(scope map[string]interface{}) interface{} {
+	if isTruthy(expr.condition.evaluate(scope)) {
+		return expr.then.evaluate(scope)
+	}
+	return expr.otherwise.evaluate(scope)
+}
+
+// normalize converts numbers of all types to float64 like they're in JavaScript
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case float32:
+		return float64(typed)
+	case json.Number:
+		number, err := typed.Float64()
+		if err != nil {
+			return typed.String()
+		}
+		return number
+	}
+	return value
+}
+
+// isTruthy converts the value to boolean the same way as JavaScript
+func isTruthy(value interface{}) bool {
+	switch typed := normalize(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return typed
+	case float64:
+		return typed != 0 && !math.IsNaN(typed)
+	case string:
+		return typed != ""
+	default:
+		return true
+	}
+}
+
+// toNumber converts the value to number, false is returned if it's not possible
+func toNumber(value interface{}) (float64, bool) {
+	switch typed := normalize(value).(type) {
+	case float64:
+		return typed, true
+	case bool:
+		if typed {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// toString converts the value to its textual representation, nil is converted
+// to empty string, so missing values don't show up in the rendered text
+func toString(value interface{}) string {
+	switch typed := normalize(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case bool:
+		return strconv.FormatBool(typed)
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(typed))
+		for i, item := range typed {
+			items[i] = toString(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		data, err := json.Marshal(typed)
+		if err != nil {
+			return fmt.Sprint(typed)
+		}
+		return string(data)
+	}
+}
+
+func strictEquals(left, right interface{}) bool {
+	left, right = normalize(left), normalize(right)
+
+	switch left.(type) {
+	case nil, bool, float64, string:
+		return left == right
+	}
+
+	// arrays and objects are compared by identity in JavaScript
+	return false
+}
+
+func looseEquals(left, right interface{}) bool {
+	left, right = normalize(left), normalize(right)
+
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	if strictEquals(left, right) {
+		return true
+	}
+
+	_, leftIsString := left.(string)
+	_, rightIsString := right.(string)
+	if leftIsString && rightIsString {
+		return false
+	}
+
+	leftNumber, leftOK := toNumber(left)
+	rightNumber, rightOK := toNumber(right)
+	return leftOK && rightOK && leftNumber == rightNumber
+}
+
+func add(left, right interface{}) interface{} {
+	leftNumber, leftOK := normalize(left).(float64)
+	rightNumber, rightOK := normalize(right).(float64)
+	if leftOK && rightOK {
+		return leftNumber + rightNumber
+	}
+
+	return toString(left) + toString(right)
+}
+
+func compare(operator string, left, right interface{}) bool {
+	leftString, leftIsString := normalize(left).(string)
+	rightString, rightIsString := normalize(right).(string)
+
+	var result int
+	if leftIsString && rightIsString {
+		result = strings.Compare(leftString, rightString)
+	} else {
+		leftNumber, leftOK := toNumber(left)
+		rightNumber, rightOK := toNumber(right)
+		if !leftOK || !rightOK {
+			return false
+		}
+		switch {
+		case leftNumber < rightNumber:
+			result = -1
+		case leftNumber > rightNumber:
+			result = 1
+		}
+	}
+
+	switch operator {
+	case "<":
+		return result < 0
+	case ">":
+		return result > 0
+	case "<=":
+		return result <= 0
+	default:
+		return result >= 0
+	}
+}
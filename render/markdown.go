@@ -0,0 +1,173 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	unorderedItemPattern = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedItemPattern   = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+
+	codeSpanPattern = regexp.MustCompile("`([^`]+)`")
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emphasisPattern = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+)
+
+// markdownConverter converts Markdown into HTML line by line
+type markdownConverter struct {
+	output    strings.Builder
+	paragraph []string
+	listTag   string
+	inCode    bool
+}
+
+// MarkdownToHTML converts the subset of Markdown used in rule content into HTML:
+// headings, paragraphs, ordered and unordered lists, fenced code blocks, inline
+// code, links, strong and emphasized text. All other text is escaped, so raw HTML
+// in the Markdown is displayed as it is.
+func MarkdownToHTML(markdown string) string {
+	converter := markdownConverter{}
+
+	for _, line := range strings.Split(strings.Replace(markdown, "\r\n", "\n", -1), "\n") {
+		converter.convertLine(line)
+	}
+
+	if converter.inCode {
+		converter.output.WriteString("</code></pre>\n")
+	}
+	converter.closeBlocks()
+
+	return strings.TrimSuffix(converter.output.String(), "\n")
+}
+
+func (converter *markdownConverter) convertLine(line string) {
+	if strings.HasPrefix(strings.TrimSpace(line), "```") {
+		if converter.inCode {
+			converter.output.WriteString("</code></pre>\n")
+		} else {
+			converter.closeBlocks()
+			converter.output.WriteString("<pre><code>")
+		}
+		converter.inCode = !converter.inCode
+		return
+	}
+
+	if converter.inCode {
+		converter.output.WriteString(html.EscapeString(line) + "\n")
+		return
+	}
+
+	if strings.TrimSpace(line) == "" {
+		converter.closeBlocks()
+		return
+	}
+
+	if match := headingPattern.FindStringSubmatch(line); match != nil {
+		converter.closeBlocks()
+		level := strconv.Itoa(len(match[1]))
+		converter.output.WriteString("<h" + level + ">" + convertInline(match[2]) + "</h" + level + ">\n")
+		return
+	}
+
+	if match := unorderedItemPattern.FindStringSubmatch(line); match != nil {
+		converter.addListItem("ul", match[1])
+		return
+	}
+
+	if match := orderedItemPattern.FindStringSubmatch(line); match != nil {
+		converter.addListItem("ol", match[1])
+		return
+	}
+
+	if converter.listTag != "" {
+		// continuation of the list item is not supported, the line starts a new paragraph
+		converter.closeBlocks()
+	}
+	converter.paragraph = append(converter.paragraph, strings.TrimSpace(line))
+}
+
+func (converter *markdownConverter) addListItem(listTag, text string) {
+	if converter.listTag != listTag {
+		converter.closeBlocks()
+		converter.output.WriteString("<" + listTag + ">\n")
+		converter.listTag = listTag
+	}
+
+	converter.output.WriteString("<li>" + convertInline(text) + "</li>\n")
+}
+
+// closeBlocks finishes the current paragraph or list
+func (converter *markdownConverter) closeBlocks() {
+	if len(converter.paragraph) != 0 {
+		converter.output.WriteString("<p>" + convertInline(strings.Join(converter.paragraph, "\n")) + "</p>\n")
+		converter.paragraph = nil
+	}
+
+	if converter.listTag != "" {
+		converter.output.WriteString("</" + converter.listTag + ">\n")
+		converter.listTag = ""
+	}
+}
+
+// convertInline escapes the text and converts inline Markdown elements
+func convertInline(text string) string {
+	// code spans are replaced by placeholders, so their content is not converted
+	var codeSpans []string
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(span string) string {
+		codeSpans = append(codeSpans, "<code>"+html.EscapeString(span[1:len(span)-1])+"</code>")
+		return "\x00" + strconv.Itoa(len(codeSpans)-1) + "\x00"
+	})
+
+	text = html.EscapeString(text)
+
+	text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		match := linkPattern.FindStringSubmatch(link)
+		if !isSafeURL(html.UnescapeString(match[2])) {
+			return match[1]
+		}
+		return `<a href="` + match[2] + `">` + match[1] + `</a>`
+	})
+	text = strongPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emphasisPattern.ReplaceAllString(text, "<em>$1$2</em>")
+
+	for i, span := range codeSpans {
+		text = strings.Replace(text, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+
+	return text
+}
+
+// isSafeURL allows only links which can't execute scripts
+func isSafeURL(url string) bool {
+	lowerURL := strings.ToLower(url)
+
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lowerURL, scheme) {
+			return true
+		}
+	}
+
+	// relative links don't have any scheme
+	return !strings.Contains(lowerURL, ":")
+}
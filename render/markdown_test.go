@@ -0,0 +1,54 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/render"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		markdown string
+		expected string
+	}{
+		{"paragraphs", "first\nline\n\nsecond", "<p>first\nline</p>\n<p>second</p>"},
+		{"heading", "## Title ##\ntext", "<h2>Title</h2>\n<p>text</p>"},
+		{"unordered list", "- one\n* two\n\nafter", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<p>after</p>"},
+		{"ordered list", "1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>"},
+		{"code block", "```\n<a> & *b*\n```", "<pre><code>&lt;a&gt; &amp; *b*\n</code></pre>"},
+		{"unterminated code block", "```\ncode", "<pre><code>code\n</code></pre>"},
+		{"inline code", "run `oc get <pods>` **now**", "<p>run <code>oc get &lt;pods&gt;</code> <strong>now</strong></p>"},
+		{"emphasis", "*one* _two_ snake_case_name", "<p><em>one</em> <em>two</em> snake_case_name</p>"},
+		{
+			"link",
+			"[docs](https://example.com/a?b=c&d=e)",
+			`<p><a href="https://example.com/a?b=c&amp;d=e">docs</a></p>`,
+		},
+		{"unsafe link", "[click](javascript:alert(1))", "<p>click)</p>"},
+		{"raw HTML is escaped", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>"},
+		{"empty", "", ""},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, render.MarkdownToHTML(testCase.markdown))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render renders rule content templates written in doT template syntax
+// with details of rule hits and converts the rendered Markdown into HTML, so
+// clients don't need to implement the template substitution by themselves.
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format is the output format of rendered content
+type Format string
+
+const (
+	// FormatMarkdown keeps the rendered content in Markdown as it's written in rule content
+	FormatMarkdown Format = "markdown"
+	// FormatHTML converts the rendered content into HTML
+	FormatHTML Format = "html"
+)
+
+// ParseFormat returns the format of the name, empty name means Markdown
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatMarkdown:
+		return FormatMarkdown, nil
+	case FormatHTML:
+		return FormatHTML, nil
+	}
+
+	return "", fmt.Errorf("unknown format, expected '%v' or '%v'", FormatMarkdown, FormatHTML)
+}
+
+// Render renders the template with the data and converts it into the format
+func Render(template string, data interface{}, format Format) (string, error) {
+	rendered, err := Template(template, data)
+	if err != nil {
+		return "", err
+	}
+
+	if format == FormatHTML {
+		return MarkdownToHTML(rendered), nil
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,294 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// dataVariable is the name under which details of the hit are available in templates
+const dataVariable = "pydata"
+
+// node is a part of parsed template
+type node interface {
+	write(output *strings.Builder, scope map[string]interface{})
+}
+
+// textNode is a text outside of template tags
+type textNode struct {
+	text string
+}
+
+// interpolationNode is {{= expression}} or {{! expression}}, the latter escapes HTML
+type interpolationNode struct {
+	value  expression
+	escape bool
+}
+
+// conditionalBranch is one branch of {{? condition}} ... {{?? condition}} ... {{??}} ... {{?}},
+// the condition of else branch is nil
+type conditionalBranch struct {
+	condition expression
+	body      []node
+}
+
+type conditionalNode struct {
+	branches []conditionalBranch
+}
+
+// loopNode is {{~ array :value:index}} ... {{~}}
+type loopNode struct {
+	array     expression
+	valueName string
+	indexName string
+	body      []node
+}
+
+func (n textNode) write(output *strings.Builder, _ map[string]interface{}) {
+	output.WriteString(n.text)
+}
+
+func (n interpolationNode) write(output *strings.Builder, scope map[string]interface{}) {
+	value := toString(n.value.evaluate(scope))
+	if n.escape {
+		value = html.EscapeString(value)
+	}
+	output.WriteString(value)
+}
+
+func (n conditionalNode) write(output *strings.Builder, scope map[string]interface{}) {
+	for _, branch := range n.branches {
+		if branch.condition == nil || isTruthy(branch.condition.evaluate(scope)) {
+			writeNodes(output, branch.body, scope)
+			return
+		}
+	}
+}
+
+// write repeats the body for every item of the array, anything else is skipped
+func (n loopNode) write(output *strings.Builder, scope map[string]interface{}) {
+	items, ok := n.array.evaluate(scope).([]interface{})
+	if !ok {
+		return
+	}
+
+	for index, item := range items {
+		loopScope := make(map[string]interface{}, len(scope)+2)
+		for name, value := range scope {
+			loopScope[name] = value
+		}
+		if n.valueName != "" {
+			loopScope[n.valueName] = item
+		}
+		if n.indexName != "" {
+			loopScope[n.indexName] = float64(index)
+		}
+
+		writeNodes(output, n.body, loopScope)
+	}
+}
+
+func writeNodes(output *strings.Builder, nodes []node, scope map[string]interface{}) {
+	for _, n := range nodes {
+		n.write(output, scope)
+	}
+}
+
+// tag is a template tag, its kind is the first character after {{
+type tag struct {
+	kind    byte
+	content string
+}
+
+// templateParser parses doT template into tree of nodes
+type templateParser struct {
+	rest string
+}
+
+// Template renders doT template with details of the rule hit which are available
+// as pydata variable. Supported tags are interpolation {{= }} and {{! }},
+// conditionals {{? }} {{?? }} {{??}} {{?}} and loops {{~ }} {{~}}. Expressions
+// are a subset of JavaScript: variables, properties, literals, comparison,
+// logical operators, + and -. Missing properties are undefined, which is
+// rendered as an empty string and it's false in conditions.
+func Template(template string, data interface{}) (string, error) {
+	parser := templateParser{rest: template}
+
+	nodes, end, err := parser.parseNodes()
+	if err != nil {
+		return "", err
+	}
+	if end != nil {
+		return "", fmt.Errorf("unexpected {{%c%v}} in template", end.kind, end.content)
+	}
+
+	var output strings.Builder
+	writeNodes(&output, nodes, map[string]interface{}{dataVariable: data})
+
+	return output.String(), nil
+}
+
+// nextTag returns text before the next tag and the tag itself, nil is returned
+// if there's no other tag
+func (parser *templateParser) nextTag() (string, *tag, error) {
+	start := strings.Index(parser.rest, "{{")
+	if start < 0 {
+		text := parser.rest
+		parser.rest = ""
+		return text, nil, nil
+	}
+
+	end := strings.Index(parser.rest[start:], "}}")
+	if end < 0 {
+		return "", nil, fmt.Errorf("unterminated tag in template: %v", parser.rest[start:])
+	}
+	end += start
+
+	text := parser.rest[:start]
+	inner := parser.rest[start+2 : end]
+	parser.rest = parser.rest[end+2:]
+
+	if inner == "" {
+		return "", nil, fmt.Errorf("empty tag in template")
+	}
+
+	return text, &tag{kind: inner[0], content: inner[1:]}, nil
+}
+
+// parseNodes parses nodes until the end of the template or until a tag closing
+// or continuing an outer block, the tag is returned to the caller
+func (parser *templateParser) parseNodes() ([]node, *tag, error) {
+	var nodes []node
+
+	for {
+		text, t, err := parser.nextTag()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if text != "" {
+			nodes = append(nodes, textNode{text: text})
+		}
+		if t == nil {
+			return nodes, nil, nil
+		}
+
+		switch t.kind {
+		case '=', '!':
+			value, err := parseExpression(t.content)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, interpolationNode{value: value, escape: t.kind == '!'})
+		case '?':
+			if isBlockEnd(t) || strings.HasPrefix(t.content, "?") {
+				return nodes, t, nil
+			}
+			n, err := parser.parseConditional(t.content)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, n)
+		case '~':
+			if isBlockEnd(t) {
+				return nodes, t, nil
+			}
+			n, err := parser.parseLoop(t.content)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, n)
+		default:
+			return nil, nil, fmt.Errorf("unsupported tag {{%c%v}} in template", t.kind, t.content)
+		}
+	}
+}
+
+// isBlockEnd returns true for {{?}} and {{~}}
+func isBlockEnd(t *tag) bool {
+	return strings.TrimSpace(t.content) == ""
+}
+
+func (parser *templateParser) parseConditional(condition string) (node, error) {
+	var n conditionalNode
+
+	conditionExpr, err := parseExpression(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		body, end, err := parser.parseNodes()
+		if err != nil {
+			return nil, err
+		}
+		n.branches = append(n.branches, conditionalBranch{condition: conditionExpr, body: body})
+
+		if end == nil || end.kind != '?' {
+			return nil, fmt.Errorf("missing {{?}} in template")
+		}
+		if isBlockEnd(end) {
+			return n, nil
+		}
+
+		// {{??}} is else, {{?? condition}} is else if
+		if conditionExpr == nil {
+			return nil, fmt.Errorf("unexpected {{?%v}} after else in template", end.content)
+		}
+		conditionExpr = nil
+		if elseCondition := strings.TrimSpace(end.content[1:]); elseCondition != "" {
+			conditionExpr, err = parseExpression(elseCondition)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (parser *templateParser) parseLoop(content string) (node, error) {
+	// the content is "array :value:index", both names are optional
+	parts := strings.Split(content, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid loop {{~%v}} in template", content)
+	}
+
+	array, err := parseExpression(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	n := loopNode{array: array}
+	if len(parts) > 1 {
+		n.valueName = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		n.indexName = strings.TrimSpace(parts[2])
+	}
+
+	body, end, err := parser.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	if end == nil || end.kind != '~' {
+		return nil, fmt.Errorf("missing {{~}} in template")
+	}
+	n.body = body
+
+	return n, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/render"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// templateData are details of a rule hit as they're unmarshalled from the report
+const templateData = `{
+	"type": "rule",
+	"error_key": "NODES_MINIMUM_REQUIREMENTS_NOT_MET",
+	"nodes": [
+		{"name": "foo1", "role": "master", "memory": 8.81, "memory_req": 16},
+		{"name": "foo2", "role": "worker", "memory": 7.5, "memory_req": 8}
+	],
+	"count": 2,
+	"link": "https://example.com",
+	"enabled": false,
+	"html": "<b>bold</b>"
+}`
+
+func mustUnmarshalData(t *testing.T) interface{} {
+	var data interface{}
+	helpers.FailOnError(t, json.Unmarshal([]byte(templateData), &data))
+	return data
+}
+
+func TestTemplate(t *testing.T) {
+	data := mustUnmarshalData(t)
+
+	for _, testCase := range []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"no tags", "Plain text\nwith lines", "Plain text\nwith lines"},
+		{"interpolation", "Key: {{=pydata.error_key}}", "Key: NODES_MINIMUM_REQUIREMENTS_NOT_MET"},
+		{"numbers", "{{=pydata.count}} {{=pydata.nodes[0].memory}} {{=pydata.nodes.length}}", "2 8.81 2"},
+		{"missing key", "[{{=pydata.missing}}][{{=pydata.missing.deeper}}][{{=other.value}}]", "[][][]"},
+		{"escaped interpolation", "{{!pydata.html}} {{=pydata.html}}", "&lt;b&gt;bold&lt;/b&gt; <b>bold</b>"},
+		{"if", "{{?pydata.count > 1}}many{{?}}", "many"},
+		{"if false", "a{{?pydata.enabled}}enabled{{?}}b", "ab"},
+		{"if missing key", "{{?pydata.missing}}yes{{??}}no{{?}}", "no"},
+		{"else if", `{{?pydata.type == "other"}}1{{?? pydata.type === "rule"}}2{{??}}3{{?}}`, "2"},
+		{"logical operators", `{{?!pydata.enabled && (pydata.count >= 2 || pydata.missing)}}ok{{?}}`, "ok"},
+		{"ternary", `{{=pydata.count == 1 ? "node" : "nodes"}}`, "nodes"},
+		{"concatenation", `{{="Count: " + pydata.count}} {{=pydata.count + 1}}`, "Count: 2 3"},
+		{
+			"loop",
+			"{{~pydata.nodes :node:index}}\n{{=index + 1}}. {{=node.name}} ({{=node.role}}){{~}}",
+			"\n1. foo1 (master)\n2. foo2 (worker)",
+		},
+		{
+			"nested blocks",
+			"{{~pydata.nodes :node}}{{?node.memory < node.memory_req}}{{=node.name}};{{?}}{{~}}",
+			"foo1;foo2;",
+		},
+		{"loop over missing key", "{{~pydata.missing :item}}{{=item}}{{~}}", ""},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rendered, err := render.Template(testCase.template, data)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, testCase.expected, rendered)
+		})
+	}
+}
+
+func TestTemplateNilData(t *testing.T) {
+	rendered, err := render.Template("Node {{=pydata.nodes[0].name}}{{?pydata.count}} and others{{?}}.", nil)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "Node .", rendered)
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, template := range []string{
+		"{{=pydata.",
+		"{{=pydata.a +}}",
+		"{{?pydata.a}}missing end",
+		"{{~pydata.a :b}}missing end",
+		"{{?pydata.a}}{{~}}",
+		"{{?}}",
+		"{{#def.macro}}",
+		"{{}}",
+		`{{="unterminated}}`,
+		"{{?pydata.a}}{{??}}{{??}}{{?}}",
+		"{{=pydata.a @ 1}}",
+	} {
+		_, err := render.Template(template, nil)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := mustUnmarshalData(t)
+
+	rendered, err := render.Render("Error key **{{=pydata.error_key}}**", data, render.FormatMarkdown)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "Error key **NODES_MINIMUM_REQUIREMENTS_NOT_MET**", rendered)
+
+	rendered, err = render.Render("Error key **{{=pydata.html}}**", data, render.FormatHTML)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "<p>Error key <strong>&lt;b&gt;bold&lt;/b&gt;</strong></p>", rendered)
+
+	_, err = render.Render("{{=}}", data, render.FormatHTML)
+	assert.Error(t, err)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := render.ParseFormat("")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, render.FormatMarkdown, format)
+
+	format, err = render.ParseFormat("HTML")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, render.FormatHTML, format)
+
+	_, err = render.ParseFormat("pdf")
+	assert.EqualError(t, err, "unknown format, expected 'markdown' or 'html'")
+}
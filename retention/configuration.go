@@ -0,0 +1,35 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retention
+
+import "time"
+
+const (
+	// DefaultMaxAge is used when the maximal age of the reports is not configured
+	DefaultMaxAge = 90 * 24 * time.Hour
+	// DefaultInterval is used when the interval of the purges is not configured
+	DefaultInterval = 24 * time.Hour
+)
+
+// Configuration represents configuration of the retention job. Clusters whose
+// last report was checked more than MaxAge ago are removed every Interval.
+type Configuration struct {
+	Enabled  bool          `mapstructure:"enabled" toml:"enabled"`
+	MaxAge   time.Duration `mapstructure:"max_age" toml:"max_age"`
+	Interval time.Duration `mapstructure:"interval" toml:"interval"`
+	DryRun   bool          `mapstructure:"dry_run" toml:"dry_run"`
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package retention contains the job removing data of the clusters that stopped
+// reporting, i.e. their reports together with the feedback and toggles of their rules.
+package retention
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// Job purges the stale clusters from the storage periodically
+type Job struct {
+	configuration Configuration
+	storage       storage.Storage
+	stop          chan struct{}
+	stopped       sync.WaitGroup
+}
+
+// New constructs the retention job, defaults are used for the values that are not configured
+func New(configuration Configuration, storage storage.Storage) *Job {
+	if configuration.MaxAge <= 0 {
+		configuration.MaxAge = DefaultMaxAge
+	}
+
+	if configuration.Interval <= 0 {
+		configuration.Interval = DefaultInterval
+	}
+
+	return &Job{
+		configuration: configuration,
+		storage:       storage,
+	}
+}
+
+// Purge removes the clusters whose last report is older than the maximal age
+// at once, they're just counted in the dry run mode
+func (job *Job) Purge() (storage.PurgedClusters, error) {
+	lastCheckedBefore := time.Now().Add(-job.configuration.MaxAge)
+
+	purged, err := job.storage.PurgeStaleClusters(lastCheckedBefore, job.configuration.DryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to purge stale clusters")
+		return purged, err
+	}
+
+	if job.configuration.DryRun {
+		log.Info().
+			Int("clusters", len(purged.Clusters)).
+			Int64("reports", purged.Reports).
+			Int64("feedback", purged.Feedback).
+			Int64("toggles", purged.Toggles).
+			Msgf("Dry run: stale clusters last checked before %v would be purged", lastCheckedBefore)
+		return purged, nil
+	}
+
+	metrics.RetentionPurgedRows.WithLabelValues("report").Add(float64(purged.Reports))
+	metrics.RetentionPurgedRows.WithLabelValues("cluster_rule_user_feedback").Add(float64(purged.Feedback))
+	metrics.RetentionPurgedRows.WithLabelValues("cluster_rule_toggle").Add(float64(purged.Toggles))
+
+	log.Info().
+		Int("clusters", len(purged.Clusters)).
+		Int64("reports", purged.Reports).
+		Int64("feedback", purged.Feedback).
+		Int64("toggles", purged.Toggles).
+		Msgf("Stale clusters last checked before %v purged", lastCheckedBefore)
+
+	return purged, nil
+}
+
+// Start purges the stale clusters in background right away and then every interval
+func (job *Job) Start() {
+	job.stop = make(chan struct{})
+
+	job.stopped.Add(1)
+	go func() {
+		defer job.stopped.Done()
+
+		ticker := time.NewTicker(job.configuration.Interval)
+		defer ticker.Stop()
+
+		for {
+			// errors are logged already, the purge is retried in the next interval
+			_, _ = job.Purge()
+
+			select {
+			case <-job.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the purges started by Start
+func (job *Job) Stop() {
+	if job.stop == nil {
+		return
+	}
+
+	close(job.stop)
+	job.stopped.Wait()
+	job.stop = nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retention_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/retention"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustGetStorageWithStaleCluster returns storage with a report of testdata.ClusterName
+// checked long ago and a recent report of another cluster
+func mustGetStorageWithStaleCluster(t *testing.T) storage.Storage {
+	s := storage.NewMemoryStorage()
+
+	err := s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules,
+		time.Now().Add(-2*retention.DefaultMaxAge), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = s.WriteReportForCluster(
+		testdata.OrgID, testdata.GetRandomClusterID(), testdata.Report3Rules, time.Now(), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	return s
+}
+
+func TestJobPurge(t *testing.T) {
+	s := mustGetStorageWithStaleCluster(t)
+	purgedReports := testutil.ToFloat64(metrics.RetentionPurgedRows.WithLabelValues("report"))
+
+	purged, err := retention.New(retention.Configuration{}, s).Purge()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{testdata.ClusterName}, purged.Clusters)
+
+	count, err := s.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.Equal(t, purgedReports+1, testutil.ToFloat64(metrics.RetentionPurgedRows.WithLabelValues("report")))
+}
+
+func TestJobPurgeDryRun(t *testing.T) {
+	s := mustGetStorageWithStaleCluster(t)
+	purgedReports := testutil.ToFloat64(metrics.RetentionPurgedRows.WithLabelValues("report"))
+
+	purged, err := retention.New(retention.Configuration{DryRun: true}, s).Purge()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{testdata.ClusterName}, purged.Clusters)
+	assert.Equal(t, int64(1), purged.Reports)
+
+	count, err := s.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.Equal(t, purgedReports, testutil.ToFloat64(metrics.RetentionPurgedRows.WithLabelValues("report")))
+}
+
+func TestJobPurgeMaxAge(t *testing.T) {
+	s := mustGetStorageWithStaleCluster(t)
+
+	// both clusters are younger
+	purged, err := retention.New(retention.Configuration{MaxAge: 3 * retention.DefaultMaxAge}, s).Purge()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, purged.Clusters)
+}
+
+func TestJobPurgeClosedStorage(t *testing.T) {
+	s, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := retention.New(retention.Configuration{}, s).Purge()
+	assert.Error(t, err)
+}
+
+func TestJobStartPurgesRightAway(t *testing.T) {
+	s := mustGetStorageWithStaleCluster(t)
+
+	job := retention.New(retention.Configuration{Interval: time.Hour}, s)
+	job.Start()
+	defer job.Stop()
+
+	assert.Eventually(t, func() bool {
+		count, err := s.ReportsCount()
+		return err == nil && count == 1
+	}, 10*time.Second, time.Millisecond)
+}
+
+func TestJobStopNotStarted(t *testing.T) {
+	retention.New(retention.Configuration{}, storage.NewMemoryStorage()).Stop()
+}
@@ -47,10 +47,16 @@ type Internal struct {
 	OrgID types.OrgID `json:"org_id,string"`
 }
 
+// User contains information about the user the identity belongs to
+type User struct {
+	IsInternal bool `json:"is_internal"`
+}
+
 // Identity contains internal user info
 type Identity struct {
 	AccountNumber types.UserID `json:"account_number"`
 	Internal      Internal     `json:"internal"`
+	User          User         `json:"user"`
 }
 
 // Token is x-rh-identity struct
@@ -62,6 +68,7 @@ type Token struct {
 type JWTPayload struct {
 	AccountNumber types.UserID `json:"account_number"`
 	OrgID         types.OrgID  `json:"org_id,string"`
+	IsInternal    bool         `json:"is_internal"`
 }
 
 // Authentication middleware for checking auth rights
@@ -99,7 +106,11 @@ func (server *HTTPServer) Authentication(next http.Handler, noAuthURLs []string)
 				return
 			}
 			// Map JWT token to inner token
-			tk.Identity = Identity{AccountNumber: jwt.AccountNumber, Internal: Internal{OrgID: jwt.OrgID}}
+			tk.Identity = Identity{
+				AccountNumber: jwt.AccountNumber,
+				Internal:      Internal{OrgID: jwt.OrgID},
+				User:          User{IsInternal: jwt.IsInternal},
+			}
 		} else {
 			err = json.Unmarshal([]byte(decoded), tk)
 
@@ -134,6 +145,14 @@ func (server *HTTPServer) GetCurrentUserID(request *http.Request) (types.UserID,
 	return identity.AccountNumber, nil
 }
 
+// isInternalUser checks whether the identity of the request belongs to an internal user,
+// requests without identity are never considered to be internal
+func (server *HTTPServer) isInternalUser(request *http.Request) bool {
+	identity, ok := request.Context().Value(ContextKeyUser).(Identity)
+
+	return ok && identity.User.IsInternal
+}
+
 func (server *HTTPServer) getAuthTokenHeader(w http.ResponseWriter, r *http.Request) (string, bool) {
 	var tokenHeader string
 	// In case of testing on local machine we don't take x-rh-identity header, but instead Authorization with JWT token in it
@@ -0,0 +1,63 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+)
+
+// ContentReloader reloads rule content on demand, it's implemented by content.Watcher
+type ContentReloader interface {
+	Reload() (content.Changes, error)
+}
+
+// reloadContent reloads rule content and responds with summary of the changes,
+// only internal users are allowed to do that when the authentication is enabled
+func (server *HTTPServer) reloadContent(writer http.ResponseWriter, request *http.Request) {
+	if server.Config.Auth && !server.isInternalUser(request) {
+		// the user is always known when the authentication is enabled
+		userID, _ := server.GetCurrentUserID(request)
+		log.Warn().
+			Str("audit", "content_reload").
+			Str("user", string(userID)).
+			Msg("Reload of rule content refused to non-internal user")
+		handleServerError(writer, &AuthenticationError{errString: "only internal users can reload rule content"})
+		return
+	}
+
+	if server.ContentReloader == nil {
+		handleServerError(writer, &ContentReloadUnavailableError{})
+		return
+	}
+
+	changes, err := server.ContentReloader.Reload()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload rule content")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("changes", changes))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// contentReloaderMock returns the configured result of reloading
+type contentReloaderMock struct {
+	changes content.Changes
+	err     error
+}
+
+func (reloader contentReloaderMock) Reload() (content.Changes, error) {
+	return reloader.changes, reloader.err
+}
+
+func checkContentReload(t *testing.T, reloader server.ContentReloader, expectedStatusCode int, expectedBody string) {
+	testServer := server.New(helpers.DefaultServerConfig, nil)
+	testServer.ContentReloader = reloader
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		server.MakeURLToEndpoint(helpers.DefaultServerConfig.APIPrefix, server.ReloadContentEndpoint),
+		nil,
+	)
+	helpers.FailOnError(t, err)
+
+	response := helpers.ExecuteRequest(testServer, req, &helpers.DefaultServerConfig).Result()
+
+	assert.Equal(t, expectedStatusCode, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, expectedBody, response.Body)
+}
+
+func TestReloadContentUnavailable(t *testing.T) {
+	checkContentReload(
+		t, nil, http.StatusServiceUnavailable, `{"status": "Rule content reloading is not available"}`,
+	)
+}
+
+func TestReloadContent(t *testing.T) {
+	checkContentReload(t, contentReloaderMock{
+		changes: content.Changes{
+			Added:   []string{"rule2"},
+			Changed: []string{"rule1"},
+			Removed: []string{},
+		},
+	}, http.StatusOK, `{
+		"changes": {"added": ["rule2"], "changed": ["rule1"], "removed": []},
+		"status": "ok"
+	}`)
+}
+
+func TestReloadContentInvalid(t *testing.T) {
+	checkContentReload(t, contentReloaderMock{
+		err: &content.InvalidContentError{Problems: []string{"first problem", "second problem"}},
+	}, http.StatusUnprocessableEntity, `{
+		"status": "invalid rule content: first problem; second problem"
+	}`)
+}
+
+func TestReloadContentByExternalUser(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:      http.MethodPost,
+		Endpoint:    server.ReloadContentEndpoint,
+		XRHIdentity: makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "only internal users can reload rule content"}`,
+	})
+
+	// internal users pass the check, the reloading isn't enabled in the test server
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:      http.MethodPost,
+		Endpoint:    server.ReloadContentEndpoint,
+		XRHIdentity: makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status": "Rule content reloading is not available"}`,
+	})
+}
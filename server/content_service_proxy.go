@@ -0,0 +1,239 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+const (
+	// DefaultContentServiceTimeout is used when the timeout of requests to the content service is not configured
+	DefaultContentServiceTimeout = 10 * time.Second
+
+	// staleWarning is the value of Warning header sent with responses served from
+	// the cache after their TTL expired, see RFC 7234, section 5.5.1
+	staleWarning = `110 - "Response is Stale"`
+)
+
+// contentServiceResponse is a successful response of the content service kept in the cache
+type contentServiceResponse struct {
+	header    http.Header
+	body      []byte
+	fetchedAt time.Time
+}
+
+// contentServiceCache keeps the last successful response of every endpoint proxied
+// to the content service, so its size is bounded by the number of the endpoints.
+// The responses are served directly until their TTL expires, after that they are
+// used only when the content service is not available. Zero TTL disables the cache
+// completely.
+type contentServiceCache struct {
+	ttl       time.Duration
+	mutex     sync.RWMutex
+	responses map[string]*contentServiceResponse
+}
+
+func newContentServiceCache(ttl time.Duration) *contentServiceCache {
+	return &contentServiceCache{
+		ttl:       ttl,
+		responses: make(map[string]*contentServiceResponse),
+	}
+}
+
+// enabled returns false if the responses mustn't be cached at all
+func (cache *contentServiceCache) enabled() bool {
+	return cache.ttl > 0
+}
+
+// get returns the cached response of the key (nil if there's none)
+// and whether it is still fresh
+func (cache *contentServiceCache) get(key string) (*contentServiceResponse, bool) {
+	if !cache.enabled() {
+		return nil, false
+	}
+
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	response, found := cache.responses[key]
+	if !found {
+		return nil, false
+	}
+
+	return response, time.Since(response.fetchedAt) < cache.ttl
+}
+
+// isCacheable checks whether the response of the content service can be served to all users,
+// the responses depending on the identity forwarded to the content service are not cached
+func isCacheable(header http.Header) bool {
+	cacheControl := strings.ToLower(strings.Join(header["Cache-Control"], ","))
+	if strings.Contains(cacheControl, "private") || strings.Contains(cacheControl, "no-store") {
+		return false
+	}
+
+	for _, vary := range header["Vary"] {
+		for _, name := range strings.Split(vary, ",") {
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "*", "authorization", "x-rh-identity":
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (cache *contentServiceCache) store(key string, response *contentServiceResponse) {
+	if !cache.enabled() {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.responses[key] = response
+}
+
+// send writes the cached response to the client
+func (response *contentServiceResponse) send(writer http.ResponseWriter, stale bool) {
+	for name, values := range response.header {
+		for _, value := range values {
+			writer.Header().Add(name, value)
+		}
+	}
+
+	if stale {
+		writer.Header().Set("Warning", staleWarning)
+	}
+
+	writer.WriteHeader(http.StatusOK)
+
+	_, err := writer.Write(response.body)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// proxyToContentService forwards the request to the endpoint of the content service.
+// Headers of the request, including the identity ones, are passed as they are, the query
+// is dropped, because the proxied endpoints don't have any parameters. Successful responses
+// are cached per endpoint unless they depend on the identity, the cached ones are served while
+// they're fresh and also when the content service is not available or responds with server error.
+func (server *HTTPServer) proxyToContentService(writer http.ResponseWriter, request *http.Request, endpoint string) {
+	cached, fresh := server.contentServiceCache.get(endpoint)
+	if fresh {
+		metrics.ContentServiceCacheHits.WithLabelValues("fresh").Inc()
+		cached.send(writer, false)
+		return
+	}
+
+	targetURL, err := url.Parse(server.Config.ContentServiceURL + endpoint)
+	if err != nil {
+		log.Error().Err(err).Msg("Error during Content Service URL parsing")
+		handleServerError(writer, err)
+		return
+	}
+
+	timeout := server.Config.ContentServiceTimeout
+	if timeout <= 0 {
+		timeout = DefaultContentServiceTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	observed := false
+	observeLatency := func(statusCode string) {
+		if !observed {
+			metrics.ContentServiceResponseTime.WithLabelValues(statusCode).Observe(time.Since(startTime).Seconds())
+			observed = true
+		}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(proxyRequest *http.Request) {
+			proxyRequest.URL = targetURL
+			proxyRequest.Host = targetURL.Host
+			// CORS is handled by this server, not by the content service
+			proxyRequest.Header.Del("Origin")
+		},
+		ModifyResponse: func(response *http.Response) error {
+			observeLatency(strconv.Itoa(response.StatusCode))
+
+			if response.StatusCode >= http.StatusInternalServerError {
+				return &ContentServiceUnavailableError{}
+			}
+
+			for name := range response.Header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					response.Header.Del(name)
+				}
+			}
+
+			if response.StatusCode != http.StatusOK {
+				return nil
+			}
+
+			body, err := ioutil.ReadAll(response.Body)
+			_ = response.Body.Close()
+			if err != nil {
+				return err
+			}
+			response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			if !isCacheable(response.Header) {
+				return nil
+			}
+
+			server.contentServiceCache.store(endpoint, &contentServiceResponse{
+				header:    response.Header.Clone(),
+				body:      body,
+				fetchedAt: time.Now(),
+			})
+
+			return nil
+		},
+		ErrorHandler: func(writer http.ResponseWriter, _ *http.Request, err error) {
+			observeLatency("error")
+			log.Error().Err(err).Msg("Content service unavailable")
+
+			if cached != nil {
+				metrics.ContentServiceCacheHits.WithLabelValues("stale").Inc()
+				cached.send(writer, true)
+				return
+			}
+
+			handleServerError(writer, &ContentServiceUnavailableError{})
+		},
+	}
+
+	proxy.ServeHTTP(writer, request.WithContext(ctx))
+}
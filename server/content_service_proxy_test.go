@@ -0,0 +1,240 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+const groupsResponse = `{"groups": [{"title": "Performance", "tags": ["performance"]}], "status": "ok"}`
+
+// contentServiceMock is a stand-in for the content service counting received requests
+type contentServiceMock struct {
+	*httptest.Server
+	requests   int32
+	statusCode int32
+	lastHeader atomic.Value
+	lastQuery  atomic.Value
+	// vary is sent in the Vary header of the responses when set
+	vary atomic.Value
+}
+
+func newContentServiceMock(t *testing.T) *contentServiceMock {
+	contentService := &contentServiceMock{statusCode: http.StatusOK}
+
+	contentService.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&contentService.requests, 1)
+		contentService.lastHeader.Store(request.Header)
+		contentService.lastQuery.Store(request.URL.RawQuery)
+
+		assert.Equal(t, "/api/v1/groups", request.URL.Path)
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if vary, ok := contentService.vary.Load().(string); ok {
+			writer.Header().Set("Vary", vary)
+		}
+		writer.WriteHeader(int(atomic.LoadInt32(&contentService.statusCode)))
+		_, _ = writer.Write([]byte(groupsResponse))
+	}))
+
+	return contentService
+}
+
+func (contentService *contentServiceMock) config(ttl time.Duration) server.Configuration {
+	serverConfig := config
+	serverConfig.ContentServiceURL = contentService.URL + "/api/v1/"
+	serverConfig.ContentServiceCacheTTL = ttl
+
+	return serverConfig
+}
+
+func requestGroups(t *testing.T, testServer *server.HTTPServer, serverConfig *server.Configuration) *http.Response {
+	return requestGroupsWithQuery(t, testServer, serverConfig, "")
+}
+
+func requestGroupsWithQuery(
+	t *testing.T, testServer *server.HTTPServer, serverConfig *server.Configuration, query string,
+) *http.Response {
+	endpoint := server.MakeURLToEndpoint(serverConfig.APIPrefix, server.RuleGroupsEndpoint)
+	if query != "" {
+		endpoint += "?" + query
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	helpers.FailOnError(t, err)
+
+	req.Header.Set("x-rh-identity", "identity")
+	req.Header.Set("Authorization", "Bearer token")
+
+	return helpers.ExecuteRequest(testServer, req, serverConfig).Result()
+}
+
+func assertGroupsResponse(t *testing.T, response *http.Response) {
+	body, err := ioutil.ReadAll(response.Body)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, groupsResponse, string(body))
+}
+
+func TestGetRuleGroupsProxy(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	serverConfig := contentService.config(time.Minute)
+	testServer := server.New(serverConfig, nil)
+
+	response := requestGroups(t, testServer, &serverConfig)
+	assertGroupsResponse(t, response)
+
+	// CORS headers are added by this server only
+	assert.Equal(t, []string{"*"}, response.Header["Access-Control-Allow-Origin"])
+	assert.Empty(t, response.Header.Get("Warning"))
+
+	upstreamHeader := contentService.lastHeader.Load().(http.Header)
+	assert.Equal(t, "identity", upstreamHeader.Get("x-rh-identity"))
+	assert.Equal(t, "Bearer token", upstreamHeader.Get("Authorization"))
+
+	// the second response is served from the cache
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&contentService.requests))
+}
+
+func TestGetRuleGroupsQuery(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	serverConfig := contentService.config(time.Minute)
+	testServer := server.New(serverConfig, nil)
+
+	assertGroupsResponse(t, requestGroupsWithQuery(t, testServer, &serverConfig, "tag=security&lang=en"))
+	assert.Equal(t, "", contentService.lastQuery.Load())
+
+	// the query doesn't create new cache entries
+	assertGroupsResponse(t, requestGroupsWithQuery(t, testServer, &serverConfig, "tag=performance"))
+	assertGroupsResponse(t, requestGroupsWithQuery(t, testServer, &serverConfig, "random=1"))
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&contentService.requests))
+}
+
+func TestGetRuleGroupsIdentityResponse(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+	contentService.vary.Store("Accept-Encoding, X-Rh-Identity")
+
+	serverConfig := contentService.config(time.Minute)
+	testServer := server.New(serverConfig, nil)
+
+	// responses depending on the identity are never served to other users
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&contentService.requests))
+
+	atomic.StoreInt32(&contentService.statusCode, http.StatusInternalServerError)
+	assert.Equal(t, http.StatusServiceUnavailable, requestGroups(t, testServer, &serverConfig).StatusCode)
+}
+
+func TestGetRuleGroupsCacheDisabled(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	serverConfig := contentService.config(0)
+	testServer := server.New(serverConfig, nil)
+
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&contentService.requests))
+
+	// nothing stale is served either
+	atomic.StoreInt32(&contentService.statusCode, http.StatusInternalServerError)
+	assert.Equal(t, http.StatusServiceUnavailable, requestGroups(t, testServer, &serverConfig).StatusCode)
+}
+
+func TestGetRuleGroupsExpiredCache(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	serverConfig := contentService.config(time.Nanosecond)
+	testServer := server.New(serverConfig, nil)
+
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&contentService.requests))
+}
+
+func TestGetRuleGroupsServeStale(t *testing.T) {
+	contentService := newContentServiceMock(t)
+
+	serverConfig := contentService.config(time.Nanosecond)
+	testServer := server.New(serverConfig, nil)
+
+	assertGroupsResponse(t, requestGroups(t, testServer, &serverConfig))
+
+	// server error of the content service
+	atomic.StoreInt32(&contentService.statusCode, http.StatusInternalServerError)
+
+	response := requestGroups(t, testServer, &serverConfig)
+	assertGroupsResponse(t, response)
+	assert.Equal(t, `110 - "Response is Stale"`, response.Header.Get("Warning"))
+
+	// content service is down
+	contentService.Close()
+
+	response = requestGroups(t, testServer, &serverConfig)
+	assertGroupsResponse(t, response)
+	assert.Equal(t, `110 - "Response is Stale"`, response.Header.Get("Warning"))
+}
+
+func TestGetRuleGroupsServerErrorWithoutCache(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	atomic.StoreInt32(&contentService.statusCode, http.StatusBadGateway)
+	serverConfig := contentService.config(time.Minute)
+
+	helpers.AssertAPIRequest(t, nil, &serverConfig, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleGroupsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status": "Content service is unreachable"}`,
+	})
+}
+
+func TestGetRuleGroupsClientErrorNotCached(t *testing.T) {
+	contentService := newContentServiceMock(t)
+	defer contentService.Close()
+
+	atomic.StoreInt32(&contentService.statusCode, http.StatusNotFound)
+	serverConfig := contentService.config(time.Minute)
+	testServer := server.New(serverConfig, nil)
+
+	assert.Equal(t, http.StatusNotFound, requestGroups(t, testServer, &serverConfig).StatusCode)
+	assert.Equal(t, http.StatusNotFound, requestGroups(t, testServer, &serverConfig).StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&contentService.requests))
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// getContentVersion responds with version of the rule content loaded into the storage
+func (server *HTTPServer) getContentVersion(writer http.ResponseWriter, request *http.Request) {
+	version, err := server.requestStorage(request).GetContentVersion()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read content version")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("content_version", version))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readContentVersionHash returns hash of the loaded rule content included in reports,
+// the report is still served when the version is not available
+func (server *HTTPServer) readContentVersionHash(request *http.Request) string {
+	version, err := server.requestStorage(request).GetContentVersion()
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to read content version")
+		return ""
+	}
+
+	if version == nil {
+		return ""
+	}
+
+	return version.Hash
+}
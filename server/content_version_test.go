@@ -0,0 +1,110 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustLoadVersionedContent(t *testing.T, mockStorage storage.Storage) {
+	contentDir := testdata.RuleContent3Rules
+	contentDir.Version = content.RuleContentVersion{Hash: "0123abcd", Commit: "fedcba98"}
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(contentDir))
+}
+
+func TestGetContentVersionNotLoaded(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ContentVersionEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID content version was not found in the storage"}`,
+	})
+}
+
+func TestGetContentVersion(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadVersionedContent(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ContentVersionEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Status         string               `json:"status"`
+				ContentVersion types.ContentVersion `json:"content_version"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Equal(t, "ok", response.Status)
+			assert.Equal(t, "0123abcd", response.ContentVersion.Hash)
+			assert.Equal(t, "fedcba98", response.ContentVersion.Commit)
+			assert.WithinDuration(t, time.Now(), response.ContentVersion.LoadedAt, time.Minute)
+		},
+	})
+}
+
+func TestReadReportContainsContentVersion(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadVersionedContent(t, mockStorage)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"report": {
+				"meta": {
+					"count": -1,
+					"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+					"content_version": "0123abcd"
+				},
+				"data":[]
+			}
+		}`,
+	})
+}
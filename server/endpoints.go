@@ -43,6 +43,12 @@ const (
 	ResetVoteOnRuleEndpoint = "clusters/{cluster}/rules/{rule_id}/reset_vote"
 	// GetVoteOnRuleEndpoint is an endpoint to get vote on rule. DEBUG only
 	GetVoteOnRuleEndpoint = "clusters/{cluster}/rules/{rule_id}/get_vote"
+	// RulesEndpoint returns page of all rules with their error keys
+	RulesEndpoint = "rules"
+	// RuleTagsEndpoint returns all tags of rules with number of rule error keys having them
+	RuleTagsEndpoint = "rules/tags"
+	// RuleSearchEndpoint returns rules matching full-text query
+	RuleSearchEndpoint = "rules/search"
 	// RuleEndpoint is an endpoint to create&delete a rule. DEBUG only
 	RuleEndpoint = "rules/{rule_id}"
 	// RuleErrorKeyEndpoint is for endpoints to create&delete a rule_error_key (DEBUG only)
@@ -52,10 +58,39 @@ const (
 	RuleGroupsEndpoint = "groups"
 	// ClustersForOrganizationEndpoint returns all clusters for {organization}
 	ClustersForOrganizationEndpoint = "organizations/{organization}/clusters"
+	// OrganizationEventsEndpoint streams Server-Sent Events about changes of clusters in {organization}
+	OrganizationEventsEndpoint = "organizations/{organization}/events"
+	// OrganizationWebhooksEndpoint lists and creates webhook subscriptions of {organization}
+	OrganizationWebhooksEndpoint = "organizations/{organization}/webhooks"
+	// OrganizationWebhookEndpoint returns, updates and deletes webhook subscription {webhook_id} of {organization}
+	OrganizationWebhookEndpoint = "organizations/{organization}/webhooks/{webhook_id}"
+	// WebhookDeliveriesEndpoint returns the latest deliveries of webhook {webhook_id} of {organization}
+	WebhookDeliveriesEndpoint = "organizations/{organization}/webhooks/{webhook_id}/deliveries"
+	// PurgeOrganizationEndpoint removes all data of {organization}, allowed only to internal users
+	PurgeOrganizationEndpoint = "admin/organizations/{organization}"
 	// DisableRuleForClusterEndpoint disables a rule for specified cluster
 	DisableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/disable"
 	// EnableRuleForClusterEndpoint re-enables a rule for specified cluster
 	EnableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/enable"
+	// DisabledRulesForOrganizationEndpoint returns rules disabled on all clusters of {organization}
+	// for all its users or for the current user
+	DisabledRulesForOrganizationEndpoint = "organizations/{organization}/rules/disabled"
+	// DisableRuleForOrganizationEndpoint disables a rule on all clusters of {organization} for all its users
+	DisableRuleForOrganizationEndpoint = "organizations/{organization}/rules/{rule_id}/disable"
+	// EnableRuleForOrganizationEndpoint re-enables a rule disabled for all users of {organization}
+	EnableRuleForOrganizationEndpoint = "organizations/{organization}/rules/{rule_id}/enable"
+	// DisableRuleForUserEndpoint disables a rule on all clusters of {organization} for the current user
+	DisableRuleForUserEndpoint = "organizations/{organization}/rules/{rule_id}/disable_for_user"
+	// EnableRuleForUserEndpoint re-enables a rule disabled on all clusters of {organization} for the current user
+	EnableRuleForUserEndpoint = "organizations/{organization}/rules/{rule_id}/enable_for_user"
+	// ReloadContentEndpoint reloads rule content from the content directory, allowed only to internal users
+	ReloadContentEndpoint = "admin/content/reload"
+	// ContentVersionEndpoint returns version of the loaded rule content
+	ContentVersionEndpoint = "content/version"
+	// LivenessEndpoint returns status ok when the server is able to handle requests
+	LivenessEndpoint = "health/live"
+	// ReadinessEndpoint returns results of health checks of dependencies, 503 if any of them fails
+	ReadinessEndpoint = "health/ready"
 	// MetricsEndpoint returns prometheus metrics
 	MetricsEndpoint = "metrics"
 )
@@ -87,15 +122,35 @@ func (server *HTTPServer) addEndpointsToRouter(router *mux.Router) {
 
 	// common REST API endpoints
 	router.HandleFunc(apiPrefix+MainEndpoint, server.mainEndpoint).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+LivenessEndpoint, server.liveness).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ReadinessEndpoint, server.readiness).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ReportEndpoint, server.readReportForCluster).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+LikeRuleEndpoint, server.likeRule).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+DislikeRuleEndpoint, server.dislikeRule).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ResetVoteOnRuleEndpoint, server.resetVoteOnRule).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ClustersForOrganizationEndpoint, server.listOfClustersForOrganization).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrganizationEventsEndpoint, server.streamOrganizationEvents).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrganizationWebhooksEndpoint, server.listWebhooks).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrganizationWebhooksEndpoint, server.createWebhook).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc(apiPrefix+OrganizationWebhookEndpoint, server.getWebhook).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrganizationWebhookEndpoint, server.updateWebhook).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+OrganizationWebhookEndpoint, server.deleteWebhook).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc(apiPrefix+WebhookDeliveriesEndpoint, server.listWebhookDeliveries).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+PurgeOrganizationEndpoint, server.purgeOrganization).Methods(http.MethodDelete)
 	router.HandleFunc(apiPrefix+DisableRuleForClusterEndpoint, server.disableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+EnableRuleForClusterEndpoint, server.enableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+DisabledRulesForOrganizationEndpoint, server.listDisabledRulesForOrganization).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+DisableRuleForOrganizationEndpoint, server.disableRuleForOrganization).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+EnableRuleForOrganizationEndpoint, server.enableRuleForOrganization).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+DisableRuleForUserEndpoint, server.disableRuleForUser).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+EnableRuleForUserEndpoint, server.enableRuleForUser).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+RuleGroupsEndpoint, server.getRuleGroups).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+RuleErrorKeyEndpoint, server.getRule).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleSearchEndpoint, server.searchRules).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RulesEndpoint, server.listRules).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleTagsEndpoint, server.listRuleTags).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ReloadContentEndpoint, server.reloadContent).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+ContentVersionEndpoint, server.getContentVersion).Methods(http.MethodGet)
 
 	// Prometheus metrics
 	router.Handle(apiPrefix+MetricsEndpoint, promhttp.Handler()).Methods(http.MethodGet)
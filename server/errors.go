@@ -22,6 +22,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/content"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -51,6 +52,20 @@ func (e *RouterParsingError) Error() string {
 	)
 }
 
+// ValidationError validation error, for example when value in request body is out of allowed range
+type ValidationError struct {
+	paramName  string
+	paramValue interface{}
+	errString  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf(
+		"Error during validating param '%v' with value '%v'. Error: '%v'",
+		e.paramName, e.paramValue, e.errString,
+	)
+}
+
 // AuthenticationError happens during auth problems, for example malformed token
 type AuthenticationError struct {
 	errString string
@@ -74,6 +89,20 @@ func (*ContentServiceUnavailableError) Error() string {
 	return "Content service is unreachable"
 }
 
+// EventStreamUnavailableError error is used when the events can't be streamed to the client
+type EventStreamUnavailableError struct{}
+
+func (*EventStreamUnavailableError) Error() string {
+	return "Event stream is not available"
+}
+
+// ContentReloadUnavailableError error is used when rule content reloading is not enabled
+type ContentReloadUnavailableError struct{}
+
+func (*ContentReloadUnavailableError) Error() string {
+	return "Rule content reloading is not available"
+}
+
 // handleServerError handles separate server errors and sends appropriate responses
 func handleServerError(writer http.ResponseWriter, err error) {
 	log.Error().Err(err).Msg("handleServerError()")
@@ -81,7 +110,7 @@ func handleServerError(writer http.ResponseWriter, err error) {
 	var respErr error
 
 	switch err := err.(type) {
-	case *RouterMissingParamError, *RouterParsingError, *json.SyntaxError, *NoBodyError:
+	case *RouterMissingParamError, *RouterParsingError, *json.SyntaxError, *NoBodyError, *ValidationError:
 		respErr = responses.SendBadRequest(writer, err.Error())
 	case *json.UnmarshalTypeError:
 		respErr = responses.SendBadRequest(writer, "bad type in json data")
@@ -89,8 +118,10 @@ func handleServerError(writer http.ResponseWriter, err error) {
 		respErr = responses.SendNotFound(writer, err.Error())
 	case *AuthenticationError:
 		respErr = responses.SendForbidden(writer, err.Error())
-	case *ContentServiceUnavailableError:
+	case *ContentServiceUnavailableError, *EventStreamUnavailableError, *ContentReloadUnavailableError:
 		respErr = responses.SendServiceUnavailable(writer, err.Error())
+	case *content.InvalidContentError:
+		respErr = responses.Send(http.StatusUnprocessableEntity, writer, responses.BuildResponse(err.Error()))
 	default:
 		respErr = responses.SendInternalServerError(writer, "Internal Server Error")
 	}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// eventStreamKeepAliveInterval is the period of comments sent to idle streams
+// so that proxies and load balancers don't close the connection
+const eventStreamKeepAliveInterval = 30 * time.Second
+
+// streamOrganizationEvents streams events related to clusters of the organization
+// to the client using Server-Sent Events. The stream is open until the client
+// disconnects or the server is stopped.
+func (server *HTTPServer) streamOrganizationEvents(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Response writer does not support streaming")
+		handleServerError(writer, &EventStreamUnavailableError{})
+		return
+	}
+
+	subscription := server.EventBus.Subscribe(organizationID)
+	if subscription == nil {
+		log.Error().Msg("Event bus is not available")
+		handleServerError(writer, &EventStreamUnavailableError{})
+		return
+	}
+	defer server.EventBus.Unsubscribe(subscription)
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// the bus has been closed
+				return
+			}
+
+			if err := writeEvent(writer, event); err != nil {
+				log.Error().Err(err).Msg("Unable to send event to client")
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(writer, ": keep-alive\n\n"); err != nil {
+				log.Error().Err(err).Msg("Unable to send keep-alive to client")
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one event in Server-Sent Events format
+func writeEvent(writer io.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// publishClusterEvent notifies subscribers of the cluster's organization about a change
+func (server *HTTPServer) publishClusterEvent(
+	eventType events.EventType, clusterID types.ClusterName, ruleID types.RuleID,
+) {
+	if server.EventBus == nil {
+		return
+	}
+
+	// the change is already done, so the event is published
+	// even when the client of the request has disconnected
+	orgID, err := server.Storage.GetOrgIDByClusterID(clusterID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get org id, event is not published")
+		return
+	}
+
+	server.EventBus.Publish(events.Event{
+		Type:        eventType,
+		OrgID:       orgID,
+		ClusterName: clusterID,
+		RuleID:      ruleID,
+	})
+}
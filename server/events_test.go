@@ -0,0 +1,179 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+)
+
+// readEvent reads one event from Server-Sent Events stream
+func readEvent(t *testing.T, reader *bufio.Reader) (string, events.Event) {
+	var (
+		eventType string
+		event     events.Event
+	)
+
+	for {
+		line, err := reader.ReadString('\n')
+		helpers.FailOnError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return eventType, event
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			helpers.FailOnError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		}
+	}
+}
+
+func TestStreamOrganizationEvents(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	bus := events.NewBus()
+	defer bus.Close()
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.EventBus = bus
+
+	httpServer := httptest.NewServer(testServer.Initialize(helpers.DefaultServerConfig.Address))
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + server.MakeURLToEndpoint(
+		helpers.DefaultServerConfig.APIPrefix, server.OrganizationEventsEndpoint, testdata.OrgID,
+	))
+	helpers.FailOnError(t, err)
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	// headers are sent only after the subscription has been made,
+	// event for another organization must not be streamed
+	bus.Publish(events.Event{
+		Type:        events.RuleToggled,
+		OrgID:       testdata.OrgID + 1,
+		ClusterName: testdata.ClusterName,
+	})
+	bus.Publish(events.Event{
+		Type:        events.ReportStored,
+		OrgID:       testdata.OrgID,
+		ClusterName: testdata.ClusterName,
+	})
+
+	reader := bufio.NewReader(response.Body)
+
+	eventType, event := readEvent(t, reader)
+	assert.Equal(t, string(events.ReportStored), eventType)
+	assert.Equal(t, events.ReportStored, event.Type)
+	assert.Equal(t, testdata.OrgID, event.OrgID)
+	assert.Equal(t, testdata.ClusterName, event.ClusterName)
+
+	// stream ends when the bus is closed
+	bus.Close()
+
+	_, err = reader.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestStreamOrganizationEventsBadOrganization(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationEventsEndpoint,
+		EndpointArgs: []interface{}{"not-a-number"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestStreamOrganizationEventsNoBus(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationEventsEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+	})
+}
+
+func TestToggleAndVotePublishEvents(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	bus := events.NewBus()
+	defer bus.Close()
+
+	subscription := bus.Subscribe(testdata.OrgID)
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.EventBus = bus
+
+	for _, endpoint := range []string{server.DisableRuleForClusterEndpoint, server.LikeRuleEndpoint} {
+		req, err := http.NewRequest(http.MethodPut, server.MakeURLToEndpoint(
+			helpers.DefaultServerConfig.APIPrefix, endpoint, testdata.ClusterName, testdata.Rule1ID,
+		), nil)
+		helpers.FailOnError(t, err)
+
+		identity := server.Identity{AccountNumber: testdata.UserID}
+		req = req.WithContext(context.WithValue(req.Context(), server.ContextKeyUser, identity))
+
+		response := helpers.ExecuteRequest(testServer, req, &helpers.DefaultServerConfig)
+		checkResponseCode(t, http.StatusOK, response.Code)
+	}
+
+	for _, expectedType := range []events.EventType{events.RuleToggled, events.RuleVoted} {
+		select {
+		case event := <-subscription.Events():
+			assert.Equal(t, expectedType, event.Type)
+			assert.Equal(t, testdata.OrgID, event.OrgID)
+			assert.Equal(t, testdata.ClusterName, event.ClusterName)
+			assert.Equal(t, testdata.Rule1ID, event.RuleID)
+		case <-time.After(time.Second):
+			t.Fatalf("event %v hasn't been published", expectedType)
+		}
+	}
+}
@@ -24,4 +24,6 @@ var (
 	ReadClusterNames          = readClusterNames
 	GetRouterPositiveIntParam = getRouterPositiveIntParam
 	ReadRuleID                = readRuleID
+	ParseAcceptLanguage       = parseAcceptLanguage
+	SelectLanguage            = selectLanguage
 )
@@ -0,0 +1,162 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+)
+
+const (
+	// healthCheckTimeout limits how long one dependency check can take
+	healthCheckTimeout = 5 * time.Second
+
+	healthStatusOK    = "ok"
+	healthStatusError = "error"
+)
+
+// HealthCheck is a named check of one dependency of the service done by the
+// readiness endpoint. Check returns optional details included in the response
+// and an error when the dependency is not usable.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) (interface{}, error)
+}
+
+// ConsumerSession is implemented by consumers able to tell whether
+// they are currently joined to a session of their consumer group
+type ConsumerSession interface {
+	HasActiveSession() bool
+}
+
+// healthCheckResult is the result of one check in the readiness response
+type healthCheckResult struct {
+	Status  string      `json:"status"`
+	Details interface{} `json:"details,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// DatabaseHealthCheck pings the database connection
+func DatabaseHealthCheck(connection *sql.DB) HealthCheck {
+	return HealthCheck{
+		Name: "database",
+		Check: func(ctx context.Context) (interface{}, error) {
+			return nil, connection.PingContext(ctx)
+		},
+	}
+}
+
+// MigrationHealthCheck checks that the database has the latest migration version
+func MigrationHealthCheck(connection *sql.DB) HealthCheck {
+	return HealthCheck{
+		Name: "migration",
+		Check: func(ctx context.Context) (interface{}, error) {
+			currentVersion, err := migration.GetDBVersionContext(ctx, connection)
+			if err != nil {
+				return nil, err
+			}
+
+			latestVersion := migration.GetMaxVersion()
+			details := map[string]migration.Version{
+				"current_version": currentVersion,
+				"latest_version":  latestVersion,
+			}
+
+			if currentVersion != latestVersion {
+				return details, fmt.Errorf(
+					"old DB migration version (current: %d, latest: %d)", currentVersion, latestVersion,
+				)
+			}
+
+			return details, nil
+		},
+	}
+}
+
+// ConsumerHealthCheck checks that the consumer has an active session,
+// getConsumer returns nil if the consumer has not been started yet
+func ConsumerHealthCheck(getConsumer func() ConsumerSession) HealthCheck {
+	return HealthCheck{
+		Name: "kafka_consumer",
+		Check: func(context.Context) (interface{}, error) {
+			consumer := getConsumer()
+			if consumer == nil {
+				return nil, fmt.Errorf("consumer has not been started")
+			}
+
+			active := consumer.HasActiveSession()
+			details := map[string]bool{"session_active": active}
+
+			if !active {
+				return details, fmt.Errorf("consumer has no active session")
+			}
+
+			return details, nil
+		},
+	}
+}
+
+// liveness responds OK whenever the server is able to handle requests, dependencies
+// are not checked so that the pod isn't restarted just because of their outage
+func (server *HTTPServer) liveness(writer http.ResponseWriter, _ *http.Request) {
+	err := responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readiness runs all health checks and responds with their results,
+// status code 503 is returned when any of them fails
+func (server *HTTPServer) readiness(writer http.ResponseWriter, request *http.Request) {
+	status := healthStatusOK
+	statusCode := http.StatusOK
+	checks := make(map[string]healthCheckResult)
+
+	for _, healthCheck := range server.HealthChecks {
+		ctx, cancel := context.WithTimeout(request.Context(), healthCheckTimeout)
+		details, err := healthCheck.Check(ctx)
+		cancel()
+
+		result := healthCheckResult{Status: healthStatusOK, Details: details}
+		if err != nil {
+			log.Warn().Err(err).Str("check", healthCheck.Name).Msg("Health check failed")
+
+			result.Status = healthStatusError
+			result.Error = err.Error()
+			status = healthStatusError
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		checks[healthCheck.Name] = result
+	}
+
+	err := responses.Send(statusCode, writer, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
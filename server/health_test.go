@@ -0,0 +1,176 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// consumerSessionMock reports the configured session state
+type consumerSessionMock struct {
+	active bool
+}
+
+func (consumer consumerSessionMock) HasActiveSession() bool {
+	return consumer.active
+}
+
+func consumerGetter(consumer server.ConsumerSession) func() server.ConsumerSession {
+	return func() server.ConsumerSession {
+		return consumer
+	}
+}
+
+func checkReadiness(t *testing.T, healthChecks []server.HealthCheck, expectedStatusCode int, expectedBody string) {
+	testServer := server.New(helpers.DefaultServerConfig, nil)
+	testServer.HealthChecks = healthChecks
+
+	req, err := http.NewRequest(
+		http.MethodGet,
+		server.MakeURLToEndpoint(helpers.DefaultServerConfig.APIPrefix, server.ReadinessEndpoint),
+		nil,
+	)
+	helpers.FailOnError(t, err)
+
+	response := helpers.ExecuteRequest(testServer, req, &helpers.DefaultServerConfig).Result()
+
+	assert.Equal(t, expectedStatusCode, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, expectedBody, response.Body)
+}
+
+func TestLiveness(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.LivenessEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+}
+
+func TestReadinessWithoutChecks(t *testing.T) {
+	checkReadiness(t, nil, http.StatusOK, `{"status": "ok", "checks": {}}`)
+}
+
+func TestReadinessOK(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	latestVersion := migration.GetMaxVersion()
+
+	checkReadiness(t, []server.HealthCheck{
+		server.DatabaseHealthCheck(connection),
+		server.MigrationHealthCheck(connection),
+		server.ConsumerHealthCheck(consumerGetter(consumerSessionMock{active: true})),
+	}, http.StatusOK, fmt.Sprintf(`{
+		"status": "ok",
+		"checks": {
+			"database": {"status": "ok"},
+			"migration": {
+				"status": "ok",
+				"details": {"current_version": %d, "latest_version": %d}
+			},
+			"kafka_consumer": {"status": "ok", "details": {"session_active": true}}
+		}
+	}`, latestVersion, latestVersion))
+}
+
+func TestReadinessOldMigration(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	connection := dbStorage.GetConnection()
+	latestVersion := migration.GetMaxVersion()
+
+	helpers.FailOnError(t, migration.SetDBVersion(connection, dbStorage.GetDBDriverType(), latestVersion-1))
+
+	checkReadiness(t, []server.HealthCheck{
+		server.MigrationHealthCheck(connection),
+	}, http.StatusServiceUnavailable, fmt.Sprintf(`{
+		"status": "error",
+		"checks": {
+			"migration": {
+				"status": "error",
+				"details": {"current_version": %d, "latest_version": %d},
+				"error": "old DB migration version (current: %d, latest: %d)"
+			}
+		}
+	}`, latestVersion-1, latestVersion, latestVersion-1, latestVersion))
+}
+
+func TestMigrationHealthCheckCanceled(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	check := server.MigrationHealthCheck(mockStorage.(*storage.DBStorage).GetConnection())
+	_, err := check.Check(ctx)
+	assert.EqualError(t, err, context.Canceled.Error())
+}
+
+func TestReadinessDatabaseClosed(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	closer()
+
+	checkReadiness(t, []server.HealthCheck{
+		server.DatabaseHealthCheck(connection),
+	}, http.StatusServiceUnavailable, `{
+		"status": "error",
+		"checks": {
+			"database": {"status": "error", "error": "sql: database is closed"}
+		}
+	}`)
+}
+
+func TestReadinessConsumer(t *testing.T) {
+	checkReadiness(t, []server.HealthCheck{
+		server.ConsumerHealthCheck(consumerGetter(consumerSessionMock{active: false})),
+	}, http.StatusServiceUnavailable, `{
+		"status": "error",
+		"checks": {
+			"kafka_consumer": {
+				"status": "error",
+				"details": {"session_active": false},
+				"error": "consumer has no active session"
+			}
+		}
+	}`)
+
+	checkReadiness(t, []server.HealthCheck{
+		server.ConsumerHealthCheck(consumerGetter(nil)),
+	}, http.StatusServiceUnavailable, `{
+		"status": "error",
+		"checks": {
+			"kafka_consumer": {"status": "error", "error": "consumer has not been started"}
+		}
+	}`)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultLanguage is the language of the rule content without translation
+const defaultLanguage = "en"
+
+// acceptedLanguage is a language from Accept-Language header with its weight
+type acceptedLanguage struct {
+	tag     string
+	quality float64
+}
+
+// parseAcceptLanguage returns lower case language tags from Accept-Language header
+// ordered by their preference, the languages that are not acceptable are left out
+func parseAcceptLanguage(header string) []string {
+	var accepted []acceptedLanguage
+
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			var err error
+			if quality, err = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil {
+				quality = 0
+			}
+		}
+
+		if quality > 0 {
+			accepted = append(accepted, acceptedLanguage{tag: tag, quality: quality})
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+
+	tags := make([]string, 0, len(accepted))
+	for _, language := range accepted {
+		tags = append(tags, language.tag)
+	}
+
+	return tags
+}
+
+// selectLanguage selects the most preferred of the accepted languages that is available,
+// language with region (e.g. es-mx) falls back to the language without it (es).
+// Empty string is returned when the default language should be used.
+func selectLanguage(accepted, available []string) string {
+	isAvailable := map[string]bool{}
+	for _, language := range available {
+		isAvailable[language] = true
+	}
+
+	for _, tag := range accepted {
+		candidates := []string{tag}
+		if i := strings.Index(tag, "-"); i > 0 {
+			candidates = append(candidates, tag[:i])
+		}
+
+		for _, candidate := range candidates {
+			if candidate == defaultLanguage {
+				return ""
+			}
+			if isAvailable[candidate] {
+				return candidate
+			}
+		}
+	}
+
+	return ""
+}
+
+// readLanguage selects language of the rule content from Accept-Language header
+// and sets Content-Language header of the response accordingly. Vary header tells
+// caches that the response depends on Accept-Language. If it's not possible,
+// it writes http error to the writer and returns error
+func (server *HTTPServer) readLanguage(writer http.ResponseWriter, request *http.Request) (string, error) {
+	language := ""
+
+	writer.Header().Add("Vary", "Accept-Language")
+
+	if header := request.Header.Get("Accept-Language"); header != "" {
+		available, err := server.requestStorage(request).GetContentLanguages()
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read languages of rule content")
+			handleServerError(writer, err)
+			return "", err
+		}
+
+		language = selectLanguage(parseAcceptLanguage(header), available)
+	}
+
+	if language == "" {
+		writer.Header().Set("Content-Language", defaultLanguage)
+	} else {
+		writer.Header().Set("Content-Language", language)
+	}
+
+	return language, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Empty(t, server.ParseAcceptLanguage(""))
+	assert.Equal(t, []string{"ja"}, server.ParseAcceptLanguage("ja"))
+	assert.Equal(
+		t,
+		[]string{"es-mx", "ja", "es", "en"},
+		server.ParseAcceptLanguage("en;q=0.1, es;q=0.5, ES-MX, *;q=0.2, ja;q=0.8, fr;q=0, de;q=abc"),
+	)
+}
+
+func TestSelectLanguage(t *testing.T) {
+	available := []string{"es", "ja", "pt-br"}
+
+	assert.Equal(t, "ja", server.SelectLanguage([]string{"ja"}, available))
+	assert.Equal(t, "es", server.SelectLanguage([]string{"es-mx"}, available))
+	assert.Equal(t, "pt-br", server.SelectLanguage([]string{"fr", "pt-br"}, available))
+	// English is the language of the content without translation
+	assert.Equal(t, "", server.SelectLanguage([]string{"en-us", "ja"}, available))
+	assert.Equal(t, "", server.SelectLanguage([]string{"fr"}, available))
+	assert.Equal(t, "", server.SelectLanguage(nil, available))
+}
+
+// mustLoadTranslatedContent loads rule 1 with Japanese translation of its reason and generic texts
+func mustLoadTranslatedContent(t *testing.T, mockStorage storage.Storage) {
+	rule := testdata.RuleContent3Rules.Rules["rc1"]
+	rule.Translations = map[string]content.RuleTranslation{
+		"ja": {Summary: []byte("概要"), Reason: []byte("理由")},
+	}
+	errorKey := rule.ErrorKeys[testdata.ErrorKey1]
+	errorKey.GenericTranslations = map[string][]byte{"ja": []byte("汎用")}
+	rule.ErrorKeys = map[string]content.RuleErrorKeyContent{testdata.ErrorKey1: errorKey}
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules:  map[string]content.RuleContent{"rc1": rule},
+	}))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// checkTranslatedReport checks texts of the only rule in the report and the language of the response
+func checkTranslatedReport(
+	t *testing.T, mockStorage storage.Storage, acceptLanguage, contentLanguage, reason, generic string,
+) {
+	rule := testdata.RuleContent3Rules.Rules["rc1"]
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		Headers:      map[string]string{"Accept-Language": acceptLanguage},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Language": contentLanguage, "Vary": "Accept-Language"},
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Report types.ReportResponse `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Len(t, response.Report.Rules, 1)
+			assert.Equal(t, reason, response.Report.Rules[0].Reason)
+			assert.Equal(t, generic, response.Report.Rules[0].Generic)
+			// the texts without translation are in English
+			assert.Equal(t, string(rule.Resolution), response.Report.Rules[0].Resolution)
+		},
+	})
+}
+
+func TestReadReportTranslated(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTranslatedContent(t, mockStorage)
+
+	checkTranslatedReport(t, mockStorage, "ja-JP, en;q=0.5", "ja", "理由", "汎用")
+}
+
+func TestReadReportNotTranslated(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTranslatedContent(t, mockStorage)
+
+	rule := testdata.RuleContent3Rules.Rules["rc1"]
+	generic := string(rule.ErrorKeys[testdata.ErrorKey1].Generic)
+
+	checkTranslatedReport(t, mockStorage, "fr", "en", string(rule.Reason), generic)
+	checkTranslatedReport(t, mockStorage, "en, ja;q=0.5", "en", string(rule.Reason), generic)
+}
+
+func TestGetRuleTranslated(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTranslatedContent(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleErrorKeyEndpoint,
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+		Headers:      map[string]string{"Accept-Language": "ja"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Language": "ja", "Vary": "Accept-Language"},
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Rule types.RuleWithContent `json:"rule"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			rule := testdata.RuleContent3Rules.Rules["rc1"]
+			assert.Equal(t, "概要", response.Rule.Summary)
+			assert.Equal(t, "理由", response.Rule.Reason)
+			assert.Equal(t, string(rule.Resolution), response.Rule.Resolution)
+			assert.Equal(t, string(rule.MoreInfo), response.Rule.MoreInfo)
+			assert.Equal(t, "汎用", response.Rule.Generic)
+		},
+	})
+}
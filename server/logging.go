@@ -37,6 +37,13 @@ func (writer loggingResponseWriter) WriteHeader(statusCode int) {
 	).Inc()
 }
 
+// Flush sends any buffered data to the client, it's needed by streaming endpoints
+func (writer loggingResponseWriter) Flush() {
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func logRequestHandler(writer http.ResponseWriter, request *http.Request, nextHandler http.Handler) {
 	log.Info().Msgf("Request received - URI: %s, Method: %s", request.RequestURI, request.Method)
 
@@ -0,0 +1,73 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// purgeOrganization removes all data of the organization, it's allowed only to internal users
+// and every attempt is written into the audit log together with numbers of the removed rows
+func (server *HTTPServer) purgeOrganization(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := getRouterPositiveIntParam(request, "organization")
+	if err != nil {
+		handleOrgIDError(writer, err)
+		return
+	}
+	orgID := types.OrgID(organizationID)
+
+	// the user is not known when the authentication is disabled
+	userID, _ := server.GetCurrentUserID(request)
+
+	if server.Config.Auth && !server.isInternalUser(request) {
+		log.Warn().
+			Str("audit", "organization_purge").
+			Str("user", string(userID)).
+			Uint32("organization", uint32(orgID)).
+			Msg("Purge of organization refused to non-internal user")
+		handleServerError(writer, &AuthenticationError{errString: "only internal users can purge organizations"})
+		return
+	}
+
+	purged, err := server.requestStorage(request).PurgeOrganization(orgID)
+	if err != nil {
+		log.Error().Err(err).
+			Str("audit", "organization_purge").
+			Str("user", string(userID)).
+			Uint32("organization", uint32(orgID)).
+			Msg("Unable to purge organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().
+		Str("audit", "organization_purge").
+		Str("user", string(userID)).
+		Uint32("organization", uint32(orgID)).
+		Interface("removed", purged).
+		Msg("Organization purged")
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("removed", purged))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
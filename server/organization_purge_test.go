@@ -0,0 +1,116 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestPurgeOrganization(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.PurgeOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"removed": {
+				"cluster_rule_toggle": 0,
+				"cluster_rule_user_feedback": 0,
+				"consumer_error": 0,
+				"report": 1,
+				"rule_disable": 0,
+				"webhook": 0,
+				"webhook_delivery": 0
+			},
+			"status": "ok"
+		}`,
+	})
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestPurgeOrganizationByExternalUser(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// external users can't purge even their own organization
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.PurgeOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "only internal users can purge organizations"}`,
+	})
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestPurgeOrganizationBadOrgID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.PurgeOrganizationEndpoint,
+		EndpointArgs: []interface{}{"not-a-number"},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'organization' with value 'not-a-number'. Error: 'unsigned integer expected'"
+		}`,
+	})
+}
+
+func TestPurgeOrganizationDBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, false)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.PurgeOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/render"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// renderOptions tell if and how rule content templates are rendered,
+// they're read from query parameters render and format
+type renderOptions struct {
+	enabled bool
+	format  render.Format
+}
+
+// readRenderOptions retrieves render options from request
+// if it's not possible, it writes http error to the writer and returns error
+func readRenderOptions(writer http.ResponseWriter, request *http.Request) (renderOptions, error) {
+	query := request.URL.Query()
+	options := renderOptions{format: render.FormatMarkdown}
+
+	if renderParam := query.Get("render"); renderParam != "" {
+		enabled, err := strconv.ParseBool(renderParam)
+		if err != nil {
+			err = &RouterParsingError{paramName: "render", paramValue: renderParam, errString: "boolean value expected"}
+			handleServerError(writer, err)
+			return options, err
+		}
+		options.enabled = enabled
+	}
+
+	formatParam := query.Get("format")
+	format, err := render.ParseFormat(formatParam)
+	if err != nil {
+		err = &RouterParsingError{paramName: "format", paramValue: formatParam, errString: err.Error()}
+		handleServerError(writer, err)
+		return options, err
+	}
+	options.format = format
+
+	return options, nil
+}
+
+// renderField renders the template in place, the template is kept
+// as it is if it can't be rendered, so the response is still usable
+func renderField(field *string, data interface{}, format render.Format) {
+	rendered, err := render.Render(*field, data, format)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to render rule content template")
+		return
+	}
+
+	*field = rendered
+}
+
+// renderRulesContent renders templates of rules in the report with details of their hits
+func renderRulesContent(rules []types.RuleContentResponse, options renderOptions) {
+	if !options.enabled {
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		renderField(&rule.Generic, rule.TemplateData, options.format)
+		renderField(&rule.Reason, rule.TemplateData, options.format)
+		renderField(&rule.Resolution, rule.TemplateData, options.format)
+	}
+}
+
+// renderRuleWithContent renders templates of the rule, there are no details of any hit,
+// so the parts of templates depending on them are left out
+func renderRuleWithContent(rule *types.RuleWithContent, options renderOptions) {
+	if !options.enabled || rule == nil {
+		return
+	}
+
+	renderField(&rule.Summary, nil, options.format)
+	renderField(&rule.Reason, nil, options.format)
+	renderField(&rule.Resolution, nil, options.format)
+	renderField(&rule.MoreInfo, nil, options.format)
+	renderField(&rule.Generic, nil, options.format)
+}
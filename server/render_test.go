@@ -0,0 +1,169 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// reportWithDetails is a report with one hit of rule 1 with details used by templates
+const reportWithDetails = types.ClusterReport(`{
+	"system": {"metadata": {}, "hostname": null},
+	"reports": [{
+		"component": "` + string(testdata.Rule1ID) + `.report",
+		"key": "` + testdata.ErrorKey1 + `",
+		"details": {"nodes": [{"name": "node1"}, {"name": "node2"}], "message": "<b>low memory</b>"}
+	}],
+	"fingerprints": [],
+	"skips": [],
+	"info": []
+}`)
+
+// mustLoadTemplatedContent loads rule 1 with content templates
+func mustLoadTemplatedContent(t *testing.T, mockStorage storage.Storage) {
+	rule := testdata.RuleContent3Rules.Rules["rc1"]
+	rule.Reason = []byte("Nodes:\n\n{{~pydata.nodes :node}}\n- {{=node.name}}{{~}}")
+	rule.Resolution = []byte("Fix **{{=pydata.message}}**{{?pydata.missing}} never{{?}}")
+	rule.ErrorKeys = map[string]content.RuleErrorKeyContent{
+		testdata.ErrorKey1: {
+			Generic:  []byte("Broken template {{=pydata.}}"),
+			Metadata: rule.ErrorKeys[testdata.ErrorKey1].Metadata,
+		},
+	}
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules:  map[string]content.RuleContent{"rc1": rule},
+	}))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, reportWithDetails, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// checkRenderedReport checks content of the only rule in the report
+func checkRenderedReport(t *testing.T, mockStorage storage.Storage, query, reason, resolution string) {
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + query,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Report types.ReportResponse `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Len(t, response.Report.Rules, 1)
+			rule := response.Report.Rules[0]
+			assert.Equal(t, reason, rule.Reason)
+			assert.Equal(t, resolution, rule.Resolution)
+			// template that can't be rendered is returned as it is
+			assert.Equal(t, "Broken template {{=pydata.}}", rule.Generic)
+		},
+	})
+}
+
+func TestReadReportNotRendered(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTemplatedContent(t, mockStorage)
+
+	checkRenderedReport(
+		t, mockStorage, "?render=false",
+		"Nodes:\n\n{{~pydata.nodes :node}}\n- {{=node.name}}{{~}}",
+		"Fix **{{=pydata.message}}**{{?pydata.missing}} never{{?}}",
+	)
+}
+
+func TestReadReportRenderedMarkdown(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTemplatedContent(t, mockStorage)
+
+	checkRenderedReport(
+		t, mockStorage, "?render=true",
+		"Nodes:\n\n\n- node1\n- node2",
+		"Fix **<b>low memory</b>**",
+	)
+}
+
+func TestReadReportRenderedHTML(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTemplatedContent(t, mockStorage)
+
+	checkRenderedReport(
+		t, mockStorage, "?render=true&format=html",
+		"<p>Nodes:</p>\n<ul>\n<li>node1</li>\n<li>node2</li>\n</ul>",
+		"<p>Fix <strong>&lt;b&gt;low memory&lt;/b&gt;</strong></p>",
+	)
+}
+
+func TestReadReportBadRenderParams(t *testing.T) {
+	for _, query := range []string{"?render=maybe", "?render=true&format=pdf"} {
+		helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ReportEndpoint + query,
+			EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestGetRuleRendered(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadTemplatedContent(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleErrorKeyEndpoint + "?render=true&format=html",
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Rule types.RuleWithContent `json:"rule"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			// there are no details of any hit
+			assert.Equal(t, "<p>Nodes:</p>", response.Rule.Reason)
+			assert.Equal(t, "<p>Fix ****</p>", response.Rule.Resolution)
+			assert.Equal(t, "<p>rule 1 summary</p>", response.Rule.Summary)
+		},
+	})
+}
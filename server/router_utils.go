@@ -67,6 +67,31 @@ func getRouterPositiveIntParam(request *http.Request, paramName string) (uint64,
 	return uintValue, nil
 }
 
+// readIntQueryParam retrieves integer query parameter that has to be between minValue and maxValue,
+// defaultValue is returned when the parameter is not set. If it's not possible,
+// it writes http error to the writer and returns error
+func readIntQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, defaultValue, minValue, maxValue int,
+) (int, error) {
+	param := request.URL.Query().Get(paramName)
+	if param == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(param)
+	if err != nil || value < minValue || value > maxValue {
+		err = &RouterParsingError{
+			paramName:  paramName,
+			paramValue: param,
+			errString:  fmt.Sprintf("integer between %v and %v expected", minValue, maxValue),
+		}
+		handleServerError(writer, err)
+		return 0, err
+	}
+
+	return value, nil
+}
+
 // validateClusterName checks that the cluster name is a valid UUID.
 // Converted cluster name is returned if everything is okay, otherwise an error is returned.
 func validateClusterName(clusterName string) (types.ClusterName, error) {
@@ -236,6 +261,19 @@ func readErrorKey(writer http.ResponseWriter, request *http.Request) (types.Erro
 	return types.ErrorKey(errorKey), nil
 }
 
+// readWebhookID retrieves webhook id from request
+// if it's not possible, it writes http error to the writer and returns error
+func readWebhookID(writer http.ResponseWriter, request *http.Request) (types.WebhookID, error) {
+	webhookID, err := getRouterPositiveIntParam(request, "webhook_id")
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting webhook ID from request")
+		handleServerError(writer, err)
+		return 0, err
+	}
+
+	return types.WebhookID(webhookID), nil
+}
+
 // readClusterRuleUserParams gets cluster_name, rule_id and user_id from current request
 func (server *HTTPServer) readClusterRuleUserParams(
 	writer http.ResponseWriter, request *http.Request,
@@ -259,13 +297,13 @@ func (server *HTTPServer) readClusterRuleUserParams(
 	}
 
 	// it's gonna raise an error if cluster does not exist
-	_, _, err = server.Storage.ReadReportForClusterByClusterName(clusterID)
+	_, _, err = server.requestStorage(request).ReadReportForClusterByClusterName(clusterID)
 	if err != nil {
 		handleServerError(writer, err)
 		return "", "", "", err
 	}
 
-	_, err = server.Storage.GetRuleByID(ruleID)
+	_, err = server.requestStorage(request).GetRuleByID(ruleID)
 	if err != nil {
 		handleServerError(writer, err)
 		return "", "", "", err
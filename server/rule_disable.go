@@ -0,0 +1,158 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ruleDisableResponse is a rule disabled on all clusters of the organization
+type ruleDisableResponse struct {
+	RuleID     types.RuleID `json:"rule_id"`
+	Scope      string       `json:"scope"`
+	DisabledAt time.Time    `json:"disabled_at"`
+}
+
+// disableRuleForOrganization disables the rule on all clusters of the organization for all its users
+func (server *HTTPServer) disableRuleForOrganization(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForOrganization(writer, request, storage.RuleDisableScopeOrganization, storage.RuleToggleDisable)
+}
+
+// enableRuleForOrganization enables the rule disabled for all users of the organization
+func (server *HTTPServer) enableRuleForOrganization(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForOrganization(writer, request, storage.RuleDisableScopeOrganization, storage.RuleToggleEnable)
+}
+
+// disableRuleForUser disables the rule on all clusters of the organization for the current user
+func (server *HTTPServer) disableRuleForUser(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForOrganization(writer, request, storage.RuleDisableScopeUser, storage.RuleToggleDisable)
+}
+
+// enableRuleForUser enables the rule disabled on all clusters of the organization for the current user
+func (server *HTTPServer) enableRuleForUser(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRuleForOrganization(writer, request, storage.RuleDisableScopeUser, storage.RuleToggleEnable)
+}
+
+// toggleRuleForOrganization contains shared functionality for enable/disable of the rule
+// on all clusters of the organization, scope is either organization or user. Only internal
+// users can change the disables of the whole organization when the authentication is enabled.
+func (server *HTTPServer) toggleRuleForOrganization(
+	writer http.ResponseWriter, request *http.Request, scope string, toggleRule storage.RuleToggle,
+) {
+	orgID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	if scope == storage.RuleDisableScopeOrganization && server.Config.Auth && !server.isInternalUser(request) {
+		// the user is always known when the authentication is enabled
+		userID, _ := server.GetCurrentUserID(request)
+		log.Warn().
+			Str("audit", "rule_disable").
+			Str("user", string(userID)).
+			Msg("Change of rule disable for whole organization refused to non-internal user")
+		handleServerError(writer, &AuthenticationError{
+			errString: "only internal users can change rule disables of the whole organization",
+		})
+		return
+	}
+
+	ruleID, err := readRuleID(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	var userID types.UserID
+	if scope == storage.RuleDisableScopeUser {
+		userID, err = server.readUserID(request, writer)
+		if err != nil {
+			// everything has been handled already
+			return
+		}
+	}
+
+	if toggleRule == storage.RuleToggleDisable {
+		// the rule is checked just when it's disabled, so that the disable
+		// can be removed even after the rule is removed from the content
+		_, err = server.requestStorage(request).GetRuleByID(ruleID)
+		if err != nil {
+			handleServerError(writer, err)
+			return
+		}
+
+		err = server.requestStorage(request).DisableRuleForOrg(orgID, ruleID, userID)
+	} else {
+		err = server.requestStorage(request).EnableRuleForOrg(orgID, ruleID, userID)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to toggle rule for selected organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// listDisabledRulesForOrganization returns rules disabled on all clusters of the organization,
+// i.e. the ones disabled for all users of the organization and the ones disabled for the current user
+func (server *HTTPServer) listDisabledRulesForOrganization(writer http.ResponseWriter, request *http.Request) {
+	orgID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	// the user is not known when the authentication is disabled
+	userID, _ := server.GetCurrentUserID(request)
+
+	disables, err := server.requestStorage(request).ListRuleDisablesForOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to list disabled rules for selected organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	disabledRules := []ruleDisableResponse{}
+	for _, disable := range disables {
+		if disable.UserID != "" && disable.UserID != userID {
+			continue
+		}
+
+		disabledRules = append(disabledRules, ruleDisableResponse{
+			RuleID:     disable.RuleID,
+			Scope:      disable.Scope(),
+			DisabledAt: disable.DisabledAt.UTC(),
+		})
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("disabled_rules", disabledRules))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
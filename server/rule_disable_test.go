@@ -0,0 +1,226 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// assertDisabledRulesForOrganization checks scopes of the rules returned by the endpoint listing disabled rules
+func assertDisabledRulesForOrganization(t *testing.T, mockStorage storage.Storage, expected map[string]string) {
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.DisabledRulesForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				DisabledRules []struct {
+					RuleID string `json:"rule_id"`
+					Scope  string `json:"scope"`
+				} `json:"disabled_rules"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			scopes := make(map[string]string)
+			for _, rule := range response.DisabledRules {
+				scopes[rule.RuleID] = rule.Scope
+			}
+			assert.Equal(t, expected, scopes)
+		},
+	})
+}
+
+func TestRuleDisableForOrganization(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.LoadRuleContent(testdata.RuleContent3Rules)
+	helpers.FailOnError(t, err)
+
+	for _, request := range []struct {
+		endpoint string
+		ruleID   types.RuleID
+	}{
+		{server.DisableRuleForOrganizationEndpoint, testdata.Rule1ID},
+		{server.DisableRuleForUserEndpoint, testdata.Rule2ID},
+	} {
+		helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     request.endpoint,
+			EndpointArgs: []interface{}{testdata.OrgID, request.ruleID},
+			XRHIdentity:  makeXRHIdentity(t, true),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       `{"status": "ok"}`,
+		})
+	}
+
+	// the user-wide disable of other users isn't listed
+	err = mockStorage.DisableRuleForOrg(testdata.OrgID, testdata.Rule3ID, testdata.User2ID)
+	helpers.FailOnError(t, err)
+
+	assertDisabledRulesForOrganization(t, mockStorage, map[string]string{
+		string(testdata.Rule1ID): storage.RuleDisableScopeOrganization,
+		string(testdata.Rule2ID): storage.RuleDisableScopeUser,
+	})
+
+	disabledRules, err := mockStorage.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledRules, 2)
+
+	for _, request := range []struct {
+		endpoint string
+		ruleID   types.RuleID
+	}{
+		{server.EnableRuleForOrganizationEndpoint, testdata.Rule1ID},
+		{server.EnableRuleForUserEndpoint, testdata.Rule2ID},
+	} {
+		helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     request.endpoint,
+			EndpointArgs: []interface{}{testdata.OrgID, request.ruleID},
+			XRHIdentity:  makeXRHIdentity(t, true),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       `{"status": "ok"}`,
+		})
+	}
+
+	assertDisabledRulesForOrganization(t, mockStorage, map[string]string{})
+}
+
+func TestRuleDisableForOrganizationUnknownRule(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID test.rule1 was not found in the storage"}`,
+	})
+
+	// the disable can be removed even when the rule doesn't exist anymore
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.EnableRuleForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+}
+
+func TestRuleDisableForOrganizationNotInternalUser(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	for _, endpoint := range []string{
+		server.DisableRuleForOrganizationEndpoint, server.EnableRuleForOrganizationEndpoint,
+	} {
+		helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     endpoint,
+			EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+			XRHIdentity:  makeXRHIdentity(t, false),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusForbidden,
+			Body:       `{"status": "only internal users can change rule disables of the whole organization"}`,
+		})
+	}
+
+	// the user can still disable the rule just for themselves
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForUserEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	assertDisabledRulesForOrganization(t, mockStorage, map[string]string{
+		string(testdata.Rule1ID): storage.RuleDisableScopeUser,
+	})
+}
+
+func TestRuleDisableForOtherOrganization(t *testing.T) {
+	for _, endpoint := range []string{
+		server.DisableRuleForOrganizationEndpoint, server.EnableRuleForOrganizationEndpoint,
+		server.DisableRuleForUserEndpoint, server.EnableRuleForUserEndpoint,
+	} {
+		helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     endpoint,
+			EndpointArgs: []interface{}{testdata.OrgID + 1, testdata.Rule1ID},
+			XRHIdentity:  makeXRHIdentity(t, false),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusForbidden,
+			Body:       `{"status": "You have no permissions to get or change info about this organization"}`,
+		})
+	}
+
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.DisabledRulesForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID + 1},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "You have no permissions to get or change info about this organization"}`,
+	})
+}
+
+func TestRuleDisableForOrganizationDBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, false)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.DisabledRulesForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+const (
+	// defaultPageLimit is number of returned items when the limit isn't specified
+	defaultPageLimit = 20
+	// maxPageLimit is the highest number of items that can be returned at once
+	maxPageLimit = 100
+)
+
+// listRules returns page of all rule error keys with content,
+// optionally filtered by tag, impact, likelihood and active state
+func (server *HTTPServer) listRules(writer http.ResponseWriter, request *http.Request) {
+	offset, err := readIntQueryParam(writer, request, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	limit, err := readIntQueryParam(writer, request, "limit", defaultPageLimit, 1, maxPageLimit)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	listFilter, err := readRuleListFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	contentFilter, err := server.readRuleContentFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	contentFilter.Language, err = server.readLanguage(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	rules, total, err := server.requestStorage(request).ListRules(contentFilter, listFilter, offset, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to list rules")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponseWithData("rules", rules)
+	response["total"] = total
+
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// listRuleTags returns all tags of rule error keys with number of the error keys having them
+func (server *HTTPServer) listRuleTags(writer http.ResponseWriter, request *http.Request) {
+	filter, err := server.readRuleContentFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	tags, err := server.requestStorage(request).ListRuleTags(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to list rule tags")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("tags", tags))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readRuleListFilter retrieves filter of listed rules from tag, impact, likelihood and active
+// query parameters, if it's not possible, it writes http error to the writer and returns error
+func readRuleListFilter(writer http.ResponseWriter, request *http.Request) (storage.RuleListFilter, error) {
+	var (
+		filter storage.RuleListFilter
+		err    error
+	)
+
+	filter.Tag = request.URL.Query().Get("tag")
+
+	filter.Impact, err = readIntQueryParam(writer, request, "impact", 0, 1, math.MaxInt32)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Likelihood, err = readIntQueryParam(writer, request, "likelihood", 0, 1, math.MaxInt32)
+	if err != nil {
+		return filter, err
+	}
+
+	if activeParam := request.URL.Query().Get("active"); activeParam != "" {
+		active, err := strconv.ParseBool(activeParam)
+		if err != nil {
+			err = &RouterParsingError{paramName: "active", paramValue: activeParam, errString: "boolean value expected"}
+			handleServerError(writer, err)
+			return filter, err
+		}
+		filter.Active = &active
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestListRules(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RulesEndpoint + "?impact=2&offset=1&limit=1",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Rules []types.RuleWithContent `json:"rules"`
+				Total int                     `json:"total"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Equal(t, 2, response.Total)
+			assert.Len(t, response.Rules, 1)
+			assert.Equal(t, testdata.Rule3ID, response.Rules[0].Module)
+			assert.Equal(t, types.ErrorKey(testdata.ErrorKey3), response.Rules[0].ErrorKey)
+		},
+	})
+}
+
+func TestListRulesEmpty(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RulesEndpoint + "?tag=etcd&active=true",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"rules": [], "total": 0, "status": "ok"}`,
+	})
+}
+
+func TestListRulesBadParams(t *testing.T) {
+	for _, testCase := range []struct {
+		query  string
+		status string
+	}{
+		{"?offset=-1", "Error during parsing param 'offset' with value '-1'. Error: 'integer between 0 and 2147483647 expected'"},
+		{"?limit=0", "Error during parsing param 'limit' with value '0'. Error: 'integer between 1 and 100 expected'"},
+		{"?impact=high", "Error during parsing param 'impact' with value 'high'. Error: 'integer between 1 and 2147483647 expected'"},
+		{"?likelihood=0", "Error during parsing param 'likelihood' with value '0'. Error: 'integer between 1 and 2147483647 expected'"},
+		{"?active=maybe", "Error during parsing param 'active' with value 'maybe'. Error: 'boolean value expected'"},
+	} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:   http.MethodGet,
+			Endpoint: server.RulesEndpoint + testCase.query,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"status": "` + testCase.status + `"}`,
+		})
+	}
+}
+
+func TestListRuleTags(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleTagsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"tags": [{"tag": "tag1", "count": 3}, {"tag": "tag2", "count": 3}],
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestListRuleTagsIncludeInactiveExternalUser(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:      http.MethodGet,
+		Endpoint:    server.RuleTagsEndpoint + "?include_inactive=true",
+		XRHIdentity: makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "inactive rules can be included by internal users only"}`,
+	})
+}
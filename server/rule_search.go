@@ -0,0 +1,57 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+)
+
+// searchRules returns rules matching the full-text query q ordered by their relevance
+func (server *HTTPServer) searchRules(writer http.ResponseWriter, request *http.Request) {
+	query := strings.TrimSpace(request.URL.Query().Get("q"))
+	if query == "" {
+		handleServerError(writer, &RouterMissingParamError{paramName: "q"})
+		return
+	}
+
+	limit, err := readIntQueryParam(writer, request, "limit", defaultPageLimit, 1, maxPageLimit)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	filter, err := server.readRuleContentFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	results, err := server.requestStorage(request).SearchRules(query, filter, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to search rules")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("rules", results))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
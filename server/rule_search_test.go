@@ -0,0 +1,123 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestSearchRules(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleSearchEndpoint + "?q=rule+2+reason",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Rules []types.RuleSearchResult `json:"rules"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Len(t, response.Rules, 1)
+			assert.Equal(t, testdata.Rule2ID, response.Rules[0].Module)
+			assert.Equal(t, "rule 2 reason", response.Rules[0].Reason)
+			assert.NotEmpty(t, response.Rules[0].Snippet)
+		},
+	})
+}
+
+func TestSearchRulesLimit(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleSearchEndpoint + "?q=rule&limit=2",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Rules []types.RuleSearchResult `json:"rules"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Len(t, response.Rules, 2)
+		},
+	})
+}
+
+func TestSearchRulesNoResults(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleSearchEndpoint + "?q=nonexistent",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"rules": [], "status": "ok"}`,
+	})
+}
+
+func TestSearchRulesMissingQuery(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleSearchEndpoint + "?q=+",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Missing required param from request: q"}`,
+	})
+}
+
+func TestSearchRulesBadLimit(t *testing.T) {
+	for _, limit := range []string{"many", "0", "101"} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:   http.MethodGet,
+			Endpoint: server.RuleSearchEndpoint + "?q=rule&limit=" + limit,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: `{
+				"status": "Error during parsing param 'limit' with value '` + limit +
+				`'. Error: 'integer between 1 and 100 expected'"
+			}`,
+		})
+	}
+}
+
+func TestSearchRulesIncludeInactiveExternalUser(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:      http.MethodGet,
+		Endpoint:    server.RuleSearchEndpoint + "?q=rule&include_inactive=true",
+		XRHIdentity: makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "inactive rules can be included by internal users only"}`,
+	})
+}
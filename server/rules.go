@@ -18,15 +18,18 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
-// getRule returns rule with content for provided rule ID and rule error key
+// getRule returns rule with content for provided rule ID and rule error key,
+// rules that are not visible to the user are not found
 func (server *HTTPServer) getRule(writer http.ResponseWriter, request *http.Request) {
 	ruleID, err := readRuleID(writer, request)
 	if err != nil {
@@ -40,12 +43,33 @@ func (server *HTTPServer) getRule(writer http.ResponseWriter, request *http.Requ
 		return
 	}
 
-	ruleWithContent, err := server.Storage.GetRuleWithContent(ruleID, errorKey)
+	renderOptions, err := readRenderOptions(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	filter, err := server.readRuleContentFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	filter.Language, err = server.readLanguage(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	// rules that the user can't see are not found
+	ruleWithContent, err := server.requestStorage(request).GetRuleWithContent(ruleID, errorKey, filter)
 	if err != nil {
 		handleServerError(writer, err)
 		return
 	}
 
+	renderRuleWithContent(ruleWithContent, renderOptions)
+
 	err = responses.SendOK(writer, responses.BuildOkResponseWithData("rule", ruleWithContent))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
@@ -76,13 +100,15 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
-	err = server.Storage.ToggleRuleForCluster(clusterID, ruleID, userID, toggleRule)
+	err = server.requestStorage(request).ToggleRuleForCluster(clusterID, ruleID, userID, toggleRule)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to toggle rule for selected cluster")
 		handleServerError(writer, err)
 		return
 	}
 
+	server.publishClusterEvent(events.RuleToggled, clusterID, ruleID)
+
 	err = responses.SendOK(writer, responses.BuildOkResponse())
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
@@ -109,7 +135,7 @@ func (server *HTTPServer) createRule(writer http.ResponseWriter, request *http.R
 
 	rule.Module = ruleID
 
-	err = server.Storage.CreateRule(rule)
+	err = server.requestStorage(request).CreateRule(rule)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -130,7 +156,7 @@ func (server *HTTPServer) deleteRule(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	err = server.Storage.DeleteRule(ruleID)
+	err = server.requestStorage(request).DeleteRule(ruleID)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -150,7 +176,7 @@ func (server *HTTPServer) createRuleErrorKey(writer http.ResponseWriter, request
 	}
 
 	// it's gonna raise an error if rule does not exist
-	_, err = server.Storage.GetRuleByID(ruleID)
+	_, err = server.requestStorage(request).GetRuleByID(ruleID)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -176,7 +202,7 @@ func (server *HTTPServer) createRuleErrorKey(writer http.ResponseWriter, request
 	ruleErrorKey.RuleModule = ruleID
 	ruleErrorKey.ErrorKey = errorKey
 
-	err = server.Storage.CreateRuleErrorKey(ruleErrorKey)
+	err = server.requestStorage(request).CreateRuleErrorKey(ruleErrorKey)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -203,7 +229,7 @@ func (server *HTTPServer) deleteRuleErrorKey(writer http.ResponseWriter, request
 		return
 	}
 
-	err = server.Storage.DeleteRuleErrorKey(ruleID, errorKey)
+	err = server.requestStorage(request).DeleteRuleErrorKey(ruleID, errorKey)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -229,7 +255,7 @@ func (server *HTTPServer) deleteFromRuleClusterToggle(writer http.ResponseWriter
 		return
 	}
 
-	err = server.Storage.DeleteFromRuleClusterToggle(clusterID, ruleID, userID)
+	err = server.requestStorage(request).DeleteFromRuleClusterToggle(clusterID, ruleID, userID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to delete from rule_cluster_toggle")
 		handleServerError(writer, err)
@@ -249,32 +275,78 @@ func getTotalRuleCount(reportRules types.ReportRules) int {
 	return totalCount
 }
 
+// readRuleContentFilter retrieves which rules can be shown to the user, internal rules are shown
+// to internal users and inactive or unpublished rules just to internal users asking for them
+// by include_inactive query parameter. If it's not possible, it writes http error to the writer
+// and returns error
+func (server *HTTPServer) readRuleContentFilter(
+	writer http.ResponseWriter, request *http.Request,
+) (storage.RuleContentFilter, error) {
+	filter := storage.RuleContentFilter{IncludeInternal: server.isInternalUser(request)}
+
+	includeInactiveParam := request.URL.Query().Get("include_inactive")
+	if includeInactiveParam == "" {
+		return filter, nil
+	}
+
+	includeInactive, err := strconv.ParseBool(includeInactiveParam)
+	if err != nil {
+		err = &RouterParsingError{
+			paramName: "include_inactive", paramValue: includeInactiveParam, errString: "boolean value expected",
+		}
+		handleServerError(writer, err)
+		return filter, err
+	}
+
+	if includeInactive && !filter.IncludeInternal {
+		err = &AuthenticationError{errString: "inactive rules can be included by internal users only"}
+		handleServerError(writer, err)
+		return filter, err
+	}
+	filter.IncludeInactive = includeInactive
+
+	return filter, nil
+}
+
 // getContentForRules returns the hit rules from the report, as well as total count of all rules (skipped, ..)
+// and count of hit rules that are not returned because they're inactive or not published yet
 func (server *HTTPServer) getContentForRules(
 	writer http.ResponseWriter,
+	request *http.Request,
 	report types.ClusterReport,
 	userID types.UserID,
 	clusterName types.ClusterName,
-) ([]types.RuleContentResponse, int, error) {
+	filter storage.RuleContentFilter,
+) ([]types.RuleContentResponse, int, int, error) {
 	var reportRules types.ReportRules
 
 	err := json.Unmarshal([]byte(report), &reportRules)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to parse cluster report")
 		handleServerError(writer, err)
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 
 	totalRules := getTotalRuleCount(reportRules)
 
-	hitRules, err := server.Storage.GetContentForRules(reportRules, userID, clusterName)
+	hitRules, err := server.requestStorage(request).GetContentForRules(reportRules, userID, clusterName, filter)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to retrieve rules content from database")
 		handleServerError(writer, err)
-		return nil, 0, err
+		return nil, 0, 0, err
+	}
+
+	suppressedRules := 0
+	if !filter.IncludeInactive {
+		suppressedRules, err = server.requestStorage(request).GetSuppressedRulesCount(reportRules, filter.IncludeInternal)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to count suppressed rules in database")
+			handleServerError(writer, err)
+			return nil, 0, 0, err
+		}
 	}
 
-	return hitRules, totalRules, nil
+	return hitRules, totalRules, suppressedRules, nil
 }
 
 // getUserVoteForRules returns user votes for defined list of report's IDs
@@ -18,12 +18,34 @@ limitations under the License.
 // Insights results aggregator service. In current version, the following
 // REST API endpoints are available:
 //
+// API_PREFIX/health/live - liveness probe, OK whenever the server handles requests (HTTP GET)
+//
+// API_PREFIX/health/ready - readiness probe checking database, migration version and consumer session (HTTP GET)
+//
 // API_PREFIX/organizations - list of all organizations (HTTP GET)
 //
 // API_PREFIX/organizations/{organization}/clusters - list of all clusters for given organization (HTTP GET)
 //
 // API_PREFIX/report/{organization}/{cluster} - insights OCP results for given cluster name (HTTP GET)
 //
+// API_PREFIX/organizations/{organization}/events - stream of Server-Sent Events about changes in given organization (HTTP GET)
+//
+// API_PREFIX/organizations/{organization}/webhooks - list (HTTP GET) or create (HTTP POST) webhook subscriptions of given organization
+//
+// API_PREFIX/organizations/{organization}/webhooks/{webhook_id} - get (HTTP GET), update (HTTP PUT) or delete (HTTP DELETE) webhook subscription
+//
+// API_PREFIX/organizations/{organization}/webhooks/{webhook_id}/deliveries - latest deliveries of the webhook (HTTP GET)
+//
+// API_PREFIX/rules - page of all rules with their error keys, filtered by tag, impact, likelihood and active state
+//
+// API_PREFIX/rules/tags - all tags of rules with number of rule error keys having them
+//
+// API_PREFIX/rules/search?q={query} - rules matching the full-text query ordered by relevance
+//
+// API_PREFIX/admin/content/reload - reload rule content from the content directory, only for internal users (HTTP POST)
+//
+// API_PREFIX/content/version - version of the loaded rule content and time when it was loaded
+//
 // API_PREFIX/rule/{cluster}/{rule_id}/like - like a rule for cluster with current user (from auth token)
 //
 // API_PREFIX/rule/{cluster}/{rule_id}/dislike - dislike a rule for cluster with current user (from auth token)
@@ -45,7 +67,6 @@ package server
 import (
 	"context"
 	"net/http"
-	"net/url"
 
 	// we just have to import this package in order to expose pprof interface in debug mode
 	// disable "G108 (CWE-): Profiling endpoint is automatically exposed on /debug/pprof"
@@ -57,22 +78,30 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 // HTTPServer in an implementation of Server interface
 type HTTPServer struct {
-	Config  Configuration
-	Storage storage.Storage
-	Serv    *http.Server
+	Config          Configuration
+	Storage         storage.Storage
+	EventBus        *events.Bus
+	HealthChecks    []HealthCheck
+	ContentReloader ContentReloader
+	// AllowPrivateWebhookAddresses allows registering webhooks in the internal network
+	AllowPrivateWebhookAddresses bool
+	Serv                         *http.Server
+	contentServiceCache          *contentServiceCache
 }
 
 // New constructs new implementation of Server interface
 func New(config Configuration, storage storage.Storage) *HTTPServer {
 	return &HTTPServer{
-		Config:  config,
-		Storage: storage,
+		Config:              config,
+		Storage:             storage,
+		contentServiceCache: newContentServiceCache(config.ContentServiceCacheTTL),
 	}
 }
 
@@ -83,8 +112,14 @@ func (server *HTTPServer) mainEndpoint(writer http.ResponseWriter, _ *http.Reque
 	}
 }
 
-func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, _ *http.Request) {
-	organizations, err := server.Storage.ListOfOrgs()
+// requestStorage returns the storage running its queries in context of the request,
+// so they're canceled when the client disconnects
+func (server *HTTPServer) requestStorage(request *http.Request) storage.Storage {
+	return server.Storage.WithContext(request.Context())
+}
+
+func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, request *http.Request) {
+	organizations, err := server.requestStorage(request).ListOfOrgs()
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of organizations")
 		handleServerError(writer, err)
@@ -104,7 +139,7 @@ func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWrit
 		return
 	}
 
-	clusters, err := server.Storage.ListOfClustersForOrg(organizationID)
+	clusters, err := server.requestStorage(request).ListOfClustersForOrg(organizationID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of clusters")
 		handleServerError(writer, err)
@@ -135,21 +170,41 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
-	report, lastChecked, err := server.Storage.ReadReportForCluster(organizationID, clusterName)
+	renderOptions, err := readRenderOptions(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	contentFilter, err := server.readRuleContentFilter(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	contentFilter.Language, err = server.readLanguage(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	report, lastChecked, err := server.requestStorage(request).ReadReportForCluster(organizationID, clusterName)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to read report for cluster")
 		handleServerError(writer, err)
 		return
 	}
 
-	rulesContent, rulesCount, err := server.getContentForRules(writer, report, userID, clusterName)
+	rulesContent, rulesCount, suppressedCount, err := server.getContentForRules(
+		writer, request, report, userID, clusterName, contentFilter,
+	)
 	if err != nil {
 		// everything has been handled already
 		return
 	}
 	hitRulesCount := len(rulesContent)
 
-	feedbacks, err := server.Storage.GetUserFeedbackOnRules(clusterName, rulesContent, userID)
+	feedbacks, err := server.requestStorage(request).GetUserFeedbackOnRules(clusterName, rulesContent, userID)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to retrieve feedback results from database")
 		handleServerError(writer, err)
@@ -157,6 +212,7 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 	}
 
 	rulesContent = server.getUserVoteForRules(feedbacks, rulesContent)
+	renderRulesContent(rulesContent, renderOptions)
 
 	// -1 as count in response means there are no rules for this cluster
 	// as opposed to no rules hit for the cluster
@@ -168,8 +224,10 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 
 	response := types.ReportResponse{
 		Meta: types.ReportResponseMeta{
-			Count:         rulesCount,
-			LastCheckedAt: lastChecked,
+			Count:           rulesCount,
+			LastCheckedAt:   lastChecked,
+			ContentVersion:  server.readContentVersionHash(request),
+			SuppressedCount: suppressedCount,
 		},
 		Rules: rulesContent,
 	}
@@ -183,7 +241,7 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 // checkUserClusterPermissions retrieves organization ID by checking the owner of cluster ID, checks if it matches the one from request
 func (server *HTTPServer) checkUserClusterPermissions(writer http.ResponseWriter, request *http.Request, clusterID types.ClusterName) error {
 	if server.Config.Auth {
-		orgID, err := server.Storage.GetOrgIDByClusterID(clusterID)
+		orgID, err := server.requestStorage(request).GetOrgIDByClusterID(clusterID)
 		if err != nil {
 			log.Error().Err(err).Msg("Unable to get org id")
 			handleServerError(writer, err)
@@ -198,31 +256,9 @@ func (server *HTTPServer) checkUserClusterPermissions(writer http.ResponseWriter
 	return nil
 }
 
-// getRuleGroups serves as a proxy to the insights-content-service redirecting the request
-// if the service is alive
+// getRuleGroups serves as a proxy to the insights-content-service
 func (server *HTTPServer) getRuleGroups(writer http.ResponseWriter, request *http.Request) {
-	contentServiceURL, err := url.Parse(server.Config.ContentServiceURL)
-
-	if err != nil {
-		log.Error().Err(err).Msg("Error during Content Service URL parsing")
-		handleServerError(writer, err)
-		return
-	}
-
-	// test if service is alive
-	_, err = http.Get(contentServiceURL.String())
-	if err != nil {
-		log.Error().Err(err).Msg("Content service unavailable")
-
-		if _, ok := err.(*url.Error); ok {
-			err = &ContentServiceUnavailableError{}
-		}
-
-		handleServerError(writer, err)
-		return
-	}
-
-	http.Redirect(writer, request, contentServiceURL.String()+RuleGroupsEndpoint, 302)
+	server.proxyToContentService(writer, request, RuleGroupsEndpoint)
 }
 
 // readUserID tries to retrieve user ID from request. If any error occurs, error response is send back to client.
@@ -246,7 +282,7 @@ func (server *HTTPServer) deleteOrganizations(writer http.ResponseWriter, reques
 	}
 
 	for _, org := range orgIds {
-		if err := server.Storage.DeleteReportsForOrg(org); err != nil {
+		if err := server.requestStorage(request).DeleteReportsForOrg(org); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
 			handleServerError(writer, err)
 			return
@@ -267,7 +303,7 @@ func (server *HTTPServer) deleteClusters(writer http.ResponseWriter, request *ht
 	}
 
 	for _, cluster := range clusterNames {
-		if err := server.Storage.DeleteReportsForCluster(cluster); err != nil {
+		if err := server.requestStorage(request).DeleteReportsForCluster(cluster); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
 			handleServerError(writer, err)
 			return
@@ -328,6 +364,8 @@ func (server *HTTPServer) Initialize(address string) http.Handler {
 
 	metricsURL := apiPrefix + MetricsEndpoint
 	openAPIURL := apiPrefix + filepath.Base(server.Config.APISpecFile)
+	livenessURL := apiPrefix + LivenessEndpoint
+	readinessURL := apiPrefix + ReadinessEndpoint
 
 	// enable authentication, but only if it is setup in configuration
 	if server.Config.Auth {
@@ -340,6 +378,8 @@ func (server *HTTPServer) Initialize(address string) http.Handler {
 			openAPIURL,
 			metricsURL + "?", // to be able to test using Frisby
 			openAPIURL + "?", // to be able to test using Frisby
+			livenessURL,
+			readinessURL,
 		}
 		router.Use(func(next http.Handler) http.Handler { return server.Authentication(next, noAuthURLs) })
 	}
@@ -377,5 +417,8 @@ func (server *HTTPServer) Start() error {
 
 // Stop stops server's execution
 func (server *HTTPServer) Stop(ctx context.Context) error {
+	// event streams would otherwise keep their connections open forever
+	server.EventBus.Close()
+
 	return server.Serv.Shutdown(ctx)
 }
@@ -16,6 +16,8 @@ package server_test
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
@@ -23,6 +25,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/content"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -459,3 +462,170 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 	})
 
 }
+
+// mustLoadContentWithInternalRule loads content of 3 rules, rule 2 being internal
+func mustLoadContentWithInternalRule(t *testing.T, mockStorage storage.Storage) {
+	rules := map[string]content.RuleContent{}
+	for name, rule := range testdata.RuleContent3Rules.Rules {
+		rules[name] = rule
+	}
+	internalRule := rules["rc2"]
+	internalRule.Internal = true
+	rules["rc2"] = internalRule
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules:  rules,
+	}))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// makeXRHIdentity encodes the identity the same way as the 3scale gateway does
+func makeXRHIdentity(t *testing.T, isInternal bool) string {
+	token, err := json.Marshal(server.Token{Identity: server.Identity{
+		AccountNumber: testdata.UserID,
+		Internal:      server.Internal{OrgID: testdata.OrgID},
+		User:          server.User{IsInternal: isInternal},
+	}})
+	helpers.FailOnError(t, err)
+
+	return base64.StdEncoding.EncodeToString(token)
+}
+
+// checkReportRuleModules checks modules of the rules returned in the report for the identity
+func checkReportRuleModules(t *testing.T, mockStorage storage.Storage, isInternal bool, expected []string) {
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		XRHIdentity:  makeXRHIdentity(t, isInternal),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Report types.ReportResponse `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			modules := []string{}
+			for _, rule := range response.Report.Rules {
+				modules = append(modules, rule.RuleModule)
+			}
+			assert.ElementsMatch(t, expected, modules)
+		},
+	})
+}
+
+func TestReadReportInternalRuleHiddenFromExternalUser(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadContentWithInternalRule(t, mockStorage)
+
+	checkReportRuleModules(t, mockStorage, false, []string{"test.rule1", "test.rule3"})
+}
+
+func TestReadReportInternalRuleShownToInternalUser(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadContentWithInternalRule(t, mockStorage)
+
+	checkReportRuleModules(t, mockStorage, true, []string{"test.rule1", "test.rule2", "test.rule3"})
+}
+
+// mustLoadContentWithInactiveRule loads content of 3 rules, rule 3 being inactive
+func mustLoadContentWithInactiveRule(t *testing.T, mockStorage storage.Storage) {
+	rules := map[string]content.RuleContent{}
+	for name, rule := range testdata.RuleContent3Rules.Rules {
+		rules[name] = rule
+	}
+	inactiveRule := rules["rc3"]
+	inactiveRule.ErrorKeys = map[string]content.RuleErrorKeyContent{}
+	for errorKey, errorKeyContent := range rules["rc3"].ErrorKeys {
+		errorKeyContent.Metadata.Status = "inactive"
+		inactiveRule.ErrorKeys[errorKey] = errorKeyContent
+	}
+	rules["rc3"] = inactiveRule
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules:  rules,
+	}))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// checkReportSuppressedRules checks rules returned in the report and count of the suppressed ones
+func checkReportSuppressedRules(t *testing.T, mockStorage storage.Storage, query string, rulesCount, suppressedCount int) {
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + query,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got string) {
+			var response struct {
+				Report types.ReportResponse `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal([]byte(got), &response))
+
+			assert.Len(t, response.Report.Rules, rulesCount)
+			assert.Equal(t, rulesCount, response.Report.Meta.Count)
+			assert.Equal(t, suppressedCount, response.Report.Meta.SuppressedCount)
+		},
+	})
+}
+
+func TestReadReportInactiveRuleSuppressed(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadContentWithInactiveRule(t, mockStorage)
+
+	checkReportSuppressedRules(t, mockStorage, "", 2, 1)
+	checkReportSuppressedRules(t, mockStorage, "?include_inactive=false", 2, 1)
+}
+
+func TestReadReportIncludeInactive(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustLoadContentWithInactiveRule(t, mockStorage)
+
+	checkReportSuppressedRules(t, mockStorage, "?include_inactive=true", 3, 0)
+}
+
+func TestReadReportIncludeInactiveExternalUser(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?include_inactive=true",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		XRHIdentity:  makeXRHIdentity(t, false),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "inactive rules can be included by internal users only"}`,
+	})
+}
+
+func TestReadReportIncludeInactiveBadValue(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?include_inactive=maybe",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'include_inactive' with value 'maybe'. Error: 'boolean value expected'"
+		}`,
+	})
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/content"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -1275,10 +1276,12 @@ func TestHttpServer_GetRule(t *testing.T) {
 	expectedRuleStr, err := json.MarshalIndent(testdata.RuleWithContent1, "", "\t")
 	helpers.FailOnError(t, err)
 
-	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+	// the error keys are inactive, so they are visible only to internal users asking for them
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
 		Method:       http.MethodGet,
-		Endpoint:     server.RuleErrorKeyEndpoint,
+		Endpoint:     server.RuleErrorKeyEndpoint + "?include_inactive=true",
 		EndpointArgs: []interface{}{testdata.Rule1.Module, testdata.RuleErrorKey1.ErrorKey},
+		XRHIdentity:  makeXRHIdentity(t, true),
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
 		Body: fmt.Sprintf(`{
@@ -1290,10 +1293,11 @@ func TestHttpServer_GetRule(t *testing.T) {
 	expectedRuleStr, err = json.MarshalIndent(testdata.RuleWithContent2, "", "\t")
 	helpers.FailOnError(t, err)
 
-	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
 		Method:       http.MethodGet,
-		Endpoint:     server.RuleErrorKeyEndpoint,
+		Endpoint:     server.RuleErrorKeyEndpoint + "?include_inactive=true",
 		EndpointArgs: []interface{}{testdata.Rule2.Module, testdata.RuleErrorKey2.ErrorKey},
+		XRHIdentity:  makeXRHIdentity(t, true),
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
 		Body: fmt.Sprintf(`{
@@ -1303,6 +1307,64 @@ func TestHttpServer_GetRule(t *testing.T) {
 	})
 }
 
+func TestHttpServer_GetRule_NotVisible(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	internalRule := testdata.RuleContent3Rules.Rules["rc1"]
+	internalRule.Internal = true
+
+	unpublishedRule := testdata.RuleContent3Rules.Rules["rc2"]
+	unpublishedErrorKey := unpublishedRule.ErrorKeys[testdata.ErrorKey2]
+	unpublishedErrorKey.Metadata.PublishDate = "2999-01-01T00:00:00Z"
+	unpublishedRule.ErrorKeys = map[string]content.RuleErrorKeyContent{testdata.ErrorKey2: unpublishedErrorKey}
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules:  map[string]content.RuleContent{"rc1": internalRule, "rc2": unpublishedRule},
+	}))
+
+	for _, rule := range []struct {
+		ruleID   types.RuleID
+		errorKey types.ErrorKey
+	}{
+		{ruleID: testdata.Rule1ID, errorKey: testdata.ErrorKey1},
+		{ruleID: testdata.Rule2ID, errorKey: testdata.ErrorKey2},
+	} {
+		helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.RuleErrorKeyEndpoint,
+			EndpointArgs: []interface{}{rule.ruleID, rule.errorKey},
+			XRHIdentity:  makeXRHIdentity(t, false),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusNotFound,
+			Body: fmt.Sprintf(
+				`{"status": "Item with ID %v/%v was not found in the storage"}`, rule.ruleID, rule.errorKey,
+			),
+		})
+	}
+
+	// the internal rule is visible to internal users
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleErrorKeyEndpoint,
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	// and the unpublished one to internal users asking for inactive rules
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleErrorKeyEndpoint + "?include_inactive=true",
+		EndpointArgs: []interface{}{testdata.Rule2ID, testdata.ErrorKey2},
+		XRHIdentity:  makeXRHIdentity(t, true),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+}
+
 func TestHttpServer_GetRule_DBError(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
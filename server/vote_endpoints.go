@@ -20,6 +20,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -51,12 +52,14 @@ func (server *HTTPServer) voteOnRule(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	err = server.Storage.VoteOnRule(clusterID, ruleID, userID, userVote)
+	err = server.requestStorage(request).VoteOnRule(clusterID, ruleID, userID, userVote)
 	if err != nil {
 		handleServerError(writer, err)
 		return
 	}
 
+	server.publishClusterEvent(events.RuleVoted, clusterID, ruleID)
+
 	err = responses.SendOK(writer, responses.BuildOkResponse())
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
@@ -76,7 +79,7 @@ func (server *HTTPServer) getVoteOnRule(writer http.ResponseWriter, request *htt
 		return
 	}
 
-	userFeedbackOnRule, err := server.Storage.GetUserFeedbackOnRule(clusterID, ruleID, userID)
+	userFeedbackOnRule, err := server.requestStorage(request).GetUserFeedbackOnRule(clusterID, ruleID, userID)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -0,0 +1,324 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
+)
+
+const (
+	// minWebhookTotalRisk and maxWebhookTotalRisk is the range of total risk the rules can have
+	minWebhookTotalRisk = 1
+	maxWebhookTotalRisk = 4
+	// webhookDeliveriesLimit is the max number of deliveries returned by the deliveries endpoint
+	webhookDeliveriesLimit = 100
+)
+
+// webhookRequest is the body of requests creating or updating webhooks
+type webhookRequest struct {
+	URL          string `json:"url"`
+	Secret       string `json:"secret"`
+	MinTotalRisk int    `json:"min_total_risk"`
+}
+
+// readWebhookRequest reads and validates webhook from request body. Secret is
+// required only for new webhooks, the current one is kept on update without it.
+// If it's not possible, it writes http error to the writer and returns error
+func (server *HTTPServer) readWebhookRequest(
+	writer http.ResponseWriter, request *http.Request, secretRequired bool,
+) (webhookRequest, error) {
+	var webhook webhookRequest
+
+	err := json.NewDecoder(request.Body).Decode(&webhook)
+	if err != nil {
+		if err == io.EOF {
+			err = &NoBodyError{}
+		}
+		handleServerError(writer, err)
+		return webhook, err
+	}
+
+	err = validateWebhookRequest(webhook, secretRequired)
+	if err != nil {
+		handleServerError(writer, err)
+		return webhook, err
+	}
+
+	if !server.AllowPrivateWebhookAddresses {
+		err = checkWebhookHost(request, webhook)
+		if err != nil {
+			handleServerError(writer, err)
+			return webhook, err
+		}
+	}
+
+	return webhook, nil
+}
+
+func validateWebhookRequest(webhook webhookRequest, secretRequired bool) error {
+	webhookURL, err := url.Parse(webhook.URL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Hostname() == "" {
+		return &ValidationError{
+			paramName:  "url",
+			paramValue: webhook.URL,
+			errString:  "absolute http or https URL expected",
+		}
+	}
+
+	if secretRequired && webhook.Secret == "" {
+		return &ValidationError{
+			paramName:  "secret",
+			paramValue: "",
+			errString:  "secret is required",
+		}
+	}
+
+	if webhook.MinTotalRisk < minWebhookTotalRisk || webhook.MinTotalRisk > maxWebhookTotalRisk {
+		return &ValidationError{
+			paramName:  "min_total_risk",
+			paramValue: webhook.MinTotalRisk,
+			errString:  "value from 1 to 4 expected",
+		}
+	}
+
+	return nil
+}
+
+// checkWebhookHost refuses webhooks pointing to the internal network, i.e. to loopback,
+// link-local, private or unspecified addresses, the URL has been validated already
+func checkWebhookHost(request *http.Request, webhook webhookRequest) error {
+	webhookURL, err := url.Parse(webhook.URL)
+	if err != nil {
+		return err
+	}
+
+	err = webhooks.CheckHost(request.Context(), webhookURL.Hostname())
+	if err != nil {
+		return &ValidationError{
+			paramName:  "url",
+			paramValue: webhook.URL,
+			errString:  err.Error(),
+		}
+	}
+
+	return nil
+}
+
+// hideWebhookSecret removes the secret so that it's never sent back to clients
+func hideWebhookSecret(webhook *types.Webhook) {
+	webhook.Secret = ""
+}
+
+// listWebhooks returns webhook subscriptions of the organization
+func (server *HTTPServer) listWebhooks(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhooks, err := server.requestStorage(request).ListWebhooksForOrg(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get list of webhooks")
+		handleServerError(writer, err)
+		return
+	}
+
+	for i := range webhooks {
+		hideWebhookSecret(&webhooks[i])
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("webhooks", webhooks))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// createWebhook subscribes new webhook for the organization
+func (server *HTTPServer) createWebhook(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookData, err := server.readWebhookRequest(writer, request, true)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookID, err := server.requestStorage(request).CreateWebhook(types.Webhook{
+		OrgID:        organizationID,
+		URL:          webhookData.URL,
+		Secret:       webhookData.Secret,
+		MinTotalRisk: webhookData.MinTotalRisk,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create webhook")
+		handleServerError(writer, err)
+		return
+	}
+
+	server.sendWebhook(writer, request, organizationID, webhookID)
+}
+
+// getWebhook returns one webhook subscription of the organization
+func (server *HTTPServer) getWebhook(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookID, err := readWebhookID(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	server.sendWebhook(writer, request, organizationID, webhookID)
+}
+
+// updateWebhook changes URL, secret or risk threshold of the webhook subscription
+func (server *HTTPServer) updateWebhook(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookID, err := readWebhookID(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookData, err := server.readWebhookRequest(writer, request, false)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhook, err := server.requestStorage(request).GetWebhook(organizationID, webhookID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	webhook.URL = webhookData.URL
+	webhook.MinTotalRisk = webhookData.MinTotalRisk
+	if webhookData.Secret != "" {
+		webhook.Secret = webhookData.Secret
+	}
+
+	err = server.requestStorage(request).UpdateWebhook(*webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to update webhook")
+		handleServerError(writer, err)
+		return
+	}
+
+	server.sendWebhook(writer, request, organizationID, webhookID)
+}
+
+// deleteWebhook unsubscribes the webhook
+func (server *HTTPServer) deleteWebhook(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookID, err := readWebhookID(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	err = server.requestStorage(request).DeleteWebhook(organizationID, webhookID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// listWebhookDeliveries returns the latest records from delivery log of the webhook
+func (server *HTTPServer) listWebhookDeliveries(writer http.ResponseWriter, request *http.Request) {
+	organizationID, err := readOrganizationID(writer, request, server.Config.Auth)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	webhookID, err := readWebhookID(writer, request)
+	if err != nil {
+		// everything has been handled already
+		return
+	}
+
+	// checks that the webhook belongs to the organization
+	_, err = server.requestStorage(request).GetWebhook(organizationID, webhookID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	deliveries, err := server.requestStorage(request).ListWebhookDeliveries(webhookID, webhookDeliveriesLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get webhook deliveries")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("deliveries", deliveries))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// sendWebhook reads the webhook from storage and sends it without its secret
+func (server *HTTPServer) sendWebhook(
+	writer http.ResponseWriter, request *http.Request, orgID types.OrgID, webhookID types.WebhookID,
+) {
+	webhook, err := server.requestStorage(request).GetWebhook(orgID, webhookID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	hideWebhookSecret(webhook)
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("webhook", webhook))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const webhookBody = `{"url": "https://example.com/hook", "secret": "secret", "min_total_risk": 3}`
+
+// checkWebhooksBody compares response bodies ignoring timestamps of webhooks
+func checkWebhooksBody(t testing.TB, expected, got string) {
+	var body map[string]interface{}
+	helpers.FailOnError(t, json.Unmarshal([]byte(got), &body))
+
+	removeTimestamps := func(webhook interface{}) {
+		delete(webhook.(map[string]interface{}), "created_at")
+		delete(webhook.(map[string]interface{}), "updated_at")
+	}
+
+	if webhook, found := body["webhook"]; found {
+		removeTimestamps(webhook)
+	}
+	if webhooks, found := body["webhooks"]; found {
+		for _, webhook := range webhooks.([]interface{}) {
+			removeTimestamps(webhook)
+		}
+	}
+
+	gotWithoutTimestamps, err := json.Marshal(body)
+	helpers.FailOnError(t, err)
+
+	assert.JSONEq(t, expected, string(gotWithoutTimestamps))
+}
+
+func mustCreateWebhook(t *testing.T, mockStorage storage.Storage, orgID types.OrgID) types.WebhookID {
+	webhookID, err := mockStorage.CreateWebhook(types.Webhook{
+		OrgID:        orgID,
+		URL:          "https://example.com/hook",
+		Secret:       "secret",
+		MinTotalRisk: 3,
+	})
+	helpers.FailOnError(t, err)
+
+	return webhookID
+}
+
+func TestCreateWebhook(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.OrganizationWebhooksEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         webhookBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"webhook": {"id": 1, "org_id": 1, "url": "https://example.com/hook", "min_total_risk": 3}
+		}`,
+		BodyChecker: checkWebhooksBody,
+	})
+
+	webhook, err := mockStorage.GetWebhook(testdata.OrgID, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "secret", webhook.Secret)
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	for _, body := range []string{
+		`{"url": "example.com/hook", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "ftp://example.com/hook", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "https://example.com/hook", "min_total_risk": 3}`,
+		`{"url": "https://example.com/hook", "secret": "secret", "min_total_risk": 0}`,
+		`{"url": "https://example.com/hook", "secret": "secret", "min_total_risk": 5}`,
+		`{"url": "https://example.com/hook", "secret": "secret", "min_total_risk": "3"}`,
+		`not json`,
+		``,
+	} {
+		helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+			Method:       http.MethodPost,
+			Endpoint:     server.OrganizationWebhooksEndpoint,
+			EndpointArgs: []interface{}{testdata.OrgID},
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestCreateWebhookPrivateAddress(t *testing.T) {
+	for _, body := range []string{
+		`{"url": "http://169.254.169.254/latest/meta-data", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "http://127.0.0.1:8080/hook", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "http://10.0.0.1/hook", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "http://[::1]/hook", "secret": "secret", "min_total_risk": 3}`,
+		`{"url": "http://localhost/hook", "secret": "secret", "min_total_risk": 3}`,
+	} {
+		helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+			Method:       http.MethodPost,
+			Endpoint:     server.OrganizationWebhooksEndpoint,
+			EndpointArgs: []interface{}{testdata.OrgID},
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestListWebhooks(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationWebhooksEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "webhooks": []}`,
+	})
+
+	mustCreateWebhook(t, mockStorage, testdata.OrgID)
+	mustCreateWebhook(t, mockStorage, testdata.OrgID+1)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationWebhooksEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"webhooks": [{"id": 1, "org_id": 1, "url": "https://example.com/hook", "min_total_risk": 3}]
+		}`,
+		BodyChecker: checkWebhooksBody,
+	})
+}
+
+func TestGetWebhookOfAnotherOrganization(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testdata.OrgID+1)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, webhookID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestGetWebhookBadID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, "not-an-id"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestUpdateWebhookKeepsSecret(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testdata.OrgID)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, webhookID},
+		Body:         `{"url": "http://example.com/other", "min_total_risk": 4}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"webhook": {"id": 1, "org_id": 1, "url": "http://example.com/other", "min_total_risk": 4}
+		}`,
+		BodyChecker: checkWebhooksBody,
+	})
+
+	webhook, err := mockStorage.GetWebhook(testdata.OrgID, webhookID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "secret", webhook.Secret)
+}
+
+func TestUpdateWebhookNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, 1},
+		Body:         webhookBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testdata.OrgID)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, webhookID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.OrganizationWebhookEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, webhookID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testdata.OrgID)
+
+	helpers.FailOnError(t, mockStorage.WriteWebhookDelivery(types.WebhookDelivery{
+		WebhookID:   webhookID,
+		ClusterName: testdata.ClusterName,
+		Payload:     "{}",
+		Attempts:    1,
+		StatusCode:  http.StatusOK,
+		Succeeded:   true,
+		DeliveredAt: testdata.LastCheckedAt,
+	}))
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.WebhookDeliveriesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, webhookID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"deliveries": [{
+				"webhook_id": 1,
+				"cluster": "` + string(testdata.ClusterName) + `",
+				"payload": "{}",
+				"attempts": 1,
+				"status_code": 200,
+				"error": "",
+				"succeeded": true,
+				"delivered_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `"
+			}]
+		}`,
+	})
+
+	// webhook of another organization
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.WebhookDeliveriesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID + 1, webhookID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
@@ -0,0 +1,716 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// DefaultCacheMaxReports is the number of cached reports used when not configured
+	DefaultCacheMaxReports = 10000
+	// DefaultCacheMaxRuleContent is the number of cached rules used when not configured
+	DefaultCacheMaxRuleContent = 5000
+	// DefaultCacheMaxContents is the number of cached contents of rules hit by clusters used when not configured
+	DefaultCacheMaxContents = 10000
+	// DefaultCacheMaxFeedback is the number of cached feedback of users on clusters used when not configured
+	DefaultCacheMaxFeedback = 10000
+	// DefaultCacheContentsTTL is how long contents of rules hit by clusters are cached when not configured
+	DefaultCacheContentsTTL = time.Minute
+
+	// generationStripes is the number of generations of clusters, the clusters share them
+	// by hash of their names, so that the memory used by the generations is bounded
+	generationStripes = 256
+
+	reportCacheName      = "report"
+	ruleContentCacheName = "rule_content"
+	feedbackCacheName    = "feedback"
+	ruleCacheName        = "rule"
+)
+
+type cachedReport struct {
+	orgID       types.OrgID
+	report      types.ClusterReport
+	lastChecked types.Timestamp
+}
+
+type clusterUserKey struct {
+	clusterName types.ClusterName
+	userID      types.UserID
+}
+
+// contentsKey is the key of cached content of rules hit by the cluster, the variants
+// of the content read with different filters are cached side by side
+type contentsKey struct {
+	clusterUserKey
+	filter RuleContentFilter
+}
+
+// cachedRulesContent expires, because the rules become visible when their publish date passes
+type cachedRulesContent struct {
+	fingerprint string
+	rules       []types.RuleContentResponse
+	expiresAt   time.Time
+}
+
+type cachedFeedbacks struct {
+	fingerprint string
+	feedbacks   map[types.RuleID]types.UserVote
+}
+
+type ruleWithContentKey struct {
+	ruleID   types.RuleID
+	errorKey types.ErrorKey
+	filter   RuleContentFilter
+}
+
+// contentLanguagesKey is the key of available content languages in the rules cache
+type contentLanguagesKey struct{}
+
+// Cache holds the data cached by CachedStorage. One instance should be
+// shared by all CachedStorage instances in the process (consumer and server)
+// so that the reports written by the consumer invalidate the reports read
+// by the server.
+//
+// Every invalidation increments a generation, the global one or the one of
+// the cluster. Values read from the underlying storage are added only if the
+// generation hasn't changed during the read, otherwise a read racing with
+// the invalidation could put the old value back into the cache.
+//
+// When the storage reads from a replica, the value read just after the
+// invalidation can still be the old one, because the replica hasn't caught
+// up with the write yet. So nothing is added for the replica lag after the
+// invalidation of the cluster or of all data.
+type Cache struct {
+	mutex                sync.Mutex
+	reports              *lruCache
+	contents             *lruCache
+	feedback             *lruCache
+	rules                *lruCache
+	contentsTTL          time.Duration
+	replicaLag           time.Duration
+	generation           uint64
+	clusterGenerations   [generationStripes]uint64
+	invalidatedAt        time.Time
+	clusterInvalidatedAt [generationStripes]time.Time
+}
+
+// NewCache creates a new cache with sizes taken from the storage configuration
+func NewCache(configuration Configuration) *Cache {
+	maxReports := configuration.CacheMaxReports
+	if maxReports <= 0 {
+		maxReports = DefaultCacheMaxReports
+	}
+
+	maxRuleContent := configuration.CacheMaxRuleContent
+	if maxRuleContent <= 0 {
+		maxRuleContent = DefaultCacheMaxRuleContent
+	}
+
+	maxContents := configuration.CacheMaxContents
+	if maxContents <= 0 {
+		maxContents = DefaultCacheMaxContents
+	}
+
+	maxFeedback := configuration.CacheMaxFeedback
+	if maxFeedback <= 0 {
+		maxFeedback = DefaultCacheMaxFeedback
+	}
+
+	contentsTTL := configuration.CacheContentsTTL
+	if contentsTTL <= 0 {
+		contentsTTL = DefaultCacheContentsTTL
+	}
+
+	return &Cache{
+		reports:     newLRUCache(maxReports),
+		contents:    newLRUCache(maxContents),
+		feedback:    newLRUCache(maxFeedback),
+		rules:       newLRUCache(maxRuleContent),
+		contentsTTL: contentsTTL,
+		replicaLag:  configuredReplicaLag(configuration),
+	}
+}
+
+// generationStripe returns index of the generation shared by the cluster
+func generationStripe(clusterName types.ClusterName) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(clusterName))
+	return int(hash.Sum32() % generationStripes)
+}
+
+// currentGeneration returns generation of the cached data of the cluster, it changes on every
+// invalidation of the cluster's data or of all data. Rule entries not related to any cluster
+// use empty cluster name, so they are guarded by the global generation.
+func (cache *Cache) currentGeneration(clusterName types.ClusterName) uint64 {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.generationLocked(clusterName)
+}
+
+func (cache *Cache) generationLocked(clusterName types.ClusterName) uint64 {
+	return cache.generation + cache.clusterGenerations[generationStripe(clusterName)]
+}
+
+// invalidateClusterLocked starts a new generation of the cluster's data
+func (cache *Cache) invalidateClusterLocked(clusterName types.ClusterName) {
+	stripe := generationStripe(clusterName)
+	cache.clusterGenerations[stripe]++
+	cache.clusterInvalidatedAt[stripe] = time.Now()
+}
+
+// invalidateAllLocked starts a new generation of all data
+func (cache *Cache) invalidateAllLocked() {
+	cache.generation++
+	cache.invalidatedAt = time.Now()
+}
+
+// mayReadStaleLocked checks whether the replica could return data of the cluster
+// older than the last invalidation, because it's lagging behind the primary
+func (cache *Cache) mayReadStaleLocked(clusterName types.ClusterName) bool {
+	if cache.replicaLag <= 0 {
+		return false
+	}
+
+	return time.Since(cache.invalidatedAt) < cache.replicaLag ||
+		time.Since(cache.clusterInvalidatedAt[generationStripe(clusterName)]) < cache.replicaLag
+}
+
+// InvalidateCluster removes all cached data related to the cluster
+func (cache *Cache) InvalidateCluster(clusterName types.ClusterName) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateClusterLocked(clusterName)
+
+	cache.reports.remove(clusterName)
+	cache.contents.removeIf(func(key interface{}) bool {
+		return key.(contentsKey).clusterName == clusterName
+	})
+	cache.feedback.removeIf(func(key interface{}) bool {
+		return key.(clusterUserKey).clusterName == clusterName
+	})
+}
+
+// InvalidateRuleContent removes all cached rule content, it needs
+// to be called every time the rule content changes in the storage
+func (cache *Cache) InvalidateRuleContent() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateAllLocked()
+
+	cache.rules.clear()
+	cache.contents.clear()
+}
+
+// invalidateContents removes cached content for rules of all clusters and users
+func (cache *Cache) invalidateContents() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateAllLocked()
+
+	cache.contents.clear()
+}
+
+// InvalidateAll removes everything from the cache
+func (cache *Cache) InvalidateAll() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateAllLocked()
+
+	cache.reports.clear()
+	cache.contents.clear()
+	cache.feedback.clear()
+	cache.rules.clear()
+}
+
+func (cache *Cache) get(lru *lruCache, name string, key interface{}) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	value, found := lru.get(key)
+	if found {
+		metrics.StorageCacheHits.WithLabelValues(name).Inc()
+	} else {
+		metrics.StorageCacheMisses.WithLabelValues(name).Inc()
+	}
+
+	return value, found
+}
+
+// add adds the value read from the underlying storage unless the data of the cluster
+// have been invalidated since the generation was taken before the read or the value
+// could have been read from the replica before it caught up with the invalidating write
+func (cache *Cache) add(lru *lruCache, key, value interface{}, clusterName types.ClusterName, generation uint64) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.generationLocked(clusterName) != generation || cache.mayReadStaleLocked(clusterName) {
+		return
+	}
+
+	lru.add(key, value)
+}
+
+func (cache *Cache) remove(lru *lruCache, key clusterUserKey) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateClusterLocked(key.clusterName)
+
+	lru.remove(key)
+}
+
+// removeContents removes the content cached for the cluster and the user with any filter
+func (cache *Cache) removeContents(key clusterUserKey) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateClusterLocked(key.clusterName)
+
+	cache.contents.removeIf(func(cachedKey interface{}) bool {
+		return cachedKey.(contentsKey).clusterUserKey == key
+	})
+}
+
+// CachedStorage is a decorator of Storage interface which caches reports,
+// rule content and user feedback in memory. Rule content is cached until
+// it is changed via this storage, reports until a newer one is written
+// and feedback until the user votes again. All methods that are not
+// overridden here are passed directly to the underlying storage.
+//
+// Please note that the cache is local to the process, so changes made
+// by other replicas are not visible until the cached entries are evicted.
+type CachedStorage struct {
+	Storage
+	cache *Cache
+}
+
+// NewCachedStorage wraps the storage with the provided cache
+func NewCachedStorage(backend Storage, cache *Cache) *CachedStorage {
+	return &CachedStorage{
+		Storage: backend,
+		cache:   cache,
+	}
+}
+
+// WithContext returns the cached storage whose underlying storage runs its queries
+// in the context, the cache is shared with this storage
+func (storage *CachedStorage) WithContext(ctx context.Context) Storage {
+	return NewCachedStorage(storage.Storage.WithContext(ctx), storage.cache)
+}
+
+// GetCache returns the cache used by this storage
+func (storage *CachedStorage) GetCache() *Cache {
+	return storage.cache
+}
+
+// ReadReportForCluster returns the cached report or reads it from the underlying storage
+func (storage *CachedStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	if value, found := storage.cache.get(storage.cache.reports, reportCacheName, clusterName); found {
+		if cached := value.(cachedReport); cached.orgID == orgID {
+			return cached.report, cached.lastChecked, nil
+		}
+	}
+
+	generation := storage.cache.currentGeneration(clusterName)
+
+	report, lastChecked, err := storage.Storage.ReadReportForCluster(orgID, clusterName)
+	if err != nil {
+		return report, lastChecked, err
+	}
+
+	storage.cache.add(storage.cache.reports, clusterName, cachedReport{
+		orgID:       orgID,
+		report:      report,
+		lastChecked: lastChecked,
+	}, clusterName, generation)
+
+	return report, lastChecked, nil
+}
+
+// ReadReportForClusterByClusterName returns the cached report or reads it from the underlying storage
+func (storage *CachedStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	if value, found := storage.cache.get(storage.cache.reports, reportCacheName, clusterName); found {
+		cached := value.(cachedReport)
+		return cached.report, cached.lastChecked, nil
+	}
+
+	return storage.Storage.ReadReportForClusterByClusterName(clusterName)
+}
+
+// WriteReportForCluster writes the report and invalidates everything cached for the cluster
+func (storage *CachedStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	collectedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	err := storage.Storage.WriteReportForCluster(orgID, clusterName, report, collectedAtTime, kafkaOffset)
+	if err == nil {
+		storage.cache.InvalidateCluster(clusterName)
+	}
+
+	return err
+}
+
+// WriteReportsForClusters writes the reports and invalidates everything cached for the clusters
+// whose reports were written
+func (storage *CachedStorage) WriteReportsForClusters(reports []ReportWrite) ([]error, error) {
+	results, err := storage.Storage.WriteReportsForClusters(reports)
+	if err != nil {
+		return results, err
+	}
+
+	for i, result := range results {
+		if result == nil {
+			storage.cache.InvalidateCluster(reports[i].ClusterName)
+		}
+	}
+
+	return results, nil
+}
+
+// PurgeStaleClusters removes the stale clusters and invalidates everything cached for them
+func (storage *CachedStorage) PurgeStaleClusters(lastCheckedBefore time.Time, dryRun bool) (PurgedClusters, error) {
+	purged, err := storage.Storage.PurgeStaleClusters(lastCheckedBefore, dryRun)
+	if err == nil && !dryRun {
+		for _, clusterName := range purged.Clusters {
+			storage.cache.InvalidateCluster(clusterName)
+		}
+	}
+
+	return purged, err
+}
+
+// DeleteReportsForOrg deletes the reports and invalidates cached reports
+func (storage *CachedStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	err := storage.Storage.DeleteReportsForOrg(orgID)
+
+	// cluster names for the organization are not known here,
+	// but this operation is rare enough to just drop everything
+	storage.cache.InvalidateAll()
+
+	return err
+}
+
+// PurgeOrganization removes all data of the organization and invalidates everything cached
+func (storage *CachedStorage) PurgeOrganization(orgID types.OrgID) (PurgedRows, error) {
+	purged, err := storage.Storage.PurgeOrganization(orgID)
+
+	// like for DeleteReportsForOrg, cluster names of the organization are not known here
+	storage.cache.InvalidateAll()
+
+	return purged, err
+}
+
+// DeleteReportsForCluster deletes the reports and invalidates everything cached for the cluster
+func (storage *CachedStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	err := storage.Storage.DeleteReportsForCluster(clusterName)
+	storage.cache.InvalidateCluster(clusterName)
+
+	return err
+}
+
+// GetContentForRules returns the cached content or reads it from the underlying storage,
+// the content is cached just for a limited time because the visibility of rules depends on it
+func (storage *CachedStorage) GetContentForRules(
+	reportRules types.ReportRules,
+	userID types.UserID,
+	clusterName types.ClusterName,
+	filter RuleContentFilter,
+) ([]types.RuleContentResponse, error) {
+	key := contentsKey{
+		clusterUserKey: clusterUserKey{clusterName: clusterName, userID: userID},
+		filter:         filter,
+	}
+
+	fingerprint, err := json.Marshal(reportRules.HitRules)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to compute fingerprint of hit rules, bypassing cache")
+		return storage.Storage.GetContentForRules(reportRules, userID, clusterName, filter)
+	}
+
+	if value, found := storage.cache.get(storage.cache.contents, ruleContentCacheName, key); found {
+		cached := value.(cachedRulesContent)
+		if cached.fingerprint == string(fingerprint) && time.Now().Before(cached.expiresAt) {
+			return copyRuleContentResponses(cached.rules), nil
+		}
+	}
+
+	generation := storage.cache.currentGeneration(clusterName)
+
+	rules, err := storage.Storage.GetContentForRules(reportRules, userID, clusterName, filter)
+	if err != nil {
+		return rules, err
+	}
+
+	storage.cache.add(storage.cache.contents, key, cachedRulesContent{
+		fingerprint: string(fingerprint),
+		rules:       copyRuleContentResponses(rules),
+		expiresAt:   time.Now().Add(storage.cache.contentsTTL),
+	}, clusterName, generation)
+
+	return rules, nil
+}
+
+// GetUserFeedbackOnRules returns the cached feedback or reads it from the underlying storage
+func (storage *CachedStorage) GetUserFeedbackOnRules(
+	clusterID types.ClusterName,
+	rulesContent []types.RuleContentResponse,
+	userID types.UserID,
+) (map[types.RuleID]types.UserVote, error) {
+	key := clusterUserKey{clusterName: clusterID, userID: userID}
+
+	var fingerprint string
+	for _, rule := range rulesContent {
+		fingerprint += rule.RuleModule + ","
+	}
+
+	if value, found := storage.cache.get(storage.cache.feedback, feedbackCacheName, key); found {
+		if cached := value.(cachedFeedbacks); cached.fingerprint == fingerprint {
+			return copyFeedbacks(cached.feedbacks), nil
+		}
+	}
+
+	generation := storage.cache.currentGeneration(clusterID)
+
+	feedbacks, err := storage.Storage.GetUserFeedbackOnRules(clusterID, rulesContent, userID)
+	if err != nil {
+		return feedbacks, err
+	}
+
+	storage.cache.add(storage.cache.feedback, key, cachedFeedbacks{
+		fingerprint: fingerprint,
+		feedbacks:   copyFeedbacks(feedbacks),
+	}, clusterID, generation)
+
+	return feedbacks, nil
+}
+
+// VoteOnRule votes on the rule and invalidates cached feedback of the user
+func (storage *CachedStorage) VoteOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	userVote types.UserVote,
+) error {
+	err := storage.Storage.VoteOnRule(clusterID, ruleID, userID, userVote)
+	storage.cache.remove(storage.cache.feedback, clusterUserKey{clusterName: clusterID, userID: userID})
+
+	return err
+}
+
+// AddOrUpdateFeedbackOnRule stores the feedback and invalidates cached feedback of the user
+func (storage *CachedStorage) AddOrUpdateFeedbackOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	message string,
+) error {
+	err := storage.Storage.AddOrUpdateFeedbackOnRule(clusterID, ruleID, userID, message)
+	storage.cache.remove(storage.cache.feedback, clusterUserKey{clusterName: clusterID, userID: userID})
+
+	return err
+}
+
+// ToggleRuleForCluster toggles the rule and invalidates cached content of the user
+func (storage *CachedStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	ruleToggle RuleToggle,
+) error {
+	err := storage.Storage.ToggleRuleForCluster(clusterID, ruleID, userID, ruleToggle)
+	storage.cache.removeContents(clusterUserKey{clusterName: clusterID, userID: userID})
+
+	return err
+}
+
+// DeleteFromRuleClusterToggle deletes the toggle and invalidates cached content of the user
+func (storage *CachedStorage) DeleteFromRuleClusterToggle(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+) error {
+	err := storage.Storage.DeleteFromRuleClusterToggle(clusterID, ruleID, userID)
+	storage.cache.removeContents(clusterUserKey{clusterName: clusterID, userID: userID})
+
+	return err
+}
+
+// DisableRuleForOrg disables the rule and invalidates all cached content, clusters
+// of the organization aren't known here, see DeleteReportsForOrg
+func (storage *CachedStorage) DisableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	err := storage.Storage.DisableRuleForOrg(orgID, ruleID, userID)
+	storage.cache.invalidateContents()
+
+	return err
+}
+
+// EnableRuleForOrg enables the rule and invalidates all cached content
+func (storage *CachedStorage) EnableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	err := storage.Storage.EnableRuleForOrg(orgID, ruleID, userID)
+	storage.cache.invalidateContents()
+
+	return err
+}
+
+// GetRuleByID returns the cached rule or reads it from the underlying storage
+func (storage *CachedStorage) GetRuleByID(ruleID types.RuleID) (*types.Rule, error) {
+	if value, found := storage.cache.get(storage.cache.rules, ruleCacheName, ruleID); found {
+		rule := value.(types.Rule)
+		return &rule, nil
+	}
+
+	generation := storage.cache.currentGeneration("")
+
+	rule, err := storage.Storage.GetRuleByID(ruleID)
+	if err != nil || rule == nil {
+		return rule, err
+	}
+
+	storage.cache.add(storage.cache.rules, ruleID, *rule, "", generation)
+
+	return rule, nil
+}
+
+// GetRuleWithContent returns the cached rule content or reads it from the underlying storage.
+// Rules are cached per filter, only visible ones are cached, so a rule being published
+// later can't make a cached one invisible.
+func (storage *CachedStorage) GetRuleWithContent(
+	ruleID types.RuleID, ruleErrorKey types.ErrorKey, filter RuleContentFilter,
+) (*types.RuleWithContent, error) {
+	key := ruleWithContentKey{ruleID: ruleID, errorKey: ruleErrorKey, filter: filter}
+
+	if value, found := storage.cache.get(storage.cache.rules, ruleCacheName, key); found {
+		rule := value.(types.RuleWithContent)
+		return &rule, nil
+	}
+
+	generation := storage.cache.currentGeneration("")
+
+	rule, err := storage.Storage.GetRuleWithContent(ruleID, ruleErrorKey, filter)
+	if err != nil || rule == nil {
+		return rule, err
+	}
+
+	storage.cache.add(storage.cache.rules, key, *rule, "", generation)
+
+	return rule, nil
+}
+
+// GetContentLanguages returns the cached content languages or reads them from the underlying storage
+func (storage *CachedStorage) GetContentLanguages() ([]string, error) {
+	if value, found := storage.cache.get(storage.cache.rules, ruleCacheName, contentLanguagesKey{}); found {
+		return append([]string(nil), value.([]string)...), nil
+	}
+
+	generation := storage.cache.currentGeneration("")
+
+	languages, err := storage.Storage.GetContentLanguages()
+	if err != nil {
+		return languages, err
+	}
+
+	storage.cache.add(storage.cache.rules, contentLanguagesKey{}, append([]string(nil), languages...), "", generation)
+
+	return languages, nil
+}
+
+// LoadRuleContent loads the rule content and invalidates all cached rule content
+func (storage *CachedStorage) LoadRuleContent(contentDir content.RuleContentDirectory) error {
+	err := storage.Storage.LoadRuleContent(contentDir)
+	storage.cache.InvalidateRuleContent()
+
+	return err
+}
+
+// CreateRule creates the rule and invalidates all cached rule content
+func (storage *CachedStorage) CreateRule(ruleData types.Rule) error {
+	err := storage.Storage.CreateRule(ruleData)
+	storage.cache.InvalidateRuleContent()
+
+	return err
+}
+
+// DeleteRule deletes the rule and invalidates all cached rule content
+func (storage *CachedStorage) DeleteRule(ruleID types.RuleID) error {
+	err := storage.Storage.DeleteRule(ruleID)
+	storage.cache.InvalidateRuleContent()
+
+	return err
+}
+
+// CreateRuleErrorKey creates the rule error key and invalidates all cached rule content
+func (storage *CachedStorage) CreateRuleErrorKey(ruleErrorKey types.RuleErrorKey) error {
+	err := storage.Storage.CreateRuleErrorKey(ruleErrorKey)
+	storage.cache.InvalidateRuleContent()
+
+	return err
+}
+
+// DeleteRuleErrorKey deletes the rule error key and invalidates all cached rule content
+func (storage *CachedStorage) DeleteRuleErrorKey(ruleID types.RuleID, errorKey types.ErrorKey) error {
+	err := storage.Storage.DeleteRuleErrorKey(ruleID, errorKey)
+	storage.cache.InvalidateRuleContent()
+
+	return err
+}
+
+// copyRuleContentResponses makes a shallow copy of the slice,
+// because callers modify the returned items (user votes etc.)
+func copyRuleContentResponses(rules []types.RuleContentResponse) []types.RuleContentResponse {
+	if rules == nil {
+		return nil
+	}
+
+	result := make([]types.RuleContentResponse, len(rules))
+	copy(result, rules)
+
+	return result
+}
+
+func copyFeedbacks(feedbacks map[types.RuleID]types.UserVote) map[types.RuleID]types.UserVote {
+	if feedbacks == nil {
+		return nil
+	}
+
+	result := make(map[types.RuleID]types.UserVote, len(feedbacks))
+	for ruleID, vote := range feedbacks {
+		result[ruleID] = vote
+	}
+
+	return result
+}
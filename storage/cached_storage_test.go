@@ -0,0 +1,497 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustGetCachedStorage(t *testing.T) (*storage.CachedStorage, func()) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+
+	return storage.NewCachedStorage(mockStorage, storage.NewCache(storage.Configuration{})), closer
+}
+
+func getReportRules(t *testing.T, report types.ClusterReport) types.ReportRules {
+	var reportRules types.ReportRules
+	helpers.FailOnError(t, json.Unmarshal([]byte(report), &reportRules))
+	return reportRules
+}
+
+func cacheHits(cacheName string) float64 {
+	return testutil.ToFloat64(metrics.StorageCacheHits.WithLabelValues(cacheName))
+}
+
+func cacheMisses(cacheName string) float64 {
+	return testutil.ToFloat64(metrics.StorageCacheMisses.WithLabelValues(cacheName))
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := storage.NewLRUCache(2)
+
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+
+	// "a" becomes the most recently used one
+	_, found := cache.Get("a")
+	assert.True(t, found)
+
+	cache.Add("c", 3)
+	assert.Equal(t, 2, cache.Len())
+
+	_, found = cache.Get("b")
+	assert.False(t, found, "least recently used entry should be evicted")
+
+	value, found := cache.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	cache.Remove("a")
+	_, found = cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestLRUCacheUpdateExisting(t *testing.T) {
+	cache := storage.NewLRUCache(1)
+
+	cache.Add("a", 1)
+	cache.Add("a", 2)
+
+	value, found := cache.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestCachedStorageReadReportHit(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	hits, misses := cacheHits("report"), cacheMisses("report")
+
+	report, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	report, _, err = cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	assert.Equal(t, misses+1, cacheMisses("report"))
+	assert.Equal(t, hits+1, cacheHits("report"))
+}
+
+func TestCachedStorageReadReportDifferentOrg(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	// cached report must not be returned for another organization
+	_, _, err = cachedStorage.ReadReportForCluster(testdata.OrgID+1, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestCachedStorageWriteNewerReportInvalidates(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	err = cachedStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	report, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+}
+
+func TestCachedStorageWriteReportsBatchInvalidates(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	results, err := cachedStorage.WriteReportsForClusters([]storage.ReportWrite{{
+		OrgID:           testdata.OrgID,
+		ClusterName:     testdata.ClusterName,
+		Report:          testdata.Report2Rules,
+		LastCheckedTime: testdata.LastCheckedAt.Add(time.Hour),
+		KafkaOffset:     testdata.KafkaOffset,
+	}})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []error{nil}, results)
+
+	report, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+}
+
+func TestCachedStorageSharedCache(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cache := storage.NewCache(storage.Configuration{})
+	serverStorage := storage.NewCachedStorage(mockStorage, cache)
+	consumerStorage := storage.NewCachedStorage(mockStorage, cache)
+
+	mustWriteReport3Rules(t, consumerStorage)
+
+	_, _, err := serverStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	err = consumerStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	report, _, err := serverStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+}
+
+func TestCachedStorageContentInvalidatedByToggle(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+	reportRules := getReportRules(t, testdata.Report3Rules)
+
+	rules, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+
+	hits := cacheHits("rule_content")
+
+	// returned rules can be modified by the caller without affecting the cache
+	rules[0].UserVote = types.UserVoteLike
+
+	rules, err = cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, hits+1, cacheHits("rule_content"))
+	for _, rule := range rules {
+		assert.Equal(t, types.UserVoteNone, rule.UserVote)
+		assert.False(t, rule.Disabled)
+	}
+
+	helpers.FailOnError(t, cachedStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	rules, err = cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+
+	for _, rule := range rules {
+		assert.Equal(t, rule.RuleModule == string(testdata.Rule1ID), rule.Disabled)
+	}
+}
+
+func TestCachedStorageContentFilters(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+	reportRules := getReportRules(t, testdata.Report3Rules)
+
+	filters := []storage.RuleContentFilter{{}, {IncludeInactive: true}}
+
+	for _, filter := range filters {
+		_, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, filter)
+		helpers.FailOnError(t, err)
+	}
+
+	hits, misses := cacheHits("rule_content"), cacheMisses("rule_content")
+
+	// the content read with different filters is cached side by side
+	for _, filter := range filters {
+		_, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, filter)
+		helpers.FailOnError(t, err)
+	}
+
+	assert.Equal(t, hits+2, cacheHits("rule_content"))
+	assert.Equal(t, misses, cacheMisses("rule_content"))
+
+	// the toggle invalidates all the variants
+	helpers.FailOnError(t, cachedStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	for _, filter := range filters {
+		rules, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, filter)
+		helpers.FailOnError(t, err)
+		for _, rule := range rules {
+			assert.Equal(t, rule.RuleModule == string(testdata.Rule1ID), rule.Disabled)
+		}
+	}
+
+	assert.Equal(t, misses+2, cacheMisses("rule_content"))
+}
+
+func TestCachedStorageFeedbackInvalidatedByVote(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	feedbacks, err := cachedStorage.GetUserFeedbackOnRules(
+		testdata.ClusterName, testdata.RuleContentResponses, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, feedbacks)
+
+	helpers.FailOnError(t, cachedStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike,
+	))
+
+	feedbacks, err = cachedStorage.GetUserFeedbackOnRules(
+		testdata.ClusterName, testdata.RuleContentResponses, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]types.UserVote{testdata.Rule1ID: types.UserVoteLike}, feedbacks)
+}
+
+func TestCachedStorageRuleContentInvalidatedByLoad(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	helpers.FailOnError(t, cachedStorage.CreateRule(testdata.Rule1))
+	helpers.FailOnError(t, cachedStorage.CreateRuleErrorKey(testdata.RuleErrorKey1))
+
+	rule, err := cachedStorage.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.RuleWithContent1, *rule)
+
+	hits := cacheHits("rule")
+
+	rule, err = cachedStorage.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.RuleWithContent1, *rule)
+	assert.Equal(t, hits+1, cacheHits("rule"))
+
+	helpers.FailOnError(t, cachedStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	rule, err = cachedStorage.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Rule1Description, rule.Description)
+}
+
+func TestCachedStorageSizeLimit(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cachedStorage := storage.NewCachedStorage(mockStorage, storage.NewCache(storage.Configuration{
+		CacheMaxReports: 1,
+	}))
+
+	cluster1, cluster2 := testdata.GetRandomClusterID(), testdata.GetRandomClusterID()
+	for _, cluster := range []types.ClusterName{cluster1, cluster2} {
+		writeReportForCluster(t, cachedStorage, testdata.OrgID, cluster, testClusterEmptyReport)
+
+		_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, cluster)
+		helpers.FailOnError(t, err)
+	}
+
+	misses := cacheMisses("report")
+
+	// the first cluster has been evicted by the second one
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, cluster1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, misses+1, cacheMisses("report"))
+}
+
+func TestCachedStorageWithContextSharesCache(t *testing.T) {
+	cachedStorage, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctxStorage := cachedStorage.WithContext(ctx)
+	assert.IsType(t, &storage.CachedStorage{}, ctxStorage)
+
+	// the cached report is returned without any query
+	report, _, err := ctxStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	_, err = ctxStorage.ReportsCount()
+	assert.Equal(t, context.Canceled, err)
+}
+
+// racingStorage calls the hook after it has read the report, like a write
+// of a newer report finished while the report was being read
+type racingStorage struct {
+	storage.Storage
+	afterRead func()
+}
+
+func (backend *racingStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	report, lastChecked, err := backend.Storage.ReadReportForCluster(orgID, clusterName)
+	if backend.afterRead != nil {
+		backend.afterRead()
+		backend.afterRead = nil
+	}
+	return report, lastChecked, err
+}
+
+func TestCachedStorageReadRacingWithInvalidation(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	backend := &racingStorage{Storage: mockStorage}
+	cachedStorage := storage.NewCachedStorage(backend, storage.NewCache(storage.Configuration{}))
+
+	writeReportForCluster(t, cachedStorage, testdata.OrgID, testdata.ClusterName, testClusterEmptyReport)
+
+	backend.afterRead = func() {
+		helpers.FailOnError(t, cachedStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, time.Now().Add(time.Hour), testdata.KafkaOffset,
+		))
+	}
+
+	// the old report is returned, but it isn't cached
+	report, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testClusterEmptyReport, report)
+
+	report, _, err = cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+}
+
+func TestCachedStorageReplicaLag(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cachedStorage := storage.NewCachedStorage(mockStorage, storage.NewCache(storage.Configuration{
+		SQLiteReplicaDataSource: "replica.db",
+		ReplicaLag:              50 * time.Millisecond,
+	}))
+
+	mustWriteReport3Rules(t, cachedStorage)
+
+	hits, misses := cacheHits("report"), cacheMisses("report")
+
+	// the report could be read from the replica that doesn't have it yet, so it isn't cached
+	for i := 0; i < 2; i++ {
+		_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+	}
+
+	assert.Equal(t, misses+2, cacheMisses("report"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+	}
+
+	assert.Equal(t, misses+3, cacheMisses("report"))
+	assert.Equal(t, hits+1, cacheHits("report"))
+}
+
+func TestCachedStorageContentExpires(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cachedStorage := storage.NewCachedStorage(mockStorage, storage.NewCache(storage.Configuration{
+		CacheContentsTTL: time.Millisecond,
+	}))
+
+	mustWriteReport3Rules(t, cachedStorage)
+	reportRules := getReportRules(t, testdata.Report3Rules)
+
+	rules, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+
+	// the visibility of the rule changes without any invalidation, like when its publish date passes
+	ruleContent := testdata.RuleContent3Rules
+	ruleContent.Rules = map[string]content.RuleContent{
+		"rc1": testdata.RuleContent3Rules.Rules["rc1"],
+		"rc2": testdata.RuleContent3Rules.Rules["rc2"],
+	}
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContent))
+
+	time.Sleep(10 * time.Millisecond)
+
+	rules, err = cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestCachedStorageContentSizeLimit(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cachedStorage := storage.NewCachedStorage(mockStorage, storage.NewCache(storage.Configuration{
+		CacheMaxContents: 1,
+	}))
+
+	mustWriteReport3Rules(t, cachedStorage)
+	reportRules := getReportRules(t, testdata.Report3Rules)
+
+	for _, userID := range []types.UserID{testdata.UserID, testdata.User2ID} {
+		_, err := cachedStorage.GetContentForRules(reportRules, userID, testdata.ClusterName, storage.RuleContentFilter{})
+		helpers.FailOnError(t, err)
+	}
+
+	misses := cacheMisses("rule_content")
+
+	// the content of the first user has been evicted, the reports are limited separately
+	_, err := cachedStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, misses+1, cacheMisses("rule_content"))
+}
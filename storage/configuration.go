@@ -16,6 +16,8 @@ limitations under the License.
 
 package storage
 
+import "time"
+
 // Configuration represents configuration of data storage
 type Configuration struct {
 	Driver           string `mapstructure:"db_driver" toml:"db_driver"`
@@ -27,4 +29,36 @@ type Configuration struct {
 	PGPort           int    `mapstructure:"pg_port" toml:"pg_port"`
 	PGDBName         string `mapstructure:"pg_db_name" toml:"pg_db_name"`
 	PGParams         string `mapstructure:"pg_params" toml:"pg_params"`
+	// CacheEnabled turns on the in-process read cache (see CachedStorage)
+	CacheEnabled        bool `mapstructure:"cache_enabled" toml:"cache_enabled"`
+	CacheMaxReports     int  `mapstructure:"cache_max_reports" toml:"cache_max_reports"`
+	CacheMaxRuleContent int  `mapstructure:"cache_max_rule_content" toml:"cache_max_rule_content"`
+	CacheMaxContents    int  `mapstructure:"cache_max_contents" toml:"cache_max_contents"`
+	CacheMaxFeedback    int  `mapstructure:"cache_max_feedback" toml:"cache_max_feedback"`
+	// CacheContentsTTL limits how long the content of rules hit by a cluster is cached,
+	// so that the rules published in the meantime are shown
+	CacheContentsTTL time.Duration `mapstructure:"cache_contents_ttl" toml:"cache_contents_ttl"`
+	// LastCheckedCacheDisabled turns off the cache of times when the clusters were last checked,
+	// so that the replicas writing reports rely on the check in the database only
+	LastCheckedCacheDisabled bool `mapstructure:"last_checked_cache_disabled" toml:"last_checked_cache_disabled"`
+	LastCheckedCacheSize     int  `mapstructure:"last_checked_cache_size" toml:"last_checked_cache_size"`
+	// QueryTimeout limits duration of every storage call, zero means no limit
+	QueryTimeout time.Duration `mapstructure:"query_timeout" toml:"query_timeout"`
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime size the pool of connections
+	// to the primary database, zero values keep the defaults of database/sql
+	MaxOpenConns    int           `mapstructure:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	// SQLiteReplicaDataSource or PGReplicaHost turn on the read replica serving reports and rule content,
+	// the replica of PostgreSQL uses the same credentials and database name as the primary
+	SQLiteReplicaDataSource string `mapstructure:"sqlite_replica_datasource" toml:"sqlite_replica_datasource"`
+	PGReplicaHost           string `mapstructure:"pg_replica_host" toml:"pg_replica_host"`
+	PGReplicaPort           int    `mapstructure:"pg_replica_port" toml:"pg_replica_port"`
+	// ReplicaMaxOpenConns, ReplicaMaxIdleConns and ReplicaConnMaxLifetime size the pool of connections to the replica
+	ReplicaMaxOpenConns    int           `mapstructure:"replica_max_open_conns" toml:"replica_max_open_conns"`
+	ReplicaMaxIdleConns    int           `mapstructure:"replica_max_idle_conns" toml:"replica_max_idle_conns"`
+	ReplicaConnMaxLifetime time.Duration `mapstructure:"replica_conn_max_lifetime" toml:"replica_conn_max_lifetime"`
+	// ReplicaLag is the time after the user votes or toggles a rule for a cluster (or disables it for
+	// the organization) when their feedback and toggles are read from the primary, so that they see their writes
+	ReplicaLag time.Duration `mapstructure:"replica_lag" toml:"replica_lag"`
 }
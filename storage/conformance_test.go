@@ -0,0 +1,1179 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// storageFactory creates an empty initialized storage and a function closing it
+type storageFactory func(t *testing.T) (storage.Storage, func())
+
+// conformanceTests check the behaviour every implementation of storage.Storage must have
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, s storage.Storage)
+}{
+	{"ReportNotFound", testConformanceReportNotFound},
+	{"WriteAndReadReport", testConformanceWriteAndReadReport},
+	{"OldReport", testConformanceOldReport},
+	{"WriteReportsBatch", testConformanceWriteReportsBatch},
+	{"ListOrgsAndClusters", testConformanceListOrgsAndClusters},
+	{"DeleteReports", testConformanceDeleteReports},
+	{"PurgeStaleClusters", testConformancePurgeStaleClusters},
+	{"PurgeOrganization", testConformancePurgeOrganization},
+	{"ContentForRules", testConformanceContentForRules},
+	{"ContentVisibility", testConformanceContentVisibility},
+	{"LoadInvalidRuleContent", testConformanceLoadInvalidRuleContent},
+	{"ContentVersion", testConformanceContentVersion},
+	{"ReadRuleContent", testConformanceReadRuleContent},
+	{"RulesAndErrorKeys", testConformanceRulesAndErrorKeys},
+	{"Feedback", testConformanceFeedback},
+	{"FeedbackKeptOnReload", testConformanceFeedbackKeptOnReload},
+	{"Toggles", testConformanceToggles},
+	{"RuleDisables", testConformanceRuleDisables},
+	{"ListFeedbackAndToggles", testConformanceListFeedbackAndToggles},
+	{"ConsumerError", testConformanceConsumerError},
+	{"Webhooks", testConformanceWebhooks},
+	{"ListRulesAndTags", testConformanceListRulesAndTags},
+	{"SearchRules", testConformanceSearchRules},
+	{"ContextCanceled", testConformanceContextCanceled},
+}
+
+func runConformanceTests(t *testing.T, newStorage storageFactory) {
+	for _, conformanceTest := range conformanceTests {
+		conformanceTest := conformanceTest
+		t.Run(conformanceTest.name, func(t *testing.T) {
+			s, closer := newStorage(t)
+			defer closer()
+
+			conformanceTest.test(t, s)
+		})
+	}
+}
+
+func TestDBStorageConformance(t *testing.T) {
+	runConformanceTests(t, func(t *testing.T) (storage.Storage, func()) {
+		return helpers.MustGetMockStorage(t, true)
+	})
+}
+
+func TestMemoryStorageConformance(t *testing.T) {
+	runConformanceTests(t, func(t *testing.T) (storage.Storage, func()) {
+		s := storage.NewMemoryStorage()
+		helpers.FailOnError(t, s.Init())
+
+		return s, func() {
+			helpers.FailOnError(t, s.Close())
+		}
+	})
+}
+
+// reportRules3Rules returns hits of all rules of testdata.RuleContent3Rules
+func reportRules3Rules() types.ReportRules {
+	return types.ReportRules{
+		HitRules: []types.RuleOnReport{
+			{Module: string(testdata.Rule1ID) + ".report", ErrorKey: testdata.ErrorKey1, Details: "details 1"},
+			{Module: string(testdata.Rule2ID) + ".report", ErrorKey: testdata.ErrorKey2, Details: "details 2"},
+			{Module: string(testdata.Rule3ID) + ".report", ErrorKey: testdata.ErrorKey3, Details: "details 3"},
+		},
+		TotalCount: 3,
+	}
+}
+
+func contentRuleModules(rules []types.RuleContentResponse) []string {
+	modules := []string{}
+	for _, rule := range rules {
+		modules = append(modules, rule.RuleModule)
+	}
+
+	return modules
+}
+
+func testConformanceReportNotFound(t *testing.T, s storage.Storage) {
+	_, _, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Equal(t, &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v", testdata.OrgID, testdata.ClusterName),
+	}, err)
+
+	_, _, err = s.ReadReportForClusterByClusterName(testdata.ClusterName)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: string(testdata.ClusterName)}, err)
+
+	_, err = s.GetOrgIDByClusterID(testdata.ClusterName)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	offset, err := s.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(0), offset)
+}
+
+func testConformanceWriteAndReadReport(t *testing.T, s storage.Storage) {
+	err := s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	report, lastChecked, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339)), lastChecked)
+
+	report, lastChecked, err = s.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339)), lastChecked)
+
+	orgID, err := s.GetOrgIDByClusterID(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, orgID)
+
+	// the cluster belongs to a different organization
+	_, _, err = s.ReadReportForCluster(testdata.OrgID+1, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func testConformanceOldReport(t *testing.T, s storage.Storage) {
+	err := s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, lastChecked := range []time.Time{testdata.LastCheckedAt.Add(-time.Hour), testdata.LastCheckedAt} {
+		err = s.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, lastChecked, testdata.KafkaOffset,
+		)
+		assert.Equal(t, types.ErrOldReport, err)
+	}
+
+	report, _, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+
+	err = s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	report, _, err = s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+}
+
+func reportWrite(
+	clusterName types.ClusterName, report types.ClusterReport, lastChecked time.Time, offset types.KafkaOffset,
+) storage.ReportWrite {
+	return storage.ReportWrite{
+		OrgID:           testdata.OrgID,
+		ClusterName:     clusterName,
+		Report:          report,
+		LastCheckedTime: lastChecked,
+		KafkaOffset:     offset,
+	}
+}
+
+func testConformanceWriteReportsBatch(t *testing.T, s storage.Storage) {
+	otherCluster := testdata.GetRandomClusterID()
+
+	err := s.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	results, err := s.WriteReportsForClusters([]storage.ReportWrite{
+		// older than the stored report
+		reportWrite(testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt.Add(-time.Hour), 1),
+		reportWrite(otherCluster, testdata.Report2Rules, testdata.LastCheckedAt, 2),
+		// older than the report of the cluster earlier in the batch
+		reportWrite(otherCluster, testdata.Report0Rules, testdata.LastCheckedAt.Add(-time.Hour), 3),
+		reportWrite(otherCluster, testdata.Report3Rules, testdata.LastCheckedAt.Add(time.Hour), 4),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []error{types.ErrOldReport, nil, types.ErrOldReport, nil}, results)
+
+	report, _, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report2Rules, report)
+
+	report, _, err = s.ReadReportForCluster(testdata.OrgID, otherCluster)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	offset, err := s.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(4), offset)
+
+	results, err = s.WriteReportsForClusters(nil)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+}
+
+func testConformanceListOrgsAndClusters(t *testing.T, s storage.Storage) {
+	clusters := []types.ClusterName{testdata.GetRandomClusterID(), testdata.GetRandomClusterID()}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i] < clusters[j]
+	})
+
+	// written in reverse order to check the sorting
+	reports := []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		offset      types.KafkaOffset
+	}{
+		{testdata.OrgID + 1, testdata.ClusterName, 5},
+		{testdata.OrgID, clusters[1], 7},
+		{testdata.OrgID, clusters[0], 3},
+	}
+	for _, report := range reports {
+		err := s.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.Report0Rules, testdata.LastCheckedAt, report.offset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	orgs, err := s.ListOfOrgs()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID, testdata.OrgID + 1}, orgs)
+
+	orgClusters, err := s.ListOfClustersForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, clusters, orgClusters)
+
+	orgClusters, err = s.ListOfClustersForOrg(testdata.OrgID + 2)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, orgClusters)
+
+	assertNumberOfReports(t, s, 3)
+
+	offset, err := s.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(7), offset)
+}
+
+func testConformanceDeleteReports(t *testing.T, s storage.Storage) {
+	otherCluster := testdata.GetRandomClusterID()
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, otherCluster} {
+		err := s.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report0Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+	err := s.WriteReportForCluster(
+		testdata.OrgID+1, testdata.GetRandomClusterID(), testdata.Report0Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, s.DeleteReportsForCluster(otherCluster))
+	assertNumberOfReports(t, s, 2)
+
+	helpers.FailOnError(t, s.DeleteReportsForOrg(testdata.OrgID))
+	assertNumberOfReports(t, s, 1)
+
+	_, _, err = s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func testConformancePurgeStaleClusters(t *testing.T, s storage.Storage) {
+	activeCluster := testdata.GetRandomClusterID()
+
+	mustWriteReport3Rules(t, s)
+	err := s.WriteReportForCluster(
+		testdata.OrgID, activeCluster, testdata.Report3Rules, testdata.LastCheckedAt.Add(48*time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, activeCluster} {
+		helpers.FailOnError(t, s.VoteOnRule(clusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+		helpers.FailOnError(t, s.VoteOnRule(clusterName, testdata.Rule2ID, testdata.UserID, types.UserVoteLike))
+		helpers.FailOnError(t, s.ToggleRuleForCluster(clusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable))
+	}
+
+	expected := storage.PurgedClusters{
+		Clusters: []types.ClusterName{testdata.ClusterName},
+		Reports:  1,
+		Feedback: 2,
+		Toggles:  1,
+	}
+	lastCheckedBefore := testdata.LastCheckedAt.Add(24 * time.Hour)
+
+	// nothing is removed in the dry run
+	purged, err := s.PurgeStaleClusters(lastCheckedBefore, true)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, purged)
+	assertNumberOfReports(t, s, 2)
+
+	purged, err = s.PurgeStaleClusters(lastCheckedBefore, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, purged)
+	assertNumberOfReports(t, s, 1)
+
+	_, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// data of the active cluster are kept
+	_, err = s.GetUserFeedbackOnRule(activeCluster, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	_, err = s.GetFromClusterRuleToggle(activeCluster, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	purged, err = s.PurgeStaleClusters(lastCheckedBefore, false)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, purged.Clusters)
+}
+
+func testConformancePurgeOrganization(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	otherCluster := testdata.GetRandomClusterID()
+	err := s.WriteReportForCluster(
+		testdata.OrgID+1, otherCluster, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, otherCluster} {
+		helpers.FailOnError(t, s.VoteOnRule(clusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+		helpers.FailOnError(t, s.ToggleRuleForCluster(
+			clusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+		))
+	}
+
+	for _, orgID := range []types.OrgID{testdata.OrgID, testdata.OrgID + 1} {
+		helpers.FailOnError(t, s.DisableRuleForOrg(orgID, testdata.Rule2ID, ""))
+	}
+
+	webhookID := mustCreateWebhook(t, s, testWebhook)
+	helpers.FailOnError(t, s.WriteWebhookDelivery(types.WebhookDelivery{
+		WebhookID:   webhookID,
+		ClusterName: testdata.ClusterName,
+		Attempts:    1,
+		DeliveredAt: time.Now(),
+	}))
+	otherWebhook := testWebhook
+	otherWebhook.OrgID = testdata.OrgID + 1
+	otherWebhookID := mustCreateWebhook(t, s, otherWebhook)
+
+	for i, value := range []string{
+		fmt.Sprintf(`{"OrgID": %v, "ClusterName": "%v"}`, testdata.OrgID, testdata.ClusterName),
+		fmt.Sprintf(`{"OrgID": %v, "ClusterName": "%v"}`, testdata.OrgID+1, otherCluster),
+		"not a JSON",
+	} {
+		helpers.FailOnError(t, s.WriteConsumerError(&sarama.ConsumerMessage{
+			Topic:     "topic",
+			Partition: 1,
+			Offset:    int64(i),
+			Value:     []byte(value),
+			Timestamp: time.Now(),
+		}, errors.New("consumer error")))
+	}
+
+	purged, err := s.PurgeOrganization(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.PurgedRows{
+		"cluster_rule_user_feedback": 1,
+		"cluster_rule_toggle":        1,
+		"webhook_delivery":           1,
+		"webhook":                    1,
+		"rule_disable":               1,
+		"consumer_error":             1,
+		"report":                     1,
+	}, purged)
+
+	_, _, err = s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = s.GetWebhook(testdata.OrgID, webhookID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// data of the other organization stay untouched
+	_, _, err = s.ReadReportForCluster(testdata.OrgID+1, otherCluster)
+	helpers.FailOnError(t, err)
+
+	_, err = s.GetUserFeedbackOnRule(otherCluster, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	_, err = s.GetFromClusterRuleToggle(otherCluster, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	_, err = s.GetWebhook(testdata.OrgID+1, otherWebhookID)
+	helpers.FailOnError(t, err)
+
+	disables, err := s.ListRuleDisablesForOrg(testdata.OrgID + 1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disables, 1)
+
+	// nothing is left to be removed
+	purged, err = s.PurgeOrganization(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, purged, 7)
+	for table, removed := range purged {
+		assert.Zero(t, removed, table)
+	}
+}
+
+func testConformanceContentForRules(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	rules, err := s.GetContentForRules(
+		reportRules3Rules(), testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, []string{
+		string(testdata.Rule1ID), string(testdata.Rule2ID), string(testdata.Rule3ID),
+	}, contentRuleModules(rules))
+
+	for _, rule := range rules {
+		if rule.RuleModule != string(testdata.Rule2ID) {
+			continue
+		}
+
+		assert.Equal(t, types.RuleContentResponse{
+			CreatedAt:    testdata.Rule2CreatedAt,
+			Description:  testdata.Rule2Description,
+			ErrorKey:     testdata.ErrorKey2,
+			Generic:      testdata.Rule2Details,
+			Reason:       testdata.Rule2Reason,
+			Resolution:   testdata.Rule2Resolution,
+			TotalRisk:    4,
+			RuleModule:   string(testdata.Rule2ID),
+			TemplateData: "details 2",
+			Tags:         []string{"tag1", "tag2"},
+		}, rule)
+	}
+
+	// the disabled rules are the last ones
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	rules, err = s.GetContentForRules(
+		reportRules3Rules(), testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, string(testdata.Rule1ID), rules[2].RuleModule)
+	assert.True(t, rules[2].Disabled)
+	assert.False(t, rules[0].Disabled)
+
+	// the rule is disabled by the user only
+	rules, err = s.GetContentForRules(
+		reportRules3Rules(), testdata.User2ID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	for _, rule := range rules {
+		assert.False(t, rule.Disabled)
+	}
+
+	rules, err = s.GetContentForRules(
+		types.ReportRules{}, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+}
+
+func testConformanceContentVisibility(t *testing.T, s storage.Storage) {
+	ruleContent := ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"active": {
+			Impact: "One", Likelihood: 1, PublishDate: "1970-01-01 00:00:00", Status: "active",
+		},
+		"inactive": {
+			Impact: "One", Likelihood: 1, PublishDate: "1970-01-01 00:00:00", Status: "inactive",
+		},
+		"unpublished": {
+			Impact: "One", Likelihood: 1, PublishDate: "2999-01-01 00:00:00", Status: "active",
+		},
+	})
+	helpers.FailOnError(t, s.LoadRuleContent(ruleContent))
+
+	reportRules := reportRulesForErrorKeys("active", "inactive", "unpublished")
+
+	rules, err := s.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "active", rules[0].ErrorKey)
+
+	rules, err = s.GetContentForRules(
+		reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+
+	count, err := s.GetSuppressedRulesCount(reportRules, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = s.GetRuleWithContent(testRuleID, "active", storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+
+	for _, errorKey := range []types.ErrorKey{"inactive", "unpublished"} {
+		_, err = s.GetRuleWithContent(testRuleID, errorKey, storage.RuleContentFilter{})
+		assert.IsType(t, &types.ItemNotFoundError{}, err, errorKey)
+
+		_, err = s.GetRuleWithContent(testRuleID, errorKey, storage.RuleContentFilter{IncludeInactive: true})
+		helpers.FailOnError(t, err)
+	}
+
+	// the internal rules are visible just to the internal users
+	internalRule := ruleContent.Rules["rc"]
+	internalRule.Internal = true
+	ruleContent.Rules["rc"] = internalRule
+	helpers.FailOnError(t, s.LoadRuleContent(ruleContent))
+
+	rules, err = s.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+
+	rules, err = s.GetContentForRules(
+		reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{IncludeInternal: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+
+	_, err = s.GetRuleWithContent(testRuleID, "active", storage.RuleContentFilter{})
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = s.GetRuleWithContent(testRuleID, "active", storage.RuleContentFilter{IncludeInternal: true})
+	helpers.FailOnError(t, err)
+
+	count, err = s.GetSuppressedRulesCount(reportRules, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = s.GetSuppressedRulesCount(reportRules, true)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func testConformanceLoadInvalidRuleContent(t *testing.T, s storage.Storage) {
+	helpers.FailOnError(t, s.LoadRuleContent(ruleContentExample1))
+
+	err := s.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"ek": {Impact: "One", PublishDate: "1970-01-01 00:00:00", Status: "bad"},
+	}))
+	assert.EqualError(t, err, "invalid rule error key status: 'bad'")
+
+	err = s.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"ek": {Impact: "One", PublishDate: "not a date", Status: "active"},
+	}))
+	assert.EqualError(t, err, "invalid rule error key publish date: 'not a date'")
+
+	// the previously loaded content is kept
+	_, err = s.GetRuleWithContent(testRuleID, "ek", storage.RuleContentFilter{IncludeInactive: true})
+	helpers.FailOnError(t, err)
+}
+
+func testConformanceContentVersion(t *testing.T, s storage.Storage) {
+	_, err := s.GetContentVersion()
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: "content version"}, err)
+
+	ruleContent := ruleContentExample1
+	ruleContent.Version = content.RuleContentVersion{Hash: "hash", Commit: "commit"}
+	helpers.FailOnError(t, s.LoadRuleContent(ruleContent))
+
+	version, err := s.GetContentVersion()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "hash", version.Hash)
+	assert.Equal(t, "commit", version.Commit)
+	assert.False(t, version.LoadedAt.IsZero())
+}
+
+func testConformanceReadRuleContent(t *testing.T, s storage.Storage) {
+	contentDir, err := s.ReadRuleContent()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, contentDir.Rules)
+
+	rule := testdata.RuleContent3Rules.Rules["rc1"]
+	rule.Internal = true
+	rule.Translations = map[string]content.RuleTranslation{"ja": {Summary: []byte("概要")}}
+	errorKey := rule.ErrorKeys[testdata.ErrorKey1]
+	errorKey.GenericTranslations = map[string][]byte{"ja": []byte("汎用")}
+	rule.ErrorKeys = map[string]content.RuleErrorKeyContent{testdata.ErrorKey1: errorKey}
+
+	loadedContent := content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules: map[string]content.RuleContent{
+			"rc1": rule,
+			"rc2": testdata.RuleContent3Rules.Rules["rc2"],
+		},
+		Version: content.RuleContentVersion{Hash: "hash", Commit: "commit"},
+	}
+	helpers.FailOnError(t, s.LoadRuleContent(loadedContent))
+
+	contentDir, err = s.ReadRuleContent()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, loadedContent.Version, contentDir.Version)
+	assert.Equal(t, map[string]int{"2": 2, "6": 6}, contentDir.Config.Impact)
+	assert.Len(t, contentDir.Rules, 2)
+
+	readRule := contentDir.Rules[string(testdata.Rule1ID)]
+	assert.Equal(t, rule.Plugin.Name, readRule.Plugin.Name)
+	assert.Equal(t, rule.Summary, readRule.Summary)
+	assert.True(t, readRule.Internal)
+	assert.Equal(t, rule.Translations, readRule.Translations)
+
+	readErrorKey := readRule.ErrorKeys[testdata.ErrorKey1]
+	assert.Equal(t, errorKey.Generic, readErrorKey.Generic)
+	assert.Equal(t, errorKey.GenericTranslations, readErrorKey.GenericTranslations)
+	assert.Equal(t, content.ErrorKeyMetadata{
+		Condition:   errorKey.Metadata.Condition,
+		Description: errorKey.Metadata.Description,
+		Impact:      "2",
+		Likelihood:  errorKey.Metadata.Likelihood,
+		PublishDate: testdata.Rule1CreatedAt,
+		Status:      "active",
+		Tags:        errorKey.Metadata.Tags,
+	}, readErrorKey.Metadata)
+
+	// the read content can be loaded back without any change
+	helpers.FailOnError(t, s.LoadRuleContent(contentDir))
+
+	reloadedContent, err := s.ReadRuleContent()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, contentDir, reloadedContent)
+}
+
+func testConformanceRulesAndErrorKeys(t *testing.T, s storage.Storage) {
+	_, err := s.GetRuleByID(testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	// the error isn't converted by DBStorage, so it depends on the driver
+	err = s.CreateRuleErrorKey(testdata.RuleErrorKey1)
+	assert.Error(t, err)
+
+	helpers.FailOnError(t, s.CreateRule(testdata.Rule1))
+	helpers.FailOnError(t, s.CreateRuleErrorKey(testdata.RuleErrorKey1))
+
+	rule, err := s.GetRuleByID(testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Rule1, *rule)
+
+	ruleWithContent, err := s.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.True(t, testdata.RuleWithContent1.PublishDate.Equal(ruleWithContent.PublishDate))
+	ruleWithContent.PublishDate = testdata.RuleWithContent1.PublishDate
+	assert.Equal(t, testdata.RuleWithContent1, *ruleWithContent)
+
+	// existing rule and error key are updated
+	updatedRule := testdata.Rule1
+	updatedRule.Summary = "updated summary"
+	helpers.FailOnError(t, s.CreateRule(updatedRule))
+
+	updatedErrorKey := testdata.RuleErrorKey1
+	updatedErrorKey.Description = "updated description"
+	helpers.FailOnError(t, s.CreateRuleErrorKey(updatedErrorKey))
+
+	ruleWithContent, err = s.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "updated summary", ruleWithContent.Summary)
+	assert.Equal(t, "updated description", ruleWithContent.Description)
+
+	helpers.FailOnError(t, s.DeleteRuleErrorKey(testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey))
+
+	err = s.DeleteRuleErrorKey(testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey)
+	assert.Equal(t, &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v", testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey),
+	}, err)
+
+	_, err = s.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	assert.Equal(t, &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v", testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey),
+	}, err)
+
+	helpers.FailOnError(t, s.DeleteRule(testdata.Rule1ID))
+
+	err = s.DeleteRule(testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+}
+
+func testConformanceFeedback(t *testing.T, s storage.Storage) {
+	// the cluster must have a report and the rule must exist
+	err := s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike)
+	assert.IsType(t, &types.ForeignKeyError{}, err)
+
+	mustWriteReport3Rules(t, s)
+
+	err = s.VoteOnRule(testdata.ClusterName, testdata.BadRuleID, testdata.UserID, types.UserVoteLike)
+	assert.IsType(t, &types.ForeignKeyError{}, err)
+
+	_, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.Equal(t, &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v/%v", testdata.ClusterName, testdata.Rule1ID, testdata.UserID),
+	}, err)
+
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+	helpers.FailOnError(t, s.AddOrUpdateFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, "message"))
+
+	feedback, err := s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	assert.Equal(t, "message", feedback.Message)
+
+	// the vote doesn't change the message
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteDislike))
+
+	feedback, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteDislike, feedback.UserVote)
+	assert.Equal(t, "message", feedback.Message)
+
+	// the message alone doesn't set any vote
+	helpers.FailOnError(t, s.AddOrUpdateFeedbackOnRule(testdata.ClusterName, testdata.Rule2ID, testdata.UserID, "other"))
+
+	votes, err := s.GetUserFeedbackOnRules(testdata.ClusterName, testdata.RuleContentResponses, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]types.UserVote{
+		testdata.Rule1ID: types.UserVoteDislike,
+		testdata.Rule2ID: types.UserVoteNone,
+	}, votes)
+
+	votes, err = s.GetUserFeedbackOnRules(testdata.ClusterName, testdata.RuleContentResponses, testdata.User2ID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, votes)
+
+	// the feedback is deleted together with the report
+	helpers.FailOnError(t, s.DeleteReportsForCluster(testdata.ClusterName))
+
+	_, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func testConformanceFeedbackKeptOnReload(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule2ID, testdata.UserID, types.UserVoteDislike))
+
+	// the first rule is changed and the second one is removed from the content
+	changedRule := testdata.RuleContent3Rules.Rules["rc1"]
+	changedRule.Summary = []byte("changed summary")
+	helpers.FailOnError(t, s.LoadRuleContent(content.RuleContentDirectory{
+		Config: testdata.RuleContent3Rules.Config,
+		Rules: map[string]content.RuleContent{
+			"rc1": changedRule,
+			"rc3": testdata.RuleContent3Rules.Rules["rc3"],
+		},
+	}))
+
+	rule, err := s.GetRuleByID(testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "changed summary", rule.Summary)
+
+	feedback, err := s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+
+	_, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule2ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// the same content can be loaded again
+	helpers.FailOnError(t, s.LoadRuleContent(testdata.RuleContent3Rules))
+
+	feedback, err = s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+}
+
+func testConformanceToggles(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	_, err := s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	err = s.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggle(2))
+	assert.EqualError(t, err, "Unexpected rule toggle value")
+
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	toggle, err := s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	assert.True(t, toggle.DisabledAt.Valid)
+	assert.False(t, toggle.EnabledAt.Valid)
+
+	disabledRules, err := s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledRules, 1)
+	assert.Equal(t, string(testdata.Rule1ID), disabledRules[0].RuleModule)
+	assert.Equal(t, testdata.Rule1Description, disabledRules[0].Description)
+	assert.Equal(t, testdata.Rule1Details, disabledRules[0].Generic)
+
+	disabledRules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.User2ID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleEnable,
+	))
+
+	toggle, err = s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleEnable, toggle.Disabled)
+	assert.False(t, toggle.DisabledAt.Valid)
+	assert.True(t, toggle.EnabledAt.Valid)
+
+	disabledRules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+
+	helpers.FailOnError(t, s.DeleteFromRuleClusterToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID))
+
+	_, err = s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func testConformanceRuleDisables(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	otherCluster := testdata.GetRandomClusterID()
+	helpers.FailOnError(t, s.WriteReportForCluster(
+		testdata.OrgID+1, otherCluster, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	))
+
+	disables, err := s.ListRuleDisablesForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disables)
+
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule1ID, ""))
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule2ID, testdata.UserID))
+
+	disables, err = s.ListRuleDisablesForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disables, 2)
+	assert.Equal(t, testdata.Rule1ID, disables[0].RuleID)
+	assert.Equal(t, storage.RuleDisableScopeOrganization, disables[0].Scope())
+	assert.Equal(t, testdata.Rule2ID, disables[1].RuleID)
+	assert.Equal(t, testdata.UserID, disables[1].UserID)
+	assert.Equal(t, storage.RuleDisableScopeUser, disables[1].Scope())
+
+	disabledRules, err := s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]string{
+		string(testdata.Rule1ID): storage.RuleDisableScopeOrganization,
+		string(testdata.Rule2ID): storage.RuleDisableScopeUser,
+	}, disabledRuleScopes(disabledRules))
+
+	// the user-wide disable doesn't apply to the other users
+	disabledRules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.User2ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]string{
+		string(testdata.Rule1ID): storage.RuleDisableScopeOrganization,
+	}, disabledRuleScopes(disabledRules))
+
+	// neither of them applies to clusters of the other organization
+	disabledRules, err = s.ListDisabledRulesForCluster(otherCluster, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+
+	rules, err := s.GetContentForRules(
+		reportRules3Rules(), testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+	for _, rule := range rules {
+		assert.Equal(t, rule.RuleModule != string(testdata.Rule3ID), rule.Disabled, rule.RuleModule)
+	}
+	assert.Equal(t, string(testdata.Rule3ID), rules[0].RuleModule)
+
+	// enabling the rule for the cluster doesn't override the organization-wide disable,
+	// disabling it for the cluster takes precedence in the listed scope
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleEnable,
+	))
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule2ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	disabledRules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]string{
+		string(testdata.Rule1ID): storage.RuleDisableScopeOrganization,
+		string(testdata.Rule2ID): storage.RuleDisableScopeCluster,
+	}, disabledRuleScopes(disabledRules))
+
+	helpers.FailOnError(t, s.EnableRuleForOrg(testdata.OrgID, testdata.Rule1ID, ""))
+	helpers.FailOnError(t, s.EnableRuleForOrg(testdata.OrgID, testdata.Rule2ID, testdata.UserID))
+	// enabling the rule which isn't disabled does nothing
+	helpers.FailOnError(t, s.EnableRuleForOrg(testdata.OrgID, testdata.Rule3ID, ""))
+
+	disables, err = s.ListRuleDisablesForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disables)
+
+	rules, err = s.GetContentForRules(
+		reportRules3Rules(), testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{},
+	)
+	helpers.FailOnError(t, err)
+	for _, rule := range rules {
+		assert.Equal(t, rule.RuleModule == string(testdata.Rule2ID), rule.Disabled, rule.RuleModule)
+	}
+}
+
+// disabledRuleScopes returns scopes of the disabled rules by their modules
+func disabledRuleScopes(rules []types.DisabledRuleResponse) map[string]string {
+	scopes := make(map[string]string)
+	for _, rule := range rules {
+		scopes[rule.RuleModule] = rule.Scope
+	}
+	return scopes
+}
+
+func testConformanceListFeedbackAndToggles(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	feedbacks, err := s.ListFeedbackForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, feedbacks)
+
+	toggles, err := s.ListTogglesForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, toggles)
+
+	for _, userID := range []types.UserID{testdata.User2ID, testdata.UserID} {
+		helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, userID, types.UserVoteLike))
+		helpers.FailOnError(t, s.ToggleRuleForCluster(
+			testdata.ClusterName, testdata.Rule2ID, userID, storage.RuleToggleDisable,
+		))
+	}
+	helpers.FailOnError(t, s.AddOrUpdateFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, "message"))
+
+	feedbacks, err = s.ListFeedbackForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, feedbacks, 2)
+	for _, feedback := range feedbacks {
+		assert.Equal(t, testdata.Rule1ID, feedback.RuleID)
+		assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	}
+	assert.ElementsMatch(t, []string{"", "message"}, []string{feedbacks[0].Message, feedbacks[1].Message})
+
+	toggles, err = s.ListTogglesForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, toggles, 2)
+	for _, toggle := range toggles {
+		assert.Equal(t, testdata.Rule2ID, toggle.RuleID)
+		assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	}
+
+	feedbacks, err = s.ListFeedbackForCluster(testdata.GetRandomClusterID())
+	helpers.FailOnError(t, err)
+	assert.Empty(t, feedbacks)
+}
+
+func testConformanceConsumerError(t *testing.T, s storage.Storage) {
+	err := s.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 1,
+		Offset:    2,
+		Key:       []byte("key"),
+		Value:     []byte("value"),
+		Timestamp: time.Now(),
+	}, errors.New("consumer error"))
+	helpers.FailOnError(t, err)
+}
+
+func testConformanceWebhooks(t *testing.T, s storage.Storage) {
+	webhookID := mustCreateWebhook(t, s, testWebhook)
+	otherWebhookID := mustCreateWebhook(t, s, testWebhook)
+	assert.NotEqual(t, webhookID, otherWebhookID)
+
+	webhook, err := s.GetWebhook(testdata.OrgID, webhookID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testWebhook.URL, webhook.URL)
+	assert.False(t, webhook.CreatedAt.IsZero())
+
+	_, err = s.GetWebhook(testdata.OrgID+1, webhookID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+
+	webhooks, err := s.ListWebhooksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, webhookID, webhooks[0].ID)
+
+	orgIDs, err := s.ListOrgsWithWebhooks()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID}, orgIDs)
+
+	updatedWebhook := *webhook
+	updatedWebhook.URL = "https://example.com/other"
+	helpers.FailOnError(t, s.UpdateWebhook(updatedWebhook))
+
+	webhook, err = s.GetWebhook(testdata.OrgID, webhookID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, updatedWebhook.URL, webhook.URL)
+
+	updatedWebhook.OrgID = testdata.OrgID + 1
+	err = s.UpdateWebhook(updatedWebhook)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+
+	err = s.WriteWebhookDelivery(types.WebhookDelivery{WebhookID: webhookID + 100, DeliveredAt: time.Now()})
+	assert.IsType(t, &types.ForeignKeyError{}, err)
+
+	deliveredAt := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		helpers.FailOnError(t, s.WriteWebhookDelivery(types.WebhookDelivery{
+			WebhookID:   webhookID,
+			ClusterName: testdata.ClusterName,
+			Attempts:    i + 1,
+			DeliveredAt: deliveredAt.Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	deliveries, err := s.ListWebhookDeliveries(webhookID, 2)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, 2, deliveries[1].Attempts)
+
+	helpers.FailOnError(t, s.DeleteWebhook(testdata.OrgID, webhookID))
+
+	err = s.DeleteWebhook(testdata.OrgID, webhookID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+
+	deliveries, err = s.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func testConformanceListRulesAndTags(t *testing.T, s storage.Storage) {
+	helpers.FailOnError(t, s.LoadRuleContent(testdata.RuleContent3Rules))
+
+	rules, total, err := s.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{}, 1, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []types.RuleID{testdata.Rule2ID}, listedRuleIDs(rules))
+
+	rules, total, err = s.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{Impact: 6}, 0, 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []types.RuleID{testdata.Rule2ID}, listedRuleIDs(rules))
+
+	rules, total, err = s.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{Tag: "tag"}, 0, 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, rules)
+
+	tags, err := s.ListRuleTags(storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.RuleTagCount{{Tag: "tag1", Count: 3}, {Tag: "tag2", Count: 3}}, tags)
+}
+
+func testConformanceSearchRules(t *testing.T, s storage.Storage) {
+	helpers.FailOnError(t, s.LoadRuleContent(testdata.RuleContent3Rules))
+
+	results, err := s.SearchRules("reason 2", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, testdata.Rule2ID, results[0].Module)
+		assert.Contains(t, results[0].Snippet, "**")
+	}
+
+	results, err = s.SearchRules("rule", storage.RuleContentFilter{}, 2)
+	helpers.FailOnError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = s.SearchRules("nonexistent", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	results, err = s.SearchRules(`"*`, storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	// rules and error keys created and deleted one by one are searchable immediately
+	inactive := storage.RuleContentFilter{IncludeInactive: true}
+	createdRule := testdata.Rule1
+	createdRule.Module = "created.rule"
+	createdRule.Summary = "searchable summary"
+	helpers.FailOnError(t, s.CreateRule(createdRule))
+
+	createdErrorKey := testdata.RuleErrorKey1
+	createdErrorKey.RuleModule = createdRule.Module
+	helpers.FailOnError(t, s.CreateRuleErrorKey(createdErrorKey))
+
+	results, err = s.SearchRules("searchable", inactive, 10)
+	helpers.FailOnError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, createdRule.Module, results[0].Module)
+	}
+
+	createdRule.Summary = "changed summary"
+	helpers.FailOnError(t, s.CreateRule(createdRule))
+
+	results, err = s.SearchRules("searchable", inactive, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	results, err = s.SearchRules("changed", inactive, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, results, 1)
+
+	helpers.FailOnError(t, s.DeleteRuleErrorKey(createdRule.Module, createdErrorKey.ErrorKey))
+
+	results, err = s.SearchRules("changed", inactive, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	helpers.FailOnError(t, s.CreateRuleErrorKey(createdErrorKey))
+	helpers.FailOnError(t, s.DeleteRule(createdRule.Module))
+
+	results, err = s.SearchRules("changed", inactive, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+}
+
+func testConformanceContextCanceled(t *testing.T, s storage.Storage) {
+	mustWriteReport3Rules(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctxStorage := s.WithContext(ctx)
+
+	_, _, err := ctxStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Equal(t, context.Canceled, err)
+
+	err = ctxStorage.DeleteReportsForCluster(testdata.ClusterName)
+	assert.Equal(t, context.Canceled, err)
+
+	// the original storage is not bound to the context
+	assertNumberOfReports(t, s, 1)
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// newRuleContentDirectory returns an empty rule content with the version of the loaded content
+func newRuleContentDirectory(version *types.ContentVersion) content.RuleContentDirectory {
+	contentDir := content.RuleContentDirectory{
+		Config: content.GlobalRuleConfig{Impact: make(map[string]int)},
+		Rules:  make(map[string]content.RuleContent),
+	}
+
+	if version != nil {
+		contentDir.Version = content.RuleContentVersion{Hash: version.Hash, Commit: version.Commit}
+	}
+
+	return contentDir
+}
+
+// addRuleToContentDirectory adds the rule without error keys to the content
+func addRuleToContentDirectory(
+	contentDir *content.RuleContentDirectory, rule types.Rule, internal bool, translations map[string]content.RuleTranslation,
+) {
+	contentDir.Rules[string(rule.Module)] = content.RuleContent{
+		Summary:    []byte(rule.Summary),
+		Reason:     []byte(rule.Reason),
+		Resolution: []byte(rule.Resolution),
+		MoreInfo:   []byte(rule.MoreInfo),
+		Plugin: content.RulePluginInfo{
+			Name:         rule.Name,
+			PythonModule: string(rule.Module),
+		},
+		ErrorKeys:    make(map[string]content.RuleErrorKeyContent),
+		Internal:     internal,
+		Translations: translations,
+	}
+}
+
+// addErrorKeyToContentDirectory adds the error key to its rule that has to be added already,
+// only the numeric impact is stored, so its value is used as its name in the configuration
+func addErrorKeyToContentDirectory(
+	contentDir *content.RuleContentDirectory, errorKey types.RuleErrorKey, genericTranslations map[string][]byte,
+) {
+	impact := strconv.Itoa(errorKey.Impact)
+	contentDir.Config.Impact[impact] = errorKey.Impact
+
+	status := "inactive"
+	if errorKey.Active {
+		status = "active"
+	}
+
+	contentDir.Rules[string(errorKey.RuleModule)].ErrorKeys[string(errorKey.ErrorKey)] = content.RuleErrorKeyContent{
+		Generic: []byte(errorKey.Generic),
+		Metadata: content.ErrorKeyMetadata{
+			Condition:   errorKey.Condition,
+			Description: errorKey.Description,
+			Impact:      impact,
+			Likelihood:  errorKey.Likelihood,
+			PublishDate: errorKey.PublishDate.UTC().Format(time.RFC3339),
+			Status:      status,
+			Tags:        errorKey.Tags,
+		},
+		GenericTranslations: genericTranslations,
+	}
+}
+
+// nullableBytes converts the text read from a nullable column back to the content,
+// NULL means that the text is not available
+func nullableBytes(text sql.NullString) []byte {
+	if !text.Valid {
+		return nil
+	}
+
+	return []byte(text.String)
+}
+
+// ReadRuleContent returns the rule content loaded by LoadRuleContent, so it can be loaded
+// into another storage. Impacts are named by their values, the content version is kept.
+func (storage DBStorage) ReadRuleContent() (content.RuleContentDirectory, error) {
+	version, err := storage.GetContentVersion()
+	if _, notFound := err.(*types.ItemNotFoundError); err != nil && !notFound {
+		return content.RuleContentDirectory{}, err
+	}
+
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	contentDir := newRuleContentDirectory(version)
+
+	translations, err := storage.readRuleTranslations(ctx)
+	if err != nil {
+		return contentDir, err
+	}
+
+	if err := storage.readRules(ctx, &contentDir, translations); err != nil {
+		return contentDir, err
+	}
+
+	genericTranslations, err := storage.readRuleErrorKeyTranslations(ctx)
+	if err != nil {
+		return contentDir, err
+	}
+
+	err = storage.readRuleErrorKeys(ctx, &contentDir, genericTranslations)
+	return contentDir, err
+}
+
+// readRuleTranslations reads translations of all rules by rule module and language
+func (storage DBStorage) readRuleTranslations(
+	ctx context.Context,
+) (map[types.RuleID]map[string]content.RuleTranslation, error) {
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT rule_module, language, summary, reason, resolution, more_info FROM rule_translation`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	translations := make(map[types.RuleID]map[string]content.RuleTranslation)
+	for rows.Next() {
+		var ruleModule types.RuleID
+		var language string
+		var summary, reason, resolution, moreInfo sql.NullString
+
+		if err := rows.Scan(&ruleModule, &language, &summary, &reason, &resolution, &moreInfo); err != nil {
+			return nil, err
+		}
+
+		if translations[ruleModule] == nil {
+			translations[ruleModule] = make(map[string]content.RuleTranslation)
+		}
+		translations[ruleModule][language] = content.RuleTranslation{
+			Summary:    nullableBytes(summary),
+			Reason:     nullableBytes(reason),
+			Resolution: nullableBytes(resolution),
+			MoreInfo:   nullableBytes(moreInfo),
+		}
+	}
+
+	return translations, rows.Err()
+}
+
+// readRules adds all rules with their translations to the content
+func (storage DBStorage) readRules(
+	ctx context.Context,
+	contentDir *content.RuleContentDirectory,
+	translations map[types.RuleID]map[string]content.RuleTranslation,
+) error {
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT module, "name", summary, reason, resolution, more_info, internal FROM rule`,
+	)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var rule types.Rule
+		var internal bool
+
+		err := rows.Scan(&rule.Module, &rule.Name, &rule.Summary, &rule.Reason, &rule.Resolution, &rule.MoreInfo, &internal)
+		if err != nil {
+			return err
+		}
+
+		addRuleToContentDirectory(contentDir, rule, internal, translations[rule.Module])
+	}
+
+	return rows.Err()
+}
+
+// readRuleErrorKeyTranslations reads translated generic texts of all error keys
+// by rule module, error key and language
+func (storage DBStorage) readRuleErrorKeyTranslations(
+	ctx context.Context,
+) (map[types.RuleID]map[types.ErrorKey]map[string][]byte, error) {
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT error_key, rule_module, language, generic FROM rule_error_key_translation`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	genericTranslations := make(map[types.RuleID]map[types.ErrorKey]map[string][]byte)
+	for rows.Next() {
+		var errorKey types.ErrorKey
+		var ruleModule types.RuleID
+		var language, generic string
+
+		if err := rows.Scan(&errorKey, &ruleModule, &language, &generic); err != nil {
+			return nil, err
+		}
+
+		if genericTranslations[ruleModule] == nil {
+			genericTranslations[ruleModule] = make(map[types.ErrorKey]map[string][]byte)
+		}
+		if genericTranslations[ruleModule][errorKey] == nil {
+			genericTranslations[ruleModule][errorKey] = make(map[string][]byte)
+		}
+		genericTranslations[ruleModule][errorKey][language] = []byte(generic)
+	}
+
+	return genericTranslations, rows.Err()
+}
+
+// readRuleErrorKeys adds all error keys with their translations to the rules of the content
+func (storage DBStorage) readRuleErrorKeys(
+	ctx context.Context,
+	contentDir *content.RuleContentDirectory,
+	genericTranslations map[types.RuleID]map[types.ErrorKey]map[string][]byte,
+) error {
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT error_key, rule_module, condition, description, impact, likelihood, publish_date, active, generic, tags
+		FROM rule_error_key`,
+	)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var errorKey types.RuleErrorKey
+		var tags string
+
+		err := rows.Scan(
+			&errorKey.ErrorKey,
+			&errorKey.RuleModule,
+			&errorKey.Condition,
+			&errorKey.Description,
+			&errorKey.Impact,
+			&errorKey.Likelihood,
+			&errorKey.PublishDate,
+			&errorKey.Active,
+			&errorKey.Generic,
+			&tags,
+		)
+		if err != nil {
+			return err
+		}
+		errorKey.Tags = commaSeparatedStrToTags(tags)
+
+		addErrorKeyToContentDirectory(
+			contentDir, errorKey, genericTranslations[errorKey.RuleModule][errorKey.ErrorKey],
+		)
+	}
+
+	return rows.Err()
+}
+
+// ListFeedbackForCluster returns feedback of all users on rules of the cluster
+func (storage DBStorage) ListFeedbackForCluster(clusterName types.ClusterName) ([]UserFeedbackOnRule, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT cluster_id, rule_id, user_id, message, user_vote, added_at, updated_at
+		FROM cluster_rule_user_feedback
+		WHERE cluster_id = $1
+		ORDER BY rule_id, user_id`,
+		clusterName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	feedbacks := []UserFeedbackOnRule{}
+	for rows.Next() {
+		var feedback UserFeedbackOnRule
+
+		err := rows.Scan(
+			&feedback.ClusterID,
+			&feedback.RuleID,
+			&feedback.UserID,
+			&feedback.Message,
+			&feedback.UserVote,
+			&feedback.AddedAt,
+			&feedback.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		feedbacks = append(feedbacks, feedback)
+	}
+
+	return feedbacks, rows.Err()
+}
+
+// ListTogglesForCluster returns rule toggles of all users for the cluster
+func (storage DBStorage) ListTogglesForCluster(clusterName types.ClusterName) ([]ClusterRuleToggle, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT cluster_id, rule_id, user_id, disabled, disabled_at, enabled_at, updated_at
+		FROM cluster_rule_toggle
+		WHERE cluster_id = $1
+		ORDER BY rule_id, user_id`,
+		clusterName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	toggles := []ClusterRuleToggle{}
+	for rows.Next() {
+		var toggle ClusterRuleToggle
+
+		err := rows.Scan(
+			&toggle.ClusterID,
+			&toggle.RuleID,
+			&toggle.UserID,
+			&toggle.Disabled,
+			&toggle.DisabledAt,
+			&toggle.EnabledAt,
+			&toggle.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		toggles = append(toggles, toggle)
+	}
+
+	return toggles, rows.Err()
+}
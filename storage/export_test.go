@@ -18,6 +18,9 @@ package storage
 
 import (
 	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 // Export for testing
@@ -32,6 +35,37 @@ const (
 	SQLHooksKeyQueryBeginTime = sqlHooksKeyQueryBeginTime
 )
 
+var NormalizeQuery = normalizeQuery
+
+var NewLRUCache = newLRUCache
+
+func (cache *lruCache) Get(key interface{}) (interface{}, bool) { return cache.get(key) }
+func (cache *lruCache) Add(key, value interface{})              { cache.add(key, value) }
+func (cache *lruCache) Remove(key interface{})                  { cache.remove(key) }
+func (cache *lruCache) Len() int                                { return cache.len() }
+
 func GetConnection(storage *DBStorage) *sql.DB {
 	return storage.connection
 }
+
+var NewLastCheckedCache = newLastCheckedCache
+
+func (cache *lastCheckedCache) Get(clusterName types.ClusterName) (time.Time, bool) {
+	return cache.get(clusterName)
+}
+func (cache *lastCheckedCache) Set(clusterName types.ClusterName, lastChecked time.Time) {
+	cache.set(clusterName, lastChecked)
+}
+
+func SetLastCheckedCache(storage *DBStorage, configuration Configuration) {
+	storage.clustersLastChecked = newLastCheckedCache(configuration)
+}
+
+func GetReplicaConnection(storage *DBStorage) *sql.DB {
+	return storage.replicaConnection
+}
+
+func SetReplicaConnection(storage *DBStorage, replicaConnection *sql.DB, replicaLag time.Duration) {
+	storage.replicaConnection = replicaConnection
+	storage.recentWrites = newRecentWrites(replicaLag)
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// DefaultLastCheckedCacheSize is the number of clusters whose last checked time
+// is cached when the size is not configured
+const DefaultLastCheckedCacheSize = 100000
+
+// lastCheckedCacheName is the label of the last checked cache in storage cache metrics
+const lastCheckedCacheName = "last_checked"
+
+// lastCheckedCache is a concurrency-safe bounded cache of times when the clusters were last checked,
+// it allows to skip old reports without a query. Clusters that are not cached are checked
+// in the database, so the cache can be disabled, which is represented by nil cache.
+type lastCheckedCache struct {
+	mutex sync.Mutex
+	lru   *lruCache
+}
+
+// newLastCheckedCache creates the cache according to the configuration, nil is returned if it's disabled
+func newLastCheckedCache(configuration Configuration) *lastCheckedCache {
+	if configuration.LastCheckedCacheDisabled {
+		return nil
+	}
+
+	size := configuration.LastCheckedCacheSize
+	if size <= 0 {
+		size = DefaultLastCheckedCacheSize
+	}
+
+	return &lastCheckedCache{lru: newLRUCache(size)}
+}
+
+// get returns the cached time when the cluster was last checked
+func (cache *lastCheckedCache) get(clusterName types.ClusterName) (time.Time, bool) {
+	if cache == nil {
+		return time.Time{}, false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	value, found := cache.lru.get(clusterName)
+	if !found {
+		metrics.StorageCacheMisses.WithLabelValues(lastCheckedCacheName).Inc()
+		return time.Time{}, false
+	}
+
+	metrics.StorageCacheHits.WithLabelValues(lastCheckedCacheName).Inc()
+	return value.(time.Time), true
+}
+
+// set stores the time when the cluster was last checked unless a later time is cached already
+func (cache *lastCheckedCache) set(clusterName types.ClusterName, lastChecked time.Time) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if value, found := cache.lru.get(clusterName); found && value.(time.Time).After(lastChecked) {
+		return
+	}
+
+	cache.lru.add(clusterName, lastChecked)
+}
+
+// maxEntries returns the number of clusters that can be cached, zero if the cache is disabled
+func (cache *lastCheckedCache) maxEntries() int {
+	if cache == nil {
+		return 0
+	}
+
+	return cache.lru.maxEntries
+}
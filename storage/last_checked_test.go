@@ -0,0 +1,163 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const lastCheckedCacheName = "last_checked"
+
+func mustWriteReportCheckedAt(t *testing.T, mockStorage storage.Storage, clusterName types.ClusterName, lastChecked time.Time) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, clusterName, testdata.Report3Rules, lastChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageWriteOldReportWithoutLastCheckedCache(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetLastCheckedCache(mockStorage.(*storage.DBStorage), storage.Configuration{LastCheckedCacheDisabled: true})
+
+	newerTime := time.Now().UTC()
+	mustWriteReportCheckedAt(t, mockStorage, testdata.ClusterName, newerTime)
+
+	hits, misses := cacheHits(lastCheckedCacheName), cacheMisses(lastCheckedCacheName)
+	skipped := testutil.ToFloat64(metrics.SkippedOldReports)
+
+	// the old report is refused by the check in the database
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testClusterEmptyReport, newerTime.Add(-time.Hour), testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	report, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	assert.Equal(t, skipped+1, testutil.ToFloat64(metrics.SkippedOldReports))
+	assert.Equal(t, hits, cacheHits(lastCheckedCacheName))
+	assert.Equal(t, misses, cacheMisses(lastCheckedCacheName))
+}
+
+func TestDBStorageLastCheckedCacheHit(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	newerTime := time.Now().UTC()
+	mustWriteReportCheckedAt(t, mockStorage, testdata.ClusterName, newerTime)
+
+	hits := cacheHits(lastCheckedCacheName)
+	skipped := testutil.ToFloat64(metrics.SkippedOldReports)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testClusterEmptyReport, newerTime, testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	assert.Equal(t, hits+1, cacheHits(lastCheckedCacheName))
+	assert.Equal(t, skipped+1, testutil.ToFloat64(metrics.SkippedOldReports))
+}
+
+func TestDBStorageLastCheckedCacheEviction(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetLastCheckedCache(mockStorage.(*storage.DBStorage), storage.Configuration{LastCheckedCacheSize: 1})
+
+	newerTime := time.Now().UTC()
+	mustWriteReportCheckedAt(t, mockStorage, testdata.ClusterName, newerTime)
+	mustWriteReportCheckedAt(t, mockStorage, testdata.GetRandomClusterID(), newerTime)
+
+	misses := cacheMisses(lastCheckedCacheName)
+
+	// the evicted cluster is checked in the database
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testClusterEmptyReport, newerTime.Add(-time.Hour), testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+	assert.Equal(t, misses+1, cacheMisses(lastCheckedCacheName))
+}
+
+func TestDBStorageInitFillsLastCheckedCache(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	newerTime := time.Now().UTC()
+	mustWriteReportCheckedAt(t, mockStorage, testdata.ClusterName, newerTime)
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetLastCheckedCache(dbStorage, storage.Configuration{})
+	helpers.FailOnError(t, dbStorage.Init())
+
+	hits := cacheHits(lastCheckedCacheName)
+
+	err := dbStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testClusterEmptyReport, newerTime.Add(-time.Hour), testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+	assert.Equal(t, hits+1, cacheHits(lastCheckedCacheName))
+}
+
+func TestLastCheckedCacheKeepsLatestTime(t *testing.T) {
+	cache := storage.NewLastCheckedCache(storage.Configuration{})
+
+	newerTime := time.Now()
+	cache.Set(testdata.ClusterName, newerTime)
+	cache.Set(testdata.ClusterName, newerTime.Add(-time.Hour))
+
+	lastChecked, found := cache.Get(testdata.ClusterName)
+	assert.True(t, found)
+	assert.Equal(t, newerTime, lastChecked)
+}
+
+func TestLastCheckedCacheConcurrentAccess(t *testing.T) {
+	cache := storage.NewLastCheckedCache(storage.Configuration{LastCheckedCacheSize: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			clusterName := types.ClusterName(fmt.Sprint(i % 15))
+			cache.Set(clusterName, time.Unix(int64(i), 0))
+			cache.Get(clusterName)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLastCheckedCacheDisabled(t *testing.T) {
+	cache := storage.NewLastCheckedCache(storage.Configuration{LastCheckedCacheDisabled: true})
+
+	cache.Set(testdata.ClusterName, time.Now())
+	_, found := cache.Get(testdata.ClusterName)
+	assert.False(t, found)
+}
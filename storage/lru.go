@@ -0,0 +1,99 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "container/list"
+
+// lruCache is a simple size-bounded cache which evicts the least recently
+// used entry when it is full. It is not safe for concurrent use, the owner
+// is expected to guard it with its own lock.
+type lruCache struct {
+	maxEntries int
+	items      map[interface{}]*list.Element
+	order      *list.List
+}
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// newLRUCache creates a new cache with the specified maximum number of entries.
+// Zero or negative maxEntries means that the number of entries is not limited.
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		items:      map[interface{}]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// get returns the value stored under the key and marks it as recently used
+func (cache *lruCache) get(key interface{}) (interface{}, bool) {
+	element, found := cache.items[key]
+	if !found {
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// add stores the value under the key, evicting the oldest entry if the cache is full
+func (cache *lruCache) add(key, value interface{}) {
+	if element, found := cache.items[key]; found {
+		element.Value.(*lruEntry).value = value
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.items[key] = cache.order.PushFront(&lruEntry{key: key, value: value})
+
+	if cache.maxEntries > 0 && cache.order.Len() > cache.maxEntries {
+		cache.removeElement(cache.order.Back())
+	}
+}
+
+// remove removes the entry with the key if it exists
+func (cache *lruCache) remove(key interface{}) {
+	if element, found := cache.items[key]; found {
+		cache.removeElement(element)
+	}
+}
+
+// removeIf removes all entries whose keys match the predicate
+func (cache *lruCache) removeIf(predicate func(key interface{}) bool) {
+	for key, element := range cache.items {
+		if predicate(key) {
+			cache.removeElement(element)
+		}
+	}
+}
+
+// clear removes all entries
+func (cache *lruCache) clear() {
+	cache.items = map[interface{}]*list.Element{}
+	cache.order.Init()
+}
+
+// len returns the number of entries stored in the cache
+func (cache *lruCache) len() int {
+	return cache.order.Len()
+}
+
+func (cache *lruCache) removeElement(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.items, element.Value.(*lruEntry).key)
+}
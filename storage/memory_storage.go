@@ -0,0 +1,1767 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/content"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// MemoryDriverName is the storage driver that makes the service use MemoryStorage
+const MemoryDriverName = "memory"
+
+// MemoryStorage is an implementation of Storage interface that keeps all the data
+// in memory. It behaves like DBStorage with enforced foreign keys, i.e. it returns
+// the same errors and deletes dependent records, so it can replace the database
+// in tests and during local development. The data are lost when the process ends.
+type MemoryStorage struct {
+	// ctx is the context of the calls, they fail when it's done
+	ctx  context.Context
+	data *memoryData
+}
+
+// memoryData is the content of MemoryStorage shared by all its copies made by WithContext
+type memoryData struct {
+	mutex             sync.RWMutex
+	reports           map[types.ClusterName]memoryReport
+	feedbacks         map[clusterRuleUserKey]UserFeedbackOnRule
+	toggles           map[clusterRuleUserKey]ClusterRuleToggle
+	ruleDisables      map[orgRuleUserKey]RuleDisable
+	rules             map[types.RuleID]*memoryRule
+	contentVersion    *types.ContentVersion
+	consumerErrors    []memoryConsumerError
+	webhooks          map[types.WebhookID]types.Webhook
+	lastWebhookID     types.WebhookID
+	webhookDeliveries []types.WebhookDelivery
+}
+
+type memoryReport struct {
+	orgID       types.OrgID
+	report      types.ClusterReport
+	reportedAt  time.Time
+	lastChecked time.Time
+	kafkaOffset types.KafkaOffset
+}
+
+type clusterRuleUserKey struct {
+	clusterID types.ClusterName
+	ruleID    types.RuleID
+	userID    types.UserID
+}
+
+type orgRuleUserKey struct {
+	orgID  types.OrgID
+	ruleID types.RuleID
+	userID types.UserID
+}
+
+// memoryRule is a rule with its error keys and translations, the translations
+// of error keys are kept until the rule is deleted like in the database
+type memoryRule struct {
+	rule                types.Rule
+	internal            bool
+	errorKeys           map[types.ErrorKey]types.RuleErrorKey
+	translations        map[string]content.RuleTranslation
+	genericTranslations map[types.ErrorKey]map[string][]byte
+}
+
+type memoryConsumerError struct {
+	topic      string
+	partition  int32
+	offset     int64
+	key        []byte
+	producedAt time.Time
+	consumedAt time.Time
+	message    []byte
+	err        string
+}
+
+// NewMemoryStorage creates an empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		ctx: context.Background(),
+		data: &memoryData{
+			reports:      make(map[types.ClusterName]memoryReport),
+			feedbacks:    make(map[clusterRuleUserKey]UserFeedbackOnRule),
+			toggles:      make(map[clusterRuleUserKey]ClusterRuleToggle),
+			ruleDisables: make(map[orgRuleUserKey]RuleDisable),
+			rules:        make(map[types.RuleID]*memoryRule),
+			webhooks:     make(map[types.WebhookID]types.Webhook),
+		},
+	}
+}
+
+// WithContext returns the storage sharing the data with this one whose calls fail
+// once the context is done, the same way as the queries of DBStorage are canceled
+func (storage MemoryStorage) WithContext(ctx context.Context) Storage {
+	storage.ctx = ctx
+	return &storage
+}
+
+// lock locks the data for writing unless the context is already done
+func (storage MemoryStorage) lock() error {
+	if err := storage.ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.data.mutex.Lock()
+	return nil
+}
+
+func (storage MemoryStorage) unlock() {
+	storage.data.mutex.Unlock()
+}
+
+// rlock locks the data for reading unless the context is already done
+func (storage MemoryStorage) rlock() error {
+	if err := storage.ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.data.mutex.RLock()
+	return nil
+}
+
+func (storage MemoryStorage) runlock() {
+	storage.data.mutex.RUnlock()
+}
+
+// Init does nothing as there's nothing to be loaded
+func (storage MemoryStorage) Init() error {
+	return nil
+}
+
+// Close does nothing, the data are kept until the storage is garbage collected
+func (storage MemoryStorage) Close() error {
+	return nil
+}
+
+// ListOfOrgs returns all organizations that have at least one cluster report
+func (storage MemoryStorage) ListOfOrgs() ([]types.OrgID, error) {
+	orgs := make([]types.OrgID, 0)
+
+	if err := storage.rlock(); err != nil {
+		return orgs, err
+	}
+	defer storage.runlock()
+
+	seen := make(map[types.OrgID]bool)
+	for _, report := range storage.data.reports {
+		if !seen[report.orgID] {
+			seen[report.orgID] = true
+			orgs = append(orgs, report.orgID)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		return orgs[i] < orgs[j]
+	})
+
+	return orgs, nil
+}
+
+// ListOfClustersForOrg returns all clusters of the organization
+func (storage MemoryStorage) ListOfClustersForOrg(orgID types.OrgID) ([]types.ClusterName, error) {
+	clusters := make([]types.ClusterName, 0)
+
+	if err := storage.rlock(); err != nil {
+		return clusters, err
+	}
+	defer storage.runlock()
+
+	for clusterName, report := range storage.data.reports {
+		if report.orgID == orgID {
+			clusters = append(clusters, clusterName)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i] < clusters[j]
+	})
+
+	return clusters, nil
+}
+
+// GetOrgIDByClusterID returns organization of the cluster,
+// sql.ErrNoRows is returned if there's no report for the cluster
+func (storage MemoryStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
+	if err := storage.rlock(); err != nil {
+		return 0, err
+	}
+	defer storage.runlock()
+
+	report, found := storage.data.reports[cluster]
+	if !found {
+		return 0, sql.ErrNoRows
+	}
+
+	return report.orgID, nil
+}
+
+// ReadReportForCluster returns report of the cluster of the organization
+func (storage MemoryStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	if err := storage.rlock(); err != nil {
+		return "", "", err
+	}
+	defer storage.runlock()
+
+	report, found := storage.data.reports[clusterName]
+	if !found || report.orgID != orgID {
+		return "", "", types.ConvertDBError(sql.ErrNoRows, []interface{}{orgID, clusterName})
+	}
+
+	return report.report, types.Timestamp(report.lastChecked.UTC().Format(time.RFC3339)), nil
+}
+
+// ReadReportForClusterFromPrimary returns report of the cluster of the organization,
+// there's no replica of the memory storage
+func (storage MemoryStorage) ReadReportForClusterFromPrimary(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	return storage.ReadReportForCluster(orgID, clusterName)
+}
+
+// ReadReportForClusterByClusterName returns report of the cluster
+func (storage MemoryStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	if err := storage.rlock(); err != nil {
+		return "", "", err
+	}
+	defer storage.runlock()
+
+	report, found := storage.data.reports[clusterName]
+	if !found {
+		return "", "", &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v", clusterName)}
+	}
+
+	return report.report, types.Timestamp(report.lastChecked.UTC().Format(time.RFC3339)), nil
+}
+
+// GetLatestKafkaOffset returns the greatest Kafka offset of the stored reports or zero
+func (storage MemoryStorage) GetLatestKafkaOffset() (types.KafkaOffset, error) {
+	if err := storage.rlock(); err != nil {
+		return 0, err
+	}
+	defer storage.runlock()
+
+	var offset types.KafkaOffset
+	for _, report := range storage.data.reports {
+		if report.kafkaOffset > offset {
+			offset = report.kafkaOffset
+		}
+	}
+
+	return offset, nil
+}
+
+// WriteReportForCluster stores report of the cluster, ErrOldReport is returned
+// if the stored report isn't older than the written one
+func (storage MemoryStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	return storage.writeReport(ReportWrite{
+		OrgID:           orgID,
+		ClusterName:     clusterName,
+		Report:          report,
+		LastCheckedTime: lastCheckedTime,
+		KafkaOffset:     kafkaOffset,
+	})
+}
+
+// WriteReportsForClusters stores the reports at once, ErrOldReport is the result
+// of the reports that aren't newer than the stored report of their cluster
+func (storage MemoryStorage) WriteReportsForClusters(reports []ReportWrite) ([]error, error) {
+	if err := storage.lock(); err != nil {
+		return nil, err
+	}
+	defer storage.unlock()
+
+	results := make([]error, len(reports))
+	for i, report := range reports {
+		results[i] = storage.writeReport(report)
+	}
+
+	return results, nil
+}
+
+// writeReport stores report of the cluster, the caller has to hold the write lock
+func (storage MemoryStorage) writeReport(report ReportWrite) error {
+	if oldReport, found := storage.data.reports[report.ClusterName]; found &&
+		!report.LastCheckedTime.After(oldReport.lastChecked) {
+		metrics.SkippedOldReports.Inc()
+		return types.ErrOldReport
+	}
+
+	storage.data.reports[report.ClusterName] = memoryReport{
+		orgID:       report.OrgID,
+		report:      report.Report,
+		reportedAt:  time.Now(),
+		lastChecked: report.LastCheckedTime,
+		kafkaOffset: report.KafkaOffset,
+	}
+	metrics.WrittenReports.Inc()
+
+	return nil
+}
+
+// ReportsCount returns number of stored reports
+func (storage MemoryStorage) ReportsCount() (int, error) {
+	if err := storage.rlock(); err != nil {
+		return -1, err
+	}
+	defer storage.runlock()
+
+	return len(storage.data.reports), nil
+}
+
+// DeleteReportsForOrg deletes all reports of the organization together with the feedback on its clusters
+func (storage MemoryStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	for clusterName, report := range storage.data.reports {
+		if report.orgID == orgID {
+			storage.deleteReport(clusterName)
+		}
+	}
+
+	return nil
+}
+
+// PurgeOrganization removes all data of the organization at once, see DBStorage.PurgeOrganization
+func (storage MemoryStorage) PurgeOrganization(orgID types.OrgID) (PurgedRows, error) {
+	if err := storage.lock(); err != nil {
+		return nil, err
+	}
+	defer storage.unlock()
+
+	purged := PurgedRows{"consumer_error": 0}
+	for _, purgeQuery := range organizationPurgeQueries {
+		purged[purgeQuery.table] = 0
+	}
+
+	clusters := make(map[types.ClusterName]bool)
+	for clusterName, report := range storage.data.reports {
+		if report.orgID == orgID {
+			clusters[clusterName] = true
+		}
+	}
+
+	for key := range storage.data.feedbacks {
+		if clusters[key.clusterID] {
+			delete(storage.data.feedbacks, key)
+			purged["cluster_rule_user_feedback"]++
+		}
+	}
+
+	for key := range storage.data.toggles {
+		if clusters[key.clusterID] {
+			delete(storage.data.toggles, key)
+			purged["cluster_rule_toggle"]++
+		}
+	}
+
+	for key := range storage.data.ruleDisables {
+		if key.orgID == orgID {
+			delete(storage.data.ruleDisables, key)
+			purged["rule_disable"]++
+		}
+	}
+
+	for webhookID, webhook := range storage.data.webhooks {
+		if webhook.OrgID == orgID {
+			delete(storage.data.webhooks, webhookID)
+			purged["webhook"]++
+		}
+	}
+
+	deliveries := storage.data.webhookDeliveries[:0]
+	for _, delivery := range storage.data.webhookDeliveries {
+		if _, found := storage.data.webhooks[delivery.WebhookID]; found {
+			deliveries = append(deliveries, delivery)
+		} else {
+			purged["webhook_delivery"]++
+		}
+	}
+	storage.data.webhookDeliveries = deliveries
+
+	consumerErrors := storage.data.consumerErrors[:0]
+	for _, consumerError := range storage.data.consumerErrors {
+		if messageOrgID, ok := consumerErrorOrgID(consumerError.message); ok && messageOrgID == orgID {
+			purged["consumer_error"]++
+		} else {
+			consumerErrors = append(consumerErrors, consumerError)
+		}
+	}
+	storage.data.consumerErrors = consumerErrors
+
+	for clusterName := range clusters {
+		delete(storage.data.reports, clusterName)
+		purged["report"]++
+	}
+
+	return purged, nil
+}
+
+// DeleteReportsForCluster deletes report of the cluster together with the feedback on the cluster
+func (storage MemoryStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	storage.deleteReport(clusterName)
+
+	return nil
+}
+
+// deleteReport deletes the report and the feedback referencing it, the data must be locked
+func (storage MemoryStorage) deleteReport(clusterName types.ClusterName) {
+	delete(storage.data.reports, clusterName)
+
+	for key := range storage.data.feedbacks {
+		if key.clusterID == clusterName {
+			delete(storage.data.feedbacks, key)
+		}
+	}
+}
+
+// PurgeStaleClusters removes reports of the clusters that were last checked before the given time
+// together with the feedback and toggles of their rules, see DBStorage.PurgeStaleClusters
+func (storage MemoryStorage) PurgeStaleClusters(lastCheckedBefore time.Time, dryRun bool) (PurgedClusters, error) {
+	if err := storage.lock(); err != nil {
+		return PurgedClusters{}, err
+	}
+	defer storage.unlock()
+
+	var purged PurgedClusters
+
+	stale := make(map[types.ClusterName]bool)
+	for clusterName, report := range storage.data.reports {
+		if report.lastChecked.Before(lastCheckedBefore) {
+			stale[clusterName] = true
+			purged.Clusters = append(purged.Clusters, clusterName)
+		}
+	}
+
+	sort.Slice(purged.Clusters, func(i, j int) bool {
+		return purged.Clusters[i] < purged.Clusters[j]
+	})
+	purged.Reports = int64(len(purged.Clusters))
+
+	for key := range storage.data.feedbacks {
+		if stale[key.clusterID] {
+			purged.Feedback++
+		}
+	}
+
+	for key := range storage.data.toggles {
+		if stale[key.clusterID] {
+			purged.Toggles++
+			if !dryRun {
+				delete(storage.data.toggles, key)
+			}
+		}
+	}
+
+	if !dryRun {
+		for clusterName := range stale {
+			storage.deleteReport(clusterName)
+		}
+	}
+
+	return purged, nil
+}
+
+// VoteOnRule likes or dislikes rule for cluster by user. If entry exists, it overwrites it
+func (storage MemoryStorage) VoteOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	userVote types.UserVote,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, userID, &userVote, nil)
+}
+
+// AddOrUpdateFeedbackOnRule adds feedback on rule for cluster by user. If entry exists, it overwrites it
+func (storage MemoryStorage) AddOrUpdateFeedbackOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	message string,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, userID, nil, &message)
+}
+
+// addOrUpdateUserFeedbackOnRuleForCluster adds or updates feedback,
+// the cluster must have a report and the rule must exist
+func (storage MemoryStorage) addOrUpdateUserFeedbackOnRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	userID types.UserID,
+	userVotePtr *types.UserVote,
+	messagePtr *string,
+) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	if _, found := storage.data.reports[clusterID]; !found {
+		return &types.ForeignKeyError{
+			TableName: "cluster_rule_user_feedback", Details: fmt.Sprintf("cluster %v has no report", clusterID),
+		}
+	}
+
+	if _, found := storage.data.rules[ruleID]; !found {
+		return &types.ForeignKeyError{
+			TableName: "cluster_rule_user_feedback", Details: fmt.Sprintf("rule %v doesn't exist", ruleID),
+		}
+	}
+
+	now := time.Now()
+	key := clusterRuleUserKey{clusterID, ruleID, userID}
+
+	feedback, found := storage.data.feedbacks[key]
+	if !found {
+		feedback = UserFeedbackOnRule{
+			ClusterID: clusterID,
+			RuleID:    ruleID,
+			UserID:    userID,
+			UserVote:  types.UserVoteNone,
+			AddedAt:   now,
+		}
+	}
+
+	if userVotePtr != nil {
+		feedback.UserVote = *userVotePtr
+	}
+
+	if messagePtr != nil {
+		feedback.Message = *messagePtr
+	}
+
+	feedback.UpdatedAt = now
+	storage.data.feedbacks[key] = feedback
+
+	metrics.FeedbackOnRules.Inc()
+
+	return nil
+}
+
+// GetUserFeedbackOnRule returns feedback of the user on the rule for the cluster
+func (storage MemoryStorage) GetUserFeedbackOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	feedback, found := storage.data.feedbacks[clusterRuleUserKey{clusterID, ruleID, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v", clusterID, ruleID, userID),
+		}
+	}
+
+	return &feedback, nil
+}
+
+// ListFeedbackForCluster returns feedback of all users on rules of the cluster
+func (storage MemoryStorage) ListFeedbackForCluster(clusterName types.ClusterName) ([]UserFeedbackOnRule, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	feedbacks := []UserFeedbackOnRule{}
+	for key, feedback := range storage.data.feedbacks {
+		if key.clusterID == clusterName {
+			feedbacks = append(feedbacks, feedback)
+		}
+	}
+
+	sort.Slice(feedbacks, func(i, j int) bool {
+		if feedbacks[i].RuleID != feedbacks[j].RuleID {
+			return feedbacks[i].RuleID < feedbacks[j].RuleID
+		}
+		return feedbacks[i].UserID < feedbacks[j].UserID
+	})
+
+	return feedbacks, nil
+}
+
+// GetUserFeedbackOnRules returns votes of the user on the rules for the cluster
+func (storage MemoryStorage) GetUserFeedbackOnRules(
+	clusterID types.ClusterName, rulesContent []types.RuleContentResponse, userID types.UserID,
+) (map[types.RuleID]types.UserVote, error) {
+	feedbacks := make(map[types.RuleID]types.UserVote)
+
+	if err := storage.rlock(); err != nil {
+		return feedbacks, err
+	}
+	defer storage.runlock()
+
+	for _, ruleContent := range rulesContent {
+		ruleID := types.RuleID(ruleContent.RuleModule)
+		if feedback, found := storage.data.feedbacks[clusterRuleUserKey{clusterID, ruleID, userID}]; found {
+			feedbacks[ruleID] = feedback.UserVote
+		}
+	}
+
+	return feedbacks, nil
+}
+
+// isRuleErrorKeyVisible checks whether the rule error key is visible according to the filter
+func isRuleErrorKeyVisible(
+	rule *memoryRule, errorKey types.RuleErrorKey, filter RuleContentFilter, now time.Time,
+) bool {
+	if rule.internal && !filter.IncludeInternal {
+		return false
+	}
+
+	return filter.IncludeInactive || isRuleErrorKeyActive(errorKey, now)
+}
+
+// isRuleErrorKeyActive checks whether the rule error key is active and already published
+func isRuleErrorKeyActive(errorKey types.RuleErrorKey, now time.Time) bool {
+	return errorKey.Active && !errorKey.PublishDate.After(now)
+}
+
+// translatedText returns the translated text if it's available, the original one otherwise
+func translatedText(text string, translation []byte) string {
+	if translation == nil {
+		return text
+	}
+
+	return string(translation)
+}
+
+// ruleWithContent returns the rule error key with content translated to the language
+func (rule *memoryRule) ruleWithContent(errorKey types.RuleErrorKey, language string) types.RuleWithContent {
+	translation := rule.translations[language]
+	tags := errorKey.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return types.RuleWithContent{
+		Module:      rule.rule.Module,
+		Name:        rule.rule.Name,
+		Summary:     translatedText(rule.rule.Summary, translation.Summary),
+		Reason:      translatedText(rule.rule.Reason, translation.Reason),
+		Resolution:  translatedText(rule.rule.Resolution, translation.Resolution),
+		MoreInfo:    translatedText(rule.rule.MoreInfo, translation.MoreInfo),
+		ErrorKey:    errorKey.ErrorKey,
+		Condition:   errorKey.Condition,
+		Description: errorKey.Description,
+		TotalRisk:   calculateTotalRisk(errorKey.Impact, errorKey.Likelihood),
+		PublishDate: errorKey.PublishDate,
+		Active:      errorKey.Active,
+		Generic:     translatedText(errorKey.Generic, rule.genericTranslations[errorKey.ErrorKey][language]),
+		Tags:        tags,
+	}
+}
+
+// sortedRuleErrorKeys returns all rule error keys ordered by the rule module and error key
+// together with their rules, the data must be locked
+func (storage MemoryStorage) sortedRuleErrorKeys() ([]*memoryRule, []types.RuleErrorKey) {
+	var (
+		rules     []*memoryRule
+		errorKeys []types.RuleErrorKey
+	)
+
+	for _, rule := range storage.data.rules {
+		for _, errorKey := range rule.errorKeys {
+			rules = append(rules, rule)
+			errorKeys = append(errorKeys, errorKey)
+		}
+	}
+
+	sort.Sort(ruleErrorKeysByID{rules, errorKeys})
+
+	return rules, errorKeys
+}
+
+// ruleErrorKeysByID sorts the error keys with their rules by the rule module and error key
+type ruleErrorKeysByID struct {
+	rules     []*memoryRule
+	errorKeys []types.RuleErrorKey
+}
+
+func (keys ruleErrorKeysByID) Len() int {
+	return len(keys.errorKeys)
+}
+
+func (keys ruleErrorKeysByID) Less(i, j int) bool {
+	if keys.errorKeys[i].RuleModule != keys.errorKeys[j].RuleModule {
+		return keys.errorKeys[i].RuleModule < keys.errorKeys[j].RuleModule
+	}
+
+	return keys.errorKeys[i].ErrorKey < keys.errorKeys[j].ErrorKey
+}
+
+func (keys ruleErrorKeysByID) Swap(i, j int) {
+	keys.rules[i], keys.rules[j] = keys.rules[j], keys.rules[i]
+	keys.errorKeys[i], keys.errorKeys[j] = keys.errorKeys[j], keys.errorKeys[i]
+}
+
+// hitRuleErrorKeys returns the rule error keys hit in the report with their rules, the data must be locked
+func (storage MemoryStorage) hitRuleErrorKeys(reportRules types.ReportRules) ([]*memoryRule, []types.RuleErrorKey) {
+	hits := make(map[types.RuleID]map[types.ErrorKey]bool)
+	for _, hitRule := range reportRules.HitRules {
+		module := types.RuleID(strings.TrimSuffix(hitRule.Module, ".report"))
+		if hits[module] == nil {
+			hits[module] = make(map[types.ErrorKey]bool)
+		}
+		hits[module][types.ErrorKey(hitRule.ErrorKey)] = true
+	}
+
+	var (
+		rules     []*memoryRule
+		errorKeys []types.RuleErrorKey
+	)
+
+	allRules, allErrorKeys := storage.sortedRuleErrorKeys()
+	for i, errorKey := range allErrorKeys {
+		if hits[errorKey.RuleModule][errorKey.ErrorKey] {
+			rules = append(rules, allRules[i])
+			errorKeys = append(errorKeys, errorKey)
+		}
+	}
+
+	return rules, errorKeys
+}
+
+// GetContentForRules retrieves content for rules that were hit in the report,
+// the rules that aren't visible according to the filter are left out
+func (storage MemoryStorage) GetContentForRules(
+	reportRules types.ReportRules,
+	userID types.UserID,
+	clusterName types.ClusterName,
+	filter RuleContentFilter,
+) ([]types.RuleContentResponse, error) {
+	rules := make([]types.RuleContentResponse, 0)
+
+	if err := storage.rlock(); err != nil {
+		return rules, err
+	}
+	defer storage.runlock()
+
+	now := time.Now().UTC()
+
+	hitRules, hitErrorKeys := storage.hitRuleErrorKeys(reportRules)
+	for i, errorKey := range hitErrorKeys {
+		rule := hitRules[i]
+		if !isRuleErrorKeyVisible(rule, errorKey, filter, now) {
+			continue
+		}
+
+		ruleContent := rule.ruleWithContent(errorKey, filter.Language)
+		toggle := storage.data.toggles[clusterRuleUserKey{clusterName, errorKey.RuleModule, userID}]
+		_, orgDisabled := storage.ruleDisableForCluster(clusterName, errorKey.RuleModule, userID)
+
+		rules = append(rules, types.RuleContentResponse{
+			CreatedAt:   errorKey.PublishDate.UTC().Format(time.RFC3339Nano),
+			Description: ruleContent.Description,
+			ErrorKey:    string(ruleContent.ErrorKey),
+			Generic:     ruleContent.Generic,
+			Reason:      ruleContent.Reason,
+			Resolution:  ruleContent.Resolution,
+			TotalRisk:   ruleContent.TotalRisk,
+			RuleModule:  string(ruleContent.Module),
+			Tags:        ruleContent.Tags,
+			Disabled:    toggle.Disabled == RuleToggleDisable || orgDisabled,
+		})
+	}
+
+	// the disabled rules are the last ones
+	sort.SliceStable(rules, func(i, j int) bool {
+		return !rules[i].Disabled && rules[j].Disabled
+	})
+
+	return getExtraDataFromReportRules(rules, reportRules), nil
+}
+
+// GetSuppressedRulesCount returns number of rules hit in the report that have content,
+// but are not shown because they are inactive or not published yet
+func (storage MemoryStorage) GetSuppressedRulesCount(reportRules types.ReportRules, includeInternal bool) (int, error) {
+	if err := storage.rlock(); err != nil {
+		return 0, err
+	}
+	defer storage.runlock()
+
+	now := time.Now().UTC()
+	count := 0
+
+	hitRules, hitErrorKeys := storage.hitRuleErrorKeys(reportRules)
+	for i, errorKey := range hitErrorKeys {
+		if isRuleErrorKeyActive(errorKey, now) || (hitRules[i].internal && !includeInternal) {
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// ToggleRuleForCluster toggles rule for specified cluster
+func (storage MemoryStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID, ruleToggle RuleToggle,
+) error {
+	var enabledAt, disabledAt sql.NullTime
+
+	now := time.Now()
+
+	switch ruleToggle {
+	case RuleToggleDisable:
+		disabledAt = sql.NullTime{Time: now, Valid: true}
+	case RuleToggleEnable:
+		enabledAt = sql.NullTime{Time: now, Valid: true}
+	default:
+		return fmt.Errorf("Unexpected rule toggle value")
+	}
+
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	key := clusterRuleUserKey{clusterID, ruleID, userID}
+
+	toggle, found := storage.data.toggles[key]
+	if !found {
+		// the time of the update is set just when the toggle is created like in the database
+		toggle = ClusterRuleToggle{
+			ClusterID: clusterID,
+			RuleID:    ruleID,
+			UserID:    userID,
+			UpdatedAt: sql.NullTime{Time: now, Valid: true},
+		}
+	}
+
+	toggle.Disabled = ruleToggle
+	toggle.DisabledAt = disabledAt
+	toggle.EnabledAt = enabledAt
+	storage.data.toggles[key] = toggle
+
+	return nil
+}
+
+// ListDisabledRulesForCluster retrieves rules disabled for specified cluster and user,
+// see DBStorage.ListDisabledRulesForCluster
+func (storage MemoryStorage) ListDisabledRulesForCluster(
+	clusterID types.ClusterName, userID types.UserID,
+) ([]types.DisabledRuleResponse, error) {
+	rules := make([]types.DisabledRuleResponse, 0)
+
+	if err := storage.rlock(); err != nil {
+		return rules, err
+	}
+	defer storage.runlock()
+
+	_, errorKeys := storage.sortedRuleErrorKeys()
+	for _, errorKey := range errorKeys {
+		rule := types.DisabledRuleResponse{
+			RuleModule:  string(errorKey.RuleModule),
+			Description: errorKey.Description,
+			Generic:     errorKey.Generic,
+		}
+
+		toggle, found := storage.data.toggles[clusterRuleUserKey{clusterID, errorKey.RuleModule, userID}]
+		if found && toggle.Disabled == RuleToggleDisable {
+			rule.DisabledAt, rule.Scope = formatDisabledAt(toggle.DisabledAt.Time), RuleDisableScopeCluster
+		} else if disable, found := storage.ruleDisableForCluster(clusterID, errorKey.RuleModule, userID); found {
+			rule.DisabledAt, rule.Scope = formatDisabledAt(disable.DisabledAt), disable.Scope()
+		} else {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ruleDisableForCluster returns the most specific disable of the rule on all clusters
+// of the cluster's organization which applies to the user, the lock has to be held
+func (storage MemoryStorage) ruleDisableForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (RuleDisable, bool) {
+	report, found := storage.data.reports[clusterID]
+	if !found {
+		return RuleDisable{}, false
+	}
+
+	if userID != "" {
+		if disable, found := storage.data.ruleDisables[orgRuleUserKey{report.orgID, ruleID, userID}]; found {
+			return disable, true
+		}
+	}
+
+	disable, found := storage.data.ruleDisables[orgRuleUserKey{report.orgID, ruleID, ""}]
+	return disable, found
+}
+
+// GetFromClusterRuleToggle returns toggle of the rule for the cluster by the user
+func (storage MemoryStorage) GetFromClusterRuleToggle(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*ClusterRuleToggle, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	toggle, found := storage.data.toggles[clusterRuleUserKey{clusterID, ruleID, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	return &toggle, nil
+}
+
+// DisableRuleForOrg disables the rule on all clusters of the organization, for all users
+// of the organization if userID is empty or just for the user otherwise
+func (storage MemoryStorage) DisableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	storage.data.ruleDisables[orgRuleUserKey{orgID, ruleID, userID}] = RuleDisable{
+		OrgID:      orgID,
+		RuleID:     ruleID,
+		UserID:     userID,
+		DisabledAt: time.Now(),
+	}
+
+	return nil
+}
+
+// EnableRuleForOrg enables the rule disabled by DisableRuleForOrg with the same arguments
+func (storage MemoryStorage) EnableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	delete(storage.data.ruleDisables, orgRuleUserKey{orgID, ruleID, userID})
+
+	return nil
+}
+
+// ListRuleDisablesForOrg returns the rules disabled for the organization and for its users,
+// ordered by the rule and the user
+func (storage MemoryStorage) ListRuleDisablesForOrg(orgID types.OrgID) ([]RuleDisable, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	disables := []RuleDisable{}
+	for key, disable := range storage.data.ruleDisables {
+		if key.orgID == orgID {
+			disables = append(disables, disable)
+		}
+	}
+
+	sort.Slice(disables, func(i, j int) bool {
+		if disables[i].RuleID != disables[j].RuleID {
+			return disables[i].RuleID < disables[j].RuleID
+		}
+		return disables[i].UserID < disables[j].UserID
+	})
+
+	return disables, nil
+}
+
+// ListTogglesForCluster returns rule toggles of all users for the cluster
+func (storage MemoryStorage) ListTogglesForCluster(clusterName types.ClusterName) ([]ClusterRuleToggle, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	toggles := []ClusterRuleToggle{}
+	for key, toggle := range storage.data.toggles {
+		if key.clusterID == clusterName {
+			toggles = append(toggles, toggle)
+		}
+	}
+
+	sort.Slice(toggles, func(i, j int) bool {
+		if toggles[i].RuleID != toggles[j].RuleID {
+			return toggles[i].RuleID < toggles[j].RuleID
+		}
+		return toggles[i].UserID < toggles[j].UserID
+	})
+
+	return toggles, nil
+}
+
+// DeleteFromRuleClusterToggle deletes toggle of the rule for the cluster by the user
+func (storage MemoryStorage) DeleteFromRuleClusterToggle(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	delete(storage.data.toggles, clusterRuleUserKey{clusterID, ruleID, userID})
+
+	return nil
+}
+
+// newMemoryRule converts the parsed rule content to the stored rule
+func newMemoryRule(ruleConfig content.GlobalRuleConfig, ruleContent content.RuleContent) (*memoryRule, error) {
+	rule := &memoryRule{
+		rule: types.Rule{
+			Module:     types.RuleID(ruleContent.Plugin.PythonModule),
+			Name:       ruleContent.Plugin.Name,
+			Summary:    string(ruleContent.Summary),
+			Reason:     string(ruleContent.Reason),
+			Resolution: string(ruleContent.Resolution),
+			MoreInfo:   string(ruleContent.MoreInfo),
+		},
+		internal:            ruleContent.Internal,
+		errorKeys:           make(map[types.ErrorKey]types.RuleErrorKey),
+		translations:        make(map[string]content.RuleTranslation),
+		genericTranslations: make(map[types.ErrorKey]map[string][]byte),
+	}
+
+	for language, translation := range ruleContent.Translations {
+		rule.translations[language] = translation
+	}
+
+	for errName, errProperties := range ruleContent.ErrorKeys {
+		var errIsActiveStatus bool
+		switch strings.ToLower(errProperties.Metadata.Status) {
+		case "active":
+			errIsActiveStatus = true
+		case "inactive":
+			errIsActiveStatus = false
+		default:
+			return nil, fmt.Errorf("invalid rule error key status: '%s'", errProperties.Metadata.Status)
+		}
+
+		publishDate, err := content.ParsePublishDate(errProperties.Metadata.PublishDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule error key publish date: '%s'", errProperties.Metadata.PublishDate)
+		}
+
+		errorKey := types.ErrorKey(errName)
+		rule.errorKeys[errorKey] = types.RuleErrorKey{
+			ErrorKey:    errorKey,
+			RuleModule:  rule.rule.Module,
+			Condition:   errProperties.Metadata.Condition,
+			Description: errProperties.Metadata.Description,
+			Impact:      ruleConfig.Impact[errProperties.Metadata.Impact],
+			Likelihood:  errProperties.Metadata.Likelihood,
+			PublishDate: publishDate.UTC(),
+			Active:      errIsActiveStatus,
+			Generic:     string(errProperties.Generic),
+			Tags:        commaSeparatedStrToTags(strings.Join(errProperties.Metadata.Tags, ",")),
+		}
+
+		if len(errProperties.GenericTranslations) > 0 {
+			rule.genericTranslations[errorKey] = make(map[string][]byte)
+		}
+		for language, generic := range errProperties.GenericTranslations {
+			rule.genericTranslations[errorKey][language] = generic
+		}
+	}
+
+	return rule, nil
+}
+
+// LoadRuleContent replaces all the rules by the parsed rule content,
+// the feedback is deleted only for the rules removed from the content
+func (storage MemoryStorage) LoadRuleContent(contentDir content.RuleContentDirectory) error {
+	rules := make(map[types.RuleID]*memoryRule)
+	for _, ruleContent := range contentDir.Rules {
+		rule, err := newMemoryRule(contentDir.Config, ruleContent)
+		if err != nil {
+			return err
+		}
+		rules[rule.rule.Module] = rule
+	}
+
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	storage.data.rules = rules
+	for key := range storage.data.feedbacks {
+		if _, found := rules[key.ruleID]; !found {
+			delete(storage.data.feedbacks, key)
+		}
+	}
+	storage.data.contentVersion = &types.ContentVersion{
+		Hash:     contentDir.Version.Hash,
+		Commit:   contentDir.Version.Commit,
+		LoadedAt: time.Now(),
+	}
+
+	return nil
+}
+
+// ReadRuleContent returns the rule content loaded by LoadRuleContent, see DBStorage.ReadRuleContent
+func (storage MemoryStorage) ReadRuleContent() (content.RuleContentDirectory, error) {
+	if err := storage.rlock(); err != nil {
+		return content.RuleContentDirectory{}, err
+	}
+	defer storage.runlock()
+
+	contentDir := newRuleContentDirectory(storage.data.contentVersion)
+	for _, rule := range storage.data.rules {
+		translations := make(map[string]content.RuleTranslation)
+		for language, translation := range rule.translations {
+			translations[language] = translation
+		}
+		addRuleToContentDirectory(&contentDir, rule.rule, rule.internal, translations)
+
+		for _, errorKey := range rule.errorKeys {
+			var genericTranslations map[string][]byte
+			if len(rule.genericTranslations[errorKey.ErrorKey]) > 0 {
+				genericTranslations = make(map[string][]byte)
+			}
+			for language, generic := range rule.genericTranslations[errorKey.ErrorKey] {
+				genericTranslations[language] = generic
+			}
+			addErrorKeyToContentDirectory(&contentDir, errorKey, genericTranslations)
+		}
+	}
+
+	return contentDir, nil
+}
+
+// GetContentVersion returns version of the rule content loaded by LoadRuleContent
+func (storage MemoryStorage) GetContentVersion() (*types.ContentVersion, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	if storage.data.contentVersion == nil {
+		return nil, &types.ItemNotFoundError{ItemID: "content version"}
+	}
+
+	version := *storage.data.contentVersion
+	return &version, nil
+}
+
+// GetRuleByID gets a rule by ID
+func (storage MemoryStorage) GetRuleByID(ruleID types.RuleID) (*types.Rule, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	rule, found := storage.data.rules[ruleID]
+	if !found {
+		return nil, &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	result := rule.rule
+	return &result, nil
+}
+
+// CreateRule creates the rule or updates the existing one
+func (storage MemoryStorage) CreateRule(ruleData types.Rule) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	if rule, found := storage.data.rules[ruleData.Module]; found {
+		rule.rule = ruleData
+		return nil
+	}
+
+	storage.data.rules[ruleData.Module] = &memoryRule{
+		rule:                ruleData,
+		errorKeys:           make(map[types.ErrorKey]types.RuleErrorKey),
+		translations:        make(map[string]content.RuleTranslation),
+		genericTranslations: make(map[types.ErrorKey]map[string][]byte),
+	}
+
+	return nil
+}
+
+// DeleteRule deletes the rule together with its error keys and the feedback on it
+func (storage MemoryStorage) DeleteRule(ruleID types.RuleID) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	if _, found := storage.data.rules[ruleID]; !found {
+		return &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	delete(storage.data.rules, ruleID)
+
+	for key := range storage.data.feedbacks {
+		if key.ruleID == ruleID {
+			delete(storage.data.feedbacks, key)
+		}
+	}
+
+	return nil
+}
+
+// CreateRuleErrorKey creates the rule error key or updates the existing one, its rule must exist.
+// Tags of the error key are not stored the same way as they are not stored in the database.
+func (storage MemoryStorage) CreateRuleErrorKey(ruleErrorKey types.RuleErrorKey) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	rule, found := storage.data.rules[ruleErrorKey.RuleModule]
+	if !found {
+		return &types.ForeignKeyError{
+			TableName: "rule_error_key", Details: fmt.Sprintf("rule %v doesn't exist", ruleErrorKey.RuleModule),
+		}
+	}
+
+	ruleErrorKey.Tags = rule.errorKeys[ruleErrorKey.ErrorKey].Tags
+	rule.errorKeys[ruleErrorKey.ErrorKey] = ruleErrorKey
+
+	return nil
+}
+
+// DeleteRuleErrorKey deletes the rule error key
+func (storage MemoryStorage) DeleteRuleErrorKey(ruleID types.RuleID, errorKey types.ErrorKey) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	rule, found := storage.data.rules[ruleID]
+	if found {
+		_, found = rule.errorKeys[errorKey]
+	}
+	if !found {
+		return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v", ruleID, errorKey)}
+	}
+
+	delete(rule.errorKeys, errorKey)
+
+	return nil
+}
+
+// WriteConsumerError stores a report about a consumer error
+func (storage MemoryStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	storage.data.consumerErrors = append(storage.data.consumerErrors, memoryConsumerError{
+		topic:      msg.Topic,
+		partition:  msg.Partition,
+		offset:     msg.Offset,
+		key:        msg.Key,
+		producedAt: msg.Timestamp,
+		consumedAt: time.Now().UTC(),
+		message:    msg.Value,
+		err:        consumerErr.Error(),
+	})
+
+	return nil
+}
+
+// GetRuleWithContent returns rule with content for provided ruleID and ruleErrorKey,
+// the texts are translated to the language of the filter if the translation is available.
+// Rules that aren't visible according to the filter are not found.
+func (storage MemoryStorage) GetRuleWithContent(
+	ruleID types.RuleID, ruleErrorKey types.ErrorKey, filter RuleContentFilter,
+) (*types.RuleWithContent, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	notFoundErr := types.ConvertDBError(sql.ErrNoRows, []interface{}{ruleID, ruleErrorKey})
+
+	rule, found := storage.data.rules[ruleID]
+	if !found {
+		return nil, notFoundErr
+	}
+
+	errorKey, found := rule.errorKeys[ruleErrorKey]
+	if !found || !isRuleErrorKeyVisible(rule, errorKey, filter, time.Now().UTC()) {
+		return nil, notFoundErr
+	}
+
+	result := rule.ruleWithContent(errorKey, filter.Language)
+	return &result, nil
+}
+
+// GetContentLanguages returns languages of all available rule content translations
+func (storage MemoryStorage) GetContentLanguages() ([]string, error) {
+	languages := make([]string, 0)
+
+	if err := storage.rlock(); err != nil {
+		return languages, err
+	}
+	defer storage.runlock()
+
+	seen := make(map[string]bool)
+	addLanguage := func(language string) {
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+
+	for _, rule := range storage.data.rules {
+		for language := range rule.translations {
+			addLanguage(language)
+		}
+		for _, translations := range rule.genericTranslations {
+			for language := range translations {
+				addLanguage(language)
+			}
+		}
+	}
+	sort.Strings(languages)
+
+	return languages, nil
+}
+
+// searchRuleErrorKey matches the words as prefixes of words of the searched content of the rule error key.
+// It returns number of the matched words and a snippet of the content with the most matches,
+// zero is returned if any of the words doesn't match.
+func searchRuleErrorKey(rule *memoryRule, errorKey types.RuleErrorKey, words []string) (int, string) {
+	fields := []string{
+		rule.rule.Name, rule.rule.Summary, rule.rule.Reason, rule.rule.Resolution,
+		errorKey.Description, strings.Join(errorKey.Tags, " "),
+	}
+
+	matchedWords := make(map[string]bool)
+	rank, bestMatches, snippet := 0, 0, ""
+
+	for _, field := range fields {
+		tokens := searchWordRegex.FindAllStringIndex(field, -1)
+		matched := make([]bool, len(tokens))
+		matches, firstMatch := 0, -1
+
+		for i, token := range tokens {
+			tokenText := strings.ToLower(field[token[0]:token[1]])
+			for _, word := range words {
+				if strings.HasPrefix(tokenText, word) {
+					matchedWords[word] = true
+					matched[i] = true
+				}
+			}
+
+			if matched[i] {
+				matches++
+				if firstMatch < 0 {
+					firstMatch = i
+				}
+			}
+		}
+
+		rank += matches
+		if matches > bestMatches {
+			bestMatches = matches
+			snippet = searchSnippet(field, tokens, matched, firstMatch)
+		}
+	}
+
+	if len(matchedWords) < len(words) {
+		return 0, ""
+	}
+
+	return rank, snippet
+}
+
+// searchSnippetWords is the maximal number of words of the search result snippet
+const searchSnippetWords = 16
+
+// searchSnippet returns a part of the text around the first matched token
+// with the matched tokens highlighted in Markdown bold
+func searchSnippet(text string, tokens [][]int, matched []bool, firstMatch int) string {
+	start := firstMatch - searchSnippetWords/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + searchSnippetWords
+	if end > len(tokens) {
+		end = len(tokens)
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("...")
+	}
+
+	for i := start; i < end; i++ {
+		if i > start {
+			snippet.WriteString(text[tokens[i-1][1]:tokens[i][0]])
+		}
+
+		token := text[tokens[i][0]:tokens[i][1]]
+		if matched[i] {
+			token = "**" + token + "**"
+		}
+		snippet.WriteString(token)
+	}
+
+	if end < len(tokens) {
+		snippet.WriteString("...")
+	}
+
+	return snippet.String()
+}
+
+// SearchRules finds rules whose name, summary, reason, resolution, description or tags
+// contain words starting with all words of the query. Results are ordered by number
+// of the matched words and contain snippets of the matching content with the words
+// highlighted in Markdown bold. Rules that are not visible according to the filter are left out.
+func (storage MemoryStorage) SearchRules(
+	query string, filter RuleContentFilter, limit int,
+) ([]types.RuleSearchResult, error) {
+	results := []types.RuleSearchResult{}
+
+	words := searchWordRegex.FindAllString(strings.ToLower(query), -1)
+	if len(words) == 0 || limit <= 0 {
+		return results, nil
+	}
+
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	now := time.Now().UTC()
+
+	rules, errorKeys := storage.sortedRuleErrorKeys()
+	for i, errorKey := range errorKeys {
+		if !isRuleErrorKeyVisible(rules[i], errorKey, filter, now) {
+			continue
+		}
+
+		rank, snippet := searchRuleErrorKey(rules[i], errorKey, words)
+		if rank == 0 {
+			continue
+		}
+
+		// the searched content isn't translated
+		results = append(results, types.RuleSearchResult{
+			RuleWithContent: rules[i].ruleWithContent(errorKey, ""),
+			Rank:            float64(rank),
+			Snippet:         snippet,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matchesRuleListFilter checks whether the rule error key matches the list filter
+func matchesRuleListFilter(errorKey types.RuleErrorKey, listFilter RuleListFilter) bool {
+	if listFilter.Tag != "" && !containsTag(errorKey.Tags, listFilter.Tag) {
+		return false
+	}
+
+	if listFilter.Impact != 0 && errorKey.Impact != listFilter.Impact {
+		return false
+	}
+
+	if listFilter.Likelihood != 0 && errorKey.Likelihood != listFilter.Likelihood {
+		return false
+	}
+
+	return listFilter.Active == nil || errorKey.Active == *listFilter.Active
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListRules returns page of rule error keys with content ordered by the rule module and error key,
+// and total number of rule error keys matching the filters. The texts are translated
+// to the language of the content filter if the translation is available.
+func (storage MemoryStorage) ListRules(
+	contentFilter RuleContentFilter, listFilter RuleListFilter, offset, limit int,
+) ([]types.RuleWithContent, int, error) {
+	rules := make([]types.RuleWithContent, 0)
+
+	if err := storage.rlock(); err != nil {
+		return rules, 0, err
+	}
+	defer storage.runlock()
+
+	now := time.Now().UTC()
+	total := 0
+
+	allRules, errorKeys := storage.sortedRuleErrorKeys()
+	for i, errorKey := range errorKeys {
+		if !isRuleErrorKeyVisible(allRules[i], errorKey, contentFilter, now) ||
+			!matchesRuleListFilter(errorKey, listFilter) {
+			continue
+		}
+
+		if total >= offset && len(rules) < limit {
+			rules = append(rules, allRules[i].ruleWithContent(errorKey, contentFilter.Language))
+		}
+		total++
+	}
+
+	return rules, total, nil
+}
+
+// ListRuleTags returns all distinct tags of the rule error keys visible according to the filter
+// with number of the error keys having the tag, ordered by the tag
+func (storage MemoryStorage) ListRuleTags(filter RuleContentFilter) ([]types.RuleTagCount, error) {
+	tagCounts := make([]types.RuleTagCount, 0)
+
+	if err := storage.rlock(); err != nil {
+		return tagCounts, err
+	}
+	defer storage.runlock()
+
+	now := time.Now().UTC()
+	counts := make(map[string]int)
+
+	rules, errorKeys := storage.sortedRuleErrorKeys()
+	for i, errorKey := range errorKeys {
+		if !isRuleErrorKeyVisible(rules[i], errorKey, filter, now) {
+			continue
+		}
+
+		for _, tag := range errorKey.Tags {
+			counts[tag]++
+		}
+	}
+
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, types.RuleTagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool {
+		return tagCounts[i].Tag < tagCounts[j].Tag
+	})
+
+	return tagCounts, nil
+}
+
+// CreateWebhook stores new webhook subscription and returns its ID
+func (storage MemoryStorage) CreateWebhook(webhook types.Webhook) (types.WebhookID, error) {
+	if err := storage.lock(); err != nil {
+		return 0, err
+	}
+	defer storage.unlock()
+
+	now := time.Now()
+
+	storage.data.lastWebhookID++
+	webhook.ID = storage.data.lastWebhookID
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	storage.data.webhooks[webhook.ID] = webhook
+
+	return webhook.ID, nil
+}
+
+// GetWebhook returns webhook subscription of the organization
+func (storage MemoryStorage) GetWebhook(orgID types.OrgID, webhookID types.WebhookID) (*types.Webhook, error) {
+	if err := storage.rlock(); err != nil {
+		return nil, err
+	}
+	defer storage.runlock()
+
+	webhook, found := storage.data.webhooks[webhookID]
+	if !found || webhook.OrgID != orgID {
+		return nil, &types.ItemNotFoundError{ItemID: webhookID}
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooksForOrg returns all webhook subscriptions of the organization
+func (storage MemoryStorage) ListWebhooksForOrg(orgID types.OrgID) ([]types.Webhook, error) {
+	webhooks := make([]types.Webhook, 0)
+
+	if err := storage.rlock(); err != nil {
+		return webhooks, err
+	}
+	defer storage.runlock()
+
+	for _, webhook := range storage.data.webhooks {
+		if webhook.OrgID == orgID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+// ListOrgsWithWebhooks returns the organizations having at least one webhook subscription
+func (storage MemoryStorage) ListOrgsWithWebhooks() ([]types.OrgID, error) {
+	orgIDs := make([]types.OrgID, 0)
+
+	if err := storage.rlock(); err != nil {
+		return orgIDs, err
+	}
+	defer storage.runlock()
+
+	found := make(map[types.OrgID]bool)
+	for _, webhook := range storage.data.webhooks {
+		if !found[webhook.OrgID] {
+			found[webhook.OrgID] = true
+			orgIDs = append(orgIDs, webhook.OrgID)
+		}
+	}
+	sort.Slice(orgIDs, func(i, j int) bool {
+		return orgIDs[i] < orgIDs[j]
+	})
+
+	return orgIDs, nil
+}
+
+// UpdateWebhook updates URL, secret and risk threshold of existing webhook subscription
+func (storage MemoryStorage) UpdateWebhook(webhook types.Webhook) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	storedWebhook, found := storage.data.webhooks[webhook.ID]
+	if !found || storedWebhook.OrgID != webhook.OrgID {
+		return &types.ItemNotFoundError{ItemID: webhook.ID}
+	}
+
+	storedWebhook.URL = webhook.URL
+	storedWebhook.Secret = webhook.Secret
+	storedWebhook.MinTotalRisk = webhook.MinTotalRisk
+	storedWebhook.UpdatedAt = time.Now()
+	storage.data.webhooks[webhook.ID] = storedWebhook
+
+	return nil
+}
+
+// DeleteWebhook deletes webhook subscription together with its delivery log
+func (storage MemoryStorage) DeleteWebhook(orgID types.OrgID, webhookID types.WebhookID) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	webhook, found := storage.data.webhooks[webhookID]
+	if !found || webhook.OrgID != orgID {
+		return &types.ItemNotFoundError{ItemID: webhookID}
+	}
+
+	delete(storage.data.webhooks, webhookID)
+
+	deliveries := storage.data.webhookDeliveries[:0]
+	for _, delivery := range storage.data.webhookDeliveries {
+		if delivery.WebhookID != webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	storage.data.webhookDeliveries = deliveries
+
+	return nil
+}
+
+// WriteWebhookDelivery writes a record to the webhook delivery log, the webhook must exist
+func (storage MemoryStorage) WriteWebhookDelivery(delivery types.WebhookDelivery) error {
+	if err := storage.lock(); err != nil {
+		return err
+	}
+	defer storage.unlock()
+
+	if _, found := storage.data.webhooks[delivery.WebhookID]; !found {
+		return &types.ForeignKeyError{
+			TableName: "webhook_delivery", Details: fmt.Sprintf("webhook %v doesn't exist", delivery.WebhookID),
+		}
+	}
+
+	storage.data.webhookDeliveries = append(storage.data.webhookDeliveries, delivery)
+
+	return nil
+}
+
+// ListWebhookDeliveries returns at most limit latest deliveries of the webhook
+func (storage MemoryStorage) ListWebhookDeliveries(
+	webhookID types.WebhookID, limit int,
+) ([]types.WebhookDelivery, error) {
+	deliveries := make([]types.WebhookDelivery, 0)
+
+	if err := storage.rlock(); err != nil {
+		return deliveries, err
+	}
+	defer storage.runlock()
+
+	for _, delivery := range storage.data.webhookDeliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].DeliveredAt.After(deliveries[j].DeliveredAt)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"context"
 	"time"
 
 	"github.com/RedHatInsights/insights-results-aggregator/content"
@@ -35,6 +36,11 @@ func (*NoopStorage) Close() error {
 	return nil
 }
 
+// WithContext noop
+func (storage *NoopStorage) WithContext(context.Context) Storage {
+	return storage
+}
+
 // ListOfOrgs noop
 func (*NoopStorage) ListOfOrgs() ([]types.OrgID, error) {
 	return nil, nil
@@ -52,6 +58,13 @@ func (*NoopStorage) ReadReportForCluster(
 	return "", "", nil
 }
 
+// ReadReportForClusterFromPrimary noop
+func (*NoopStorage) ReadReportForClusterFromPrimary(
+	types.OrgID, types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	return "", "", nil
+}
+
 // ReadReportForClusterByClusterName noop
 func (*NoopStorage) ReadReportForClusterByClusterName(
 	types.ClusterName,
@@ -71,11 +84,56 @@ func (*NoopStorage) WriteReportForCluster(
 	return nil
 }
 
+// WriteReportsForClusters noop
+func (*NoopStorage) WriteReportsForClusters(reports []ReportWrite) ([]error, error) {
+	return make([]error, len(reports)), nil
+}
+
 // ReportsCount noop
 func (*NoopStorage) ReportsCount() (int, error) {
 	return 0, nil
 }
 
+// PurgeStaleClusters noop
+func (*NoopStorage) PurgeStaleClusters(time.Time, bool) (PurgedClusters, error) {
+	return PurgedClusters{}, nil
+}
+
+// PurgeOrganization noop
+func (*NoopStorage) PurgeOrganization(types.OrgID) (PurgedRows, error) {
+	return PurgedRows{}, nil
+}
+
+// ReadRuleContent noop
+func (*NoopStorage) ReadRuleContent() (content.RuleContentDirectory, error) {
+	return content.RuleContentDirectory{}, nil
+}
+
+// ListFeedbackForCluster noop
+func (*NoopStorage) ListFeedbackForCluster(types.ClusterName) ([]UserFeedbackOnRule, error) {
+	return nil, nil
+}
+
+// ListTogglesForCluster noop
+func (*NoopStorage) ListTogglesForCluster(types.ClusterName) ([]ClusterRuleToggle, error) {
+	return nil, nil
+}
+
+// DisableRuleForOrg noop
+func (*NoopStorage) DisableRuleForOrg(types.OrgID, types.RuleID, types.UserID) error {
+	return nil
+}
+
+// EnableRuleForOrg noop
+func (*NoopStorage) EnableRuleForOrg(types.OrgID, types.RuleID, types.UserID) error {
+	return nil
+}
+
+// ListRuleDisablesForOrg noop
+func (*NoopStorage) ListRuleDisablesForOrg(types.OrgID) ([]RuleDisable, error) {
+	return nil, nil
+}
+
 // VoteOnRule noop
 func (*NoopStorage) VoteOnRule(types.ClusterName, types.RuleID, types.UserID, types.UserVote) error {
 	return nil
@@ -96,10 +154,15 @@ func (*NoopStorage) GetUserFeedbackOnRule(
 }
 
 // GetContentForRules noop
-func (*NoopStorage) GetContentForRules(types.ReportRules, types.UserID, types.ClusterName) ([]types.RuleContentResponse, error) {
+func (*NoopStorage) GetContentForRules(types.ReportRules, types.UserID, types.ClusterName, RuleContentFilter) ([]types.RuleContentResponse, error) {
 	return nil, nil
 }
 
+// GetSuppressedRulesCount noop
+func (*NoopStorage) GetSuppressedRulesCount(types.ReportRules, bool) (int, error) {
+	return 0, nil
+}
+
 // DeleteReportsForOrg noop
 func (*NoopStorage) DeleteReportsForOrg(types.OrgID) error {
 	return nil
@@ -115,6 +178,11 @@ func (*NoopStorage) LoadRuleContent(content.RuleContentDirectory) error {
 	return nil
 }
 
+// GetContentVersion noop
+func (*NoopStorage) GetContentVersion() (*types.ContentVersion, error) {
+	return nil, nil
+}
+
 // GetRuleByID noop
 func (*NoopStorage) GetRuleByID(types.RuleID) (*types.Rule, error) {
 	return nil, nil
@@ -189,7 +257,67 @@ func (*NoopStorage) GetUserFeedbackOnRules(
 
 // GetRuleWithContent noop
 func (*NoopStorage) GetRuleWithContent(
-	types.RuleID, types.ErrorKey,
+	types.RuleID, types.ErrorKey, RuleContentFilter,
 ) (*types.RuleWithContent, error) {
 	return nil, nil
 }
+
+// GetContentLanguages noop
+func (*NoopStorage) GetContentLanguages() ([]string, error) {
+	return nil, nil
+}
+
+// SearchRules noop
+func (*NoopStorage) SearchRules(string, RuleContentFilter, int) ([]types.RuleSearchResult, error) {
+	return nil, nil
+}
+
+// ListRules noop
+func (*NoopStorage) ListRules(RuleContentFilter, RuleListFilter, int, int) ([]types.RuleWithContent, int, error) {
+	return nil, 0, nil
+}
+
+// ListRuleTags noop
+func (*NoopStorage) ListRuleTags(RuleContentFilter) ([]types.RuleTagCount, error) {
+	return nil, nil
+}
+
+// CreateWebhook noop
+func (*NoopStorage) CreateWebhook(types.Webhook) (types.WebhookID, error) {
+	return 0, nil
+}
+
+// GetWebhook noop
+func (*NoopStorage) GetWebhook(types.OrgID, types.WebhookID) (*types.Webhook, error) {
+	return nil, nil
+}
+
+// ListWebhooksForOrg noop
+func (*NoopStorage) ListWebhooksForOrg(types.OrgID) ([]types.Webhook, error) {
+	return nil, nil
+}
+
+// ListOrgsWithWebhooks noop
+func (*NoopStorage) ListOrgsWithWebhooks() ([]types.OrgID, error) {
+	return nil, nil
+}
+
+// UpdateWebhook noop
+func (*NoopStorage) UpdateWebhook(types.Webhook) error {
+	return nil
+}
+
+// DeleteWebhook noop
+func (*NoopStorage) DeleteWebhook(types.OrgID, types.WebhookID) error {
+	return nil
+}
+
+// WriteWebhookDelivery noop
+func (*NoopStorage) WriteWebhookDelivery(types.WebhookDelivery) error {
+	return nil
+}
+
+// ListWebhookDeliveries noop
+func (*NoopStorage) ListWebhookDeliveries(types.WebhookID, int) ([]types.WebhookDelivery, error) {
+	return nil, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// PurgedRows contains numbers of rows removed from every table by PurgeOrganization
+type PurgedRows map[string]int64
+
+// organizationPurgeQueries remove rows of the organization from the tables, feedback
+// and toggles are selected by the reports, so they have to be removed first
+var organizationPurgeQueries = []struct {
+	table string
+	query string
+}{
+	{"cluster_rule_user_feedback", "DELETE FROM cluster_rule_user_feedback WHERE cluster_id IN (SELECT cluster FROM report WHERE org_id = $1);"},
+	{"cluster_rule_toggle", "DELETE FROM cluster_rule_toggle WHERE cluster_id IN (SELECT cluster FROM report WHERE org_id = $1);"},
+	{"webhook_delivery", "DELETE FROM webhook_delivery WHERE webhook_id IN (SELECT id FROM webhook WHERE org_id = $1);"},
+	{"webhook", "DELETE FROM webhook WHERE org_id = $1;"},
+	{"rule_disable", "DELETE FROM rule_disable WHERE org_id = $1;"},
+	{"report", "DELETE FROM report WHERE org_id = $1;"},
+}
+
+// consumerErrorOrgID returns organization ID from the message of the consumer error,
+// false is returned if the message can't be parsed or it doesn't contain the ID
+func consumerErrorOrgID(message []byte) (types.OrgID, bool) {
+	var parsed struct {
+		OrgID *types.OrgID `json:"OrgID"`
+	}
+
+	if err := json.Unmarshal(message, &parsed); err != nil || parsed.OrgID == nil {
+		return 0, false
+	}
+
+	return *parsed.OrgID, true
+}
+
+// PurgeOrganization removes all data of the organization in a single transaction, i.e. reports
+// of its clusters, feedback and toggles of their rules, webhooks with their deliveries, rules disabled
+// in the organization and errors of consuming messages of the organization. Numbers of the removed
+// rows are returned.
+func (storage DBStorage) PurgeOrganization(orgID types.OrgID) (PurgedRows, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make(PurgedRows)
+
+	for _, purgeQuery := range organizationPurgeQueries {
+		result, err := tx.ExecContext(ctx, purgeQuery.query, orgID)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+
+		purged[purgeQuery.table], err = result.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	purged["consumer_error"], err = purgeConsumerErrorsOfOrganization(ctx, tx, orgID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+// purgeConsumerErrorsOfOrganization removes consumer errors whose messages belong to the organization,
+// the organization isn't stored in a column, so all messages have to be parsed
+func purgeConsumerErrorsOfOrganization(ctx context.Context, tx *sql.Tx, orgID types.OrgID) (int64, error) {
+	type consumerErrorKey struct {
+		topic     string
+		partition int32
+		offset    int64
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT topic, partition, topic_offset, message FROM consumer_error;")
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []consumerErrorKey
+	for rows.Next() {
+		var key consumerErrorKey
+		var message []byte
+
+		if err := rows.Scan(&key.topic, &key.partition, &key.offset, &message); err != nil {
+			closeRows(rows)
+			return 0, err
+		}
+
+		if messageOrgID, ok := consumerErrorOrgID(message); ok && messageOrgID == orgID {
+			keys = append(keys, key)
+		}
+	}
+	closeRows(rows)
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM consumer_error WHERE topic = $1 AND partition = $2 AND topic_offset = $3;",
+			key.topic, key.partition, key.offset,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(keys)), nil
+}
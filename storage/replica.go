@@ -0,0 +1,248 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// DefaultReplicaLag is the time when the writes of the user are read from the primary
+// database if the replica lag is not configured
+const DefaultReplicaLag = 10 * time.Second
+
+// recentWritesSize is the maximal number of tracked clusters and users that wrote recently,
+// reads of the users that are not tracked any more are served by the replica
+const recentWritesSize = 10000
+
+// replicaDataSource returns data source of the read replica, empty string if there's no replica
+func replicaDataSource(configuration Configuration, driverType types.DBDriver) string {
+	switch driverType {
+	case types.DBDriverSQLite3:
+		return configuration.SQLiteReplicaDataSource
+	case types.DBDriverPostgres:
+		if configuration.PGReplicaHost == "" {
+			return ""
+		}
+
+		port := configuration.PGReplicaPort
+		if port == 0 {
+			port = configuration.PGPort
+		}
+
+		return fmt.Sprintf(
+			"postgresql://%v:%v@%v:%v/%v?%v",
+			configuration.PGUsername,
+			configuration.PGPassword,
+			configuration.PGReplicaHost,
+			port,
+			configuration.PGDBName,
+			configuration.PGParams,
+		)
+	default:
+		return ""
+	}
+}
+
+// configuredReplicaLag returns the replica lag if a read replica is configured, zero otherwise
+func configuredReplicaLag(configuration Configuration) time.Duration {
+	if configuration.SQLiteReplicaDataSource == "" && configuration.PGReplicaHost == "" {
+		return 0
+	}
+
+	if configuration.ReplicaLag <= 0 {
+		return DefaultReplicaLag
+	}
+
+	return configuration.ReplicaLag
+}
+
+// setConnectionPool sizes the pool of the connections, zero values keep the defaults
+func setConnectionPool(connection *sql.DB, maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) {
+	if maxOpenConns > 0 {
+		connection.SetMaxOpenConns(maxOpenConns)
+	}
+
+	if maxIdleConns > 0 {
+		connection.SetMaxIdleConns(maxIdleConns)
+	}
+
+	if connMaxLifetime > 0 {
+		connection.SetConnMaxLifetime(connMaxLifetime)
+	}
+}
+
+// recentWrites is a concurrency-safe bounded set of clusters and users that voted
+// or toggled a rule recently, so their reads have to be served by the primary
+// database until the replica catches up. Organizations whose rules were disabled
+// or enabled for all clusters are tracked too.
+type recentWrites struct {
+	mutex sync.Mutex
+	lag   time.Duration
+	lru   *lruCache
+	// lastOrgWrite is the time of the last write recorded by addForOrg,
+	// the organization of the cluster is looked up only after it
+	lastOrgWrite time.Time
+}
+
+func newRecentWrites(lag time.Duration) *recentWrites {
+	if lag <= 0 {
+		lag = DefaultReplicaLag
+	}
+
+	return &recentWrites{lag: lag, lru: newLRUCache(recentWritesSize)}
+}
+
+// orgWriteKey is the key of recentWrites recording writes of the rule disables of the organization
+type orgWriteKey struct {
+	orgID types.OrgID
+}
+
+// add records that the user wrote data related to the cluster just now
+func (writes *recentWrites) add(clusterName types.ClusterName, userID types.UserID) {
+	if writes == nil {
+		return
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	writes.lru.add(clusterUserKey{clusterName, userID}, time.Now())
+}
+
+// addForOrg records that the rule was disabled or enabled on all clusters of the organization,
+// either for a single user or for all its users
+func (writes *recentWrites) addForOrg(orgID types.OrgID) {
+	if writes == nil {
+		return
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	writes.lastOrgWrite = time.Now()
+	writes.lru.add(orgWriteKey{orgID}, writes.lastOrgWrite)
+}
+
+// isRecent checks whether the user wrote data related to the cluster within the replica lag
+func (writes *recentWrites) isRecent(clusterName types.ClusterName, userID types.UserID) bool {
+	if writes == nil {
+		return false
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	return writes.isRecentLocked(clusterUserKey{clusterName, userID})
+}
+
+// anyRecentForOrg checks whether the rule disables of any organization were written within the replica lag
+func (writes *recentWrites) anyRecentForOrg() bool {
+	if writes == nil {
+		return false
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	return time.Since(writes.lastOrgWrite) <= writes.lag
+}
+
+// isRecentForOrg checks whether the rule disables of the organization were written within the replica lag
+func (writes *recentWrites) isRecentForOrg(orgID types.OrgID) bool {
+	if writes == nil {
+		return false
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	return writes.isRecentLocked(orgWriteKey{orgID})
+}
+
+func (writes *recentWrites) isRecentLocked(key interface{}) bool {
+	value, found := writes.lru.get(key)
+	if !found {
+		return false
+	}
+
+	if time.Since(value.(time.Time)) > writes.lag {
+		writes.lru.remove(key)
+		return false
+	}
+
+	return true
+}
+
+// readConnection returns the connection for the queries that can be served by the replica
+func (storage DBStorage) readConnection() *sql.DB {
+	if storage.replicaConnection != nil {
+		return storage.replicaConnection
+	}
+
+	return storage.connection
+}
+
+// userReadConnection returns the connection for the queries reading the feedback and toggles
+// of the user for the cluster, the primary is used for some time after the user wrote them
+func (storage DBStorage) userReadConnection(clusterName types.ClusterName, userID types.UserID) *sql.DB {
+	if storage.recentWrites.isRecent(clusterName, userID) {
+		return storage.connection
+	}
+
+	return storage.readConnection()
+}
+
+// clusterReadConnection returns the connection for the queries reading the toggles and the rule
+// disables of the user for the cluster. The primary is used for some time after the user toggled
+// a rule for the cluster and also after rules were disabled or enabled for the organization of the cluster.
+func (storage DBStorage) clusterReadConnection(
+	ctx context.Context, clusterName types.ClusterName, userID types.UserID,
+) *sql.DB {
+	if storage.recentWrites.isRecent(clusterName, userID) {
+		return storage.connection
+	}
+
+	// the organization is looked up only when there is a recent write of any organization
+	if !storage.recentWrites.anyRecentForOrg() {
+		return storage.readConnection()
+	}
+
+	var orgID types.OrgID
+	err := storage.readConnection().QueryRowContext(ctx,
+		"SELECT org_id FROM report WHERE cluster = $1", clusterName,
+	).Scan(&orgID)
+	// the cluster that is not replicated yet is read from the primary
+	if err != nil || storage.recentWrites.isRecentForOrg(orgID) {
+		return storage.connection
+	}
+
+	return storage.readConnection()
+}
+
+// orgReadConnection returns the connection for the queries reading the rule disables
+// of the organization, the primary is used for some time after they were written
+func (storage DBStorage) orgReadConnection(orgID types.OrgID) *sql.DB {
+	if storage.recentWrites.isRecentForOrg(orgID) {
+		return storage.connection
+	}
+
+	return storage.readConnection()
+}
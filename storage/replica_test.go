@@ -0,0 +1,201 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustGetStorageWithReplica returns storage whose replica is a separate database,
+// so it's possible to check which of them serves the queries. The replica is returned too.
+func mustGetStorageWithReplica(t *testing.T, replicaLag time.Duration) (*storage.DBStorage, storage.Storage, func()) {
+	primaryStorage, closePrimary := helpers.MustGetSQLiteMemoryStorage(t, true)
+	replicaStorage, _ := helpers.MustGetSQLiteMemoryStorage(t, true)
+
+	dbStorage := primaryStorage.(*storage.DBStorage)
+	storage.SetReplicaConnection(dbStorage, storage.GetConnection(replicaStorage.(*storage.DBStorage)), replicaLag)
+
+	// closing the primary storage closes the replica connection too
+	return dbStorage, replicaStorage, closePrimary
+}
+
+func TestNewStorageWithReplica(t *testing.T) {
+	s, err := storage.New(storage.Configuration{
+		Driver:                  "sqlite3",
+		SQLiteDataSource:        ":memory:",
+		SQLiteReplicaDataSource: ":memory:",
+		MaxOpenConns:            3,
+		ReplicaMaxOpenConns:     5,
+	})
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, s.Close()) }()
+
+	assert.Equal(t, 3, storage.GetConnection(s).Stats().MaxOpenConnections)
+	assert.Equal(t, 5, storage.GetReplicaConnection(s).Stats().MaxOpenConnections)
+}
+
+func TestNewStorageWithoutReplica(t *testing.T) {
+	s, err := storage.New(storage.Configuration{
+		Driver:           "sqlite3",
+		SQLiteDataSource: ":memory:",
+	})
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, s.Close()) }()
+
+	assert.Nil(t, storage.GetReplicaConnection(s))
+}
+
+func TestDBStorageReadsReportsFromReplica(t *testing.T) {
+	s, replicaStorage, closer := mustGetStorageWithReplica(t, time.Minute)
+	defer closer()
+
+	mustWriteReport3Rules(t, s)
+
+	// the report is not replicated yet
+	_, _, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	orgs, err := s.ListOfOrgs()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, orgs)
+
+	_, err = s.GetRuleByID(testdata.Rule1ID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// the offset is read from the primary
+	offset, err := s.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.KafkaOffset, offset)
+
+	mustWriteReport3Rules(t, replicaStorage)
+
+	report, _, err := s.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, report)
+
+	_, err = s.GetRuleByID(testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageReadsOwnVotesFromPrimary(t *testing.T) {
+	s, _, closer := mustGetStorageWithReplica(t, time.Minute)
+	defer closer()
+
+	mustWriteReport3Rules(t, s)
+
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+	helpers.FailOnError(t, s.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.UserID, storage.RuleToggleDisable,
+	))
+
+	feedback, err := s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+
+	toggle, err := s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+
+	// other users read from the replica
+	_, err = s.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID, testdata.User2ID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorageReadsVotesFromReplicaAfterLag(t *testing.T) {
+	s, _, closer := mustGetStorageWithReplica(t, time.Nanosecond)
+	defer closer()
+
+	mustWriteReport3Rules(t, s)
+
+	helpers.FailOnError(t, s.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID, types.UserVoteLike))
+	time.Sleep(time.Millisecond)
+
+	_, err := s.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorageReadsOwnRuleDisablesFromPrimary(t *testing.T) {
+	s, _, closer := mustGetStorageWithReplica(t, time.Minute)
+	defer closer()
+
+	mustWriteReport3Rules(t, s)
+
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.UserID))
+
+	disables, err := s.ListRuleDisablesForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disables, 1)
+
+	rules, err := s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+
+	// other users read from the replica
+	rules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.User2ID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+
+	// the rule disabled for the whole organization is read from the primary by all users
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule2ID, ""))
+
+	rules, err = s.ListDisabledRulesForCluster(testdata.ClusterName, testdata.User2ID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+}
+
+func TestDBStorageReadsRuleDisablesOfOtherOrgsFromReplica(t *testing.T) {
+	s, replicaStorage, closer := mustGetStorageWithReplica(t, time.Minute)
+	defer closer()
+
+	const otherOrgID = types.OrgID(2)
+	otherCluster := testdata.GetRandomClusterID()
+	for _, dbStorage := range []storage.Storage{s, replicaStorage} {
+		helpers.FailOnError(t, dbStorage.WriteReportForCluster(
+			otherOrgID, otherCluster, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+		))
+		helpers.FailOnError(t, dbStorage.LoadRuleContent(testdata.RuleContent3Rules))
+	}
+
+	// the disable of the other organization written by another instance isn't replicated yet
+	_, err := storage.GetConnection(s).Exec(
+		"INSERT INTO rule_disable(org_id, rule_id, user_id, disabled_at) VALUES ($1, $2, '', $3)",
+		otherOrgID, testdata.Rule1ID, time.Now(),
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, s.DisableRuleForOrg(testdata.OrgID, testdata.Rule2ID, ""))
+
+	// the recent disable of the first organization doesn't affect reads of the other one
+	rules, err := s.ListDisabledRulesForCluster(otherCluster, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+
+	content, err := s.GetContentForRules(
+		reportRules3Rules(), testdata.UserID, otherCluster, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, content, 3)
+	for _, rule := range content {
+		assert.False(t, rule.Disabled)
+	}
+}
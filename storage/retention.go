@@ -0,0 +1,105 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// PurgedClusters summarizes the data of the clusters removed by PurgeStaleClusters,
+// the numbers are the removed rows of every table
+type PurgedClusters struct {
+	Clusters []types.ClusterName
+	Reports  int64
+	Feedback int64
+	Toggles  int64
+}
+
+// PurgeStaleClusters removes reports of the clusters that were last checked before the given time
+// together with the feedback and toggles of their rules. Nothing is removed in the dry run mode,
+// the data that would be removed are just counted.
+func (storage DBStorage) PurgeStaleClusters(lastCheckedBefore time.Time, dryRun bool) (PurgedClusters, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	var purged PurgedClusters
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return purged, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT cluster FROM report WHERE last_checked_at < $1 ORDER BY cluster;", lastCheckedBefore)
+	if err != nil {
+		_ = tx.Rollback()
+		return purged, err
+	}
+
+	for rows.Next() {
+		var clusterName types.ClusterName
+		if err := rows.Scan(&clusterName); err != nil {
+			closeRows(rows)
+			_ = tx.Rollback()
+			return purged, err
+		}
+
+		purged.Clusters = append(purged.Clusters, clusterName)
+	}
+	closeRows(rows)
+
+	if err := rows.Err(); err != nil || len(purged.Clusters) == 0 {
+		_ = tx.Rollback()
+		return purged, err
+	}
+
+	// feedback and toggles have to be removed first, they're selected by the stale reports
+	const staleClusters = "IN (SELECT cluster FROM report WHERE last_checked_at < $1)"
+	tables := []struct {
+		condition string
+		removed   *int64
+	}{
+		{"cluster_rule_user_feedback WHERE cluster_id " + staleClusters, &purged.Feedback},
+		{"cluster_rule_toggle WHERE cluster_id " + staleClusters, &purged.Toggles},
+		{"report WHERE last_checked_at < $1", &purged.Reports},
+	}
+
+	for _, table := range tables {
+		if dryRun {
+			err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %v;", table.condition), lastCheckedBefore).
+				Scan(table.removed)
+		} else {
+			var result sql.Result
+			result, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v;", table.condition), lastCheckedBefore)
+			if err == nil {
+				*table.removed, err = result.RowsAffected()
+			}
+		}
+
+		if err != nil {
+			_ = tx.Rollback()
+			return PurgedClusters{}, err
+		}
+	}
+
+	if dryRun {
+		return purged, tx.Rollback()
+	}
+
+	return purged, tx.Commit()
+}
@@ -15,25 +15,38 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
-// GetRuleWithContent returns rule with content for provided ruleID and ruleErrorKey
-func (storage DBStorage) GetRuleWithContent(ruleID types.RuleID, ruleErrorKey types.ErrorKey) (*types.RuleWithContent, error) {
+// GetRuleWithContent returns rule with content for provided ruleID and ruleErrorKey,
+// the texts are translated to the language of the filter if the translation is available.
+// Rules that aren't visible according to the filter are not found.
+func (storage DBStorage) GetRuleWithContent(
+	ruleID types.RuleID, ruleErrorKey types.ErrorKey, filter RuleContentFilter,
+) (*types.RuleWithContent, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var (
 		result             types.RuleWithContent
 		impact, likelihood int
 		tags               string
 	)
 
-	err := storage.connection.QueryRow(`
+	visibilityCondition, args := constructRuleVisibilityCondition(
+		filter, []interface{}{filter.Language, ruleID, ruleErrorKey},
+	)
+
+	err := storage.readConnection().QueryRowContext(ctx, fmt.Sprintf(`
 		SELECT
-			rule.module,
-			rule.name,
-			rule.summary,
-			rule.reason,
-			rule.resolution,
-			rule.more_info,
+			r.module,
+			r.name,
+			COALESCE(rt.summary, r.summary),
+			COALESCE(rt.reason, r.reason),
+			COALESCE(rt.resolution, r.resolution),
+			COALESCE(rt.more_info, r.more_info),
 			rek.error_key,
 			rek.condition,
 			rek.description,
@@ -41,12 +54,15 @@ func (storage DBStorage) GetRuleWithContent(ruleID types.RuleID, ruleErrorKey ty
 			rek.likelihood,
 			rek.publish_date,
 			rek.active,
-			rek.generic,
+			COALESCE(rekt.generic, rek.generic),
 			rek.tags
-		FROM rule
-		INNER JOIN rule_error_key rek ON rule.module = rek.rule_module
-		WHERE rule.module = $1 AND rek.error_key = $2
-	`, ruleID, ruleErrorKey).Scan(
+		FROM rule r
+		INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+		LEFT JOIN rule_translation rt ON r.module = rt.rule_module AND rt.language = $1
+		LEFT JOIN rule_error_key_translation rekt
+			ON rek.rule_module = rekt.rule_module AND rek.error_key = rekt.error_key AND rekt.language = $1
+		WHERE r.module = $2 AND rek.error_key = $3 AND %v
+	`, visibilityCondition), args...).Scan(
 		&result.Module,
 		&result.Name,
 		&result.Summary,
@@ -73,3 +89,32 @@ func (storage DBStorage) GetRuleWithContent(ruleID types.RuleID, ruleErrorKey ty
 
 	return &result, nil
 }
+
+// GetContentLanguages returns languages of all available rule content translations
+func (storage DBStorage) GetContentLanguages() ([]string, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	languages := make([]string, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT language FROM rule_translation
+		UNION
+		SELECT language FROM rule_error_key_translation
+		ORDER BY language
+	`)
+	if err != nil {
+		return languages, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			return languages, err
+		}
+		languages = append(languages, language)
+	}
+
+	return languages, rows.Err()
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// RuleDisableScopeCluster is the scope of the rule disabled for one cluster by ToggleRuleForCluster
+	RuleDisableScopeCluster = "cluster"
+	// RuleDisableScopeUser is the scope of the rule disabled for one user on all clusters of the organization
+	RuleDisableScopeUser = "user"
+	// RuleDisableScopeOrganization is the scope of the rule disabled for all users on all clusters of the organization
+	RuleDisableScopeOrganization = "organization"
+)
+
+// RuleDisable represents a record from rule_disable, i.e. the rule disabled on all clusters
+// of the organization. UserID is empty when the rule is disabled for all users of the organization.
+//
+// The rule is disabled for the cluster and the user if it's disabled on any of the levels, so
+// enabling the rule for one cluster doesn't show it when it's disabled for the whole organization.
+type RuleDisable struct {
+	OrgID      types.OrgID
+	RuleID     types.RuleID
+	UserID     types.UserID
+	DisabledAt time.Time
+}
+
+// Scope returns RuleDisableScopeOrganization or RuleDisableScopeUser according to the user of the disable
+func (disable RuleDisable) Scope() string {
+	if disable.UserID == "" {
+		return RuleDisableScopeOrganization
+	}
+	return RuleDisableScopeUser
+}
+
+// DisableRuleForOrg disables the rule on all clusters of the organization, for all users
+// of the organization if userID is empty or just for the user otherwise
+func (storage DBStorage) DisableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	var query string
+
+	switch storage.dbDriverType {
+	case types.DBDriverSQLite3, types.DBDriverPostgres:
+		query = `
+			INSERT INTO rule_disable(org_id, rule_id, user_id, disabled_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (org_id, rule_id, user_id) DO UPDATE SET
+				disabled_at = $4
+		`
+	default:
+		return fmt.Errorf("DB driver %v is not supported", storage.dbDriverType)
+	}
+
+	_, err := storage.connection.ExecContext(ctx, query, orgID, ruleID, userID, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Error during execution SQL exec for rule disable")
+		return err
+	}
+
+	storage.recentWrites.addForOrg(orgID)
+
+	return nil
+}
+
+// EnableRuleForOrg enables the rule disabled by DisableRuleForOrg with the same arguments,
+// toggles of the rule for single clusters and disables on the other level are kept
+func (storage DBStorage) EnableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		DELETE FROM rule_disable
+		WHERE org_id = $1 AND rule_id = $2 AND user_id = $3
+	`, orgID, ruleID, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error during execution SQL exec for rule enable")
+		return err
+	}
+
+	storage.recentWrites.addForOrg(orgID)
+
+	return nil
+}
+
+// ListRuleDisablesForOrg returns the rules disabled for the organization and for its users,
+// ordered by the rule and the user
+func (storage DBStorage) ListRuleDisablesForOrg(orgID types.OrgID) ([]RuleDisable, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	rows, err := storage.orgReadConnection(orgID).QueryContext(ctx, `
+		SELECT rule_id, user_id, disabled_at
+		FROM rule_disable
+		WHERE org_id = $1
+		ORDER BY rule_id, user_id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	disables := []RuleDisable{}
+	for rows.Next() {
+		disable := RuleDisable{OrgID: orgID}
+
+		err := rows.Scan(&disable.RuleID, &disable.UserID, &disable.DisabledAt)
+		if err != nil {
+			return nil, err
+		}
+
+		disables = append(disables, disable)
+	}
+
+	return disables, rows.Err()
+}
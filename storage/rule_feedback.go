@@ -66,6 +66,9 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 	userVotePtr *types.UserVote,
 	messagePtr *string,
 ) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	updateVote := false
 	updateMessage := false
 	userVote := types.UserVoteNone
@@ -86,7 +89,7 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 		return err
 	}
 
-	statement, err := storage.connection.Prepare(query)
+	statement, err := storage.connection.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -99,13 +102,14 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 
 	now := time.Now()
 
-	_, err = statement.Exec(clusterID, ruleID, userID, userVote, now, now, message)
+	_, err = statement.ExecContext(ctx, clusterID, ruleID, userID, userVote, now, now, message)
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForCluster")
 		return err
 	}
 
+	storage.recentWrites.add(clusterID, userID)
 	metrics.FeedbackOnRules.Inc()
 
 	return nil
@@ -148,9 +152,12 @@ func (storage DBStorage) constructUpsertClusterRuleUserFeedback(updateVote bool,
 func (storage DBStorage) GetUserFeedbackOnRule(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) (*UserFeedbackOnRule, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	feedback := UserFeedbackOnRule{}
 
-	err := storage.connection.QueryRow(
+	err := storage.userReadConnection(clusterID, userID).QueryRowContext(ctx,
 		`SELECT cluster_id, rule_id, user_id, message, user_vote, added_at, updated_at
 		FROM cluster_rule_user_feedback
 		WHERE cluster_id = $1 AND rule_id = $2 AND user_id = $3`,
@@ -181,6 +188,9 @@ func (storage DBStorage) GetUserFeedbackOnRule(
 func (storage DBStorage) GetUserFeedbackOnRules(
 	clusterID types.ClusterName, rulesContent []types.RuleContentResponse, userID types.UserID,
 ) (map[types.RuleID]types.UserVote, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	ruleIDs := make([]string, 0)
 	for _, v := range rulesContent {
 		ruleIDs = append(ruleIDs, v.RuleModule)
@@ -195,7 +205,7 @@ func (storage DBStorage) GetUserFeedbackOnRules(
 	whereInStatement := "'" + strings.Join([]string(ruleIDs), "','") + "'"
 	query = fmt.Sprintf(query, whereInStatement)
 
-	rows, err := storage.connection.Query(query, clusterID, userID)
+	rows, err := storage.userReadConnection(clusterID, userID).QueryContext(ctx, query, clusterID, userID)
 	if err != nil {
 		return feedbacks, err
 	}
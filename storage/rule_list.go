@@ -0,0 +1,208 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleListFilter selects rule error keys returned by ListRules,
+// zero values of the fields don't filter anything out
+type RuleListFilter struct {
+	Tag        string
+	Impact     int
+	Likelihood int
+	Active     *bool
+}
+
+// constructRuleListCondition returns SQL condition selecting rule error keys that are visible
+// according to the content filter and match the list filter, query parameters used
+// by the condition are appended to the args
+func constructRuleListCondition(
+	contentFilter RuleContentFilter, listFilter RuleListFilter, args []interface{},
+) (string, []interface{}) {
+	condition, args := constructRuleVisibilityCondition(contentFilter, args)
+
+	if listFilter.Tag != "" {
+		args = append(args, listFilter.Tag)
+		condition += fmt.Sprintf(" AND (',' || rek.tags || ',') LIKE ('%%,' || $%v || ',%%')", len(args))
+	}
+
+	if listFilter.Impact != 0 {
+		args = append(args, listFilter.Impact)
+		condition += fmt.Sprintf(" AND rek.impact = $%v", len(args))
+	}
+
+	if listFilter.Likelihood != 0 {
+		args = append(args, listFilter.Likelihood)
+		condition += fmt.Sprintf(" AND rek.likelihood = $%v", len(args))
+	}
+
+	if listFilter.Active != nil {
+		args = append(args, *listFilter.Active)
+		condition += fmt.Sprintf(" AND rek.active = $%v", len(args))
+	}
+
+	return condition, args
+}
+
+// ListRules returns page of rule error keys with content ordered by the rule module and error key,
+// and total number of rule error keys matching the filters. The texts are translated
+// to the language of the content filter if the translation is available.
+func (storage DBStorage) ListRules(
+	contentFilter RuleContentFilter, listFilter RuleListFilter, offset, limit int,
+) ([]types.RuleWithContent, int, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	rules := make([]types.RuleWithContent, 0)
+
+	countCondition, countArgs := constructRuleListCondition(contentFilter, listFilter, nil)
+
+	var total int
+	// #nosec G202
+	err := storage.readConnection().QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM rule r
+		INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+		WHERE `+countCondition, countArgs...,
+	).Scan(&total)
+	if err != nil {
+		return rules, 0, err
+	}
+
+	condition, args := constructRuleListCondition(
+		contentFilter, listFilter, []interface{}{contentFilter.Language},
+	)
+
+	// #nosec G201
+	query := fmt.Sprintf(`
+		SELECT
+			r.module,
+			r.name,
+			COALESCE(rt.summary, r.summary),
+			COALESCE(rt.reason, r.reason),
+			COALESCE(rt.resolution, r.resolution),
+			COALESCE(rt.more_info, r.more_info),
+			rek.error_key,
+			rek.condition,
+			rek.description,
+			rek.impact,
+			rek.likelihood,
+			rek.publish_date,
+			rek.active,
+			COALESCE(rekt.generic, rek.generic),
+			rek.tags
+		FROM rule r
+		INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+		LEFT JOIN rule_translation rt ON r.module = rt.rule_module AND rt.language = $1
+		LEFT JOIN rule_error_key_translation rekt
+			ON rek.rule_module = rekt.rule_module AND rek.error_key = rekt.error_key AND rekt.language = $1
+		WHERE %v
+		ORDER BY r.module, rek.error_key
+		LIMIT %d OFFSET %d
+	`, condition, limit, offset)
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return rules, 0, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			rule               types.RuleWithContent
+			impact, likelihood int
+			tags               string
+		)
+
+		err := rows.Scan(
+			&rule.Module,
+			&rule.Name,
+			&rule.Summary,
+			&rule.Reason,
+			&rule.Resolution,
+			&rule.MoreInfo,
+			&rule.ErrorKey,
+			&rule.Condition,
+			&rule.Description,
+			&impact,
+			&likelihood,
+			&rule.PublishDate,
+			&rule.Active,
+			&rule.Generic,
+			&tags,
+		)
+		if err != nil {
+			return rules, 0, err
+		}
+
+		rule.TotalRisk = calculateTotalRisk(impact, likelihood)
+		rule.Tags = commaSeparatedStrToTags(tags)
+		rules = append(rules, rule)
+	}
+
+	return rules, total, rows.Err()
+}
+
+// ListRuleTags returns all distinct tags of the rule error keys visible according to the filter
+// with number of the error keys having the tag, ordered by the tag
+func (storage DBStorage) ListRuleTags(filter RuleContentFilter) ([]types.RuleTagCount, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	tagCounts := make([]types.RuleTagCount, 0)
+
+	condition, args := constructRuleVisibilityCondition(filter, nil)
+
+	// #nosec G202
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT rek.tags
+		FROM rule r
+		INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+		WHERE `+condition, args...,
+	)
+	if err != nil {
+		return tagCounts, err
+	}
+	defer closeRows(rows)
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tags string
+		if err := rows.Scan(&tags); err != nil {
+			return tagCounts, err
+		}
+
+		for _, tag := range commaSeparatedStrToTags(tags) {
+			counts[tag]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return tagCounts, err
+	}
+
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, types.RuleTagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool {
+		return tagCounts[i].Tag < tagCounts[j].Tag
+	})
+
+	return tagCounts, nil
+}
@@ -0,0 +1,302 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// searchWordRegex matches words of the search query, everything else is ignored
+// so the query can't contain any syntax of the full-text search engines
+var searchWordRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// ruleSearchColumns are columns of RuleWithContent selected by the search queries
+const ruleSearchColumns = `
+	r.module, r.name, r.summary, r.reason, r.resolution, r.more_info,
+	rek.error_key, rek.condition, rek.description, rek.impact, rek.likelihood,
+	rek.publish_date, rek.active, rek.generic, rek.tags`
+
+// postgresSearchDocument is the text searched in PostgreSQL
+const postgresSearchDocument = `concat_ws(' ',
+	r.name, r.summary, r.reason, r.resolution, rek.description, replace(rek.tags, ',', ' '))`
+
+// SearchRules finds rules whose name, summary, reason, resolution, description or tags
+// contain all words of the query. Results are ordered by their relevance and contain
+// snippets of the matching content with the words highlighted in Markdown bold.
+// Rules that are not visible according to the filter are left out.
+func (storage DBStorage) SearchRules(
+	query string, filter RuleContentFilter, limit int,
+) ([]types.RuleSearchResult, error) {
+	words := searchWordRegex.FindAllString(strings.ToLower(query), -1)
+	if len(words) == 0 || limit <= 0 {
+		return []types.RuleSearchResult{}, nil
+	}
+
+	switch storage.dbDriverType {
+	case types.DBDriverPostgres:
+		return storage.searchRulesPostgres(words, filter, limit)
+	case types.DBDriverSQLite3:
+		return storage.searchRulesSQLite(words, filter, limit)
+	default:
+		return nil, fmt.Errorf("full-text search is not supported by DB driver %v", storage.dbDriverType)
+	}
+}
+
+func (storage DBStorage) searchRulesPostgres(
+	words []string, filter RuleContentFilter, limit int,
+) ([]types.RuleSearchResult, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	visibilityCondition, args := constructRuleVisibilityCondition(filter, []interface{}{strings.Join(words, " ")})
+
+	// #nosec G201
+	query := fmt.Sprintf(`
+		SELECT %v,
+			ts_rank(to_tsvector('english', %v), search_query) AS rank,
+			ts_headline('english', %v, search_query,
+				'StartSel="**", StopSel="**", MaxFragments=1, MaxWords=20, MinWords=5')
+		FROM rule r
+		INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+		CROSS JOIN plainto_tsquery('english', $1) search_query
+		WHERE to_tsvector('english', %v) @@ search_query AND %v
+		ORDER BY rank DESC, r.module, rek.error_key
+		LIMIT %d
+	`, ruleSearchColumns, postgresSearchDocument, postgresSearchDocument, postgresSearchDocument,
+		visibilityCondition, limit)
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	results := []types.RuleSearchResult{}
+	for rows.Next() {
+		result, err := scanRuleSearchResult(rows, &sql.NullFloat64{})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// isFTS5Used checks whether the SQLite search index uses FTS5 or older FTS4
+func (storage DBStorage) isFTS5Used() (bool, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	var definition string
+	err := storage.readConnection().QueryRowContext(ctx,
+		`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'rule_search'`,
+	).Scan(&definition)
+
+	return strings.Contains(strings.ToLower(definition), "fts5"), err
+}
+
+func (storage DBStorage) searchRulesSQLite(
+	words []string, filter RuleContentFilter, limit int,
+) ([]types.RuleSearchResult, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	fts5, err := storage.isFTS5Used()
+	if err != nil {
+		return nil, err
+	}
+
+	// words are matched as prefixes, so incomplete words can be used too
+	matchQuery := strings.Join(words, "* ") + "*"
+	visibilityCondition, args := constructRuleVisibilityCondition(filter, []interface{}{matchQuery})
+
+	// FTS4 doesn't have any ranking function, so number of matches computed
+	// from offsets of the matched words is used instead and results are sorted later
+	rankColumn, snippetColumn, orderAndLimit := "offsets(rule_search)",
+		"snippet(rule_search, '**', '**', '...', -1, 16)", ""
+	if fts5 {
+		rankColumn, snippetColumn = "-bm25(rule_search)", "snippet(rule_search, -1, '**', '**', '...', 16)"
+		orderAndLimit = fmt.Sprintf("ORDER BY search_rank DESC, r.module, rek.error_key LIMIT %d", limit)
+	}
+
+	// #nosec G201
+	query := fmt.Sprintf(`
+		SELECT %v, %v AS search_rank, %v
+		FROM rule_search
+		INNER JOIN rule r ON r.module = rule_search.rule_module
+		INNER JOIN rule_error_key rek
+			ON rek.rule_module = rule_search.rule_module AND rek.error_key = rule_search.error_key
+		WHERE rule_search MATCH $1 AND %v
+		%v
+	`, ruleSearchColumns, rankColumn, snippetColumn, visibilityCondition, orderAndLimit)
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	results := []types.RuleSearchResult{}
+	for rows.Next() {
+		var rank interface{} = &sql.NullFloat64{}
+		var offsets string
+		if !fts5 {
+			rank = &offsets
+		}
+
+		result, err := scanRuleSearchResult(rows, rank)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fts5 {
+			// offsets are 4 numbers for every match
+			result.Rank = float64(len(strings.Fields(offsets)) / 4)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !fts5 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Rank > results[j].Rank
+		})
+		if len(results) > limit {
+			results = results[:limit]
+		}
+	}
+
+	return results, nil
+}
+
+// scanRuleSearchResult scans the row with the rule, rank scanned into the rank destination and snippet,
+// the rank is set in the result if the destination is sql.NullFloat64
+func scanRuleSearchResult(rows *sql.Rows, rank interface{}) (types.RuleSearchResult, error) {
+	var (
+		result             types.RuleSearchResult
+		impact, likelihood int
+		tags               string
+		snippet            sql.NullString
+	)
+
+	err := rows.Scan(
+		&result.Module,
+		&result.Name,
+		&result.Summary,
+		&result.Reason,
+		&result.Resolution,
+		&result.MoreInfo,
+		&result.ErrorKey,
+		&result.Condition,
+		&result.Description,
+		&impact,
+		&likelihood,
+		&result.PublishDate,
+		&result.Active,
+		&result.Generic,
+		&tags,
+		rank,
+		&snippet,
+	)
+	if err != nil {
+		return result, err
+	}
+
+	result.TotalRisk = calculateTotalRisk(impact, likelihood)
+	result.Tags = commaSeparatedStrToTags(tags)
+	result.Snippet = snippet.String
+	if floatRank, ok := rank.(*sql.NullFloat64); ok {
+		result.Rank = floatRank.Float64
+	}
+
+	return result, nil
+}
+
+// ruleSearchInsert fills the SQLite search index with the rule content stored in the database
+const ruleSearchInsert = `
+	INSERT INTO rule_search(rule_module, error_key, name, summary, reason, resolution, description, tags)
+	SELECT
+		r.module, rek.error_key, r.name,
+		CAST(r.summary AS TEXT), CAST(r.reason AS TEXT), CAST(r.resolution AS TEXT),
+		rek.description, REPLACE(rek.tags, ',', ' ')
+	FROM rule r
+	INNER JOIN rule_error_key rek ON r.module = rek.rule_module
+`
+
+// rebuildRuleSearchIndex fills the SQLite search index with the loaded rule content,
+// PostgreSQL doesn't need any index as the content is searched directly
+func (storage DBStorage) rebuildRuleSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	if storage.dbDriverType != types.DBDriverSQLite3 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rule_search`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, ruleSearchInsert)
+
+	return err
+}
+
+// updateRuleSearchIndex replaces the SQLite search index of the single rule by its current content
+func (storage DBStorage) updateRuleSearchIndex(ctx context.Context, tx *sql.Tx, ruleModule types.RuleID) error {
+	if storage.dbDriverType != types.DBDriverSQLite3 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rule_search WHERE rule_module = $1`, ruleModule); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, ruleSearchInsert+"WHERE r.module = $1", ruleModule)
+
+	return err
+}
+
+// execRuleContentStatement executes the statement modifying the content of the rule
+// and updates the search index of the rule in the same transaction
+func (storage DBStorage) execRuleContentStatement(
+	ruleModule types.RuleID, query string, args ...interface{},
+) (sql.Result, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err == nil {
+		err = storage.updateRuleSearchIndex(ctx, tx, ruleModule)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return res, tx.Commit()
+}
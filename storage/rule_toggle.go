@@ -49,6 +49,8 @@ type ClusterRuleToggle struct {
 func (storage DBStorage) ToggleRuleForCluster(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID, ruleToggle RuleToggle,
 ) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
 
 	var query string
 	var enabledAt, disabledAt sql.NullTime
@@ -80,7 +82,7 @@ func (storage DBStorage) ToggleRuleForCluster(
 		return fmt.Errorf("DB driver %v is not supported", storage.dbDriverType)
 	}
 
-	_, err := storage.connection.Exec(
+	_, err := storage.connection.ExecContext(ctx,
 		query,
 		clusterID,
 		ruleID,
@@ -95,13 +97,19 @@ func (storage DBStorage) ToggleRuleForCluster(
 		return err
 	}
 
+	storage.recentWrites.add(clusterID, userID)
+
 	return nil
 }
 
-// ListDisabledRulesForCluster retrieves disabled rules for specified cluster
+// ListDisabledRulesForCluster retrieves rules disabled for specified cluster and user, i.e. disabled
+// either for the cluster or on all clusters of its organization for the user or for the whole organization.
+// The scope and time of the most specific disable are returned for rules disabled on more levels.
 func (storage DBStorage) ListDisabledRulesForCluster(
 	clusterID types.ClusterName, userID types.UserID,
 ) ([]types.DisabledRuleResponse, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
 
 	rules := make([]types.DisabledRuleResponse, 0)
 
@@ -110,18 +118,35 @@ func (storage DBStorage) ListDisabledRulesForCluster(
 		rek.rule_module,
 		rek.description,
 		rek.generic,
-		crt.disabled_at
+		crt.disabled_at,
+		rdu.disabled_at,
+		rdo.disabled_at
 	FROM
+		rule_error_key rek
+	LEFT JOIN
 		cluster_rule_toggle crt
-	INNER JOIN
-		rule_error_key rek ON crt.rule_id = rek.rule_module
+			ON crt.rule_id = rek.rule_module
+			AND crt.disabled = $1
+			AND crt.cluster_id = $2
+			AND crt.user_id = $3
+	LEFT JOIN
+		rule_disable rdu
+			ON rdu.rule_id = rek.rule_module
+			AND rdu.org_id = (SELECT org_id FROM report WHERE cluster = $2)
+			AND rdu.user_id = $3
+			AND rdu.user_id <> ''
+	LEFT JOIN
+		rule_disable rdo
+			ON rdo.rule_id = rek.rule_module
+			AND rdo.org_id = (SELECT org_id FROM report WHERE cluster = $2)
+			AND rdo.user_id = ''
 	WHERE
-		crt.disabled = $1 AND
-		crt.cluster_id = $2 AND
-		crt.user_id = $3
+		crt.rule_id IS NOT NULL OR
+		rdu.rule_id IS NOT NULL OR
+		rdo.rule_id IS NOT NULL
 	`
 
-	rows, err := storage.connection.Query(query, RuleToggleDisable, clusterID, userID)
+	rows, err := storage.clusterReadConnection(ctx, clusterID, userID).QueryContext(ctx, query, RuleToggleDisable, clusterID, userID)
 	if err != nil {
 		return rules, err
 	}
@@ -129,26 +154,47 @@ func (storage DBStorage) ListDisabledRulesForCluster(
 
 	for rows.Next() {
 		var rule types.DisabledRuleResponse
+		var clusterDisabledAt, userDisabledAt, orgDisabledAt sql.NullTime
 
 		err = rows.Scan(
 			&rule.RuleModule,
 			&rule.Description,
 			&rule.Generic,
-			&rule.DisabledAt,
+			&clusterDisabledAt,
+			&userDisabledAt,
+			&orgDisabledAt,
 		)
-		if err == nil {
-			rules = append(rules, rule)
-		} else {
+		if err != nil {
 			log.Error().Err(err).Msg("ListDisabledRulesForCluster")
+			continue
+		}
+
+		switch {
+		case clusterDisabledAt.Valid:
+			rule.DisabledAt, rule.Scope = formatDisabledAt(clusterDisabledAt.Time), RuleDisableScopeCluster
+		case userDisabledAt.Valid:
+			rule.DisabledAt, rule.Scope = formatDisabledAt(userDisabledAt.Time), RuleDisableScopeUser
+		default:
+			rule.DisabledAt, rule.Scope = formatDisabledAt(orgDisabledAt.Time), RuleDisableScopeOrganization
 		}
+
+		rules = append(rules, rule)
 	}
 	return rules, nil
 }
 
+// formatDisabledAt formats time of disabling the rule for DisabledRuleResponse
+func formatDisabledAt(disabledAt time.Time) string {
+	return disabledAt.UTC().Format(time.RFC3339Nano)
+}
+
 // GetFromClusterRuleToggle gets a rule from cluster_rule_toggle
 func (storage DBStorage) GetFromClusterRuleToggle(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) (*ClusterRuleToggle, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var disabledRule ClusterRuleToggle
 
 	query := `
@@ -168,7 +214,7 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 		user_id = $3
 	`
 
-	err := storage.connection.QueryRow(
+	err := storage.userReadConnection(clusterID, userID).QueryRowContext(ctx,
 		query,
 		clusterID,
 		ruleID,
@@ -193,6 +239,9 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 func (storage DBStorage) DeleteFromRuleClusterToggle(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	query := `
 	DELETE FROM
 		cluster_rule_toggle
@@ -201,6 +250,12 @@ func (storage DBStorage) DeleteFromRuleClusterToggle(
 		rule_id = $2 AND
 		user_id = $3
 	`
-	_, err := storage.connection.Exec(query, clusterID, ruleID, userID)
-	return err
+	_, err := storage.connection.ExecContext(ctx, query, clusterID, ruleID, userID)
+	if err != nil {
+		return err
+	}
+
+	storage.recentWrites.add(clusterID, userID)
+
+	return nil
 }
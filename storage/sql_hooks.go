@@ -21,12 +21,17 @@ import (
 	"database/sql"
 	sql_driver "database/sql/driver"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/gchaincl/sqlhooks"
 	"github.com/rs/zerolog"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 )
 
+// sqlHooks record duration and errors of all queries into metrics,
+// the queries are logged too when SQLQueriesLogger is set
 type sqlHooks struct {
 	SQLQueriesLogger *zerolog.Logger
 }
@@ -42,13 +47,94 @@ const (
 // second arg is params array
 const logFormatterString = "query `%+v` with params `%+v`"
 
+// otherQuery is used as the statement kind and the query name of queries that can't be normalized
+const otherQuery = "other"
+
+// normalizeQuery returns kind of the statement (e.g. select) and name of the query made of the kind
+// and the main table of the query (e.g. select_report), so they can be used as labels of metrics
+func normalizeQuery(query string) (statement, name string) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 || !isIdentifier(words[0]) {
+		return otherQuery, otherQuery
+	}
+	statement = words[0]
+
+	var tableKeyword string
+	switch statement {
+	case "select", "delete":
+		tableKeyword = "from"
+	case "insert":
+		tableKeyword = "into"
+	case "update":
+		return statement, statement + "_" + tableName(words[1:], 0)
+	default:
+		return statement, statement
+	}
+
+	for i, word := range words {
+		if word == tableKeyword {
+			return statement, statement + "_" + tableName(words, i+1)
+		}
+	}
+
+	return statement, statement
+}
+
+// tableName returns name of the table at the index of the words of the query,
+// subquery is returned for anything that is not a name of a table
+func tableName(words []string, index int) string {
+	if index >= len(words) {
+		return otherQuery
+	}
+
+	// the name can be followed by list of columns without any space, e.g. `INSERT INTO report(org_id, ...`
+	table := strings.Trim(strings.SplitN(words[index], "(", 2)[0], `";`)
+	if !isIdentifier(table) {
+		return "subquery"
+	}
+
+	return table
+}
+
+// isIdentifier checks that the word is a non-empty SQL identifier without quotes
+func isIdentifier(word string) bool {
+	if word == "" {
+		return false
+	}
+
+	for _, char := range word {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// queryDuration returns the time elapsed since the query began according to the context
+func queryDuration(ctx context.Context) time.Duration {
+	beginTime, ok := ctx.Value(sqlHooksKeyQueryBeginTime).(time.Time)
+	if !ok {
+		return 0
+	}
+
+	return time.Since(beginTime)
+}
+
+// formatArgs returns the arguments of the query formatted for the log, as JSON if possible
+func formatArgs(args []interface{}) interface{} {
+	jsonArgs, err := json.Marshal(args)
+	if err != nil {
+		return args
+	}
+
+	return string(jsonArgs)
+}
+
 // Before is called before the query was executed allowing yout to log what you asked db to do
 func (h *sqlHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	jsonArgs, err := json.Marshal(args)
-	if err == nil {
-		h.SQLQueriesLogger.Printf(logFormatterString+"\n", query, string(jsonArgs))
-	} else {
-		h.SQLQueriesLogger.Printf(logFormatterString+"\n", query, args)
+	if h.SQLQueriesLogger != nil {
+		h.SQLQueriesLogger.Printf(logFormatterString+"\n", query, formatArgs(args))
 	}
 
 	return context.WithValue(ctx, sqlHooksKeyQueryBeginTime, time.Now()), nil
@@ -57,48 +143,74 @@ func (h *sqlHooks) Before(ctx context.Context, query string, args ...interface{}
 // After is called after the query was executed showing only successful ones
 // it allows you to see how long your query took
 func (h *sqlHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	beginTime := ctx.Value(sqlHooksKeyQueryBeginTime).(time.Time)
+	duration := queryDuration(ctx)
 
-	jsonArgs, err := json.Marshal(args)
-	if err == nil {
+	statement, name := normalizeQuery(query)
+	metrics.SQLQueryDuration.WithLabelValues(statement, name).Observe(duration.Seconds())
+
+	if h.SQLQueriesLogger != nil {
 		h.SQLQueriesLogger.Printf(
 			logFormatterString+" took %s\n",
-			query, string(jsonArgs), time.Since(beginTime),
+			query, formatArgs(args), duration,
 		)
-	} else {
+	}
+
+	return ctx, nil
+}
+
+// OnError is called instead of After when the query failed, the error is returned unchanged
+func (h *sqlHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	// the driver asks to run the query in another way, it's not a failure
+	if err == sql_driver.ErrSkip {
+		return err
+	}
+
+	duration := queryDuration(ctx)
+
+	statement, name := normalizeQuery(query)
+	metrics.SQLQueryDuration.WithLabelValues(statement, name).Observe(duration.Seconds())
+	metrics.SQLQueryErrors.WithLabelValues(statement, name).Inc()
+
+	if h.SQLQueriesLogger != nil {
 		h.SQLQueriesLogger.Printf(
-			logFormatterString+" took %s\n",
-			query, args, time.Since(beginTime),
+			logFormatterString+" failed after %s: %v\n",
+			query, formatArgs(args), duration, err,
 		)
 	}
 
-	return ctx, nil
+	return err
 }
 
-// InitSQLDriverWithLogs initializes wrapped version of driver with logging sql queries
-// and returns its name
-func InitSQLDriverWithLogs(
-	realDriver sql_driver.Driver,
-	realDriverName string,
-	logger *zerolog.Logger,
-) string {
+// initSQLDriverWithHooks registers the driver wrapped by the hooks under the name unless
+// it's registered already and returns the name
+func initSQLDriverWithHooks(realDriver sql_driver.Driver, hooksDriverName string, hooks *sqlHooks) string {
 	// linear search is not gonna be an issue since there's not many drivers
 	// and we call New() only ones/twice per process life
-	foundHooksDriver := false
-	hooksDriverName := realDriverName + "WithHooks"
-
 	for _, existingDriver := range sql.Drivers() {
 		if existingDriver == hooksDriverName {
-			foundHooksDriver = true
-			break
+			return hooksDriverName
 		}
 	}
 
-	if !foundHooksDriver {
-		sql.Register(hooksDriverName, sqlhooks.Wrap(realDriver, &sqlHooks{
-			SQLQueriesLogger: logger,
-		}))
-	}
+	sql.Register(hooksDriverName, sqlhooks.Wrap(realDriver, hooks))
 
 	return hooksDriverName
 }
+
+// InitSQLDriverWithLogs initializes wrapped version of driver with logging sql queries
+// and recording their metrics and returns its name
+func InitSQLDriverWithLogs(
+	realDriver sql_driver.Driver,
+	realDriverName string,
+	logger *zerolog.Logger,
+) string {
+	return initSQLDriverWithHooks(realDriver, realDriverName+"WithHooks", &sqlHooks{
+		SQLQueriesLogger: logger,
+	})
+}
+
+// InitSQLDriverWithMetrics initializes wrapped version of driver recording metrics
+// of sql queries and returns its name
+func InitSQLDriverWithMetrics(realDriver sql_driver.Driver, realDriverName string) string {
+	return initSQLDriverWithHooks(realDriver, realDriverName+"WithMetrics", &sqlHooks{})
+}
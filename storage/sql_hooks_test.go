@@ -19,6 +19,9 @@ package storage_test
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -29,9 +32,13 @@ import (
 
 	"github.com/lib/pq"
 	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	prommodels "github.com/prometheus/client_model/go"
 
 	"github.com/rs/zerolog"
 
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/stretchr/testify/assert"
 )
@@ -139,3 +146,91 @@ func TestSQLHooksLoggingArgsNotJSON(t *testing.T) {
 		fmt.Sprintf(storage.LogFormatterString, query, params)+" took",
 	)
 }
+
+func TestNormalizeQuery(t *testing.T) {
+	for query, expected := range map[string][2]string{
+		"SELECT report, last_checked_at FROM report WHERE org_id = $1":  {"select", "select_report"},
+		"\n\t\tselect count(*) from\n cluster_rule_toggle;":             {"select", "select_cluster_rule_toggle"},
+		"SELECT cluster FROM (SELECT cluster FROM report) AS r":         {"select", "select_subquery"},
+		`INSERT INTO report(org_id, cluster) VALUES($1, $2)`:            {"insert", "insert_report"},
+		`insert into "rule" (module) values ($1)`:                       {"insert", "insert_rule"},
+		"UPDATE webhook SET url = $1 WHERE id = $2":                     {"update", "update_webhook"},
+		"DELETE FROM cluster_rule_user_feedback WHERE cluster_id = $1;": {"delete", "delete_cluster_rule_user_feedback"},
+		"PRAGMA foreign_keys = ON":                                      {"pragma", "pragma"},
+		"SELECT 1":                                                      {"select", "select"},
+		"UPDATE":                                                        {"update", "update_other"},
+		"":                                                              {"other", "other"},
+		"(SELECT 1)":                                                    {"other", "other"},
+	} {
+		statement, name := storage.NormalizeQuery(query)
+		assert.Equal(t, expected, [2]string{statement, name}, query)
+	}
+}
+
+// getHistogramSampleCount returns number of observations of the histogram with the labels
+func getHistogramSampleCount(t *testing.T, histogramVec *prometheus.HistogramVec, labels ...string) uint64 {
+	metric := &prommodels.Metric{}
+	helpers.FailOnError(t, histogramVec.WithLabelValues(labels...).(prometheus.Histogram).Write(metric))
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestSQLHooksMetrics(t *testing.T) {
+	const query = "SELECT cluster FROM report"
+	hooks := storage.SQLHooks{}
+
+	observations := getHistogramSampleCount(t, metrics.SQLQueryDuration, "select", "select_report")
+	errorsCount := testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_report"))
+
+	ctx, err := hooks.Before(context.Background(), query)
+	helpers.FailOnError(t, err)
+	_, err = hooks.After(ctx, query)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, observations+1, getHistogramSampleCount(t, metrics.SQLQueryDuration, "select", "select_report"))
+	assert.Equal(t, errorsCount, testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_report")))
+
+	// the error is returned unchanged
+	queryErr := errors.New("query error")
+	ctx, err = hooks.Before(context.Background(), query)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, queryErr, hooks.OnError(ctx, queryErr, query))
+
+	assert.Equal(t, observations+2, getHistogramSampleCount(t, metrics.SQLQueryDuration, "select", "select_report"))
+	assert.Equal(t, errorsCount+1, testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_report")))
+
+	// the query is retried in another way, so it's not counted
+	assert.Equal(t, driver.ErrSkip, hooks.OnError(ctx, driver.ErrSkip, query))
+	assert.Equal(t, errorsCount+1, testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_report")))
+}
+
+func TestSQLHooksLoggingError(t *testing.T) {
+	const query = "SELECT 1"
+
+	buf := new(bytes.Buffer)
+	logger := zerolog.New(buf).With().Str("type", "SQL").Logger()
+	hooks := storage.SQLHooks{SQLQueriesLogger: &logger}
+
+	err := hooks.OnError(context.Background(), errors.New("query error"), query, 1)
+	assert.EqualError(t, err, "query error")
+	assert.Contains(t, buf.String(), fmt.Sprintf(storage.LogFormatterString, query, "[1]")+" failed after")
+	assert.Contains(t, buf.String(), "query error")
+}
+
+func TestInitSQLDriverWithMetrics(t *testing.T) {
+	driverName := storage.InitSQLDriverWithMetrics(&sqlite3.SQLiteDriver{}, "sqlite3")
+	assert.Equal(t, "sqlite3WithMetrics", driverName)
+
+	connection, err := sql.Open(driverName, ":memory:")
+	helpers.FailOnError(t, err)
+	defer func() { helpers.FailOnError(t, connection.Close()) }()
+
+	errorsCount := testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_non_existing_table"))
+
+	_, err = connection.Exec("SELECT * FROM non_existing_table")
+	assert.Error(t, err)
+
+	assert.Equal(
+		t, errorsCount+1, testutil.ToFloat64(metrics.SQLQueryErrors.WithLabelValues("select", "select_non_existing_table")),
+	)
+}
@@ -17,7 +17,8 @@ See the License for the specific language governing permissions and
 // (almost any) SQL database like PostgreSQL, SQLite, or MariaDB. An implementation
 // named DBStorage is constructed via New function and it is mandatory to call Close
 // for any opened connection to database. The storage might be initialized by Init
-// method if database schema is empty.
+// method if database schema is empty. MemoryStorage constructed via NewMemoryStorage
+// keeps the data in memory instead, it's meant for tests and local development.
 //
 // It is possible to configure connection to selected database by using Configuration
 // structure. Currently that structure contains two configurable parameter:
@@ -27,6 +28,7 @@ See the License for the specific language governing permissions and
 package storage
 
 import (
+	"context"
 	"database/sql"
 	sql_driver "database/sql/driver"
 	"fmt"
@@ -52,9 +54,13 @@ import (
 type Storage interface {
 	Init() error
 	Close() error
+	WithContext(ctx context.Context) Storage
 	ListOfOrgs() ([]types.OrgID, error)
 	ListOfClustersForOrg(orgID types.OrgID) ([]types.ClusterName, error)
 	ReadReportForCluster(orgID types.OrgID, clusterName types.ClusterName) (types.ClusterReport, types.Timestamp, error)
+	ReadReportForClusterFromPrimary(
+		orgID types.OrgID, clusterName types.ClusterName,
+	) (types.ClusterReport, types.Timestamp, error)
 	ReadReportForClusterByClusterName(clusterName types.ClusterName) (types.ClusterReport, types.Timestamp, error)
 	GetLatestKafkaOffset() (types.KafkaOffset, error)
 	WriteReportForCluster(
@@ -64,6 +70,7 @@ type Storage interface {
 		collectedAtTime time.Time,
 		kafkaOffset types.KafkaOffset,
 	) error
+	WriteReportsForClusters(reports []ReportWrite) ([]error, error)
 	ReportsCount() (int, error)
 	VoteOnRule(
 		clusterID types.ClusterName,
@@ -80,13 +87,18 @@ type Storage interface {
 	GetUserFeedbackOnRule(
 		clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 	) (*UserFeedbackOnRule, error)
+	ListFeedbackForCluster(clusterName types.ClusterName) ([]UserFeedbackOnRule, error)
 	GetContentForRules(
 		rules types.ReportRules,
 		userID types.UserID,
 		clusterName types.ClusterName,
+		filter RuleContentFilter,
 	) ([]types.RuleContentResponse, error)
+	GetSuppressedRulesCount(rules types.ReportRules, includeInternal bool) (int, error)
 	DeleteReportsForOrg(orgID types.OrgID) error
 	DeleteReportsForCluster(clusterName types.ClusterName) error
+	PurgeOrganization(orgID types.OrgID) (PurgedRows, error)
+	PurgeStaleClusters(lastCheckedBefore time.Time, dryRun bool) (PurgedClusters, error)
 	ToggleRuleForCluster(
 		clusterID types.ClusterName,
 		ruleID types.RuleID,
@@ -107,7 +119,13 @@ type Storage interface {
 		ruleID types.RuleID,
 		userID types.UserID,
 	) error
+	ListTogglesForCluster(clusterName types.ClusterName) ([]ClusterRuleToggle, error)
+	DisableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error
+	EnableRuleForOrg(orgID types.OrgID, ruleID types.RuleID, userID types.UserID) error
+	ListRuleDisablesForOrg(orgID types.OrgID) ([]RuleDisable, error)
 	LoadRuleContent(contentDir content.RuleContentDirectory) error
+	ReadRuleContent() (content.RuleContentDirectory, error)
+	GetContentVersion() (*types.ContentVersion, error)
 	GetRuleByID(ruleID types.RuleID) (*types.Rule, error)
 	GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error)
 	CreateRule(ruleData types.Rule) error
@@ -120,7 +138,45 @@ type Storage interface {
 		rulesContent []types.RuleContentResponse,
 		userID types.UserID,
 	) (map[types.RuleID]types.UserVote, error)
-	GetRuleWithContent(ruleID types.RuleID, ruleErrorKey types.ErrorKey) (*types.RuleWithContent, error)
+	GetRuleWithContent(
+		ruleID types.RuleID, ruleErrorKey types.ErrorKey, filter RuleContentFilter,
+	) (*types.RuleWithContent, error)
+	GetContentLanguages() ([]string, error)
+	SearchRules(query string, filter RuleContentFilter, limit int) ([]types.RuleSearchResult, error)
+	ListRules(
+		contentFilter RuleContentFilter, listFilter RuleListFilter, offset, limit int,
+	) ([]types.RuleWithContent, int, error)
+	ListRuleTags(filter RuleContentFilter) ([]types.RuleTagCount, error)
+	CreateWebhook(webhook types.Webhook) (types.WebhookID, error)
+	GetWebhook(orgID types.OrgID, webhookID types.WebhookID) (*types.Webhook, error)
+	ListWebhooksForOrg(orgID types.OrgID) ([]types.Webhook, error)
+	ListOrgsWithWebhooks() ([]types.OrgID, error)
+	UpdateWebhook(webhook types.Webhook) error
+	DeleteWebhook(orgID types.OrgID, webhookID types.WebhookID) error
+	WriteWebhookDelivery(delivery types.WebhookDelivery) error
+	ListWebhookDeliveries(webhookID types.WebhookID, limit int) ([]types.WebhookDelivery, error)
+}
+
+// RuleContentFilter selects rules returned by GetContentForRules, GetRuleWithContent
+// and other rule content queries in addition to the active and already published
+// rules that are visible to everyone
+type RuleContentFilter struct {
+	// IncludeInternal includes rules visible just to internal users
+	IncludeInternal bool
+	// IncludeInactive includes inactive rules and rules that are not published yet
+	IncludeInactive bool
+	// Language is the lower case language tag of the translation used for the texts,
+	// the English texts are used if it's empty or the translation is not available
+	Language string
+}
+
+// ReportWrite is a report of the cluster written by WriteReportsForClusters
+type ReportWrite struct {
+	OrgID           types.OrgID
+	ClusterName     types.ClusterName
+	Report          types.ClusterReport
+	LastCheckedTime time.Time
+	KafkaOffset     types.KafkaOffset
 }
 
 // DBStorage is an implementation of Storage interface that use selected SQL like database
@@ -130,10 +186,24 @@ type Storage interface {
 type DBStorage struct {
 	connection   *sql.DB
 	dbDriverType types.DBDriver
-	// clusterLastCheckedDict is a dictionary of timestamps when the clusters were last checked.
-	clustersLastChecked map[types.ClusterName]time.Time
+	// ctx is the context of the queries, they're canceled when it's done
+	ctx context.Context
+	// queryTimeout limits duration of every call of the storage, zero means no limit
+	queryTimeout time.Duration
+	// clustersLastChecked caches timestamps when the clusters were last checked, nil if it's disabled
+	clustersLastChecked *lastCheckedCache
+	// replicaConnection serves reads of reports and rule content, nil if there's no read replica
+	replicaConnection *sql.DB
+	// recentWrites tracks the users whose reads are served by the primary, nil if there's no read replica
+	recentWrites *recentWrites
 }
 
+// names of the databases used as labels of metrics of their connection pools
+const (
+	primaryDBName = "primary"
+	replicaDBName = "replica"
+)
+
 // New function creates and initializes a new instance of Storage interface
 func New(configuration Configuration) (*DBStorage, error) {
 	driverType, driverName, dataSource, err := initAndGetDriver(configuration)
@@ -152,7 +222,38 @@ func New(configuration Configuration) (*DBStorage, error) {
 		return nil, err
 	}
 
-	return NewFromConnection(connection, driverType), nil
+	setConnectionPool(
+		connection, configuration.MaxOpenConns, configuration.MaxIdleConns, configuration.ConnMaxLifetime,
+	)
+
+	storage := NewFromConnection(connection, driverType)
+	storage.queryTimeout = configuration.QueryTimeout
+	metrics.DBStats.SetSource(primaryDBName, connection)
+	storage.clustersLastChecked = newLastCheckedCache(configuration)
+
+	if replicaSource := replicaDataSource(configuration, driverType); replicaSource != "" {
+		log.Info().Msgf("Making connection to read replica of data storage, driver=%s", driverName)
+
+		replicaConnection, err := sql.Open(driverName, replicaSource)
+		if err != nil {
+			log.Error().Err(err).Msg("Can not connect to read replica of data storage")
+			_ = connection.Close()
+			return nil, err
+		}
+
+		setConnectionPool(
+			replicaConnection,
+			configuration.ReplicaMaxOpenConns,
+			configuration.ReplicaMaxIdleConns,
+			configuration.ReplicaConnMaxLifetime,
+		)
+
+		storage.replicaConnection = replicaConnection
+		storage.recentWrites = newRecentWrites(configuration.ReplicaLag)
+		metrics.DBStats.SetSource(replicaDBName, replicaConnection)
+	}
+
+	return storage, nil
 }
 
 // NewFromConnection function creates and initializes a new instance of Storage interface from prepared connection
@@ -160,8 +261,26 @@ func NewFromConnection(connection *sql.DB, dbDriverType types.DBDriver) *DBStora
 	return &DBStorage{
 		connection:          connection,
 		dbDriverType:        dbDriverType,
-		clustersLastChecked: map[types.ClusterName]time.Time{},
+		ctx:                 context.Background(),
+		clustersLastChecked: newLastCheckedCache(Configuration{}),
+	}
+}
+
+// WithContext returns the storage running its queries in the context,
+// so they're canceled when the context is done
+func (storage DBStorage) WithContext(ctx context.Context) Storage {
+	storage.ctx = ctx
+	return &storage
+}
+
+// queryContext returns context of single call of the storage limited by the query timeout,
+// the cancel function has to be called when the call is finished
+func (storage DBStorage) queryContext() (context.Context, context.CancelFunc) {
+	if storage.queryTimeout > 0 {
+		return context.WithTimeout(storage.ctx, storage.queryTimeout)
 	}
+
+	return context.WithCancel(storage.ctx)
 }
 
 // initAndGetDriver initializes driver(with logs if logSQLQueries is true),
@@ -187,6 +306,9 @@ func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, d
 			configuration.PGDBName,
 			configuration.PGParams,
 		)
+	case MemoryDriverName:
+		err = fmt.Errorf("driver %v is supported only by the service, not by the commands", driverName)
+		return
 	default:
 		err = fmt.Errorf("driver %v is not supported", driverName)
 		return
@@ -195,6 +317,8 @@ func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, d
 	if configuration.LogSQLQueries {
 		logger := zerolog.New(os.Stdout).With().Str("type", "SQL").Logger()
 		driverName = InitSQLDriverWithLogs(driver, driverName, &logger)
+	} else {
+		driverName = InitSQLDriverWithMetrics(driver, driverName)
 	}
 
 	return
@@ -213,8 +337,22 @@ func (storage DBStorage) MigrateToLatest() error {
 // Init performs all database initialization
 // tasks necessary for further service operation.
 func (storage DBStorage) Init() error {
-	// Read clusterName:LastChecked dictionary from DB.
-	rows, err := storage.connection.Query("SELECT cluster, last_checked_at FROM report;")
+	maxEntries := storage.clustersLastChecked.maxEntries()
+	if maxEntries == 0 {
+		return nil
+	}
+
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	// Read the most recently checked clusters from DB, the oldest of them are cached first
+	// so they're evicted first.
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT cluster, last_checked_at FROM (
+			SELECT cluster, last_checked_at FROM report ORDER BY last_checked_at DESC LIMIT $1
+		) AS recent
+		ORDER BY last_checked_at;
+	`, maxEntries)
 	if err != nil {
 		return err
 	}
@@ -232,7 +370,7 @@ func (storage DBStorage) Init() error {
 			return err
 		}
 
-		storage.clustersLastChecked[clusterName] = lastChecked
+		storage.clustersLastChecked.set(clusterName, lastChecked)
 	}
 
 	// Not using defer to close the rows here to:
@@ -244,7 +382,16 @@ func (storage DBStorage) Init() error {
 // Close method closes the connection to database. Needs to be called at the end of application lifecycle.
 func (storage DBStorage) Close() error {
 	log.Info().Msg("Closing connection to data storage")
+	if storage.replicaConnection != nil {
+		metrics.DBStats.RemoveSource(replicaDBName, storage.replicaConnection)
+		err := storage.replicaConnection.Close()
+		if err != nil {
+			log.Error().Err(err).Msg("Can not close connection to read replica of data storage")
+			return err
+		}
+	}
 	if storage.connection != nil {
+		metrics.DBStats.RemoveSource(primaryDBName, storage.connection)
 		err := storage.connection.Close()
 		if err != nil {
 			log.Error().Err(err).Msg("Can not close connection to data storage")
@@ -271,9 +418,12 @@ func closeRows(rows *sql.Rows) {
 
 // ListOfOrgs reads list of all organizations that have at least one cluster report
 func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	orgs := make([]types.OrgID, 0)
 
-	rows, err := storage.connection.Query("SELECT DISTINCT org_id FROM report ORDER BY org_id;")
+	rows, err := storage.readConnection().QueryContext(ctx, "SELECT DISTINCT org_id FROM report ORDER BY org_id;")
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		return orgs, err
@@ -295,9 +445,12 @@ func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
 
 // ListOfClustersForOrg reads list of all clusters fro given organization
 func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID) ([]types.ClusterName, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	clusters := make([]types.ClusterName, 0)
 
-	rows, err := storage.connection.Query("SELECT cluster FROM report WHERE org_id = $1 ORDER BY cluster;", orgID)
+	rows, err := storage.readConnection().QueryContext(ctx, "SELECT cluster FROM report WHERE org_id = $1 ORDER BY cluster;", orgID)
 	err = types.ConvertDBError(err, orgID)
 	if err != nil {
 		return clusters, err
@@ -319,7 +472,10 @@ func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID) ([]types.Cluste
 
 // GetOrgIDByClusterID reads OrgID for specified cluster
 func (storage DBStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
-	row := storage.connection.QueryRow("SELECT org_id FROM report WHERE cluster = $1 ORDER BY org_id;", cluster)
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	row := storage.readConnection().QueryRowContext(ctx, "SELECT org_id FROM report WHERE cluster = $1 ORDER BY org_id;", cluster)
 
 	var orgID uint64
 	err := row.Scan(&orgID)
@@ -334,10 +490,27 @@ func (storage DBStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.O
 func (storage DBStorage) ReadReportForCluster(
 	orgID types.OrgID, clusterName types.ClusterName,
 ) (types.ClusterReport, types.Timestamp, error) {
+	return storage.readReportForCluster(storage.readConnection(), orgID, clusterName)
+}
+
+// ReadReportForClusterFromPrimary reads the report like ReadReportForCluster, but never from the read replica,
+// so that the report is not older than the last one written
+func (storage DBStorage) ReadReportForClusterFromPrimary(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	return storage.readReportForCluster(storage.connection, orgID, clusterName)
+}
+
+func (storage DBStorage) readReportForCluster(
+	connection *sql.DB, orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var report string
 	var lastChecked time.Time
 
-	err := storage.connection.QueryRow(
+	err := connection.QueryRowContext(ctx,
 		"SELECT report, last_checked_at FROM report WHERE org_id = $1 AND cluster = $2;", orgID, clusterName,
 	).Scan(&report, &lastChecked)
 	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
@@ -349,10 +522,13 @@ func (storage DBStorage) ReadReportForCluster(
 func (storage DBStorage) ReadReportForClusterByClusterName(
 	clusterName types.ClusterName,
 ) (types.ClusterReport, types.Timestamp, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var report string
 	var lastChecked time.Time
 
-	err := storage.connection.QueryRow(
+	err := storage.readConnection().QueryRowContext(ctx,
 		"SELECT report, last_checked_at FROM report WHERE cluster = $1;", clusterName,
 	).Scan(&report, &lastChecked)
 
@@ -368,10 +544,14 @@ func (storage DBStorage) ReadReportForClusterByClusterName(
 	return types.ClusterReport(report), types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), nil
 }
 
-// GetLatestKafkaOffset returns latest kafka offset from report table
+// GetLatestKafkaOffset returns latest kafka offset from report table,
+// it's read from the primary database as the consumer continues from it
 func (storage DBStorage) GetLatestKafkaOffset() (types.KafkaOffset, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var offset types.KafkaOffset
-	err := storage.connection.QueryRow("SELECT COALESCE(MAX(kafka_offset), 0) FROM report;").Scan(&offset)
+	err := storage.connection.QueryRowContext(ctx, "SELECT COALESCE(MAX(kafka_offset), 0) FROM report;").Scan(&offset)
 	return offset, err
 }
 
@@ -381,7 +561,7 @@ func constructWhereClauseForContent(reportRules types.ReportRules) string {
 	if len(reportRules.HitRules) == 0 {
 		return "NULL" // WHERE NULL
 	}
-	statement := "(error_key, rule_module) IN (%v)"
+	statement := "(rek.error_key, rek.rule_module) IN (%v)"
 	var values string
 
 	for i, rule := range reportRules.HitRules {
@@ -430,12 +610,45 @@ func commaSeparatedStrToTags(str string) []string {
 	return strings.Split(str, ",")
 }
 
-// GetContentForRules retrieves content for rules that were hit in the report
+// constructRuleVisibilityCondition returns SQL condition excluding rules that
+// are not visible to the user, i.e. internal or inactive and unpublished ones.
+// Query parameters used by the condition are appended to the args.
+func constructRuleVisibilityCondition(filter RuleContentFilter, args []interface{}) (string, []interface{}) {
+	condition := "TRUE"
+
+	if !filter.IncludeInternal {
+		condition += " AND r.internal = FALSE"
+	}
+
+	// PostgreSQL refuses parameters that are not used in the query,
+	// so the current time is passed only when it's needed
+	if !filter.IncludeInactive {
+		args = append(args, time.Now().UTC())
+		condition += " AND " + activeRuleCondition(len(args))
+	}
+
+	return condition, args
+}
+
+// activeRuleCondition returns SQL condition for active and already published rule error keys,
+// the current time is passed as the query parameter with the given index
+func activeRuleCondition(nowParamIndex int) string {
+	return fmt.Sprintf("rek.active = TRUE AND rek.publish_date <= $%v", nowParamIndex)
+}
+
+// GetContentForRules retrieves content for rules that were hit in the report,
+// the rules that aren't visible according to the filter are left out. The rule
+// is disabled if it's disabled for the cluster, for the user on all clusters
+// of the organization or for the whole organization.
 func (storage DBStorage) GetContentForRules(
 	reportRules types.ReportRules,
 	userID types.UserID,
 	clusterName types.ClusterName,
+	filter RuleContentFilter,
 ) ([]types.RuleContentResponse, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	rules := make([]types.RuleContentResponse, 0)
 
 	query := `
@@ -443,14 +656,17 @@ func (storage DBStorage) GetContentForRules(
 		rek.error_key,
 		rek.rule_module,
 		rek.description,
-		rek.generic,
-		r.reason,
-		r.resolution,
+		COALESCE(rekt.generic, rek.generic),
+		COALESCE(rt.reason, r.reason),
+		COALESCE(rt.resolution, r.resolution),
 		rek.publish_date,
 		rek.impact,
 		rek.likelihood,
 		rek.tags,
-		COALESCE(crt.disabled, 0) as disabled
+		CASE
+			WHEN crt.disabled = 1 OR rd.rule_id IS NOT NULL THEN 1
+			ELSE 0
+		END as disabled
 	FROM
 		rule r
 	INNER JOIN
@@ -461,15 +677,35 @@ func (storage DBStorage) GetContentForRules(
 			ON rek.rule_module = crt.rule_id
 			AND crt.cluster_id = $1
 			AND crt.user_id = $2
+	LEFT JOIN
+		(
+			SELECT DISTINCT rule_id
+			FROM rule_disable
+			WHERE org_id = (SELECT org_id FROM report WHERE cluster = $1)
+			AND user_id IN ('', $2)
+		) rd
+			ON rek.rule_module = rd.rule_id
+	LEFT JOIN
+		rule_translation rt
+			ON r.module = rt.rule_module
+			AND rt.language = $3
+	LEFT JOIN
+		rule_error_key_translation rekt
+			ON rek.rule_module = rekt.rule_module
+			AND rek.error_key = rekt.error_key
+			AND rekt.language = $3
 	WHERE %v
 	ORDER BY
 		disabled ASC
 	`
 
-	whereInStatement := constructWhereClauseForContent(reportRules)
+	visibilityCondition, args := constructRuleVisibilityCondition(
+		filter, []interface{}{clusterName, userID, filter.Language},
+	)
+	whereInStatement := fmt.Sprintf("(%v) AND %v", constructWhereClauseForContent(reportRules), visibilityCondition)
 	query = fmt.Sprintf(query, whereInStatement)
 
-	rows, err := storage.connection.Query(query, clusterName, userID)
+	rows, err := storage.clusterReadConnection(ctx, clusterName, userID).QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return rules, err
@@ -517,6 +753,33 @@ func (storage DBStorage) GetContentForRules(
 	return rules, nil
 }
 
+// GetSuppressedRulesCount returns number of rules hit in the report that have content,
+// but are not shown because they are inactive or not published yet
+func (storage DBStorage) GetSuppressedRulesCount(reportRules types.ReportRules, includeInternal bool) (int, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	query := `
+	SELECT
+		COUNT(*)
+	FROM
+		rule r
+	INNER JOIN
+		rule_error_key rek
+			ON r.module = rek.rule_module
+	WHERE %v AND NOT (%v)
+	`
+	query = fmt.Sprintf(query, constructWhereClauseForContent(reportRules), activeRuleCondition(1))
+	if !includeInternal {
+		query += " AND r.internal = FALSE"
+	}
+
+	var count int
+	err := storage.readConnection().QueryRowContext(ctx, query, time.Now().UTC()).Scan(&count)
+
+	return count, err
+}
+
 func (storage DBStorage) getReportUpsertQuery() (string, error) {
 	switch storage.dbDriverType {
 	case types.DBDriverSQLite3:
@@ -540,9 +803,13 @@ func (storage DBStorage) WriteReportForCluster(
 	lastCheckedTime time.Time,
 	kafkaOffset types.KafkaOffset,
 ) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	// Skip writing the report if it isn't newer than a report
 	// that is already in the database for the same cluster.
-	if oldLastChecked, exists := storage.clustersLastChecked[clusterName]; exists && !lastCheckedTime.After(oldLastChecked) {
+	if oldLastChecked, exists := storage.clustersLastChecked.get(clusterName); exists && !lastCheckedTime.After(oldLastChecked) {
+		metrics.SkippedOldReports.Inc()
 		return types.ErrOldReport
 	}
 
@@ -553,49 +820,144 @@ func (storage DBStorage) WriteReportForCluster(
 	}
 
 	// Begin a new transaction.
-	tx, err := storage.connection.Begin()
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = storage.upsertReportInTx(ctx, tx, upsertQuery, ReportWrite{
+		OrgID:           orgID,
+		ClusterName:     clusterName,
+		Report:          report,
+		LastCheckedTime: lastCheckedTime,
+		KafkaOffset:     kafkaOffset,
+	})
 	if err != nil {
+		_ = tx.Rollback()
+		if err == types.ErrOldReport {
+			metrics.SkippedOldReports.Inc()
+		}
 		return err
 	}
 
+	storage.clustersLastChecked.set(clusterName, lastCheckedTime)
+	metrics.WrittenReports.Inc()
+	return tx.Commit()
+}
+
+// WriteReportsForClusters writes the reports in a single transaction. The returned slice
+// contains the result of every report, types.ErrOldReport for the reports that were skipped
+// because a more recent report of the cluster already exists. Nothing is written if the
+// second error is returned.
+func (storage DBStorage) WriteReportsForClusters(reports []ReportWrite) ([]error, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	if len(reports) == 0 {
+		return nil, nil
+	}
+
+	upsertQuery, err := storage.getReportUpsertQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(reports))
+	// the batch can contain several reports of the same cluster
+	written := make(map[types.ClusterName]time.Time)
+
+	for i, report := range reports {
+		if oldLastChecked, exists := written[report.ClusterName]; exists && !report.LastCheckedTime.After(oldLastChecked) {
+			results[i] = types.ErrOldReport
+			continue
+		}
+
+		if oldLastChecked, exists := storage.clustersLastChecked.get(report.ClusterName); exists && !report.LastCheckedTime.After(oldLastChecked) {
+			results[i] = types.ErrOldReport
+			continue
+		}
+
+		err := storage.upsertReportInTx(ctx, tx, upsertQuery, report)
+		if err == types.ErrOldReport {
+			results[i] = err
+			continue
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+
+		written[report.ClusterName] = report.LastCheckedTime
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for clusterName, lastCheckedTime := range written {
+		storage.clustersLastChecked.set(clusterName, lastCheckedTime)
+	}
+
+	for _, result := range results {
+		if result == nil {
+			metrics.WrittenReports.Inc()
+		} else {
+			metrics.SkippedOldReports.Inc()
+		}
+	}
+
+	return results, nil
+}
+
+// upsertReportInTx writes the report in the transaction, types.ErrOldReport is returned
+// if there is a more recent report for the cluster already in the database
+func (storage DBStorage) upsertReportInTx(ctx context.Context, tx *sql.Tx, upsertQuery string, report ReportWrite) error {
 	// Check if there is a more recent report for the cluster already in the database.
-	rows, err := tx.Query(
+	rows, err := tx.QueryContext(ctx,
 		"SELECT last_checked_at FROM report WHERE org_id = $1 AND cluster = $2 AND last_checked_at > $3;",
-		orgID, clusterName, lastCheckedTime)
-	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+		report.OrgID, report.ClusterName, report.LastCheckedTime)
+	err = types.ConvertDBError(err, []interface{}{report.OrgID, report.ClusterName})
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to look up the most recent report in database")
-		_ = tx.Rollback()
 		return err
 	}
-	defer closeRows(rows)
+
+	moreRecentExists := rows.Next()
+	closeRows(rows)
 
 	// If there is one, print a warning and discard the report (don't update it).
-	if rows.Next() {
+	if moreRecentExists {
 		log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
-			orgID, clusterName, lastCheckedTime)
-		_ = tx.Rollback()
-		return nil
+			report.OrgID, report.ClusterName, report.LastCheckedTime)
+		return types.ErrOldReport
 	}
 
 	// Perform the report upsert.
 	reportedAtTime := time.Now()
-	_, err = tx.Exec(upsertQuery, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset)
+	_, err = tx.ExecContext(
+		ctx, upsertQuery,
+		report.OrgID, report.ClusterName, report.Report, reportedAtTime, report.LastCheckedTime, report.KafkaOffset,
+	)
 	if err != nil {
-		log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", orgID, clusterName)
-		_ = tx.Rollback()
+		log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", report.OrgID, report.ClusterName)
 		return err
 	}
 
-	storage.clustersLastChecked[clusterName] = lastCheckedTime
-	metrics.WrittenReports.Inc()
-	return tx.Commit()
+	return nil
 }
 
 // ReportsCount reads number of all records stored in database
 func (storage DBStorage) ReportsCount() (int, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	count := -1
-	err := storage.connection.QueryRow("SELECT count(*) FROM report;").Scan(&count)
+	err := storage.readConnection().QueryRowContext(ctx, "SELECT count(*) FROM report;").Scan(&count)
 	err = types.ConvertDBError(err, nil)
 
 	return count, err
@@ -603,18 +965,49 @@ func (storage DBStorage) ReportsCount() (int, error) {
 
 // DeleteReportsForOrg deletes all reports related to the specified organization from the storage.
 func (storage DBStorage) DeleteReportsForOrg(orgID types.OrgID) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE org_id = $1;", orgID)
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, "DELETE FROM report WHERE org_id = $1;", orgID)
 	return err
 }
 
 // DeleteReportsForCluster deletes all reports related to the specified cluster from the storage.
 func (storage DBStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE cluster = $1;", clusterName)
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, "DELETE FROM report WHERE cluster = $1;", clusterName)
 	return err
 }
 
-// loadRuleErrorKeyContent inserts the error key contents of all available rules into the database.
-func loadRuleErrorKeyContent(tx *sql.Tx, ruleConfig content.GlobalRuleConfig, ruleModuleName string, errorKeys map[string]content.RuleErrorKeyContent) error {
+// loadRuleErrorKeyContent inserts or updates the error key contents of the rule in the database,
+// the stored error keys that are not part of the content anymore are deleted.
+func loadRuleErrorKeyContent(ctx context.Context, tx *sql.Tx, ruleConfig content.GlobalRuleConfig, ruleModuleName string, errorKeys map[string]content.RuleErrorKeyContent) error {
+	storedErrorKeys, err := queryStrings(ctx, tx, `SELECT error_key FROM rule_error_key WHERE rule_module = $1`, ruleModuleName)
+	if err != nil {
+		return err
+	}
+
+	for _, errName := range storedErrorKeys {
+		if _, found := errorKeys[errName]; found {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM rule_error_key WHERE error_key = $1 AND rule_module = $2`, errName, ruleModuleName,
+		); err != nil {
+			return err
+		}
+	}
+
+	// the translations don't have any identity, so they're simply replaced
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM rule_error_key_translation WHERE rule_module = $1`, ruleModuleName,
+	); err != nil {
+		return err
+	}
+
 	for errName, errProperties := range errorKeys {
 		var errIsActiveStatus bool
 		switch strings.ToLower(errProperties.Metadata.Status) {
@@ -630,9 +1023,26 @@ func loadRuleErrorKeyContent(tx *sql.Tx, ruleConfig content.GlobalRuleConfig, ru
 		// quick hack to store tags list into DB
 		tags := strings.Join(errProperties.Metadata.Tags, ",")
 
-		_, err := tx.Exec(`INSERT INTO rule_error_key(error_key, rule_module, condition,
+		// publish dates are stored in UTC so they can be compared with the current time
+		publishDate, err := content.ParsePublishDate(errProperties.Metadata.PublishDate)
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("invalid rule error key publish date: '%s'", errProperties.Metadata.PublishDate)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO rule_error_key(error_key, rule_module, condition,
 				description, impact, likelihood, publish_date, active, generic, tags)
-				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				ON CONFLICT (error_key, rule_module)
+				DO UPDATE SET
+					condition = $3,
+					description = $4,
+					impact = $5,
+					likelihood = $6,
+					publish_date = $7,
+					active = $8,
+					generic = $9,
+					tags = $10`,
 			errName,
 			ruleModuleName,
 			errProperties.Metadata.Condition,
@@ -641,7 +1051,7 @@ func loadRuleErrorKeyContent(tx *sql.Tx, ruleConfig content.GlobalRuleConfig, ru
 			// dictionary, which is correct because we cannot continue if that happens.
 			ruleConfig.Impact[errProperties.Metadata.Impact],
 			errProperties.Metadata.Likelihood,
-			errProperties.Metadata.PublishDate,
+			publishDate.UTC(),
 			errIsActiveStatus,
 			errProperties.Generic,
 			tags)
@@ -650,34 +1060,143 @@ func loadRuleErrorKeyContent(tx *sql.Tx, ruleConfig content.GlobalRuleConfig, ru
 			_ = tx.Rollback()
 			return err
 		}
+
+		for language, generic := range errProperties.GenericTranslations {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO rule_error_key_translation(error_key, rule_module, language, generic)
+				VALUES($1, $2, $3, $4)`,
+				errName, ruleModuleName, language, string(generic),
+			)
+			if err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// nullableText converts the text to a value stored in a nullable column,
+// the text that is not available (nil) is stored as NULL
+func nullableText(text []byte) interface{} {
+	if text == nil {
+		return nil
+	}
+
+	return string(text)
+}
+
+// queryStrings returns the values of the single string column selected by the query
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// loadRuleTranslations replaces the translated texts of the rule
+func loadRuleTranslations(ctx context.Context, tx *sql.Tx, ruleModuleName string, translations map[string]content.RuleTranslation) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rule_translation WHERE rule_module = $1`, ruleModuleName); err != nil {
+		return err
+	}
+
+	for language, translation := range translations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO rule_translation(rule_module, language, summary, reason, resolution, more_info)
+			VALUES($1, $2, $3, $4, $5, $6)`,
+			ruleModuleName,
+			language,
+			nullableText(translation.Summary),
+			nullableText(translation.Reason),
+			nullableText(translation.Resolution),
+			nullableText(translation.MoreInfo),
+		)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// LoadRuleContent loads the parsed rule content into the database.
+// deleteRuleContent deletes the rule together with its error keys and translations
+func deleteRuleContent(ctx context.Context, tx *sql.Tx, ruleModuleName string) error {
+	for _, table := range []string{"rule_error_key_translation", "rule_translation", "rule_error_key"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE rule_module = $1", ruleModuleName); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM rule WHERE module = $1`, ruleModuleName)
+	return err
+}
+
+// LoadRuleContent loads the parsed rule content into the database. The stored rules are
+// updated in place and only the rules removed from the content are deleted, so that the
+// data referencing the rules, like the user feedback, are kept over the reloads.
 func (storage DBStorage) LoadRuleContent(contentDir content.RuleContentDirectory) error {
-	tx, err := storage.connection.Begin()
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	// SQLite doesn't support `TRUNCATE`, so it's necessary to use `DELETE` and then `VACUUM`.
-	if _, err := tx.Exec("DELETE FROM rule_error_key; DELETE FROM rule;"); err != nil {
+	storedModules, err := queryStrings(ctx, tx, `SELECT module FROM rule`)
+	if err != nil {
 		_ = tx.Rollback()
 		return err
 	}
 
+	loadedModules := make(map[string]bool, len(contentDir.Rules))
+	for _, rule := range contentDir.Rules {
+		loadedModules[rule.Plugin.PythonModule] = true
+	}
+
+	for _, module := range storedModules {
+		if loadedModules[module] {
+			continue
+		}
+
+		if err := deleteRuleContent(ctx, tx, module); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
 	for _, rule := range contentDir.Rules {
-		_, err := tx.Exec(`
-				INSERT INTO rule(module, "name", summary, reason, resolution, more_info)
-				VALUES($1, $2, $3, $4, $5, $6)`,
+		_, err := tx.ExecContext(ctx, `
+				INSERT INTO rule(module, "name", summary, reason, resolution, more_info, internal)
+				VALUES($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (module)
+				DO UPDATE SET
+					"name" = $2,
+					summary = $3,
+					reason = $4,
+					resolution = $5,
+					more_info = $6,
+					internal = $7`,
 			rule.Plugin.PythonModule,
 			rule.Plugin.Name,
 			rule.Summary,
 			rule.Reason,
 			rule.Resolution,
 			rule.MoreInfo,
+			rule.Internal,
 		)
 
 		if err != nil {
@@ -685,10 +1204,38 @@ func (storage DBStorage) LoadRuleContent(contentDir content.RuleContentDirectory
 			return err
 		}
 
-		if err := loadRuleErrorKeyContent(tx, contentDir.Config, rule.Plugin.PythonModule, rule.ErrorKeys); err != nil {
+		if err := loadRuleTranslations(ctx, tx, rule.Plugin.PythonModule, rule.Translations); err != nil {
 			_ = tx.Rollback()
 			return err
 		}
+
+		if err := loadRuleErrorKeyContent(ctx, tx, contentDir.Config, rule.Plugin.PythonModule, rule.ErrorKeys); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err := storage.rebuildRuleSearchIndex(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	// the table contains just the version of the currently loaded content
+	if _, err := tx.ExecContext(ctx, "DELETE FROM content_version;"); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO content_version(hash, commit_hash, loaded_at)
+		VALUES($1, $2, $3)`,
+		contentDir.Version.Hash,
+		contentDir.Version.Commit,
+		time.Now(),
+	)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -698,11 +1245,35 @@ func (storage DBStorage) LoadRuleContent(contentDir content.RuleContentDirectory
 	return nil
 }
 
+// GetContentVersion returns version of the rule content loaded by LoadRuleContent
+func (storage DBStorage) GetContentVersion() (*types.ContentVersion, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	var version types.ContentVersion
+
+	err := storage.readConnection().QueryRowContext(ctx, `
+		SELECT hash, commit_hash, loaded_at FROM content_version`,
+	).Scan(
+		&version.Hash,
+		&version.Commit,
+		&version.LoadedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, &types.ItemNotFoundError{ItemID: "content version"}
+	}
+
+	return &version, err
+}
+
 // GetRuleByID gets a rule by ID
 func (storage DBStorage) GetRuleByID(ruleID types.RuleID) (*types.Rule, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
 	var rule types.Rule
 
-	err := storage.connection.QueryRow(`
+	err := storage.readConnection().QueryRowContext(ctx, `
 		SELECT
 			"module",
 			"name",
@@ -728,7 +1299,7 @@ func (storage DBStorage) GetRuleByID(ruleID types.RuleID) (*types.Rule, error) {
 
 // CreateRule creates rule with provided ruleData in the DB
 func (storage DBStorage) CreateRule(ruleData types.Rule) error {
-	_, err := storage.connection.Exec(`
+	_, err := storage.execRuleContentStatement(ruleData.Module, `
 		INSERT INTO rule("module", "name", "summary", "reason", "resolution", "more_info")
 		VALUES($1, $2, $3, $4, $5, $6)
 		ON CONFLICT ("module")
@@ -753,7 +1324,7 @@ func (storage DBStorage) CreateRule(ruleData types.Rule) error {
 
 // DeleteRule deletes rule with provided ruleData in the DB
 func (storage DBStorage) DeleteRule(ruleID types.RuleID) error {
-	res, err := storage.connection.Exec(`DELETE FROM rule WHERE "module" = $1;`, ruleID)
+	res, err := storage.execRuleContentStatement(ruleID, `DELETE FROM rule WHERE "module" = $1;`, ruleID)
 	if err != nil {
 		return err
 	}
@@ -772,7 +1343,7 @@ func (storage DBStorage) DeleteRule(ruleID types.RuleID) error {
 
 // CreateRuleErrorKey creates rule_error_key with provided data in the DB
 func (storage DBStorage) CreateRuleErrorKey(ruleErrorKey types.RuleErrorKey) error {
-	_, err := storage.connection.Exec(`
+	_, err := storage.execRuleContentStatement(ruleErrorKey.RuleModule, `
 		INSERT INTO rule_error_key(
 			"error_key",
 			"rule_module",
@@ -812,7 +1383,7 @@ func (storage DBStorage) CreateRuleErrorKey(ruleErrorKey types.RuleErrorKey) err
 
 // DeleteRuleErrorKey creates rule_error_key with provided data in the DB
 func (storage DBStorage) DeleteRuleErrorKey(ruleID types.RuleID, errorKey types.ErrorKey) error {
-	res, err := storage.connection.Exec(
+	res, err := storage.execRuleContentStatement(ruleID,
 		`DELETE FROM rule_error_key WHERE "error_key" = $1 AND "rule_module" = $2;`,
 		errorKey,
 		ruleID,
@@ -840,7 +1411,10 @@ func (storage DBStorage) GetConnection() *sql.DB {
 
 // WriteConsumerError writes a report about a consumer error into the storage.
 func (storage DBStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
-	_, err := storage.connection.Exec(`
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
 		INSERT INTO consumer_error (topic, partition, topic_offset, key, produced_at, consumed_at, message, error)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
 		msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Timestamp, time.Now().UTC(), msg.Value, consumerErr.Error())
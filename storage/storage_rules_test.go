@@ -240,8 +240,11 @@ func TestDBStorageLoadRuleContentDeleteDBError(t *testing.T) {
 	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
 	expects.ExpectBegin()
-	expects.ExpectExec("DELETE FROM rule_error_key").
+	expects.ExpectQuery("SELECT module FROM rule").
+		WillReturnRows(sqlmock.NewRows([]string{"module"}).AddRow("removed.rule"))
+	expects.ExpectExec("DELETE FROM rule_error_key_translation").
 		WillReturnError(fmt.Errorf(errorStr))
+	expects.ExpectRollback()
 
 	err := mockStorage.LoadRuleContent(ruleContentActiveOK)
 	assert.EqualError(t, err, errorStr)
@@ -253,7 +256,9 @@ func TestDBStorageLoadRuleContentCommitDBError(t *testing.T) {
 	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
 	expects.ExpectBegin()
-	expects.ExpectExec("DELETE FROM rule_error_key").WillReturnResult(driver.ResultNoRows)
+	expects.ExpectQuery("SELECT module FROM rule").WillReturnRows(sqlmock.NewRows([]string{"module"}))
+	expects.ExpectExec("DELETE FROM content_version").WillReturnResult(driver.ResultNoRows)
+	expects.ExpectExec("INSERT INTO content_version").WillReturnResult(driver.ResultNoRows)
 	expects.ExpectCommit().WillReturnError(fmt.Errorf(errorStr))
 
 	err := mockStorage.LoadRuleContent(content.RuleContentDirectory{})
@@ -276,6 +281,40 @@ func TestDBStorageLoadRuleContentBadStatus(t *testing.T) {
 	assert.EqualError(t, err, "invalid rule error key status: 'bad'")
 }
 
+func TestDBStorageLoadRuleContentBadPublishDate(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	contentDir := ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"ek": {Impact: "One", PublishDate: "yesterday", Status: "active"},
+	})
+
+	err := mockStorage.LoadRuleContent(contentDir)
+	assert.EqualError(t, err, "invalid rule error key publish date: 'yesterday'")
+}
+
+func TestDBStorageGetContentVersion(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.GetContentVersion()
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	contentDir := ruleContentActiveOK
+	contentDir.Version = content.RuleContentVersion{Hash: "first", Commit: "abcdef"}
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(contentDir))
+
+	contentDir.Version = content.RuleContentVersion{Hash: "second"}
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(contentDir))
+
+	// only the last loaded version is kept
+	version, err := mockStorage.GetContentVersion()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "second", version.Hash)
+	assert.Equal(t, "", version.Commit)
+	assert.WithinDuration(t, time.Now(), version.LoadedAt, time.Minute)
+}
+
 func TestDBStorageGetContentForRulesEmpty(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -289,6 +328,7 @@ func TestDBStorageGetContentForRulesEmpty(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 	helpers.FailOnError(t, err)
 
@@ -308,6 +348,7 @@ func TestDBStorageGetContentForRulesDBError(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 	assert.EqualError(t, err, "sql: database is closed")
 }
@@ -331,6 +372,7 @@ func TestDBStorageGetContentForRulesOK(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 
 	helpers.FailOnError(t, err)
@@ -353,6 +395,151 @@ func TestDBStorageGetContentForRulesOK(t *testing.T) {
 	}, res)
 }
 
+func TestDBStorageGetContentForRulesInternal(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	internalRule := ruleContentExample1.Rules["rc"]
+	internalRule.Internal = true
+
+	err := mockStorage.LoadRuleContent(content.RuleContentDirectory{
+		Config: ruleContentExample1.Config,
+		Rules:  map[string]content.RuleContent{"rc": internalRule},
+	})
+	helpers.FailOnError(t, err)
+
+	reportRules := types.ReportRules{
+		HitRules:   []types.RuleOnReport{{Module: string(testRuleID), ErrorKey: "ek"}},
+		TotalCount: 1,
+	}
+
+	res, err := mockStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, res)
+
+	res, err = mockStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{IncludeInternal: true})
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, string(testRuleID), res[0].RuleModule)
+}
+
+// ruleContentWithErrorKeys returns content of the example rule with the error keys
+func ruleContentWithErrorKeys(errorKeys map[string]content.ErrorKeyMetadata) content.RuleContentDirectory {
+	rule := ruleContentExample1.Rules["rc"]
+	rule.ErrorKeys = map[string]content.RuleErrorKeyContent{}
+	for errorKey, metadata := range errorKeys {
+		rule.ErrorKeys[errorKey] = content.RuleErrorKeyContent{Generic: []byte("generic"), Metadata: metadata}
+	}
+
+	return content.RuleContentDirectory{
+		Config: ruleContentExample1.Config,
+		Rules:  map[string]content.RuleContent{"rc": rule},
+	}
+}
+
+// reportRulesForErrorKeys returns report with hits of the example rule with the error keys
+func reportRulesForErrorKeys(errorKeys ...string) types.ReportRules {
+	reportRules := types.ReportRules{TotalCount: len(errorKeys)}
+	for _, errorKey := range errorKeys {
+		reportRules.HitRules = append(reportRules.HitRules, types.RuleOnReport{
+			Module: string(testRuleID), ErrorKey: errorKey,
+		})
+	}
+
+	return reportRules
+}
+
+func TestDBStorageGetContentForRulesInactive(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	tomorrow := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	err := mockStorage.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"active":      {Impact: "One", PublishDate: "2020-04-03", Status: "active"},
+		"inactive":    {Impact: "One", PublishDate: "2020-04-03", Status: "inactive"},
+		"unpublished": {Impact: "One", PublishDate: tomorrow, Status: "active"},
+	}))
+	helpers.FailOnError(t, err)
+
+	reportRules := reportRulesForErrorKeys("active", "inactive", "unpublished")
+
+	res, err := mockStorage.GetContentForRules(reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "active", res[0].ErrorKey)
+
+	count, err := mockStorage.GetSuppressedRulesCount(reportRules, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, count)
+
+	res, err = mockStorage.GetContentForRules(
+		reportRules, testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{IncludeInactive: true},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 3)
+}
+
+func TestDBStorageGetSuppressedRulesCountInternal(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	contentDir := ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"inactive": {Impact: "One", PublishDate: "2020-04-03", Status: "inactive"},
+	})
+	internalRule := contentDir.Rules["rc"]
+	internalRule.Internal = true
+	contentDir.Rules["rc"] = internalRule
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(contentDir))
+
+	reportRules := reportRulesForErrorKeys("inactive")
+
+	// existence of internal rules is not revealed to external users
+	count, err := mockStorage.GetSuppressedRulesCount(reportRules, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = mockStorage.GetSuppressedRulesCount(reportRules, true)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestDBStorageGetContentLanguages(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	contentDir := ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"ek": {Impact: "One", PublishDate: "2020-04-03", Status: "active"},
+	})
+	rule := contentDir.Rules["rc"]
+	rule.Translations = map[string]content.RuleTranslation{"ja": {Summary: []byte("概要")}}
+	rule.ErrorKeys["ek"] = content.RuleErrorKeyContent{
+		Generic:             rule.ErrorKeys["ek"].Generic,
+		Metadata:            rule.ErrorKeys["ek"].Metadata,
+		GenericTranslations: map[string][]byte{"es": []byte("genérico")},
+	}
+	contentDir.Rules["rc"] = rule
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(contentDir))
+
+	languages, err := mockStorage.GetContentLanguages()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []string{"es", "ja"}, languages)
+
+	res, err := mockStorage.GetContentForRules(
+		reportRulesForErrorKeys("ek"), testdata.UserID, testdata.ClusterName, storage.RuleContentFilter{Language: "es"},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "genérico", res[0].Generic)
+	assert.Equal(t, "reason", res[0].Reason)
+
+	// translations are replaced by the newly loaded content
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentExample1))
+
+	languages, err = mockStorage.GetContentLanguages()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, languages)
+}
+
 func TestDBStorageGetContentForMultipleRulesOK(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -380,6 +567,7 @@ func TestDBStorageGetContentForMultipleRulesOK(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 
 	helpers.FailOnError(t, err)
@@ -478,6 +666,7 @@ func TestDBStorageGetContentForRulesScanError(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 
 	helpers.FailOnError(t, err)
@@ -527,6 +716,7 @@ func TestDBStorageGetContentForRulesRowsError(t *testing.T) {
 		},
 		testdata.UserID,
 		testdata.ClusterName,
+		storage.RuleContentFilter{},
 	)
 
 	assert.Error(t, err)
@@ -1137,7 +1327,9 @@ func TestDBStorage_GetRuleWithContent(t *testing.T) {
 	err = mockStorage.CreateRuleErrorKey(testdata.RuleErrorKey2)
 	helpers.FailOnError(t, err)
 
-	ruleWithContent, err := mockStorage.GetRuleWithContent(testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey)
+	ruleWithContent, err := mockStorage.GetRuleWithContent(
+		testdata.Rule1ID, testdata.RuleErrorKey1.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
 	helpers.FailOnError(t, err)
 
 	// ignore date
@@ -1145,7 +1337,9 @@ func TestDBStorage_GetRuleWithContent(t *testing.T) {
 
 	assert.Equal(t, testdata.RuleWithContent1, *ruleWithContent)
 
-	ruleWithContent, err = mockStorage.GetRuleWithContent(testdata.Rule2ID, testdata.RuleErrorKey2.ErrorKey)
+	ruleWithContent, err = mockStorage.GetRuleWithContent(
+		testdata.Rule2ID, testdata.RuleErrorKey2.ErrorKey, storage.RuleContentFilter{IncludeInactive: true},
+	)
 	helpers.FailOnError(t, err)
 
 	// ignore date
@@ -1153,3 +1347,213 @@ func TestDBStorage_GetRuleWithContent(t *testing.T) {
 
 	assert.Equal(t, testdata.RuleWithContent2, *ruleWithContent)
 }
+
+func TestDBStorageSearchRules(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	res, err := mockStorage.SearchRules("Rule 2 summary", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, testdata.Rule2ID, res[0].Module)
+	assert.Equal(t, types.ErrorKey(testdata.ErrorKey2), res[0].ErrorKey)
+	assert.Equal(t, testdata.Rule2Description, res[0].Description)
+	assert.Contains(t, res[0].Snippet, "**2**")
+	assert.True(t, res[0].Rank > 0)
+
+	// words are matched as prefixes
+	res, err = mockStorage.SearchRules("descr", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 3)
+
+	res, err = mockStorage.SearchRules("rule", storage.RuleContentFilter{}, 2)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 2)
+
+	res, err = mockStorage.SearchRules("nonexistent", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestDBStorageSearchRulesIgnoresQuerySyntax(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	res, err := mockStorage.SearchRules(`" * OR -`, storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, res)
+
+	res, err = mockStorage.SearchRules(`"summary" OR NOT`, storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestDBStorageSearchRulesRanking(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"once":   {Impact: "One", PublishDate: "2020-04-03", Status: "active", Description: "etcd is slow"},
+		"thrice": {Impact: "One", PublishDate: "2020-04-03", Status: "active", Description: "etcd etcd etcd"},
+		"none":   {Impact: "One", PublishDate: "2020-04-03", Status: "active", Description: "slow disk"},
+	})))
+
+	res, err := mockStorage.SearchRules("ETCD", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, types.ErrorKey("thrice"), res[0].ErrorKey)
+	assert.Equal(t, types.ErrorKey("once"), res[1].ErrorKey)
+	assert.True(t, res[0].Rank > res[1].Rank)
+}
+
+func TestDBStorageSearchRulesVisibility(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"active":   {Impact: "One", PublishDate: "2020-04-03", Status: "active", Description: "etcd"},
+		"inactive": {Impact: "One", PublishDate: "2020-04-03", Status: "inactive", Description: "etcd"},
+	})))
+
+	res, err := mockStorage.SearchRules("etcd", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, types.ErrorKey("active"), res[0].ErrorKey)
+
+	res, err = mockStorage.SearchRules("etcd", storage.RuleContentFilter{IncludeInactive: true}, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, res, 2)
+}
+
+func TestDBStorageSearchRulesIndexReplaced(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentExample1))
+
+	res, err := mockStorage.SearchRules("rule 2", storage.RuleContentFilter{}, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, res)
+}
+
+// listedRuleIDs returns modules of the listed rules
+func listedRuleIDs(rules []types.RuleWithContent) []types.RuleID {
+	ruleIDs := []types.RuleID{}
+	for _, rule := range rules {
+		ruleIDs = append(ruleIDs, rule.Module)
+	}
+
+	return ruleIDs
+}
+
+func TestDBStorageListRules(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	rules, total, err := mockStorage.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{}, 0, 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []types.RuleID{testdata.Rule1ID, testdata.Rule2ID, testdata.Rule3ID}, listedRuleIDs(rules))
+	assert.Equal(t, "rule 1 name", rules[0].Name)
+	assert.Equal(t, testdata.Rule1Description, rules[0].Description)
+	assert.Equal(t, []string{"tag1", "tag2"}, rules[0].Tags)
+	assert.Equal(t, 3, rules[0].TotalRisk)
+
+	rules, total, err = mockStorage.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{}, 1, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []types.RuleID{testdata.Rule2ID}, listedRuleIDs(rules))
+
+	rules, total, err = mockStorage.ListRules(storage.RuleContentFilter{}, storage.RuleListFilter{}, 5, 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Empty(t, rules)
+}
+
+func TestDBStorageListRulesImpactAndLikelihood(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	for _, testCase := range []struct {
+		filter   storage.RuleListFilter
+		expected []types.RuleID
+	}{
+		{storage.RuleListFilter{Impact: 2}, []types.RuleID{testdata.Rule1ID, testdata.Rule3ID}},
+		{storage.RuleListFilter{Likelihood: 2}, []types.RuleID{testdata.Rule2ID, testdata.Rule3ID}},
+		{storage.RuleListFilter{Impact: 2, Likelihood: 2}, []types.RuleID{testdata.Rule3ID}},
+		{storage.RuleListFilter{Impact: 5}, []types.RuleID{}},
+	} {
+		rules, total, err := mockStorage.ListRules(storage.RuleContentFilter{}, testCase.filter, 0, 10)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, testCase.expected, listedRuleIDs(rules))
+		assert.Equal(t, len(testCase.expected), total)
+	}
+}
+
+func TestDBStorageListRulesTagAndActive(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"a": {Impact: "One", PublishDate: "2020-04-03", Status: "active", Tags: []string{"etcd", "network"}},
+		"b": {Impact: "One", PublishDate: "2020-04-03", Status: "active", Tags: []string{"network_policy"}},
+		"c": {Impact: "One", PublishDate: "2020-04-03", Status: "inactive", Tags: []string{"etcd"}},
+	})))
+
+	// tags have to match exactly
+	rules, total, err := mockStorage.ListRules(
+		storage.RuleContentFilter{}, storage.RuleListFilter{Tag: "network"}, 0, 10,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, types.ErrorKey("a"), rules[0].ErrorKey)
+
+	rules, _, err = mockStorage.ListRules(
+		storage.RuleContentFilter{}, storage.RuleListFilter{Tag: "etcd"}, 0, 10,
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+
+	rules, _, err = mockStorage.ListRules(
+		storage.RuleContentFilter{IncludeInactive: true}, storage.RuleListFilter{Tag: "etcd"}, 0, 10,
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+
+	inactive := false
+	rules, total, err = mockStorage.ListRules(
+		storage.RuleContentFilter{IncludeInactive: true}, storage.RuleListFilter{Active: &inactive}, 0, 10,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, types.ErrorKey("c"), rules[0].ErrorKey)
+}
+
+func TestDBStorageListRuleTags(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(ruleContentWithErrorKeys(map[string]content.ErrorKeyMetadata{
+		"a": {Impact: "One", PublishDate: "2020-04-03", Status: "active", Tags: []string{"etcd", "network"}},
+		"b": {Impact: "One", PublishDate: "2020-04-03", Status: "active", Tags: []string{"network"}},
+		"c": {Impact: "One", PublishDate: "2020-04-03", Status: "inactive", Tags: []string{"etcd"}},
+		"d": {Impact: "One", PublishDate: "2020-04-03", Status: "active"},
+	})))
+
+	tags, err := mockStorage.ListRuleTags(storage.RuleContentFilter{})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.RuleTagCount{{Tag: "etcd", Count: 1}, {Tag: "network", Count: 2}}, tags)
+
+	tags, err = mockStorage.ListRuleTags(storage.RuleContentFilter{IncludeInactive: true})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.RuleTagCount{{Tag: "etcd", Count: 2}, {Tag: "network", Count: 2}}, tags)
+}
@@ -18,6 +18,7 @@ package storage_test
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
@@ -103,6 +104,48 @@ func TestNewStorageWithLoggingError(t *testing.T) {
 	assert.Contains(t, err.Error(), "connect: connection refused")
 }
 
+// TestNewStorageQueryTimeout checks that queries are canceled after the configured timeout
+func TestNewStorageQueryTimeout(t *testing.T) {
+	s, err := storage.New(storage.Configuration{
+		Driver:           "sqlite3",
+		SQLiteDataSource: ":memory:",
+		QueryTimeout:     time.Nanosecond,
+	})
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, s.Close())
+	}()
+
+	_, err = s.ListOfOrgs()
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// TestDBStorageWithContextCanceled checks that queries are not run in canceled context
+func TestDBStorageWithContextCanceled(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxStorage := mockStorage.WithContext(ctx)
+
+	_, _, err := ctxStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	cancel()
+
+	_, _, err = ctxStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Equal(t, context.Canceled, err)
+
+	err = ctxStorage.DeleteReportsForCluster(testdata.ClusterName)
+	assert.Equal(t, context.Canceled, err)
+
+	// the original storage is not bound to the context
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
 // TestDBStorageReadReportForClusterEmptyTable check the behaviour of method ReadReportForCluster
 func TestDBStorageReadReportForClusterEmptyTable(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
@@ -314,6 +357,70 @@ func TestDBStorageWriteReportForClusterFakePostgresOK(t *testing.T) {
 	helpers.FailOnError(t, err)
 }
 
+func TestDBStorageWriteReportsForClustersFakePostgresOK(t *testing.T) {
+	mockStorage, expects := helpers.MustGetMockStorageWithExpectsForDriver(t, types.DBDriverPostgres)
+	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	// all reports are written in a single transaction
+	expects.ExpectBegin()
+	for i := 0; i < 2; i++ {
+		expects.ExpectQuery(`SELECT last_checked_at FROM report`).
+			WillReturnRows(expects.NewRows([]string{"last_checked_at"})).
+			RowsWillBeClosed()
+
+		expects.ExpectExec("INSERT INTO report").
+			WillReturnResult(driver.ResultNoRows)
+	}
+	expects.ExpectCommit()
+
+	results, err := mockStorage.WriteReportsForClusters([]storage.ReportWrite{
+		{OrgID: testdata.OrgID, ClusterName: testdata.ClusterName, Report: testdata.Report3Rules, LastCheckedTime: testdata.LastCheckedAt},
+		{OrgID: testdata.OrgID, ClusterName: testdata.GetRandomClusterID(), Report: testdata.Report3Rules, LastCheckedTime: testdata.LastCheckedAt},
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []error{nil, nil}, results)
+}
+
+func TestDBStorageWriteReportsForClustersRollback(t *testing.T) {
+	mockStorage, expects := helpers.MustGetMockStorageWithExpectsForDriver(t, types.DBDriverPostgres)
+	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	expects.ExpectBegin()
+	expects.ExpectQuery(`SELECT last_checked_at FROM report`).
+		WillReturnRows(expects.NewRows([]string{"last_checked_at"})).
+		RowsWillBeClosed()
+	expects.ExpectExec("INSERT INTO report").
+		WillReturnResult(driver.ResultNoRows)
+	expects.ExpectQuery(`SELECT last_checked_at FROM report`).
+		WillReturnRows(expects.NewRows([]string{"last_checked_at"})).
+		RowsWillBeClosed()
+	expects.ExpectExec("INSERT INTO report").
+		WillReturnError(fmt.Errorf("insert error"))
+	expects.ExpectRollback()
+
+	_, err := mockStorage.WriteReportsForClusters([]storage.ReportWrite{
+		{OrgID: testdata.OrgID, ClusterName: testdata.ClusterName, Report: testdata.Report3Rules, LastCheckedTime: testdata.LastCheckedAt},
+		{OrgID: testdata.OrgID, ClusterName: testdata.GetRandomClusterID(), Report: testdata.Report3Rules, LastCheckedTime: testdata.LastCheckedAt},
+	})
+	assert.EqualError(t, err, "insert error")
+}
+
+func TestDBStoragePurgeOrganizationRollback(t *testing.T) {
+	mockStorage, expects := helpers.MustGetMockStorageWithExpectsForDriver(t, types.DBDriverPostgres)
+	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	// nothing is removed when any of the tables can't be purged
+	expects.ExpectBegin()
+	expects.ExpectExec("DELETE FROM cluster_rule_user_feedback").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expects.ExpectExec("DELETE FROM cluster_rule_toggle").
+		WillReturnError(fmt.Errorf("delete error"))
+	expects.ExpectRollback()
+
+	_, err := mockStorage.PurgeOrganization(testdata.OrgID)
+	assert.EqualError(t, err, "delete error")
+}
+
 // TestDBStorageListOfOrgs check the behaviour of method ListOfOrgs
 func TestDBStorageListOfOrgs(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
@@ -0,0 +1,294 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// CreateWebhook stores new webhook subscription and returns its ID
+func (storage DBStorage) CreateWebhook(webhook types.Webhook) (types.WebhookID, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	now := time.Now()
+
+	query := `
+		INSERT INTO webhook(org_id, url, secret, min_total_risk, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+	args := []interface{}{webhook.OrgID, webhook.URL, webhook.Secret, webhook.MinTotalRisk, now}
+
+	// PostgreSQL driver doesn't support LastInsertId
+	if storage.dbDriverType == types.DBDriverPostgres {
+		var webhookID types.WebhookID
+
+		err := storage.connection.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&webhookID)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to create webhook")
+			return 0, err
+		}
+
+		return webhookID, nil
+	}
+
+	res, err := storage.connection.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create webhook")
+		return 0, err
+	}
+
+	webhookID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return types.WebhookID(webhookID), nil
+}
+
+// GetWebhook returns webhook subscription of the organization
+func (storage DBStorage) GetWebhook(orgID types.OrgID, webhookID types.WebhookID) (*types.Webhook, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	var webhook types.Webhook
+
+	err := storage.connection.QueryRowContext(ctx, `
+		SELECT id, org_id, url, secret, min_total_risk, created_at, updated_at
+		FROM webhook
+		WHERE org_id = $1 AND id = $2
+	`, orgID, webhookID).Scan(
+		&webhook.ID,
+		&webhook.OrgID,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.MinTotalRisk,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, types.ConvertDBError(err, webhookID)
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooksForOrg returns all webhook subscriptions of the organization
+func (storage DBStorage) ListWebhooksForOrg(orgID types.OrgID) ([]types.Webhook, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	webhooks := make([]types.Webhook, 0)
+
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT id, org_id, url, secret, min_total_risk, created_at, updated_at
+		FROM webhook
+		WHERE org_id = $1
+		ORDER BY id
+	`, orgID)
+	if err != nil {
+		return webhooks, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var webhook types.Webhook
+
+		err = rows.Scan(
+			&webhook.ID,
+			&webhook.OrgID,
+			&webhook.URL,
+			&webhook.Secret,
+			&webhook.MinTotalRisk,
+			&webhook.CreatedAt,
+			&webhook.UpdatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ListWebhooksForOrg")
+			return webhooks, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// ListOrgsWithWebhooks returns the organizations having at least one webhook subscription
+func (storage DBStorage) ListOrgsWithWebhooks() ([]types.OrgID, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	orgIDs := make([]types.OrgID, 0)
+
+	rows, err := storage.connection.QueryContext(ctx, "SELECT DISTINCT org_id FROM webhook ORDER BY org_id")
+	if err != nil {
+		return orgIDs, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var orgID types.OrgID
+
+		if err := rows.Scan(&orgID); err != nil {
+			log.Error().Err(err).Msg("ListOrgsWithWebhooks")
+			return orgIDs, err
+		}
+
+		orgIDs = append(orgIDs, orgID)
+	}
+
+	return orgIDs, rows.Err()
+}
+
+// UpdateWebhook updates URL, secret and risk threshold of existing webhook subscription
+func (storage DBStorage) UpdateWebhook(webhook types.Webhook) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	res, err := storage.connection.ExecContext(ctx, `
+		UPDATE webhook
+		SET url = $1, secret = $2, min_total_risk = $3, updated_at = $4
+		WHERE org_id = $5 AND id = $6
+	`, webhook.URL, webhook.Secret, webhook.MinTotalRisk, time.Now(), webhook.OrgID, webhook.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return &types.ItemNotFoundError{ItemID: webhook.ID}
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes webhook subscription together with its delivery log
+func (storage DBStorage) DeleteWebhook(orgID types.OrgID, webhookID types.WebhookID) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhook WHERE org_id = $1 AND id = $2`, orgID, webhookID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if rowsAffected == 0 {
+		_ = tx.Rollback()
+		return &types.ItemNotFoundError{ItemID: webhookID}
+	}
+
+	// foreign keys are not enforced by SQLite by default
+	_, err = tx.ExecContext(ctx, `DELETE FROM webhook_delivery WHERE webhook_id = $1`, webhookID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WriteWebhookDelivery writes a record to the webhook delivery log
+func (storage DBStorage) WriteWebhookDelivery(delivery types.WebhookDelivery) error {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO webhook_delivery(
+			webhook_id, cluster_id, payload, attempts, status_code, error, succeeded, delivered_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`,
+		delivery.WebhookID,
+		delivery.ClusterName,
+		delivery.Payload,
+		delivery.Attempts,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.Succeeded,
+		delivery.DeliveredAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write webhook delivery")
+		return types.ConvertDBError(err, delivery.WebhookID)
+	}
+
+	return nil
+}
+
+// ListWebhookDeliveries returns at most limit latest deliveries of the webhook
+func (storage DBStorage) ListWebhookDeliveries(
+	webhookID types.WebhookID, limit int,
+) ([]types.WebhookDelivery, error) {
+	ctx, cancel := storage.queryContext()
+	defer cancel()
+
+	deliveries := make([]types.WebhookDelivery, 0)
+
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT webhook_id, cluster_id, payload, attempts, status_code, error, succeeded, delivered_at
+		FROM webhook_delivery
+		WHERE webhook_id = $1
+		ORDER BY delivered_at DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return deliveries, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var delivery types.WebhookDelivery
+
+		err = rows.Scan(
+			&delivery.WebhookID,
+			&delivery.ClusterName,
+			&delivery.Payload,
+			&delivery.Attempts,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&delivery.Succeeded,
+			&delivery.DeliveredAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ListWebhookDeliveries")
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
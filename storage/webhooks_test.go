@@ -0,0 +1,183 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+var testWebhook = types.Webhook{
+	OrgID:        testdata.OrgID,
+	URL:          "https://example.com/hook",
+	Secret:       "secret",
+	MinTotalRisk: 3,
+}
+
+func mustCreateWebhook(t *testing.T, mockStorage storage.Storage, webhook types.Webhook) types.WebhookID {
+	webhookID, err := mockStorage.CreateWebhook(webhook)
+	helpers.FailOnError(t, err)
+
+	return webhookID
+}
+
+func TestDBStorageCreateAndGetWebhook(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	webhook, err := mockStorage.GetWebhook(testdata.OrgID, webhookID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, webhookID, webhook.ID)
+	assert.Equal(t, testWebhook.OrgID, webhook.OrgID)
+	assert.Equal(t, testWebhook.URL, webhook.URL)
+	assert.Equal(t, testWebhook.Secret, webhook.Secret)
+	assert.Equal(t, testWebhook.MinTotalRisk, webhook.MinTotalRisk)
+	assert.False(t, webhook.CreatedAt.IsZero())
+}
+
+func TestDBStorageGetWebhookNotFound(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	_, err := mockStorage.GetWebhook(testdata.OrgID+1, webhookID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+}
+
+func TestDBStorageListWebhooksForOrg(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhooks, err := mockStorage.ListWebhooksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, webhooks)
+
+	otherOrgWebhook := testWebhook
+	otherOrgWebhook.OrgID = testdata.OrgID + 1
+
+	firstID := mustCreateWebhook(t, mockStorage, testWebhook)
+	mustCreateWebhook(t, mockStorage, otherOrgWebhook)
+	secondID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	webhooks, err = mockStorage.ListWebhooksForOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, firstID, webhooks[0].ID)
+	assert.Equal(t, secondID, webhooks[1].ID)
+}
+
+func TestDBStorageUpdateWebhook(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	updated := testWebhook
+	updated.ID = webhookID
+	updated.URL = "https://example.com/other"
+	updated.MinTotalRisk = 1
+
+	helpers.FailOnError(t, mockStorage.UpdateWebhook(updated))
+
+	webhook, err := mockStorage.GetWebhook(testdata.OrgID, webhookID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, updated.URL, webhook.URL)
+	assert.Equal(t, updated.MinTotalRisk, webhook.MinTotalRisk)
+}
+
+func TestDBStorageUpdateWebhookNotFound(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	updated := testWebhook
+	updated.ID = webhookID
+	updated.OrgID = testdata.OrgID + 1
+
+	err := mockStorage.UpdateWebhook(updated)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+}
+
+func TestDBStorageDeleteWebhook(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	helpers.FailOnError(t, mockStorage.WriteWebhookDelivery(types.WebhookDelivery{
+		WebhookID:   webhookID,
+		ClusterName: testdata.ClusterName,
+		Payload:     "{}",
+		Attempts:    1,
+		StatusCode:  200,
+		Succeeded:   true,
+		DeliveredAt: testdata.LastCheckedAt,
+	}))
+
+	helpers.FailOnError(t, mockStorage.DeleteWebhook(testdata.OrgID, webhookID))
+
+	_, err := mockStorage.GetWebhook(testdata.OrgID, webhookID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, deliveries)
+
+	err = mockStorage.DeleteWebhook(testdata.OrgID, webhookID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: webhookID}, err)
+}
+
+func TestDBStorageListWebhookDeliveries(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	webhookID := mustCreateWebhook(t, mockStorage, testWebhook)
+
+	for i := 1; i <= 3; i++ {
+		helpers.FailOnError(t, mockStorage.WriteWebhookDelivery(types.WebhookDelivery{
+			WebhookID:   webhookID,
+			ClusterName: testdata.ClusterName,
+			Payload:     "{}",
+			Attempts:    i,
+			StatusCode:  500,
+			Error:       "webhook responded with status code 500",
+			DeliveredAt: testdata.LastCheckedAt.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 2)
+	helpers.FailOnError(t, err)
+
+	// the latest deliveries go first
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, 2, deliveries[1].Attempts)
+	assert.Equal(t, testdata.ClusterName, deliveries[0].ClusterName)
+	assert.False(t, deliveries[0].Succeeded)
+}
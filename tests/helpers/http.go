@@ -40,6 +40,7 @@ import (
 // UserID is a user id for methods requiring user id (leave empty to not use it)
 // XRHIdentity is an authentication token (leave empty to not use it)
 // AuthorizationToken is an authentication token (leave empty to not use it)
+// Headers are additional request headers (leave empty to not send any)
 type APIRequest struct {
 	Method             string
 	Endpoint           string
@@ -48,6 +49,7 @@ type APIRequest struct {
 	UserID             types.UserID
 	XRHIdentity        string
 	AuthorizationToken string
+	Headers            map[string]string
 }
 
 // APIResponse is an expected api response to use in AssertAPIRequest
@@ -137,6 +139,10 @@ func makeRequest(t testing.TB, request *APIRequest, url string) *http.Request {
 		req.Header.Set("Authorization", request.AuthorizationToken)
 	}
 
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+
 	return req
 }
 
@@ -21,7 +21,10 @@ import (
 )
 
 // MockConsumerGroupSession MockConsumerGroupSession
-type MockConsumerGroupSession struct{}
+type MockConsumerGroupSession struct {
+	// MarkedMessages are the messages marked as consumed
+	MarkedMessages []*sarama.ConsumerMessage
+}
 
 // Claims returns information about the claimed partitions by topic.
 func (cgs *MockConsumerGroupSession) Claims() map[string][]int32 {
@@ -47,7 +50,9 @@ func (cgs *MockConsumerGroupSession) ResetOffset(topic string, partition int32,
 }
 
 // MarkMessage marks a message as consumed.
-func (cgs *MockConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {}
+func (cgs *MockConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	cgs.MarkedMessages = append(cgs.MarkedMessages, msg)
+}
 
 // Context returns the session context.
 func (cgs *MockConsumerGroupSession) Context() context.Context {
@@ -63,8 +63,10 @@ type ReportResponse struct {
 
 // ReportResponseMeta contains metadata about the report
 type ReportResponseMeta struct {
-	Count         int       `json:"count"`
-	LastCheckedAt Timestamp `json:"last_checked_at"`
+	Count           int       `json:"count"`
+	LastCheckedAt   Timestamp `json:"last_checked_at"`
+	ContentVersion  string    `json:"content_version,omitempty"`
+	SuppressedCount int       `json:"suppressed_count,omitempty"`
 }
 
 // RuleContentResponse represents a single rule in the response of /report endpoint
@@ -90,6 +92,7 @@ type DisabledRuleResponse struct {
 	Description string `json:"description"`
 	Generic     string `json:"details"`
 	DisabledAt  string `json:"disabled_at"`
+	Scope       string `json:"scope"`
 }
 
 // RuleID represents type for rule id
@@ -143,6 +146,55 @@ type RuleWithContent struct {
 	Tags        []string  `json:"tags"`
 }
 
+// RuleSearchResult is a rule found by full-text search with relevance of the rule
+// to the query (higher is better) and a snippet of the content matching the query
+type RuleSearchResult struct {
+	RuleWithContent
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// RuleTagCount is a tag of rule error keys with number of the error keys having the tag
+type RuleTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// WebhookID represents ID of webhook subscription
+type WebhookID int64
+
+// Webhook represents a webhook subscription of an organization. The webhook is called
+// when a cluster of the organization starts hitting a rule with total risk
+// greater than or equal to MinTotalRisk.
+type Webhook struct {
+	ID           WebhookID `json:"id"`
+	OrgID        OrgID     `json:"org_id"`
+	URL          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	MinTotalRisk int       `json:"min_total_risk"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery represents one record of webhook delivery log
+type WebhookDelivery struct {
+	WebhookID   WebhookID   `json:"webhook_id"`
+	ClusterName ClusterName `json:"cluster"`
+	Payload     string      `json:"payload"`
+	Attempts    int         `json:"attempts"`
+	StatusCode  int         `json:"status_code"`
+	Error       string      `json:"error"`
+	Succeeded   bool        `json:"succeeded"`
+	DeliveredAt time.Time   `json:"delivered_at"`
+}
+
+// ContentVersion identifies the rule content loaded into the storage
+type ContentVersion struct {
+	Hash     string    `json:"hash"`
+	Commit   string    `json:"commit"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
 // KafkaOffset type for kafka offset
 type KafkaOffset int64
 
@@ -0,0 +1,116 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// forbiddenNetworks are the networks the webhooks can't be delivered to, so that
+// customers can't make the service send requests into the internal network
+var forbiddenNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // shared address space
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata services
+	"172.16.0.0/12",  // private
+	"192.168.0.0/16", // private
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// ForbiddenAddressError is returned when the webhook points to an address in the internal network
+type ForbiddenAddressError struct {
+	Address string
+}
+
+func (e *ForbiddenAddressError) Error() string {
+	return fmt.Sprintf("address %v is not allowed for webhooks", e.Address)
+}
+
+// IsForbiddenIP checks whether the IP address is loopback, link-local, private or unspecified
+func IsForbiddenIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return true
+	}
+
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckHost checks the host of the webhook URL when the webhook is registered. Addresses of the host
+// names are resolved and all of them have to be allowed. Host names that can't be resolved are accepted,
+// the address is checked again on every delivery anyway, because DNS can change after the registration.
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if IsForbiddenIP(ip) {
+			return &ForbiddenAddressError{Address: host}
+		}
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	for _, address := range addresses {
+		if IsForbiddenIP(address.IP) {
+			return &ForbiddenAddressError{Address: host}
+		}
+	}
+
+	return nil
+}
+
+// checkDialedAddress is the control function of the dialer refusing connections
+// to the forbidden addresses, it's called with the already resolved address
+func checkDialedAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || IsForbiddenIP(ip) {
+		return &ForbiddenAddressError{Address: host}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import "time"
+
+const (
+	// DefaultMaxAttempts is used when the number of attempts is not configured
+	DefaultMaxAttempts = 5
+	// DefaultInitialBackoff is used when the initial backoff is not configured
+	DefaultInitialBackoff = time.Second
+	// DefaultTimeout is used when the timeout is not configured
+	DefaultTimeout = 10 * time.Second
+	// DefaultSubscriptionsTTL is used when the time to refresh the subscribed organizations is not configured
+	DefaultSubscriptionsTTL = 30 * time.Second
+)
+
+// Configuration represents configuration of webhook delivery. InitialBackoff
+// is the delay before the second attempt, every next delay is doubled.
+// AllowPrivateAddresses allows webhooks in the internal network, it's meant
+// for local development only. SubscriptionsTTL is the time for which the
+// organizations having any webhooks are cached by the dispatcher.
+type Configuration struct {
+	Enabled               bool          `mapstructure:"enabled" toml:"enabled"`
+	MaxAttempts           int           `mapstructure:"max_attempts" toml:"max_attempts"`
+	InitialBackoff        time.Duration `mapstructure:"initial_backoff" toml:"initial_backoff"`
+	Timeout               time.Duration `mapstructure:"timeout" toml:"timeout"`
+	AllowPrivateAddresses bool          `mapstructure:"allow_private_addresses" toml:"allow_private_addresses"`
+	SubscriptionsTTL      time.Duration `mapstructure:"subscriptions_ttl" toml:"subscriptions_ttl"`
+}
@@ -0,0 +1,395 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks contains implementation of outbound webhooks. Organizations
+// can subscribe webhooks which are called when any of their clusters starts
+// hitting a rule with total risk greater than or equal to the threshold set in
+// the subscription.
+//
+// The payload is signed by HMAC-SHA256 using the secret of the subscription,
+// the signature is sent in the X-Insights-Signature header in the form
+// "sha256=<hex encoded signature>". Failed deliveries are retried with
+// exponential backoff and every delivery is recorded in the delivery log.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// SignatureHeader is the header containing HMAC signature of the payload
+	SignatureHeader = "X-Insights-Signature"
+	// WebhookIDHeader is the header containing ID of the webhook subscription
+	WebhookIDHeader = "X-Insights-Webhook-Id"
+	// EventRulesHit is the event sent when cluster starts hitting rules
+	EventRulesHit = "rules_hit"
+)
+
+// HitRule is a rule the cluster started to hit
+type HitRule struct {
+	RuleID      types.RuleID   `json:"rule_id"`
+	ErrorKey    types.ErrorKey `json:"error_key"`
+	Description string         `json:"description"`
+	TotalRisk   int            `json:"total_risk"`
+}
+
+// Payload is the body sent to webhooks
+type Payload struct {
+	Event       string            `json:"event"`
+	OrgID       types.OrgID       `json:"org_id"`
+	ClusterName types.ClusterName `json:"cluster"`
+	Rules       []HitRule         `json:"rules"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// Dispatcher evaluates stored reports and delivers payloads to subscribed webhooks.
+// Deliveries are done in background, all methods can be called on nil *Dispatcher.
+type Dispatcher struct {
+	Configuration Configuration
+	Storage       storage.Storage
+	client        *http.Client
+	pending       sync.WaitGroup
+
+	// subscribedOrgs are the organizations having any webhooks, they're read again after SubscriptionsTTL
+	subscriptionsMutex    sync.Mutex
+	subscribedOrgs        map[types.OrgID]bool
+	subscriptionsExpireAt time.Time
+}
+
+// New constructs new dispatcher, unset configuration values are replaced by defaults
+func New(configuration Configuration, storage storage.Storage) *Dispatcher {
+	if configuration.MaxAttempts <= 0 {
+		configuration.MaxAttempts = DefaultMaxAttempts
+	}
+	if configuration.InitialBackoff <= 0 {
+		configuration.InitialBackoff = DefaultInitialBackoff
+	}
+	if configuration.Timeout <= 0 {
+		configuration.Timeout = DefaultTimeout
+	}
+	if configuration.SubscriptionsTTL <= 0 {
+		configuration.SubscriptionsTTL = DefaultSubscriptionsTTL
+	}
+
+	return &Dispatcher{
+		Configuration: configuration,
+		Storage:       storage,
+		client:        newClient(configuration),
+	}
+}
+
+// newClient creates HTTP client refusing connections to the internal network unless they're allowed.
+// The addresses are checked when the connection is made, after the host name is resolved, so the check
+// can't be bypassed by DNS changes after the webhook is registered. Proxies are not used for the same reason.
+func newClient(configuration Configuration) *http.Client {
+	if configuration.AllowPrivateAddresses {
+		return &http.Client{Timeout: configuration.Timeout}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: configuration.Timeout,
+		Control: checkDialedAddress,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: configuration.Timeout, Transport: transport}
+}
+
+// Sign returns signature of the payload in the form sent in SignatureHeader
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// isSubscribed checks whether the organization has any webhooks. The subscribed organizations
+// are cached, so that reports of the other organizations don't cause any queries. Webhooks
+// created in the meantime are notified once the cache expires. The organization is considered
+// subscribed when the organizations can't be read, so that no notification is lost.
+func (dispatcher *Dispatcher) isSubscribed(orgID types.OrgID) bool {
+	dispatcher.subscriptionsMutex.Lock()
+	defer dispatcher.subscriptionsMutex.Unlock()
+
+	if dispatcher.subscribedOrgs == nil || time.Now().After(dispatcher.subscriptionsExpireAt) {
+		orgIDs, err := dispatcher.Storage.ListOrgsWithWebhooks()
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to get organizations with webhooks")
+			return true
+		}
+
+		dispatcher.subscribedOrgs = make(map[types.OrgID]bool, len(orgIDs))
+		for _, subscribedOrgID := range orgIDs {
+			dispatcher.subscribedOrgs[subscribedOrgID] = true
+		}
+		dispatcher.subscriptionsExpireAt = time.Now().Add(dispatcher.Configuration.SubscriptionsTTL)
+	}
+
+	return dispatcher.subscribedOrgs[orgID]
+}
+
+// LastReport returns the report currently stored for the cluster, it should be called
+// before the new report is written and passed to ReportStored afterwards. Empty report
+// is returned when there's no report for the cluster yet or the organization has no
+// webhooks. The report is read from the primary database, a stale report from the
+// replica would send the alerts again.
+func (dispatcher *Dispatcher) LastReport(orgID types.OrgID, clusterName types.ClusterName) types.ClusterReport {
+	if dispatcher == nil || !dispatcher.isSubscribed(orgID) {
+		return ""
+	}
+
+	report, _, err := dispatcher.Storage.ReadReportForClusterFromPrimary(orgID, clusterName)
+	if err != nil {
+		if _, ok := err.(*types.ItemNotFoundError); !ok {
+			log.Error().Err(err).Msg("Unable to read previous report for webhooks")
+		}
+		return ""
+	}
+
+	return report
+}
+
+// ReportStored compares rules hit by the previous and the new report of the cluster
+// and notifies webhooks of the organization about newly hit rules with high enough
+// total risk. The previous report is empty if there was no report for the cluster.
+func (dispatcher *Dispatcher) ReportStored(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	previousReport types.ClusterReport,
+	report types.ClusterReport,
+) {
+	if dispatcher == nil || !dispatcher.isSubscribed(orgID) {
+		return
+	}
+
+	webhooks, err := dispatcher.Storage.ListWebhooksForOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get webhooks of organization")
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	newHitRules, err := getNewHitRules(previousReport, report)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to parse report for webhooks")
+		return
+	}
+
+	if len(newHitRules.HitRules) == 0 {
+		return
+	}
+
+	rulesContent, err := dispatcher.Storage.GetContentForRules(newHitRules, "", clusterName, storage.RuleContentFilter{})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get content of hit rules for webhooks")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		rules := filterRulesByRisk(rulesContent, webhook.MinTotalRisk)
+		if len(rules) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(Payload{
+			Event:       EventRulesHit,
+			OrgID:       orgID,
+			ClusterName: clusterName,
+			Rules:       rules,
+			Timestamp:   time.Now().UTC(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to marshal webhook payload")
+			continue
+		}
+
+		dispatcher.pending.Add(1)
+		go func(webhook types.Webhook) {
+			defer dispatcher.pending.Done()
+			dispatcher.deliver(webhook, clusterName, payload)
+		}(webhook)
+	}
+}
+
+// Wait blocks until all pending deliveries are finished
+func (dispatcher *Dispatcher) Wait() {
+	if dispatcher == nil {
+		return
+	}
+
+	dispatcher.pending.Wait()
+}
+
+// deliver sends the payload to the webhook, retrying with exponential
+// backoff, and records the result in the delivery log
+func (dispatcher *Dispatcher) deliver(webhook types.Webhook, clusterName types.ClusterName, payload []byte) {
+	delivery := types.WebhookDelivery{
+		WebhookID:   webhook.ID,
+		ClusterName: clusterName,
+		Payload:     string(payload),
+	}
+
+	backoff := dispatcher.Configuration.InitialBackoff
+
+	for delivery.Attempts < dispatcher.Configuration.MaxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		delivery.Attempts++
+
+		statusCode, err := dispatcher.send(webhook, payload)
+		delivery.StatusCode = statusCode
+
+		if err == nil {
+			delivery.Succeeded = true
+			delivery.Error = ""
+			break
+		}
+
+		delivery.Error = err.Error()
+		log.Warn().Err(err).
+			Int64("webhook_id", int64(webhook.ID)).
+			Int("attempt", delivery.Attempts).
+			Msg("Webhook delivery failed")
+
+		var forbiddenAddress *ForbiddenAddressError
+		if errors.As(err, &forbiddenAddress) || !shouldRetry(statusCode) {
+			break
+		}
+	}
+
+	delivery.DeliveredAt = time.Now()
+
+	if delivery.Succeeded {
+		metrics.WebhookDeliveries.WithLabelValues("succeeded").Inc()
+	} else {
+		metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+	}
+
+	if err := dispatcher.Storage.WriteWebhookDelivery(delivery); err != nil {
+		log.Error().Err(err).Msg("Unable to write webhook delivery log")
+	}
+}
+
+// send makes one attempt to deliver the payload, returning HTTP status code of the response if any
+func (dispatcher *Dispatcher) send(webhook types.Webhook, payload []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+	request.Header.Set(WebhookIDHeader, fmt.Sprint(webhook.ID))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook responded with status code %v", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// shouldRetry returns false for client errors that won't be fixed by retrying
+func shouldRetry(statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout {
+		return true
+	}
+
+	return statusCode < 400 || statusCode > 499
+}
+
+// getNewHitRules returns rules which are hit in the report, but weren't hit in the previous one
+func getNewHitRules(previousReport, report types.ClusterReport) (types.ReportRules, error) {
+	var previousRules, currentRules types.ReportRules
+
+	if len(previousReport) != 0 {
+		if err := json.Unmarshal([]byte(previousReport), &previousRules); err != nil {
+			return types.ReportRules{}, err
+		}
+	}
+
+	if err := json.Unmarshal([]byte(report), &currentRules); err != nil {
+		return types.ReportRules{}, err
+	}
+
+	previouslyHit := make(map[string]bool)
+	for _, rule := range previousRules.HitRules {
+		previouslyHit[hitRuleKey(rule)] = true
+	}
+
+	var newRules types.ReportRules
+	for _, rule := range currentRules.HitRules {
+		if !previouslyHit[hitRuleKey(rule)] {
+			newRules.HitRules = append(newRules.HitRules, rule)
+		}
+	}
+
+	return newRules, nil
+}
+
+func hitRuleKey(rule types.RuleOnReport) string {
+	return strings.TrimSuffix(rule.Module, ".report") + "|" + rule.ErrorKey
+}
+
+// filterRulesByRisk returns enabled rules with total risk at least minTotalRisk
+func filterRulesByRisk(rulesContent []types.RuleContentResponse, minTotalRisk int) []HitRule {
+	var rules []HitRule
+
+	for _, rule := range rulesContent {
+		if rule.Disabled || rule.TotalRisk < minTotalRisk {
+			continue
+		}
+
+		rules = append(rules, HitRule{
+			RuleID:      types.RuleID(rule.RuleModule),
+			ErrorKey:    types.ErrorKey(rule.ErrorKey),
+			Description: rule.Description,
+			TotalRisk:   rule.TotalRisk,
+		})
+	}
+
+	return rules
+}
@@ -0,0 +1,405 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/RedHatInsights/insights-results-aggregator/webhooks"
+)
+
+const testSecret = "top secret"
+
+// testConfig allows private addresses, so that the webhooks can be delivered to local stand-ins
+var testConfig = webhooks.Configuration{
+	Enabled:               true,
+	MaxAttempts:           3,
+	InitialBackoff:        time.Millisecond,
+	Timeout:               time.Second,
+	AllowPrivateAddresses: true,
+}
+
+func init() {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+}
+
+// receivedRequest is a request received by the webhook stand-in
+type receivedRequest struct {
+	body      []byte
+	signature string
+	webhookID string
+}
+
+// webhookStandIn is a local HTTP server recording requests and responding
+// with the status codes from the list, the last one is used repeatedly
+type webhookStandIn struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	requests    []receivedRequest
+	statusCodes []int
+}
+
+func newWebhookStandIn(statusCodes ...int) *webhookStandIn {
+	standIn := &webhookStandIn{statusCodes: statusCodes}
+
+	standIn.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+
+		standIn.mutex.Lock()
+		defer standIn.mutex.Unlock()
+
+		standIn.requests = append(standIn.requests, receivedRequest{
+			body:      body,
+			signature: request.Header.Get(webhooks.SignatureHeader),
+			webhookID: request.Header.Get(webhooks.WebhookIDHeader),
+		})
+
+		statusCode := standIn.statusCodes[len(standIn.statusCodes)-1]
+		if len(standIn.requests) <= len(standIn.statusCodes) {
+			statusCode = standIn.statusCodes[len(standIn.requests)-1]
+		}
+		writer.WriteHeader(statusCode)
+	}))
+
+	return standIn
+}
+
+func (standIn *webhookStandIn) receivedRequests() []receivedRequest {
+	standIn.mutex.Lock()
+	defer standIn.mutex.Unlock()
+
+	return standIn.requests
+}
+
+func mustPrepareStorage(t *testing.T, url string, minTotalRisk int) (storage.Storage, types.WebhookID, func()) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	webhookID, err := mockStorage.CreateWebhook(types.Webhook{
+		OrgID:        testdata.OrgID,
+		URL:          url,
+		Secret:       testSecret,
+		MinTotalRisk: minTotalRisk,
+	})
+	helpers.FailOnError(t, err)
+
+	return mockStorage, webhookID, closer
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(
+		t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		webhooks.Sign("key", []byte("The quick brown fox jumps over the lazy dog")),
+	)
+}
+
+func TestReportStoredDeliversNewHitRules(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusOK)
+	defer standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 4)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report2Rules)
+	dispatcher.Wait()
+
+	requests := standIn.receivedRequests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, webhooks.Sign(testSecret, requests[0].body), requests[0].signature)
+	assert.Equal(t, "1", requests[0].webhookID)
+
+	var payload webhooks.Payload
+	helpers.FailOnError(t, json.Unmarshal(requests[0].body, &payload))
+
+	assert.Equal(t, webhooks.EventRulesHit, payload.Event)
+	assert.Equal(t, testdata.OrgID, payload.OrgID)
+	assert.Equal(t, testdata.ClusterName, payload.ClusterName)
+	// only the second rule has high enough total risk
+	assert.Equal(t, []webhooks.HitRule{{
+		RuleID:      testdata.Rule2ID,
+		ErrorKey:    testdata.ErrorKey2,
+		Description: testdata.Rule2Description,
+		TotalRisk:   4,
+	}}, payload.Rules)
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Succeeded)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	assert.Equal(t, string(requests[0].body), deliveries[0].Payload)
+}
+
+func TestReportStoredOnlyNewlyHitRules(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusOK)
+	defer standIn.Close()
+
+	mockStorage, _, closer := mustPrepareStorage(t, standIn.URL, 3)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+
+	// the only new rule has total risk 2
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report3Rules)
+	// no new rules
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report2Rules)
+	// another organization
+	dispatcher.ReportStored(testdata.OrgID+1, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	assert.Empty(t, standIn.receivedRequests())
+}
+
+func TestReportStoredRetries(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusNoContent)
+	defer standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 1)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	assert.Len(t, standIn.receivedRequests(), 3)
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Succeeded)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+}
+
+func TestReportStoredGivesUp(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusBadGateway)
+	defer standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 1)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	assert.Len(t, standIn.receivedRequests(), testConfig.MaxAttempts)
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Succeeded)
+	assert.Equal(t, testConfig.MaxAttempts, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, deliveries[0].StatusCode)
+	assert.NotEmpty(t, deliveries[0].Error)
+}
+
+func TestReportStoredDoesNotRetryClientError(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusNotFound)
+	defer standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 1)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	assert.Len(t, standIn.receivedRequests(), 1)
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Succeeded)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestReportStoredUnreachableWebhook(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusOK)
+	// nothing is listening on the URL anymore
+	standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 1)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Succeeded)
+	assert.Equal(t, testConfig.MaxAttempts, deliveries[0].Attempts)
+	assert.Equal(t, 0, deliveries[0].StatusCode)
+}
+
+func TestReportStoredForbiddenAddress(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusOK)
+	defer standIn.Close()
+
+	mockStorage, webhookID, closer := mustPrepareStorage(t, standIn.URL, 1)
+	defer closer()
+
+	config := testConfig
+	config.AllowPrivateAddresses = false
+
+	dispatcher := webhooks.New(config, mockStorage)
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+
+	assert.Empty(t, standIn.receivedRequests())
+
+	// the connection is refused without any retries
+	deliveries, err := mockStorage.ListWebhookDeliveries(webhookID, 10)
+	helpers.FailOnError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Succeeded)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Contains(t, deliveries[0].Error, "is not allowed for webhooks")
+}
+
+func TestIsForbiddenIP(t *testing.T) {
+	for address, forbidden := range map[string]bool{
+		"127.0.0.1":       true,
+		"169.254.169.254": true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"::":              true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"::ffff:10.0.0.1": true,
+		"8.8.8.8":         false,
+		"172.32.0.1":      false,
+		"2001:4860::8888": false,
+	} {
+		assert.Equal(t, forbidden, webhooks.IsForbiddenIP(net.ParseIP(address)), address)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "::1", "localhost"} {
+		err := webhooks.CheckHost(context.Background(), host)
+		assert.IsType(t, &webhooks.ForbiddenAddressError{}, err, host)
+	}
+
+	helpers.FailOnError(t, webhooks.CheckHost(context.Background(), "8.8.8.8"))
+}
+
+func TestLastReport(t *testing.T) {
+	mockStorage, _, closer := mustPrepareStorage(t, "https://example.com", 1)
+	defer closer()
+
+	dispatcher := webhooks.New(testConfig, mockStorage)
+	assert.Equal(t, types.ClusterReport(""), dispatcher.LastReport(testdata.OrgID, testdata.ClusterName))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, testdata.Report2Rules, dispatcher.LastReport(testdata.OrgID, testdata.ClusterName))
+}
+
+// countingStorage counts the reads of the reports done for webhooks
+type countingStorage struct {
+	storage.Storage
+	reads int
+}
+
+func (backend *countingStorage) ReadReportForClusterFromPrimary(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ClusterReport, types.Timestamp, error) {
+	backend.reads++
+	return backend.Storage.ReadReportForClusterFromPrimary(orgID, clusterName)
+}
+
+func TestLastReportNotSubscribed(t *testing.T) {
+	mockStorage, _, closer := mustPrepareStorage(t, "https://example.com", 1)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID+1, testdata.ClusterName, testdata.Report2Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	backend := &countingStorage{Storage: mockStorage}
+	dispatcher := webhooks.New(testConfig, backend)
+
+	// the organization has no webhooks, so its report isn't read at all
+	assert.Equal(t, types.ClusterReport(""), dispatcher.LastReport(testdata.OrgID+1, testdata.ClusterName))
+	assert.Equal(t, 0, backend.reads)
+}
+
+func TestSubscribedOrganizationsCached(t *testing.T) {
+	standIn := newWebhookStandIn(http.StatusOK)
+	defer standIn.Close()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+	helpers.FailOnError(t, mockStorage.LoadRuleContent(testdata.RuleContent3Rules))
+
+	config := testConfig
+	config.SubscriptionsTTL = 50 * time.Millisecond
+	dispatcher := webhooks.New(config, mockStorage)
+
+	assert.Equal(t, types.ClusterReport(""), dispatcher.LastReport(testdata.OrgID, testdata.ClusterName))
+
+	_, err := mockStorage.CreateWebhook(types.Webhook{OrgID: testdata.OrgID, URL: standIn.URL, Secret: testSecret})
+	helpers.FailOnError(t, err)
+
+	// the webhook is not known until the subscribed organizations are read again
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report2Rules)
+	dispatcher.Wait()
+	assert.Empty(t, standIn.receivedRequests())
+
+	time.Sleep(100 * time.Millisecond)
+
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report2Rules)
+	dispatcher.Wait()
+	assert.Len(t, standIn.receivedRequests(), 1)
+}
+
+func TestNilDispatcher(t *testing.T) {
+	var dispatcher *webhooks.Dispatcher
+
+	assert.Equal(t, types.ClusterReport(""), dispatcher.LastReport(testdata.OrgID, testdata.ClusterName))
+	dispatcher.ReportStored(testdata.OrgID, testdata.ClusterName, "", testdata.Report3Rules)
+	dispatcher.Wait()
+}